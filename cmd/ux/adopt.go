@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runAdopt handles `ux adopt <target> [--yes]`: it finds packages under
+// target that no workspace member covers, asks which to adopt, writes a
+// minimal ux.toml for each, and adds members to the root config.
+func runAdopt(args []string) {
+	var target string
+	var yes bool
+	for _, arg := range args {
+		switch {
+		case arg == "--yes" || arg == "-y":
+			yes = true
+		case target == "" && ux.IsFilterArg(arg):
+			target = arg
+		default:
+			fmt.Fprintf(os.Stderr, "unknown argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+	if target == "" {
+		fmt.Fprintf(os.Stderr, "usage: ux adopt <//dir/...> [--yes]\n")
+		os.Exit(exitUsage)
+	}
+
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	filter, err := ux.ResolveFilter(root, cwd, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	rootCfg, err := ux.LoadRootConfig(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	found, err := ux.FindAdoptable(root, rootCfg, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	if len(found) == 0 {
+		fmt.Printf("nothing to adopt under %s: no uncovered packages found\n", filter)
+		return
+	}
+
+	fmt.Printf("\nFound %d package(s) under %s not covered by workspace members:\n\n", len(found), filter)
+	stdin := bufio.NewReader(os.Stdin)
+	var accepted []ux.AdoptCandidate
+	for _, c := range found {
+		desc := c.Type
+		if desc == "" {
+			desc = "has ux.toml"
+		}
+		if yes {
+			fmt.Printf("  %-40s %s\n", c.Label, desc)
+			accepted = append(accepted, c)
+			continue
+		}
+		fmt.Printf("  adopt %s (%s)? [Y/n] ", c.Label, desc)
+		answer, _ := stdin.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "" || answer == "y" || answer == "yes" {
+			accepted = append(accepted, c)
+		}
+	}
+	if len(accepted) == 0 {
+		fmt.Println("\nnothing adopted")
+		return
+	}
+
+	fmt.Println()
+	for _, c := range accepted {
+		written, err := c.WriteConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		if written {
+			fmt.Printf("  wrote %s/ux.toml\n", strings.TrimPrefix(c.Label, "//"))
+		}
+	}
+	members := ux.AdoptMembers(filter, found, accepted)
+	if err := ux.AddMembers(root, members); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	fmt.Printf("  added to members: %s\n\nYou can now run: ux list\n", strings.Join(members, ", "))
+}
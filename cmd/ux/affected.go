@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runAffected handles `ux affected [targets...] [--explain]`.
+func runAffected(args []string) {
+	var filters []string
+	var explain bool
+	for _, arg := range args {
+		switch {
+		case arg == "--explain":
+			explain = true
+		case ux.IsFilterArg(arg):
+			filters = append(filters, arg)
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(os.Stderr, "unknown flag: %s\n", arg)
+			os.Exit(exitUsage)
+		default:
+			fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, rootCfg, packages := loadWorkspace()
+	packages = selectTargets(root, packages, filters)
+	changedFiles, err := ux.ChangedFiles(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing changed files: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	affected, unmatched := ux.ExplainAffected(root, rootCfg.Affected, packages, changedFiles)
+	if explain {
+		ux.PrintAffectedExplanation(len(changedFiles), affected, unmatched)
+		return
+	}
+	for _, a := range affected {
+		fmt.Println(a.Package.Label)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+const agentUsage = "usage: ux agent [--listen host:port] [--checkout dir]\n"
+
+// defaultAgentAddr is where `ux agent` listens without --listen: loopback
+// only, for use through an SSH tunnel.
+const defaultAgentAddr = "127.0.0.1:7070"
+
+// runAgent handles `ux agent`: it serves `ux <task> --remote` clients,
+// running their packages' commands here and streaming the output back,
+// until interrupted.
+func runAgent(args []string) {
+	addr := defaultAgentAddr
+	var checkout string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--listen"):
+			addr = flagValue(args, &i, "--listen")
+		case isFlag(arg, "--checkout"):
+			checkout = flagValue(args, &i, "--checkout")
+		default:
+			fmt.Fprint(os.Stderr, agentUsage)
+			os.Exit(exitUsage)
+		}
+	}
+
+	agent := &ux.Agent{Token: os.Getenv(ux.AgentTokenEnv)}
+	if checkout != "" {
+		abs, err := filepath.Abs(checkout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		if _, err := os.Stat(filepath.Join(abs, "ux.toml")); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s is not a workspace checkout (no ux.toml)\n", checkout)
+			os.Exit(exitUsage)
+		}
+		agent.Root = abs
+	}
+
+	// Even on loopback, every local user and any browser page that rebinds
+	// a hostname to 127.0.0.1 could reach the agent, so a token is required.
+	if agent.Token == "" {
+		fmt.Fprintf(os.Stderr, "error: anyone who can reach %s could run commands here; set %s on the agent and its clients\n", addr, ux.AgentTokenEnv)
+		os.Exit(exitUsage)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	mode := "receiving shipped packages"
+	if agent.Root != "" {
+		mode = "running in " + agent.Root
+	}
+	fmt.Printf("ux agent listening on %s, %s\n", ln.Addr(), mode)
+
+	srv := &http.Server{Handler: agent.Handler()}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	err = srv.Serve(ln)
+	agent.Close()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
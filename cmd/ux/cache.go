@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+const cacheUsage = "usage: ux cache status | clean [--task <name>] [--older-than <age>] | verify [--fix]\n"
+
+// runCache handles `ux cache <status|clean|verify>`.
+func runCache(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cacheUsage)
+		os.Exit(exitUsage)
+	}
+	var task, olderThan string
+	var fix bool
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case args[0] == "clean" && isFlag(arg, "--task"):
+			task = flagValue(args, &i, "--task")
+		case args[0] == "clean" && isFlag(arg, "--older-than"):
+			olderThan = flagValue(args, &i, "--older-than")
+		case args[0] == "verify" && arg == "--fix":
+			fix = true
+		default:
+			fmt.Fprint(os.Stderr, cacheUsage)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cacheDir := ux.CacheDir(root)
+	entries, err := ux.ListCache(cacheDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading cache: %v\n", err)
+		os.Exit(exitFailure)
+	}
+
+	switch args[0] {
+	case "status":
+		history, err := ux.LoadHistory(root)
+		if err != nil {
+			ux.Warnf("%v", err)
+		}
+		ux.PrintCacheStatus(cacheDir, ux.CacheStatus(entries, history))
+	case "clean":
+		var age time.Duration
+		if olderThan != "" {
+			if age, err = ux.ParseAge(olderThan); err != nil {
+				fmt.Fprintf(os.Stderr, "error: --older-than: %v\n", err)
+				os.Exit(exitUsage)
+			}
+		}
+		removed, freed, err := ux.CleanCache(entries, task, age)
+		fmt.Printf("removed %d cache entries (%s)\n", removed, ux.FormatBytes(freed))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+	case "verify":
+		problems := ux.VerifyCache(entries)
+		for _, p := range problems {
+			fmt.Printf("%s/%s/%s: %s\n", p.Entry.Task, p.Entry.Label, p.Entry.Key, p.Reason)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%d cache entries ok\n", len(entries))
+			return
+		}
+		if !fix {
+			fmt.Printf("%d of %d cache entries corrupted; run `ux cache verify --fix` to remove them\n", len(problems), len(entries))
+			os.Exit(exitFailure)
+		}
+		var corrupted []ux.CachedEntry
+		for _, p := range problems {
+			corrupted = append(corrupted, p.Entry)
+		}
+		removed, _, err := ux.CleanCache(corrupted, "", 0)
+		fmt.Printf("removed %d corrupted cache entries\n", removed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+	default:
+		fmt.Fprint(os.Stderr, cacheUsage)
+		os.Exit(exitUsage)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// cleanTask is the task `ux clean --tasks` runs in every package defining it.
+const cleanTask = "clean"
+
+// runClean handles `ux clean [--tasks] [--dry-run]`: it removes the state ux
+// keeps for a workspace and, with --tasks, then runs each package's clean
+// task. With --dry-run it only lists what would be removed and run.
+func runClean(args []string) {
+	var tasks, dryRun bool
+	for _, arg := range args {
+		switch arg {
+		case "--tasks":
+			tasks = true
+		case "--dry-run":
+			dryRun = true
+		default:
+			fmt.Fprintf(os.Stderr, "usage: ux clean [--tasks] [--dry-run]\n")
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	rootCfg, err := ux.LoadRootConfig(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	targets, err := ux.CleanTargets(root, rootCfg.Logs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+
+	// Packages are discovered before .ux goes, so the discovery cache is
+	// still there to speed it up
+	var packages []ux.Package
+	if tasks {
+		_, _, all := loadWorkspace()
+		for _, pkg := range all {
+			if _, ok := pkg.Tasks[cleanTask]; ok {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	if dryRun {
+		for _, t := range targets {
+			fmt.Printf("would remove %s (%s)\n", t.Path, ux.FormatBytes(t.Size))
+		}
+		for _, pkg := range packages {
+			fmt.Printf("would run %s in %s\n", cleanTask, pkg.Label)
+		}
+		if len(targets) == 0 && len(packages) == 0 {
+			fmt.Println("nothing to clean")
+		}
+		return
+	}
+
+	freed, err := ux.RemoveTargets(targets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	fmt.Printf("removed %d path(s) (%s)\n", len(targets), ux.FormatBytes(freed))
+
+	if !tasks {
+		return
+	}
+	if len(packages) == 0 {
+		ux.Warnf("no packages define task %q", cleanTask)
+		return
+	}
+	// Not recorded in history or last-run, which were just removed
+	logDir := rootCfg.Logs.RunDir(root, runID)
+	results := ux.RunTask(cleanTask, packages, rootCfg.Tasks[cleanTask], ux.RunOptions{LogDir: logDir})
+	ux.PrintSummary(cleanTask, results, ux.SummaryOptions{LogDir: logDir})
+	for _, r := range results {
+		if r.Failed() {
+			os.Exit(exitFailure)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runConfig handles `ux config <command>`. It's a subcommand of its own so
+// that `ux fmt` stays free for packages' fmt tasks.
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "fmt" {
+		fmt.Fprintf(os.Stderr, "usage: ux config fmt [--check]\n")
+		os.Exit(exitUsage)
+	}
+	runConfigFmt(args[1:])
+}
+
+// runConfigFmt handles `ux config fmt [--check]`: it rewrites every workspace
+// config file in canonical form, or with --check lists the files that aren't
+// and exits 1.
+func runConfigFmt(args []string) {
+	var check bool
+	for _, arg := range args {
+		switch arg {
+		case "--check":
+			check = true
+		default:
+			fmt.Fprintf(os.Stderr, "usage: ux config fmt [--check]\n")
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, _, packages := loadWorkspace()
+	var unformatted, failed int
+	for _, rel := range ux.ConfigFiles(root, packages) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			failed++
+			continue
+		}
+		out, err := ux.FormatConfig(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", rel, err)
+			failed++
+			continue
+		}
+		if bytes.Equal(out, src) {
+			continue
+		}
+		unformatted++
+		if check {
+			fmt.Println(rel)
+			continue
+		}
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Printf("formatted %s\n", rel)
+	}
+	if failed > 0 || check && unformatted > 0 {
+		if check && unformatted > 0 {
+			fmt.Fprintf(os.Stderr, "%d config file(s) need formatting; run: ux config fmt\n", unformatted)
+		}
+		os.Exit(exitFailure)
+	}
+}
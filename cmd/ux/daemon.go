@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+const daemonUsage = "usage: ux daemon [--stop]\n"
+
+// runDaemon handles `ux daemon`: it keeps the workspace's discovery, file
+// hashes, and git state warm for other ux runs until interrupted, or stops
+// the running daemon with --stop.
+func runDaemon(args []string) {
+	var stop bool
+	for _, arg := range args {
+		switch arg {
+		case "--stop":
+			stop = true
+		default:
+			fmt.Fprint(os.Stderr, daemonUsage)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if stop {
+		if err := ux.StopDaemon(root); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		fmt.Println("ux daemon stopped")
+		return
+	}
+	rootCfg, err := ux.LoadRootConfig(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	daemon, err := ux.NewDaemon(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	ln, err := ux.ListenDaemon(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	if err := daemon.Warm(rootCfg); err != nil {
+		ln.Close()
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	fmt.Printf("ux daemon serving %s on %s\n", root, ux.DaemonSocketPath(root))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	err = daemon.Serve(ln)
+	daemon.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
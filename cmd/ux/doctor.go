@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runDoctor handles `ux doctor`.
+func runDoctor(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "usage: ux doctor\n")
+		os.Exit(exitUsage)
+	}
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	checks := ux.Doctor(root)
+	ux.PrintChecks(checks)
+	if ux.ChecksFailed(checks) {
+		os.Exit(exitFailure)
+	}
+}
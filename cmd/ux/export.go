@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runExport handles `ux export <kind>`.
+func runExport(args []string) {
+	if len(args) == 0 || args[0] != "docs" {
+		fmt.Fprintf(os.Stderr, "usage: ux export docs [--check] [--out path]\n")
+		os.Exit(exitUsage)
+	}
+
+	var check bool
+	var out string
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--check":
+			check = true
+		case isFlag(arg, "--out"):
+			out = flagValue(args, &i, "--out")
+		default:
+			fmt.Fprintf(os.Stderr, "unknown argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, rootCfg, packages := loadWorkspace()
+	if out == "" {
+		out = rootCfg.Docs.Path
+	}
+	if out == "" {
+		out = ux.DefaultDocsPath
+	}
+	path := filepath.Join(root, out)
+	docs := ux.GenerateDocs(packages)
+
+	if check {
+		current, err := os.ReadFile(path)
+		if err != nil || string(current) != docs {
+			fmt.Fprintf(os.Stderr, "error: %s is out of date; run: ux export docs\n", out)
+			os.Exit(exitFailure)
+		}
+		fmt.Printf("%s is up to date\n", out)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	if err := os.WriteFile(path, []byte(docs), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	fmt.Printf("wrote %s (%d packages)\n", out, len(packages))
+}
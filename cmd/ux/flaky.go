@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// defaultFlakyCount is how many packages `ux flaky` lists without --limit.
+const defaultFlakyCount = 20
+
+const flakyUsage = "usage: ux flaky [task] [--limit N]\n"
+
+// runFlaky handles `ux flaky [task] [--limit N]`: the packages that have
+// failed recorded runs, flakiest first, marking those already quarantined.
+func runFlaky(args []string) {
+	var task string
+	limit := defaultFlakyCount
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--limit"):
+			n, err := strconv.Atoi(flagValue(args, &i, "--limit"))
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "error: --limit needs a positive count\n")
+				os.Exit(exitUsage)
+			}
+			limit = n
+		case task == "" && !strings.HasPrefix(arg, "-"):
+			task = arg
+		default:
+			fmt.Fprint(os.Stderr, flakyUsage)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, _, packages := loadWorkspace()
+	history, err := ux.LoadHistory(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+
+	stats := history.Flaky(task)
+	if len(stats) == 0 {
+		if task != "" {
+			fmt.Printf("no failed runs of %s recorded\n", task)
+		} else {
+			fmt.Println("no failed runs recorded")
+		}
+		return
+	}
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+	flakyTasks := make(map[string][]string)
+	for _, pkg := range packages {
+		flakyTasks[pkg.Label] = pkg.FlakyTasks
+	}
+	for i, s := range stats {
+		stats[i].Quarantined = slices.Contains(flakyTasks[s.Label], s.Task)
+	}
+	ux.PrintFlaky(stats)
+}
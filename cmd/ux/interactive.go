@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// checkInteractive exits unless exactly one package is selected, as
+// --interactive needs.
+func checkInteractive(selected []ux.Package) {
+	switch len(selected) {
+	case 1:
+		return
+	case 0:
+		fmt.Fprintf(os.Stderr, "error: --interactive needs exactly one package, but none is selected\n")
+		os.Exit(exitUsage)
+	}
+	labels := make([]string, len(selected))
+	for i, pkg := range selected {
+		labels[i] = pkg.Label
+	}
+	if len(labels) > 5 {
+		labels = append(labels[:5], fmt.Sprintf("and %d more", len(selected)-5))
+	}
+	fmt.Fprintf(os.Stderr, "error: --interactive needs exactly one package, but %d are selected: %s\n",
+		len(selected), strings.Join(labels, ", "))
+	os.Exit(exitUsage)
+}
+
+// runInteractive runs the stages depends_on puts before task, as a normal
+// run does with opts and --serial or --parallel, and then task on the one
+// selected package with the terminal handed to its commands
+// (--interactive), and exits with their status. Hooks are skipped, and
+// the cache and run history only serve the depends_on stages.
+func runInteractive(root string, rootCfg *ux.RootConfig, task string, stages []ux.Stage, opts ux.RunOptions, summaryOpts ux.SummaryOptions, serial, parallel bool, extraArgs []string) {
+	last := stages[len(stages)-1]
+	if len(stages) > 1 {
+		interrupt, stopInterrupt := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		opts.Context = interrupt
+		for _, stage := range stages[:len(stages)-1] {
+			results := ux.RunTask(stage.Task, stage.Packages, stageTaskConfig(rootCfg, stage.Task, serial, parallel), opts)
+			ux.PrintSummary(stage.Task, results, summaryOpts)
+			opts.History.Record(stage.Task, results)
+			if err := opts.History.Save(root); err != nil {
+				ux.Warnf("saving run history: %v", err)
+			}
+			if interrupt.Err() != nil {
+				os.Exit(130)
+			}
+			// Packages --max-failures left out didn't run either
+			failed := len(results) < len(stage.Packages)
+			for _, r := range results {
+				failed = failed || r.Failed()
+			}
+			if failed {
+				fmt.Fprintf(os.Stderr, "error: %s failed before %s could run interactively\n", stage.Task, task)
+				os.Exit(exitFailure)
+			}
+		}
+		stopInterrupt()
+	}
+	pkg := last.Packages[0]
+	os.Exit(ux.RunInteractive(task, pkg, ux.ArgsFor(pkg, extraArgs, opts.ArgsTypes)))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runList handles `ux list [targets...] [--task <name>] [--type <type>]
+// [--owner <owner>] [--tree] [--no-cache]`. With --task, only packages defining that
+// task are listed, each with just that task's command. --tree shows packages by
+// directory instead. --no-cache rescans the workspace
+// instead of trusting the discovery index or daemon.
+func runList(args []string) {
+	var filters, owners []string
+	var task, pkgType string
+	var noCache, tree bool
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--task"):
+			task = flagValue(args, &i, "--task")
+		case isFlag(arg, "--type"):
+			pkgType = flagValue(args, &i, "--type")
+		case isFlag(arg, "--owner"):
+			owners = append(owners, flagValue(args, &i, "--owner"))
+		case arg == "--no-cache":
+			noCache = true
+		case arg == "--tree":
+			tree = true
+		case ux.IsFilterArg(arg):
+			filters = append(filters, arg)
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(os.Stderr, "unknown flag: %s\n", arg)
+			os.Exit(exitUsage)
+		default:
+			fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, rootCfg, packages := discoverWorkspace(noCache)
+	packages = selectTargets(root, packages, filters)
+
+	var selected []ux.Package
+	for _, pkg := range packages {
+		if pkgType != "" && pkg.Type != pkgType {
+			continue
+		}
+		if len(owners) > 0 && !ux.OwnedBy(pkg, owners) {
+			continue
+		}
+		if _, ok := pkg.Tasks[task]; task != "" && !ok {
+			continue
+		}
+		selected = append(selected, pkg)
+	}
+
+	switch {
+	case tree:
+		ux.PrintPackageTree(selected)
+	case task != "":
+		ux.PrintTaskList(task, rootCfg.Tasks[task].Description, selected)
+	default:
+		ux.PrintPackageList(selected)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	ux "github.com/lairoai/ux/internal/ux"
 )
@@ -11,12 +16,21 @@ import (
 // version is set at build time via -ldflags "-X main.version=<ver>".
 var version = "dev"
 
+// runID identifies this invocation in logs and names its log directory.
+var runID = ux.NewRunID()
+
 func main() {
-	args := os.Args[1:]
+	args := setupLogging(os.Args[1:])
 
 	if len(args) == 0 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// Subcommands parse their own arguments
+	if run, ok := subcommands[args[0]]; ok {
+		run(args[1:])
+		return
 	}
 
 	// Split at first "--": everything after goes to extraArgs
@@ -31,10 +45,14 @@ func main() {
 
 	// Parse arguments
 	var task string
-	var filters []string
-	var affected, verbose bool
+	var filters, owners, argsTypes []string
+	var affected, verbose, quiet, noColor, rerunFailed, noCache, skipUnchanged, ui, pty, strict, rootOnly, pick, serial, parallel, yes, local, quarantine, interactive bool
+	var flakeGate float64
+	var profile, maxFailures, jobs int
+	var streamDir, tracePath, outputMode, sortOrder, remoteAddr, metricsPush, targetsFrom string
 
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch {
 		case arg == "--help" || arg == "-h":
 			printUsage()
@@ -46,11 +64,116 @@ func main() {
 			affected = true
 		case arg == "--verbose" || arg == "-v":
 			verbose = true
+		case arg == "--quiet" || arg == "-q":
+			quiet = true
+		case arg == "--no-color":
+			noColor = true
+		case arg == "--rerun-failed":
+			rerunFailed = true
+		case arg == "--no-cache":
+			noCache = true
+		case arg == "--skip-unchanged":
+			skipUnchanged = true
+		case arg == "--root":
+			rootOnly = true
+		case arg == "--pick":
+			pick = true
+		case arg == "--strict":
+			strict = true
+		case arg == "--yes" || arg == "-y":
+			yes = true
+		case arg == "--serial":
+			serial = true
+		case arg == "--parallel":
+			parallel = true
+		case arg == "--quarantine":
+			quarantine = true
+		case arg == "--interactive" || arg == "-i":
+			interactive = true
+		case isFlag(arg, "--args-for"):
+			argsTypes = append(argsTypes, flagValue(args, &i, "--args-for"))
+		case isFlag(arg, "--max-failures"):
+			n, err := strconv.Atoi(flagValue(args, &i, "--max-failures"))
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "error: --max-failures needs a positive count\n")
+				os.Exit(exitUsage)
+			}
+			maxFailures = n
+		case isJobsFlag(arg):
+			name, value := jobsValue(args, &i)
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "error: %s needs a positive count\n", name)
+				os.Exit(exitUsage)
+			}
+			jobs = n
+		case arg == "--ui":
+			ui = true
+		case arg == "--pty":
+			pty = true
+		case arg == "--profile":
+			profile = defaultProfileCount
+		case strings.HasPrefix(arg, "--profile="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--profile="))
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "error: --profile=N needs a positive count\n")
+				os.Exit(exitUsage)
+			}
+			profile = n
+		case isFlag(arg, "--output"):
+			outputMode = flagValue(args, &i, "--output")
+			if outputMode != ux.OutputGitHub {
+				fmt.Fprintf(os.Stderr, "error: unknown --output mode %q (supported: %s)\n", outputMode, ux.OutputGitHub)
+				os.Exit(exitUsage)
+			}
+		case isFlag(arg, "--sort"):
+			sortOrder = flagValue(args, &i, "--sort")
+			if !ux.ValidSort(sortOrder) {
+				fmt.Fprintf(os.Stderr, "error: unknown --sort order %q (use %s, %s, or %s)\n", sortOrder, ux.SortLabel, ux.SortDuration, ux.SortStatus)
+				os.Exit(exitUsage)
+			}
+		case isFlag(arg, "--trace"):
+			tracePath = flagValue(args, &i, "--trace")
+		case isFlag(arg, "--targets-from"):
+			targetsFrom = flagValue(args, &i, "--targets-from")
+		case isFlag(arg, "--owner"):
+			owners = append(owners, flagValue(args, &i, "--owner"))
+		case isFlag(arg, "--metrics-push"):
+			metricsPush = flagValue(args, &i, "--metrics-push")
+			if err := ux.CheckMetricsTarget(metricsPush); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(exitUsage)
+			}
+		case isFlag(arg, "--stream-dir"):
+			streamDir = flagValue(args, &i, "--stream-dir")
+		case isFlag(arg, "--remote"):
+			remoteAddr = flagValue(args, &i, "--remote")
+		case arg == "--local":
+			local = true
+		case isFlag(arg, "--flake-gate"):
+			v, err := strconv.ParseFloat(flagValue(args, &i, "--flake-gate"), 64)
+			if err != nil || v < 0 || v > 1 {
+				fmt.Fprintf(os.Stderr, "error: --flake-gate must be a rate between 0 and 1\n")
+				os.Exit(exitUsage)
+			}
+			flakeGate = v
+		case strings.HasPrefix(arg, "//") && strings.Contains(arg, ":"):
+			filter, t, ok := ux.SplitTarget(arg)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "error: invalid target %q (want //label:task)\n", arg)
+				os.Exit(exitUsage)
+			}
+			if task != "" && task != t {
+				fmt.Fprintf(os.Stderr, "error: %s names task %q, but the run is for %q; run one task at a time\n", arg, t, task)
+				os.Exit(exitUsage)
+			}
+			task = t
+			filters = append(filters, filter)
 		case task != "" && ux.IsFilterArg(arg):
 			filters = append(filters, arg)
 		case strings.HasPrefix(arg, "-"):
 			fmt.Fprintf(os.Stderr, "unknown flag: %s\n", arg)
-			os.Exit(1)
+			os.Exit(exitUsage)
 		default:
 			if task == "" {
 				task = arg
@@ -58,113 +181,131 @@ func main() {
 				filters = append(filters, arg)
 			} else {
 				fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", arg)
-				os.Exit(1)
+				os.Exit(exitUsage)
 			}
 		}
 	}
 
 	if task == "" {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
-
-	// Handle migrate before workspace discovery (ux.toml doesn't exist yet)
-	if task == "migrate" {
-		dir, err := os.Getwd()
+	if serial && parallel {
+		fmt.Fprintf(os.Stderr, "error: --serial and --parallel can't be used together\n")
+		os.Exit(exitUsage)
+	}
+	if noColor || ux.NoColorRequested() {
+		ux.DisableColor()
+	}
+	if jobs == 0 {
+		n, err := ux.EnvJobs()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
-		if err := ux.RunMigrate(dir); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
-		os.Exit(0)
+		jobs = n
 	}
-
-	// Find workspace root
-	root, err := ux.FindWorkspaceRoot()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	if quiet && ui {
+		fmt.Fprintf(os.Stderr, "error: --quiet and --ui can't be combined\n")
+		os.Exit(exitUsage)
 	}
-
-	// Resolve relative filters to absolute //labels
-	var originalFilters []string
-	if len(filters) > 0 {
-		cwd, err := os.Getwd()
+	if interactive && (ui || remoteAddr != "") {
+		fmt.Fprintf(os.Stderr, "error: --interactive runs on this terminal; it can't be combined with --ui or --remote\n")
+		os.Exit(exitUsage)
+	}
+	if targetsFrom != "" {
+		targets, err := readTargetsFrom(targetsFrom)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
-		originalFilters = make([]string, len(filters))
-		copy(originalFilters, filters)
-		for i, f := range filters {
-			resolved, err := ux.ResolveFilter(root, cwd, f)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-				os.Exit(1)
-			}
-			filters[i] = resolved
+			os.Exit(exitUsage)
 		}
+		filters = append(filters, targets...)
+	}
+	if rootOnly && len(filters) > 0 {
+		fmt.Fprintf(os.Stderr, "error: --root can't be combined with targets\n")
+		os.Exit(exitUsage)
+	}
+	if rootOnly {
+		filters = []string{ux.RootLabel}
 	}
 
-	// Load root config
-	rootCfg, err := ux.LoadRootConfig(root)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	root, rootCfg, packages := loadWorkspace()
+	strict = strict || rootCfg.Behavior.StrictEmptySelection()
+
+	allPackages := packages
+	if err := ux.RunPackageHooks(root, rootCfg.Hooks, allPackages); err != nil {
+		ux.Warnf("package hooks: %v", err)
 	}
 
-	// Discover all packages
-	packages, err := ux.DiscoverPackages(root, rootCfg)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	// Why nothing runs, if that's how it turns out
+	empty := ux.EmptySelection{Task: task, All: allPackages, Tasks: rootCfg.Tasks}
+	noPackages := func(narrowed string) {
+		empty.Narrowed = narrowed
+		ux.PrintEmptySelection(os.Stderr, empty)
+		exitNoPackages(task, strict)
 	}
 
-	// Handle built-in commands
-	if task == "list" {
-		ux.PrintPackageList(packages)
-		os.Exit(0)
+	// An empty list selects nothing, rather than everything
+	if targetsFrom != "" && len(filters) == 0 {
+		noPackages("--targets-from")
 	}
 
 	// Apply filters
 	if len(filters) > 0 {
-		// Evaluate each filter once; warn about any that match nothing and
-		// track whether every filter came up empty.
-		var anyFilterMatchedNothing bool
-		seen := make(map[string]bool)
-		var filtered []ux.Package
-		for i, f := range filters {
-			matched := ux.FilterByLabel(packages, f)
-			if len(matched) == 0 {
-				anyFilterMatchedNothing = true
-				if suggestion := ux.SuggestFilterExpansion(packages, f); suggestion != "" {
-					ux.Warnf("filter %q matched no packages; did you mean %q?", originalFilters[i], suggestion)
-				} else {
-					ux.Warnf("filter %q matched no packages", originalFilters[i])
-				}
-			} else {
-				for _, pkg := range matched {
-					if !seen[pkg.Label] {
-						seen[pkg.Label] = true
-						filtered = append(filtered, pkg)
-					}
-				}
-			}
+		packages, empty.Misses = filterPackages(root, packages, filters)
+		if len(packages) == 0 {
+			noPackages("")
 		}
-		packages = filtered
-		// If every filter matched nothing, the warnings above are sufficient — exit cleanly.
-		if anyFilterMatchedNothing && len(packages) == 0 {
-			os.Exit(0)
+	}
+	if len(owners) > 0 {
+		packages = ux.FilterByOwner(packages, owners)
+		ux.Logger().Debug("--owner", "owners", strings.Join(owners, ","), "packages", len(packages))
+		if len(packages) == 0 {
+			noPackages("--owner")
 		}
 	}
 	if affected {
-		packages, err = ux.FilterAffected(root, packages)
+		var err error
+		packages, err = ux.FilterAffected(root, rootCfg.Affected, packages)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error filtering affected packages: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitUsage)
+		}
+		ux.Logger().Debug("--affected", "packages", len(packages))
+		if len(packages) == 0 {
+			noPackages("--affected")
+		}
+	}
+
+	if rerunFailed {
+		last, err := ux.LoadLastRun(root, task)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading last run of %q: %v\n", task, err)
+			os.Exit(exitUsage)
+		}
+		if last == nil {
+			fmt.Fprintf(os.Stderr, "error: no previous run of %q to rerun\n", task)
+			os.Exit(exitUsage)
+		}
+		failed := last.FailedLabels()
+		if len(failed) == 0 {
+			fmt.Printf("no failed packages in the last %q run\n", task)
+			writeResultFile(task, nil, nil)
+			os.Exit(0)
+		}
+		packages = ux.FilterByLabels(packages, failed)
+		ux.Logger().Debug("--rerun-failed", "failed_last_run", len(failed), "packages", len(packages))
+		if len(packages) == 0 {
+			noPackages("--rerun-failed")
+		}
+	}
+
+	// Orchestration layers can narrow any selection without touching the command line
+	if only, ok := ux.OnlyPackages(); ok {
+		packages = ux.IntersectLabels(packages, only)
+		ux.Logger().Debug(ux.OnlyPackagesEnv, "filters", strings.Join(only, ","), "packages", len(packages))
+		if len(packages) == 0 {
+			noPackages(ux.OnlyPackagesEnv)
 		}
 	}
 
@@ -177,36 +318,522 @@ func main() {
 	}
 
 	if len(relevant) == 0 {
-		ux.Warnf("no packages define task %q", task)
-		os.Exit(0)
+		empty.Selected = packages
+		noPackages("")
+	}
+
+	if pick && len(relevant) > 1 {
+		picked, err := ux.PickPackages(task, relevant)
+		if err == ux.ErrPickCancelled {
+			os.Exit(130)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		relevant = picked
 	}
 
-	// Validate extra args: reject multi-step tasks
+	// Validate extra args: a multi-step task must say where they go
+	if len(argsTypes) > 0 && len(extraArgs) == 0 {
+		fmt.Fprintf(os.Stderr, "error: --args-for needs extra args after --\n")
+		os.Exit(exitUsage)
+	}
+	if err := ux.CheckArgs(task, relevant, extraArgs, argsTypes); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
 	if len(extraArgs) > 0 {
+		getsArgs := 0
 		for _, pkg := range relevant {
-			if cmds := pkg.Tasks[task]; len(cmds) > 1 {
-				fmt.Fprintf(os.Stderr, "error: cannot pass extra args (--) to multi-step task %q in %s (%d steps)\n",
-					task, pkg.Label, len(cmds))
-				os.Exit(1)
+			if ux.ArgsFor(pkg, extraArgs, argsTypes) != nil {
+				getsArgs++
+			}
+		}
+		if getsArgs == 0 && len(relevant) > 0 {
+			ux.Warnf("no selected package is of type %s, so none gets the extra args", strings.Join(argsTypes, " or "))
+		}
+	}
+
+	if interactive {
+		checkInteractive(relevant)
+	}
+
+	// Expand depends_on into ordered stages
+	stages, err := ux.PlanTask(task, relevant, allPackages, rootCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	confirmStages(stages, rootCfg.Tasks, yes)
+
+	var planned []ux.Package
+	for _, stage := range stages {
+		planned = append(planned, stage.Packages...)
+		ux.Logger().Debug("stage", "task", stage.Task, "packages", len(stage.Packages))
+	}
+	env := ux.CaptureEnvironment(root, planned)
+
+	history, err := ux.LoadHistory(root)
+	if err != nil {
+		ux.Warnf("ignoring run history: %v", err)
+	}
+
+	cacheDir := ux.CacheDir(root)
+	if noCache {
+		cacheDir = ""
+	}
+
+	var remote *ux.Remote
+	if remoteAddr != "" {
+		remote = ux.NewRemote(remoteAddr)
+		if err := remote.Check(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		// Outputs stay on the agent, so there's nothing to cache locally
+		cacheDir = ""
+	}
+
+	var state *ux.RunState
+	if skipUnchanged {
+		state, err = ux.LoadRunState(root)
+		if err != nil {
+			ux.Warnf("ignoring run state: %v", err)
+		}
+	}
+
+	logDir := rootCfg.Logs.RunDir(root, runID)
+	summaryOpts := ux.SummaryOptions{Verbose: verbose, Quiet: quiet, LogDir: logDir, Sort: sortOrder}
+	// What every stage runs with; each adds its extra args, executors, and
+	// context
+	runOpts := ux.RunOptions{
+		ArgsTypes:   argsTypes,
+		FlakeGate:   flakeGate,
+		History:     history,
+		Quarantine:  quarantine,
+		StreamDir:   streamDir,
+		CacheDir:    cacheDir,
+		Workspace:   allPackages,
+		LogDir:      logDir,
+		MaxFailures: maxFailures,
+		Jobs:        jobs,
+		Quiet:       quiet,
+		UI:          ui,
+		PTY:         pty,
+		Remote:      remote,
+	}
+	if interactive {
+		runInteractive(root, rootCfg, task, stages, runOpts, summaryOpts, serial, parallel, extraArgs)
+	}
+
+	if err := ux.RunHook(root, "before_run", rootCfg.Hooks.BeforeRun, "UX_TASK="+task); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+
+	// With --ui, summaries and warnings are held until the alternate screen
+	// is closed so they end up in the normal scrollback
+	var report []func()
+	show := func(f func()) {
+		if ui {
+			report = append(report, f)
+		} else {
+			f()
+		}
+	}
+	// Ctrl-C (or SIGTERM) stops the run, which then ends as usual: with a
+	// summary, after_run hooks, and history saved
+	interrupt, stopInterrupt := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopInterrupt()
+	stopUI := func() {}
+	if ui {
+		stopUI = ux.StartUI()
+	}
+
+	var failed bool
+	var allResults []ux.Result
+	runStart := time.Now()
+	for i, stage := range stages {
+		// Extra args are meant for the requested task, not its dependencies
+		var stageArgs []string
+		if stage.Task == task {
+			stageArgs = extraArgs
+		}
+
+		taskCfg := stageTaskConfig(rootCfg, stage.Task, serial, parallel)
+
+		if state != nil {
+			var unchanged []ux.Package
+			stage.Packages, unchanged = state.Unchanged(root, stage.Task, stage.Packages, allPackages, stageArgs, argsTypes)
+			if len(unchanged) > 0 {
+				show(func() { ux.PrintUnchanged(stage.Task, unchanged) })
+			}
+			if len(stage.Packages) == 0 {
+				continue
+			}
+		}
+
+		// Spread a parallel stage across [executors] hosts
+		var executors *ux.Executors
+		if taskCfg.Parallel && len(rootCfg.Executors.Hosts) > 0 && !local && remote == nil {
+			executors = ux.PlanExecutors(rootCfg.Executors, root, stage.Task, stage.Packages, history)
+			if !quiet && !ui {
+				fmt.Fprintf(os.Stderr, "%s on %s\n", stage.Task, executors.Describe())
+			}
+		}
+
+		// Run
+		opts := runOpts
+		opts.ExtraArgs, opts.Executors, opts.Context = stageArgs, executors, interrupt
+		results := ux.RunTask(stage.Task, stage.Packages, taskCfg, opts)
+
+		// Print summary
+		if outputMode == ux.OutputGitHub {
+			show(func() { ux.PrintGitHubGroups(os.Stdout, stage.Task, results) })
+			if err := ux.AppendGitHubStepSummary(stage.Task, results); err != nil {
+				ux.Warnf("writing %s: %v", ux.GitHubStepSummaryEnv, err)
+			}
+		}
+		show(func() { ux.PrintSummary(stage.Task, results, summaryOpts) })
+
+		if skipped := len(stage.Packages) - len(results); skipped > 0 && interrupt.Err() != nil {
+			show(func() { ux.Warnf("%s: interrupted; %d package(s) not run", stage.Task, skipped) })
+		} else if skipped > 0 {
+			show(func() {
+				ux.Warnf("%s: stopped after %d failure(s) (--max-failures); %d package(s) not run", stage.Task, maxFailures, skipped)
+			})
+		}
+
+		allResults = append(allResults, results...)
+		history.Record(stage.Task, results)
+		if state != nil {
+			state.Record(root, stage.Task, results, allPackages, stageArgs, argsTypes)
+		}
+		summary := ux.NewRunSummary(stage.Task, results)
+		summary.Environment = env
+		if err := ux.SaveLastRun(root, summary); err != nil {
+			ux.Warnf("saving last run: %v", err)
+		}
+
+		for _, r := range results {
+			if r.Failed() {
+				failed = true
+			}
+		}
+		if interrupt.Err() != nil {
+			if i < len(stages)-1 {
+				show(func() { ux.Warnf("interrupted; skipping %d remaining stage(s)", len(stages)-1-i) })
 			}
+			break
+		}
+		if failed && i < len(stages)-1 {
+			skipped := len(stages) - 1 - i
+			show(func() { ux.Warnf("%s failed; skipping %d remaining stage(s)", stage.Task, skipped) })
+			break
+		}
+	}
+
+	if profile > 0 && len(allResults) > 0 {
+		show(func() { ux.PrintProfile(allResults, profile) })
+	}
+	if !quiet {
+		show(func() { ux.PrintEnvironment(env) })
+	}
+	stopUI()
+	for _, f := range report {
+		f()
+	}
+
+	if err := history.Save(root); err != nil {
+		ux.Warnf("saving run history: %v", err)
+	}
+	if state != nil {
+		if err := state.Save(root); err != nil {
+			ux.Warnf("saving run state: %v", err)
+		}
+	}
+	if err := ux.PruneLogs(root, rootCfg.Logs); err != nil {
+		ux.Warnf("pruning old logs: %v", err)
+	}
+
+	writeResultFile(task, allResults, env)
+
+	if tracePath != "" {
+		if err := ux.WriteChromeTrace(tracePath, allResults); err != nil {
+			ux.Warnf("writing trace: %v", err)
+		}
+	}
+	if metricsPush != "" && len(allResults) > 0 {
+		if err := ux.PushMetrics(metricsPush, task, runStart, time.Now(), allResults); err != nil {
+			ux.Warnf("pushing metrics: %v", err)
+		}
+	}
+	if endpoint := os.Getenv(ux.OtelEndpointEnv); endpoint != "" {
+		if err := ux.ExportOTLP(endpoint, task, runStart, time.Now(), allResults); err != nil {
+			ux.Warnf("exporting spans: %v", err)
 		}
 	}
 
-	// Resolve task config (default to serial if not configured)
+	// Teardown runs even when packages failed
+	if err := ux.RunHook(root, "after_run", rootCfg.Hooks.AfterRun, "UX_TASK="+task); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		failed = true
+	}
+
+	// Exit 130 if interrupted, as a shell would, or 1 if any failures
+	if interrupt.Err() != nil {
+		os.Exit(130)
+	}
+	if failed {
+		os.Exit(exitFailure)
+	}
+}
+
+// stageTaskConfig resolves the [tasks] config a stage runs with (serial if
+// not configured), with --serial or --parallel applied.
+func stageTaskConfig(rootCfg *ux.RootConfig, task string, serial, parallel bool) ux.TaskConfig {
 	taskCfg := rootCfg.Tasks[task]
+	if serial || parallel {
+		taskCfg.Parallel = parallel
+	}
+	return taskCfg
+}
+
+// setupLogging enables logging at the level --log-level (anywhere before
+// "--", for any command) or $UX_LOG_LEVEL sets, and returns args without the flag.
+func setupLogging(args []string) []string {
+	level := os.Getenv(ux.LogLevelEnv)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if isFlag(args[i], "--log-level") {
+			level = flagValue(args, &i, "--log-level")
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	if level != "" {
+		if err := ux.EnableLogging(level, runID); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+	return rest
+}
+
+// defaultProfileCount is how many packages --profile lists.
+const defaultProfileCount = 10
+
+// Exit codes, so wrappers can tell failing packages from misuse.
+const (
+	exitFailure = 1 // a package (or subcommand) failed
+	exitUsage   = 2 // bad arguments or configuration, or the run couldn't be set up
+	exitNoMatch = 3 // --strict (or [behavior]) and no packages were selected
+)
 
-	// Run
-	results := ux.RunTask(task, relevant, taskCfg, extraArgs)
+// subcommands are built-in commands that take their own arguments.
+var subcommands = map[string]func(args []string){
+	"adopt":    runAdopt,
+	"affected": runAffected,
+	"agent":    runAgent,
+	"cache":    runCache,
+	"clean":    runClean,
+	"config":   runConfig,
+	"daemon":   runDaemon,
+	"doctor":   runDoctor,
+	"export":   runExport,
+	"flaky":    runFlaky,
+	"list":     runList,
+	"migrate":  runMigrate,
+	"outdated": runOutdated,
+	"release":  runRelease,
+	"stats":    runStats,
+	"tail":     runTail,
+	"why":      runWhy,
+}
+
+// loadWorkspace finds the workspace root, loads its config, and discovers
+// packages, exiting on any error.
+func loadWorkspace() (string, *ux.RootConfig, []ux.Package) {
+	return discoverWorkspace(false)
+}
+
+// discoverWorkspace is loadWorkspace, ignoring the discovery index and a
+// running daemon when rescan is set.
+func discoverWorkspace(rescan bool) (string, *ux.RootConfig, []ux.Package) {
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	rootCfg, err := ux.LoadRootConfig(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	rootCfg.Workspace.Rescan = rescan
+	packages, err := ux.DiscoverPackages(root, rootCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	return root, rootCfg, packages
+}
 
-	// Print summary
-	ux.PrintSummary(task, results, verbose)
+// filterPackages resolves target filters (relative or //labels) against the
+// current directory and returns the packages matching any of them, along
+// with the filters that matched nothing. Those are warned about when other
+// filters still selected packages; otherwise the caller explains the empty
+// selection (see ux.PrintEmptySelection). Exits if a filter can't be
+// resolved.
+func filterPackages(root string, packages []ux.Package, filters []string) ([]ux.Package, []ux.FilterMiss) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	var misses []ux.FilterMiss
+	seen := make(map[string]bool)
+	var filtered []ux.Package
+	for _, raw := range filters {
+		f, err := ux.ResolveFilter(root, cwd, raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		matched := ux.FilterByLabel(packages, f)
+		ux.Logger().Debug("filter", "arg", raw, "resolved", f, "matched", len(matched))
+		if len(matched) == 0 {
+			misses = append(misses, ux.NewFilterMiss(packages, raw, f))
+			continue
+		}
+		for _, pkg := range matched {
+			if !seen[pkg.Label] {
+				seen[pkg.Label] = true
+				filtered = append(filtered, pkg)
+			}
+		}
+	}
+	if len(filtered) > 0 {
+		for _, m := range misses {
+			ux.Warnf("%s", m.Message())
+		}
+	}
+	return filtered, misses
+}
 
-	// Exit 1 if any failures
-	for _, r := range results {
-		if !r.Success {
-			os.Exit(1)
+// readTargetsFrom reads --targets-from's list of targets from a file, or
+// from stdin for "-".
+func readTargetsFrom(path string) ([]string, error) {
+	r := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("--targets-from: %w", err)
 		}
+		defer f.Close()
+		r = f
+	}
+	targets, err := ux.ReadTargets(r)
+	if err != nil {
+		return nil, fmt.Errorf("--targets-from %s: %w", path, err)
+	}
+	return targets, nil
+}
+
+// selectTargets filters packages for commands that don't run a task,
+// explaining on stderr if no package matches.
+func selectTargets(root string, packages []ux.Package, filters []string) []ux.Package {
+	if len(filters) == 0 {
+		return packages
+	}
+	selected, misses := filterPackages(root, packages, filters)
+	if len(selected) == 0 {
+		ux.PrintEmptySelection(os.Stderr, ux.EmptySelection{Misses: misses, All: packages})
+	}
+	return selected
+}
+
+// exitNoPackages ends a run that selected nothing: cleanly, or with
+// exitNoMatch under --strict or [behavior] empty_selection = "error".
+func exitNoPackages(task string, strict bool) {
+	writeResultFile(task, nil, nil)
+	if strict {
+		os.Exit(exitNoMatch)
+	}
+	os.Exit(0)
+}
+
+// confirmStages asks before running stages that include a confirm = true
+// task, unless yes (--yes) is set, and exits if the answer is no.
+func confirmStages(stages []ux.Stage, tasks map[string]ux.TaskConfig, yes bool) {
+	prompt := ux.ConfirmPrompt(stages, tasks)
+	if prompt == "" || yes {
+		return
+	}
+	ok, err := ux.Confirm(prompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "cancelled")
+		os.Exit(130)
+	}
+}
+
+// writeResultFile writes the JSON summary to $UX_RESULT_FILE when it is set.
+func writeResultFile(task string, results []ux.Result, env *ux.Environment) {
+	if err := ux.WriteResultFile(task, results, env); err != nil {
+		ux.Warnf("writing %s: %v", ux.ResultFileEnv, err)
+	}
+}
+
+// isFlag reports whether arg is the named flag, as "--name" or "--name=value".
+func isFlag(arg, name string) bool {
+	return arg == name || strings.HasPrefix(arg, name+"=")
+}
+
+// isJobsFlag reports whether arg is --jobs or -j, in any of the forms
+// jobsValue accepts.
+func isJobsFlag(arg string) bool {
+	if isFlag(arg, "--jobs") || isFlag(arg, "-j") {
+		return true
+	}
+	n, ok := strings.CutPrefix(arg, "-j")
+	_, err := strconv.Atoi(n)
+	return ok && err == nil
+}
+
+// jobsValue returns the name and value of the --jobs flag at args[*i],
+// given as "--jobs N", "--jobs=N", "-j N", "-j=N", or make's "-jN",
+// advancing *i past a separate value argument.
+func jobsValue(args []string, i *int) (name, value string) {
+	arg := args[*i]
+	if n, ok := strings.CutPrefix(arg, "-j"); ok && n != "" && n[0] != '=' {
+		return "-j", n
 	}
+	name, _, _ = strings.Cut(arg, "=")
+	return name, flagValue(args, i, name)
+}
+
+// flagValue returns the value of a flag given as "--name=value" or "--name value",
+// advancing *i past a separate value argument. Exits if the value is missing.
+func flagValue(args []string, i *int, name string) string {
+	if v, ok := strings.CutPrefix(args[*i], name+"="); ok {
+		return v
+	}
+	if *i+1 >= len(args) {
+		fmt.Fprintf(os.Stderr, "error: %s requires a value\n", name)
+		os.Exit(exitUsage)
+	}
+	*i++
+	return args[*i]
 }
 
 func printUsage() {
@@ -230,11 +857,73 @@ Commands:
   ux <task> //label           Run task on a specific package (absolute)
   ux <task> //dir/...         Run task on all packages under dir/
   ux <task> //a //b           Run task on multiple targets
-  ux <task> --affected        Run task only on packages changed vs origin/main
+  ux //label:task             Run exactly this task on exactly this package
+  ux <task> --root            Run only the task from [root-tasks], at the workspace root
+  ux <task> --affected        Run task only on packages changed vs origin/main (or $UX_BASE_REF)
+  ux <task> --pick            Choose which of the selected packages to run, interactively
+  ux <task> //pkg -i          Run on one package with the terminal attached (debuggers, dev servers)
   ux <task> -v                Show failure output inline (verbose)
-  ux <task> -- -n auto        Append flags to the underlying command
-  ux list                     List all discovered packages and their tasks
+  ux <task> -q, --quiet       Show only failures and the final count, no progress
+  ux <task> --no-color        Disable colors (also when NO_COLOR or UX_NO_COLOR is set)
+  ux <task> --no-cache        Run every package even if a cached result exists
+  ux <task> --skip-unchanged  Skip packages whose files and command match their last passing run
+  ux <task> --ui              Show a full-screen package view, then print the summary
+  ux <task> --pty             Run commands on a pseudo-terminal, so tools keep colors and progress
+  ux <task> --profile[=N]     List the N slowest packages after the run (default 10)
+  ux <task> --sort duration   Order the summary by duration (slowest first), status, or label
+  ux <task> --output github   Group output and annotate failures for GitHub Actions
+  ux <task> --trace <file>    Write a Chrome trace of the run (open in Perfetto)
+  ux <task> --metrics-push URL
+                              Push run metrics to a Prometheus pushgateway or statsd://host:port
+  ux <task> --rerun-failed    Run task only on packages that failed in its last run
+  ux <task> --owner team-data
+                              Run only on packages team-data owns ([package] owners or CODEOWNERS)
+  ux <task> --targets-from F  Also run on the targets listed in file F, one per line (- for stdin)
+  ux <task> --remote HOST     Run commands on a ux agent (experimental)
+  ux <task> --local           Run a parallel task here instead of on [executors] hosts
+  ux <task> --stream-dir DIR  Serve each package's live output on DIR/<label>.sock
+  ux <task> --max-failures 3  Stop starting packages after 3 failures
+  ux <task> -j, --jobs 8      Limit a parallel task to 8 weight units of packages at once
+  ux <task> --strict          Exit 3 if no packages are selected
+  ux <task> --yes             Run a confirm = true task without asking
+  ux <task> --serial          Run packages one at a time, whatever [tasks] says (--parallel: all at once)
+  ux <task> --flake-gate 0.1  Retry failures of packages with a historical flake rate <= 10%
+  ux <task> --quarantine      Report, but don't fail on, tasks packages mark flaky_tasks
+  ux <task> --log-level debug
+                              Log discovery, filters, git commands, and scheduling to stderr
+  ux <task> -- -n auto        Pass flags to the underlying command (at {args}, or appended)
+  ux <task> --args-for python -- -k foo
+                              Pass the extra args only to packages of a type
+  ux list [targets...]        List discovered packages and their tasks
+  ux list --task test         List only packages defining a task, with its command
+  ux list --type python       List only packages of a type
+  ux list --owner team-data   List only packages an owner owns
+  ux list --tree              Show packages as a tree of directories
+  ux list --no-cache          Rescan the workspace, ignoring the discovery index and daemon
+  ux adopt //dir/... [--yes]  Add uncovered packages under dir/ to the workspace
+  ux tail //label [--task t]  Follow a package's output in the running (or last) run
+  ux stats [task]             Show average and last durations per package from run history
+  ux flaky [task] [--limit N]
+                              List the packages that fail most erratically, from run history
+  ux affected [--explain]     List packages changed vs origin/main, and why with --explain
+  ux cache status             Show cache size and hit rate per task
+  ux cache clean [--task t] [--older-than 7d]
+                              Remove cached results
+  ux cache verify [--fix]     Find (and remove) corrupted cache entries
+  ux clean [--tasks] [--dry-run]
+                              Remove .ux state and logs, then run clean tasks with --tasks
+  ux outdated [task] [--hash]
+                              List packages whose outputs are older than their inputs
+  ux release [--bump minor]   Build, publish, and tag packages changed since their last tag
+  ux config fmt [--check]     Format every ux.toml canonically (--check: fail if any aren't)
+  ux agent [--listen host:port]
+                              Serve --remote runs on this machine (experimental)
+  ux daemon [--stop]          Keep discovery, hashes, and git state warm for faster runs
+  ux why //label [--task t]   Explain how a package and its tasks were resolved
+  ux doctor                   Check git, the base ref, config, members, types, and tools
   ux migrate                  Migrate from turborepo (reads package.json + turbo.json)
+  ux migrate --from make      Migrate from per-directory Makefiles (.PHONY targets)
+  ux export docs [--check]    Generate the workspace overview (--check: fail if stale)
   ux --version                Print the version and exit
 
 Examples:
@@ -247,6 +936,10 @@ Examples:
   cd packages/api && ux test .   Test from inside a package
   ux test -- -n auto          Append pytest flags
 
+Exit codes:
+  0  success          1  a package failed
+  2  usage or config error    3  nothing selected (with --strict)
+
 Configuration:
   Root ux.toml defines workspace members and task settings.
   Each package has its own ux.toml defining available tasks.
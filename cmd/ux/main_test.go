@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestJobsFlag(t *testing.T) {
+	for _, tt := range []struct {
+		args        []string
+		name, value string
+		next        int
+	}{
+		{[]string{"-j4"}, "-j", "4", 0},
+		{[]string{"-j", "4"}, "-j", "4", 1},
+		{[]string{"-j=4"}, "-j", "4", 0},
+		{[]string{"--jobs=4"}, "--jobs", "4", 0},
+		{[]string{"--jobs", "4"}, "--jobs", "4", 1},
+	} {
+		if !isJobsFlag(tt.args[0]) {
+			t.Errorf("%v: not a jobs flag", tt.args)
+			continue
+		}
+		i := 0
+		if name, value := jobsValue(tt.args, &i); name != tt.name || value != tt.value || i != tt.next {
+			t.Errorf("%v: got %s %s (next %d), want %s %s (next %d)", tt.args, name, value, i, tt.name, tt.value, tt.next)
+		}
+	}
+	for _, arg := range []string{"-jx", "--jobsx", "-i"} {
+		if isJobsFlag(arg) {
+			t.Errorf("%s taken for a jobs flag", arg)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// migrators maps each --from source to its migration.
+var migrators = map[string]func(dir string) error{
+	"turbo": ux.RunMigrate,
+	"make":  ux.RunMigrateMake,
+}
+
+// runMigrate handles `ux migrate [--from turbo|make]`. It runs before
+// workspace discovery since ux.toml doesn't exist yet.
+func runMigrate(args []string) {
+	from := "turbo"
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--from"):
+			from = flagValue(args, &i, "--from")
+		default:
+			fmt.Fprintf(os.Stderr, "unknown argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+
+	migrate, ok := migrators[from]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: unknown --from %q (expected turbo or make)\n", from)
+		os.Exit(exitUsage)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	if err := migrate(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+const outdatedUsage = "usage: ux outdated [task] [targets...] [--hash]\n"
+
+// runOutdated handles `ux outdated [task] [targets...] [--hash]`: it lists
+// packages whose outputs of task (or of every task declaring outputs) are
+// missing or older than their inputs, and exits 1 if there are any.
+func runOutdated(args []string) {
+	var task string
+	var filters []string
+	var hash bool
+	for _, arg := range args {
+		switch {
+		case arg == "--hash":
+			hash = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprint(os.Stderr, outdatedUsage)
+			os.Exit(exitUsage)
+		// Like `ux <task>`, the first argument is the task unless it's a label or path
+		case task == "" && len(filters) == 0 && !strings.HasPrefix(arg, "//") && !strings.HasPrefix(arg, "."):
+			task = arg
+		default:
+			filters = append(filters, arg)
+		}
+	}
+
+	root, rootCfg, all := loadWorkspace()
+	packages := selectTargets(root, all, filters)
+
+	tasks := ux.OutputTasks(rootCfg.Tasks)
+	if task != "" {
+		if len(rootCfg.Tasks[task].Outputs) == 0 {
+			fmt.Fprintf(os.Stderr, "error: task %q declares no outputs in [tasks.%s]\n", task, task)
+			os.Exit(exitUsage)
+		}
+		tasks = []string{task}
+	}
+	if len(tasks) == 0 {
+		fmt.Fprintln(os.Stderr, "error: no tasks declare outputs")
+		os.Exit(exitUsage)
+	}
+
+	var outdated []ux.Outdated
+	var checked int
+	for _, t := range tasks {
+		found, n, err := ux.FindOutdated(ux.CacheDir(root), t, rootCfg.Tasks[t], packages, all, hash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		outdated = append(outdated, found...)
+		checked += n
+	}
+
+	var stale []string
+	for _, o := range outdated {
+		fmt.Printf("%s %s: %s\n", o.Task, o.Label, o.Reason)
+		if len(stale) == 0 || stale[len(stale)-1] != o.Task {
+			stale = append(stale, o.Task)
+		}
+	}
+	if len(outdated) > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d package output(s) outdated; run: ux %s\n", len(outdated), checked, strings.Join(stale, ", ux "))
+		os.Exit(exitFailure)
+	}
+	fmt.Printf("%d package output(s) up to date\n", checked)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+const releaseUsage = "usage: ux release [targets...] [--bump major|minor|patch] [--dry-run] [--push] [--yes]\n"
+
+// releaseTasks run, in order, on each released package that defines them.
+var releaseTasks = []string{"build", "publish"}
+
+// runRelease handles `ux release`: it finds the packages defining publish
+// that changed since their last release tag, runs build and publish on them
+// in dependency order, and tags each one that succeeds.
+func runRelease(args []string) {
+	var filters []string
+	bump := "patch"
+	var dryRun, push, yes bool
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--bump"):
+			bump = flagValue(args, &i, "--bump")
+			if bump != "major" && bump != "minor" && bump != "patch" {
+				fmt.Fprintf(os.Stderr, "error: --bump must be major, minor, or patch\n")
+				os.Exit(exitUsage)
+			}
+		case arg == "--dry-run":
+			dryRun = true
+		case arg == "--push":
+			push = true
+		case arg == "--yes" || arg == "-y":
+			yes = true
+		case ux.IsFilterArg(arg):
+			filters = append(filters, arg)
+		default:
+			fmt.Fprint(os.Stderr, releaseUsage)
+			os.Exit(exitUsage)
+		}
+	}
+
+	root, rootCfg, all := loadWorkspace()
+	packages := selectTargets(root, all, filters)
+	var publishable []ux.Package
+	for _, pkg := range packages {
+		if _, ok := pkg.Tasks["publish"]; ok {
+			publishable = append(publishable, pkg)
+		}
+	}
+	if len(publishable) == 0 {
+		if len(packages) > 0 {
+			ux.PrintEmptySelection(os.Stderr, ux.EmptySelection{Task: "publish", Selected: packages, All: all, Tasks: rootCfg.Tasks})
+		}
+		return
+	}
+
+	if !dryRun {
+		// Tags name a commit, so what's built must be exactly what's committed
+		clean, err := ux.WorkingTreeClean(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		if !clean {
+			fmt.Fprintf(os.Stderr, "error: the working tree has uncommitted changes; commit or stash them before releasing\n")
+			os.Exit(exitUsage)
+		}
+	}
+
+	releases, err := ux.PlanReleases(root, rootCfg.Affected, publishable, bump)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if len(releases) == 0 {
+		fmt.Println("nothing to release: no package changed since its last tag")
+		return
+	}
+	levels, err := ux.ReleaseLevels(releases)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	for _, level := range levels {
+		for _, r := range level {
+			from := "first release"
+			if r.Prev != "" {
+				from = fmt.Sprintf("%d file(s) changed since %s", len(r.Changed), r.Prev)
+			}
+			fmt.Printf("%s → %s (%s)\n", r.Package.Label, r.Tag, from)
+		}
+	}
+	if dryRun {
+		return
+	}
+	var stages []ux.Stage
+	for _, task := range releaseTasks {
+		stage := ux.Stage{Task: task}
+		for _, r := range releases {
+			if _, defined := r.Package.Tasks[task]; defined {
+				stage.Packages = append(stage.Packages, r.Package)
+			}
+		}
+		stages = append(stages, stage)
+	}
+	confirmStages(stages, rootCfg.Tasks, yes)
+
+	logDir := rootCfg.Logs.RunDir(root, runID)
+	summaryOpts := ux.SummaryOptions{LogDir: logDir}
+	var tags []string
+	failed := false
+	for _, level := range levels {
+		ok := make(map[string]bool, len(level))
+		for _, r := range level {
+			ok[r.Package.Label] = true
+		}
+		for _, task := range releaseTasks {
+			var pkgs []ux.Package
+			for _, r := range level {
+				if _, defined := r.Package.Tasks[task]; defined && ok[r.Package.Label] {
+					pkgs = append(pkgs, r.WithReleaseEnv())
+				}
+			}
+			if len(pkgs) == 0 {
+				continue
+			}
+			results := ux.RunTask(task, pkgs, rootCfg.Tasks[task], ux.RunOptions{LogDir: logDir})
+			ux.PrintSummary(task, results, summaryOpts)
+			for _, r := range results {
+				if r.Failed() {
+					ok[r.Package.Label] = false
+					failed = true
+				}
+			}
+		}
+		for _, r := range level {
+			if !ok[r.Package.Label] {
+				continue
+			}
+			if err := ux.CreateReleaseTag(root, r); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(exitFailure)
+			}
+			tags = append(tags, r.Tag)
+		}
+		// Packages depending on a failed one would publish against an unreleased version
+		if failed {
+			break
+		}
+	}
+
+	if len(tags) > 0 {
+		fmt.Printf("\ntagged %s\n", strings.Join(tags, ", "))
+		if push {
+			if err := ux.PushReleaseTags(root, tags); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(exitFailure)
+			}
+			fmt.Println("pushed tags to origin")
+		}
+	}
+	if failed {
+		if skipped := len(releases) - len(tags); skipped > 0 {
+			ux.Warnf("%d package(s) not released", skipped)
+		}
+		os.Exit(exitFailure)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runStats handles `ux stats [task]`.
+func runStats(args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: ux stats [task]\n")
+		os.Exit(exitUsage)
+	}
+
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	history, err := ux.LoadHistory(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+
+	tasks := history.TaskNames()
+	if len(args) == 1 {
+		tasks = []string{args[0]}
+	}
+	if len(tasks) == 0 {
+		fmt.Println("no runs recorded yet")
+		return
+	}
+	for _, task := range tasks {
+		stats := history.Stats(task)
+		if len(stats) == 0 {
+			fmt.Fprintf(os.Stderr, "error: no runs of %s recorded\n", task)
+			os.Exit(exitFailure)
+		}
+		ux.PrintStats(task, stats)
+	}
+}
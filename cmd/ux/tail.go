@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// tailWaitInterval is how often `ux tail` checks whether a package in a
+// running run has started.
+const tailWaitInterval = 500 * time.Millisecond
+
+// runTail handles `ux tail <package> [--task <name>]`: it prints a package's
+// log from the current run, following it until the package finishes, or its
+// log from the latest run that has one.
+func runTail(args []string) {
+	var target, task string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--task"):
+			task = flagValue(args, &i, "--task")
+		case target == "" && ux.IsFilterArg(arg):
+			target = arg
+		default:
+			fmt.Fprintf(os.Stderr, "unknown argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+	if target == "" || strings.HasSuffix(target, "...") {
+		fmt.Fprintf(os.Stderr, "usage: ux tail <package> [--task <name>]\n")
+		os.Exit(exitUsage)
+	}
+
+	root, err := ux.FindWorkspaceRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	rootCfg, err := ux.LoadRootConfig(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	label, err := ux.ResolveFilter(root, cwd, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// While a run is in progress, wait for the package to start in it rather
+	// than showing an older run's log
+	log := findLog(root, rootCfg.Logs, label, task)
+	if latest, _ := ux.LatestRun(root, rootCfg.Logs); latest != "" && ux.RunActive(latest) {
+		waiting := false
+		for (log == nil || log.RunID != latest) && ux.RunActive(latest) {
+			if !waiting {
+				fmt.Fprintf(os.Stderr, "waiting for %s to start...\n", label)
+				waiting = true
+			}
+			time.Sleep(tailWaitInterval)
+			log = findLog(root, rootCfg.Logs, label, task)
+		}
+	}
+	if log == nil {
+		fmt.Fprintf(os.Stderr, "error: no logs for %s\n", label)
+		os.Exit(exitFailure)
+	}
+
+	if err := ux.FollowLog(log, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
+
+func findLog(root string, cfg ux.LogsConfig, label, task string) *ux.PackageLog {
+	log, err := ux.FindPackageLog(root, cfg, label, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	return log
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+// runWhy handles `ux why <//label> [--task <name>]`: it explains how a
+// package was resolved and where each of its tasks comes from.
+func runWhy(args []string) {
+	var target, task string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case isFlag(arg, "--task"):
+			task = flagValue(args, &i, "--task")
+		case target == "" && ux.IsFilterArg(arg):
+			target = arg
+		default:
+			fmt.Fprintf(os.Stderr, "unexpected argument: %s\n", arg)
+			os.Exit(exitUsage)
+		}
+	}
+	if target == "" {
+		fmt.Fprintf(os.Stderr, "usage: ux why <//label> [--task <name>]\n")
+		os.Exit(exitUsage)
+	}
+
+	root, rootCfg, packages := loadWorkspace()
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	label, err := ux.ResolveFilter(root, cwd, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if target == "." {
+		// Here, "." is the package in the current directory, not everything below it
+		label = strings.TrimSuffix(label, "/...")
+	}
+	if strings.HasSuffix(label, "...") {
+		fmt.Fprintf(os.Stderr, "error: ux why takes one package, not a pattern: %s\n", label)
+		os.Exit(exitUsage)
+	}
+
+	e, err := ux.Why(root, rootCfg, packages, label, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	ux.PrintExplanation(e)
+	if e.Package == nil {
+		os.Exit(exitFailure)
+	}
+}
@@ -0,0 +1,248 @@
+package ux
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AdoptCandidate is a directory that looks like a package but isn't covered
+// by any workspace member yet.
+type AdoptCandidate struct {
+	Label     string
+	Dir       string
+	Type      string // detected from marker files; may be empty
+	HasConfig bool   // already has a ux.toml
+}
+
+// FindAdoptable lists the package directories matched by filter ("//legacy"
+// or "//legacy/...") that no workspace member covers, sorted by label.
+func FindAdoptable(root string, cfg *RootConfig, filter string) ([]AdoptCandidate, error) {
+	types, err := loadPackageTypes(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+	label := strings.TrimPrefix(filter, "//")
+	base, recursive := strings.CutSuffix(label, "/...")
+	if label == "..." {
+		base, recursive = "", true
+	}
+	absBase := filepath.Join(root, filepath.FromSlash(base))
+	if info, err := os.Stat(absBase); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory in the workspace", filter)
+	}
+
+	var candidates []AdoptCandidate
+	consider := func(dir string) {
+		rel, _ := filepath.Rel(root, dir)
+		label := "//" + filepath.ToSlash(rel)
+		// Packages the members exclude with "!" were left out on purpose
+		if !types.isPackageDir(dir) || membersCover(cfg.Workspace.Members, label) ||
+			memberExcluded(memberNegations(cfg.Workspace.Members), label) {
+			return
+		}
+		_, err := os.Stat(filepath.Join(dir, "ux.toml"))
+		candidates = append(candidates, AdoptCandidate{
+			Label:     label,
+			Dir:       dir,
+			Type:      types.detectType(dir),
+			HasConfig: err == nil,
+		})
+	}
+
+	if !recursive {
+		consider(absBase)
+		return candidates, nil
+	}
+	// Like discovery, a recursive pattern doesn't include its base directory
+	err = filepath.WalkDir(absBase, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == absBase {
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || skipDirs[name] || skipsDir(types.skip, name) {
+			return filepath.SkipDir
+		}
+		if path != root {
+			consider(path)
+		}
+		return nil
+	})
+	return candidates, err
+}
+
+// membersCover reports whether any workspace member pattern matches label
+// and no "!" pattern excludes it.
+func membersCover(members []string, label string) bool {
+	if memberExcluded(memberNegations(members), label) {
+		return false
+	}
+	for _, m := range members {
+		if strings.HasPrefix(m, "!") {
+			continue
+		}
+		pattern := strings.TrimPrefix(m, "//")
+		path := strings.TrimPrefix(label, "//")
+		if pattern == "..." {
+			return true
+		}
+		if base, ok := strings.CutSuffix(pattern, "/..."); ok {
+			if strings.HasPrefix(path, base+"/") {
+				return true
+			}
+		} else if pattern == path {
+			return true
+		}
+	}
+	return false
+}
+
+// AdoptMembers returns the member entries that cover the accepted
+// candidates: the filter itself when everything it found was accepted,
+// otherwise one entry per accepted package.
+func AdoptMembers(filter string, found, accepted []AdoptCandidate) []string {
+	if strings.HasSuffix(filter, "/...") && len(accepted) == len(found) && len(found) > 0 {
+		return []string{filter}
+	}
+	members := make([]string, 0, len(accepted))
+	for _, c := range accepted {
+		members = append(members, c.Label)
+	}
+	return members
+}
+
+// WriteConfig writes a minimal ux.toml ([package] name and type) for a
+// candidate that doesn't have one. It reports whether a file was written.
+func (c AdoptCandidate) WriteConfig() (bool, error) {
+	if c.HasConfig {
+		return false, nil
+	}
+	content := generateMinimalPackageToml(migratedPackage{
+		name:    filepath.Base(c.Dir),
+		pkgType: c.Type,
+	}, nil)
+	return writeFileIfNew(filepath.Join(c.Dir, "ux.toml"), content)
+}
+
+var (
+	workspaceHeader = regexp.MustCompile(`(?m)^\[workspace\][ \t]*(#.*)?$`)
+	membersKey      = regexp.MustCompile(`(?m)^[ \t]*members[ \t]*=[ \t]*\[`)
+)
+
+// AddMembers appends entries to the members array of the root ux.toml,
+// editing it in place so the rest of the file, comments included, is kept.
+func AddMembers(root string, members []string) error {
+	path := filepath.Join(root, "ux.toml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	updated, err := appendMembers(string(data), members)
+	if err != nil {
+		return fmt.Errorf("updating %s: %w", path, err)
+	}
+	return os.WriteFile(path, []byte(updated), 0644)
+}
+
+// arrayEnd scans s, the text just past a TOML array's opening bracket, and
+// returns the index of its closing bracket, skipping brackets in strings,
+// comments, and nested arrays, or -1 if there is none. last is the index just
+// past the array's last value or comma.
+func arrayEnd(s string) (end, last int) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case '"', '\'':
+			j := i + 1
+			for ; j < len(s) && s[j] != c && s[j] != '\n'; j++ {
+				if c == '"' && s[j] == '\\' {
+					j++
+				}
+			}
+			i, last = j, j+1
+		case '[':
+			depth++
+			last = i + 1
+		case ']':
+			if depth == 0 {
+				return i, last
+			}
+			depth--
+			last = i + 1
+		case ' ', '\t', '\r', '\n':
+		default:
+			last = i + 1
+		}
+	}
+	return -1, last
+}
+
+// appendMembers adds entries to the [workspace] members array in a ux.toml's
+// text, matching its single- or multi-line layout.
+func appendMembers(content string, members []string) (string, error) {
+	header := workspaceHeader.FindStringIndex(content)
+	if header == nil {
+		return "", fmt.Errorf("no [workspace] table")
+	}
+	section := content[header[1]:]
+	if next := strings.Index(section, "\n["); next >= 0 {
+		section = section[:next+1]
+	}
+
+	quoted := make([]string, len(members))
+	for i, m := range members {
+		quoted[i] = fmt.Sprintf("%q", m)
+	}
+
+	key := membersKey.FindStringIndex(section)
+	if key == nil {
+		insert := header[1]
+		return content[:insert] + "\nmembers = [" + strings.Join(quoted, ", ") + "]" + content[insert:], nil
+	}
+	open := header[1] + key[1] // just past "["
+	end, last := arrayEnd(content[open:])
+	if end < 0 {
+		return "", fmt.Errorf("unterminated members array")
+	}
+	closing := open + end
+	inner := content[open:closing]
+	trailingComma := last > 0 && inner[last-1] == ','
+
+	if !strings.Contains(inner, "\n") {
+		trimmed := strings.TrimRight(inner, " ,")
+		if strings.TrimSpace(trimmed) != "" {
+			trimmed += ", "
+		}
+		return content[:open] + trimmed + strings.Join(quoted, ", ") + content[closing:], nil
+	}
+
+	// Multi-line: add one entry per line before the closing bracket, using
+	// the indentation of the existing entries. A comma after the last entry
+	// goes before any comment that follows it.
+	body := strings.TrimRight(inner, " \t\n")
+	indent := "  "
+	if lines := strings.Split(body, "\n"); len(lines) > 1 {
+		line := lines[len(lines)-1]
+		indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+	if strings.TrimSpace(inner[:last]) != "" && !trailingComma {
+		body = inner[:last] + "," + body[last:]
+	}
+	var b strings.Builder
+	b.WriteString(body)
+	for i, q := range quoted {
+		b.WriteString("\n" + indent + q)
+		if i < len(quoted)-1 || trailingComma {
+			b.WriteString(",")
+		}
+	}
+	b.WriteString("\n")
+	return content[:open] + b.String() + content[closing:], nil
+}
@@ -0,0 +1,119 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAppendMembers(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{
+			"single line",
+			"[workspace]\nmembers = [\"//a/...\"]\n\n[tasks]\n",
+			"[workspace]\nmembers = [\"//a/...\", \"//legacy/...\", \"//b\"]\n\n[tasks]\n",
+		},
+		{
+			"empty array",
+			"[workspace]\nmembers = []\n",
+			"[workspace]\nmembers = [\"//legacy/...\", \"//b\"]\n",
+		},
+		{
+			"multi line with trailing comma",
+			"[workspace]\nmembers = [\n    \"//a/...\",  # apps\n    \"//c\",\n]\n",
+			"[workspace]\nmembers = [\n    \"//a/...\",  # apps\n    \"//c\",\n    \"//legacy/...\",\n    \"//b\",\n]\n",
+		},
+		{
+			"multi line without trailing comma",
+			"[workspace]\nmembers = [\n  \"//a/...\"\n]\n",
+			"[workspace]\nmembers = [\n  \"//a/...\",\n  \"//legacy/...\",\n  \"//b\"\n]\n",
+		},
+		{
+			"brackets in strings and comments",
+			"[workspace]\nmembers = [\n  \"//a/[x]\",  # the [legacy] apps\n  \"//c\"  # last]\n]\n",
+			"[workspace]\nmembers = [\n  \"//a/[x]\",  # the [legacy] apps\n  \"//c\",  # last]\n  \"//legacy/...\",\n  \"//b\"\n]\n",
+		},
+		{
+			"single line with a bracket in a string",
+			"[workspace]\nmembers = ['//a]']\n",
+			"[workspace]\nmembers = ['//a]', \"//legacy/...\", \"//b\"]\n",
+		},
+		{
+			"no members key",
+			"# root\n[workspace]\ninclude = [\"x/ux.toml\"]\n",
+			"# root\n[workspace]\nmembers = [\"//legacy/...\", \"//b\"]\ninclude = [\"x/ux.toml\"]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := appendMembers(tt.content, []string{"//legacy/...", "//b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := appendMembers("[tasks]\n", []string{"//b"}); err == nil {
+		t.Error("expected an error without [workspace]")
+	}
+}
+
+func TestFindAdoptable(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//legacy/covered\"]\n\n[discovery]\nskip = [\"tmp*\"]\n")
+	writeFile(t, filepath.Join(root, "legacy", "covered", "go.mod"), "module covered\n")
+	writeFile(t, filepath.Join(root, "legacy", "tmp-old", "go.mod"), "module old\n")
+	writeFile(t, filepath.Join(root, "legacy", "svc", "go.mod"), "module svc\n")
+	writeFile(t, filepath.Join(root, "legacy", "tool", "ux.toml"), "[tasks]\nrun = \"./run.sh\"\n")
+	writeFile(t, filepath.Join(root, "legacy", "docs", "README.md"), "")
+	writeFile(t, filepath.Join(root, "legacy", "web", "node_modules", "x", "package.json"), "{}")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := FindAdoptable(root, cfg, "//legacy/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, c := range found {
+		labels = append(labels, c.Label)
+	}
+	if want := []string{"//legacy/svc", "//legacy/tool"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("FindAdoptable = %v, want %v", labels, want)
+	}
+	if found[0].Type != "go" || found[0].HasConfig || !found[1].HasConfig {
+		t.Errorf("unexpected candidates: %+v", found)
+	}
+
+	if got := AdoptMembers("//legacy/...", found, found); !reflect.DeepEqual(got, []string{"//legacy/..."}) {
+		t.Errorf("AdoptMembers(all) = %v", got)
+	}
+	if got := AdoptMembers("//legacy/...", found, found[:1]); !reflect.DeepEqual(got, []string{"//legacy/svc"}) {
+		t.Errorf("AdoptMembers(some) = %v", got)
+	}
+
+	if written, err := found[0].WriteConfig(); err != nil || !written {
+		t.Fatalf("WriteConfig = %v, %v", written, err)
+	}
+	if err := AddMembers(root, AdoptMembers("//legacy/...", found, found)); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 3 {
+		t.Errorf("discovered %d packages after adopting, want 3", len(packages))
+	}
+}
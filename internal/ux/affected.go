@@ -0,0 +1,185 @@
+package ux
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AffectedConfig is the root config's [affected] table: files outside any
+// package that still affect packages when they change.
+type AffectedConfig struct {
+	Global AffectedGlobal `toml:"global"`
+	// Map maps workspace-relative globs to the targets they affect:
+	// "proto/**" = ["//services/..."].
+	Map map[string][]string `toml:"map"`
+}
+
+// AffectedGlobal lists repo-wide files, such as lockfiles and CI workflows,
+// whose changes affect every package.
+type AffectedGlobal struct {
+	Paths []string `toml:"paths"`
+}
+
+// AffectedPackage is a package affected by changed files, with why.
+type AffectedPackage struct {
+	Package Package
+	Reasons []AffectedReason
+}
+
+// AffectedReason is one changed file affecting a package.
+type AffectedReason struct {
+	File string
+	// Rule is the [affected] rule that mapped the file onto the package:
+	// "global", an [affected.map] glob, or "generated_from <target>" for a
+	// file the package generates code from. Empty for a file in the package.
+	Rule string
+}
+
+// ChangedFiles returns the workspace-relative files changed vs the base
+// ref (see BaseRef).
+func ChangedFiles(root string) ([]string, error) {
+	base := BaseRef()
+	if files, ok := daemonChangedFiles(root, base); ok {
+		return files, nil
+	}
+	return changedFiles(root, base)
+}
+
+// changedFiles is ChangedFiles vs base, without asking a `ux daemon`.
+func changedFiles(root, base string) ([]string, error) {
+	raw, err := gitDiffFiles(root, base)
+	if err != nil {
+		return nil, err
+	}
+	changedFiles := strings.Split(strings.TrimSpace(raw), "\n")
+	if len(changedFiles) == 1 && changedFiles[0] == "" {
+		return nil, nil
+	}
+	return changedFiles, nil
+}
+
+// FilterAffected keeps only packages that have changed files vs the base ref,
+// including changes [affected] maps onto them.
+func FilterAffected(root string, cfg AffectedConfig, packages []Package) ([]Package, error) {
+	changedFiles, err := ChangedFiles(root)
+	if err != nil || len(changedFiles) == 0 {
+		return nil, err
+	}
+	return affectedPackages(root, cfg, packages, changedFiles), nil
+}
+
+// affectedPackages returns the packages affected by the changed files (see
+// ExplainAffected).
+func affectedPackages(root string, cfg AffectedConfig, packages []Package, changedFiles []string) []Package {
+	affected, _ := ExplainAffected(root, cfg, packages, changedFiles)
+	result := make([]Package, len(affected))
+	for i, a := range affected {
+		result[i] = a.Package
+	}
+	return result
+}
+
+// ExplainAffected attributes the changed files (workspace-relative,
+// slash-separated) to the packages they affect: those containing a changed
+// file or generating code from one (generated_from), those targeted by an
+// [affected.map] glob matching one, and every package if a file matches
+// [affected.global] paths. Affected packages are
+// returned in their given order; unmatched lists the files that affect none.
+func ExplainAffected(root string, cfg AffectedConfig, packages []Package, changedFiles []string) (affected []AffectedPackage, unmatched []string) {
+	reasons := make(map[string][]AffectedReason)
+	add := func(label string, r AffectedReason) {
+		reasons[label] = append(reasons[label], r)
+	}
+
+	globs := make([]string, 0, len(cfg.Map))
+	for glob := range cfg.Map {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+
+	for _, f := range changedFiles {
+		matched := false
+		if matchAnyGlob(cfg.Global.Paths, f) {
+			for _, pkg := range packages {
+				add(pkg.Label, AffectedReason{File: f, Rule: "global"})
+			}
+			matched = len(packages) > 0
+		}
+		for _, glob := range globs {
+			if !matchGlob(glob, f) {
+				continue
+			}
+			for _, target := range cfg.Map[glob] {
+				for _, pkg := range FilterByLabel(packages, target) {
+					add(pkg.Label, AffectedReason{File: f, Rule: glob})
+					matched = true
+				}
+			}
+		}
+		for _, pkg := range packages {
+			// The root package holds every file
+			if dir := slashRel(root, pkg.Dir); dir == "" || strings.HasPrefix(f, dir+"/") {
+				add(pkg.Label, AffectedReason{File: f})
+				matched = true
+			}
+			for _, target := range pkg.GeneratedFrom {
+				if underTarget(f, target) {
+					add(pkg.Label, AffectedReason{File: f, Rule: generatedFromRule + target})
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, f)
+		}
+	}
+
+	for _, pkg := range packages {
+		if rs, ok := reasons[pkg.Label]; ok {
+			affected = append(affected, AffectedPackage{Package: pkg, Reasons: rs})
+		}
+	}
+	return affected, unmatched
+}
+
+// generatedFromRule prefixes the target in an AffectedReason's Rule when the
+// package generates code from the file.
+const generatedFromRule = "generated_from "
+
+// underTarget reports whether a workspace-relative file is in the directory
+// a target names: "//proto" and "//proto/..." both cover proto/.
+func underTarget(file, target string) bool {
+	dir := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(target, "//"), "..."), "/")
+	return dir == "" || dir == "." || strings.HasPrefix(file, dir+"/")
+}
+
+// PrintAffectedExplanation prints, for `ux affected --explain`, each affected
+// package with the changed files that affect it, then the changed files that
+// affect no package.
+func PrintAffectedExplanation(changed int, affected []AffectedPackage, unmatched []string) {
+	fmt.Printf("\n%s\n\n", styleHeader.Render(fmt.Sprintf("Affected packages (%d, from %d changed files)", len(affected), changed)))
+	for _, a := range affected {
+		fmt.Printf("  %s\n", styleLabel.Render(a.Package.Label))
+		for _, r := range a.Reasons {
+			via := ""
+			switch {
+			case r.Rule == "":
+			case r.Rule == "global":
+				via = styleDim.Render(" (via [affected.global])")
+			case strings.HasPrefix(r.Rule, generatedFromRule):
+				via = styleDim.Render(" (via " + r.Rule + ")")
+			default:
+				via = styleDim.Render(fmt.Sprintf(" (via [affected.map] %q)", r.Rule))
+			}
+			fmt.Printf("    %s%s\n", r.File, via)
+		}
+	}
+	if len(unmatched) > 0 {
+		fmt.Printf("\n  %s\n", styleBold.Render(fmt.Sprintf("Not in any package (%d)", len(unmatched))))
+		for _, f := range unmatched {
+			fmt.Printf("    %s\n", styleDim.Render(f))
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,93 @@
+package ux
+
+import "testing"
+
+func TestAffectedPackages(t *testing.T) {
+	packages := []Package{
+		{Label: "//services/api", Dir: "/ws/services/api"},
+		{Label: "//services/web", Dir: "/ws/services/web"},
+		{Label: "//packages/auth", Dir: "/ws/packages/auth"},
+	}
+	cfg := AffectedConfig{
+		Global: AffectedGlobal{Paths: []string{"go.work", ".github/workflows/**"}},
+		Map:    map[string][]string{"proto/**": {"//services/..."}},
+	}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{"package files", []string{"packages/auth/main.go"}, []string{"//packages/auth"}},
+		{"unrelated file", []string{"README.md"}, nil},
+		{"global path", []string{"go.work"}, []string{"//services/api", "//services/web", "//packages/auth"}},
+		{"global glob", []string{".github/workflows/ci.yml"}, []string{"//services/api", "//services/web", "//packages/auth"}},
+		{"mapped glob", []string{"proto/user/v1/user.proto"}, []string{"//services/api", "//services/web"}},
+		{"mapped and direct", []string{"proto/a.proto", "packages/auth/x.go"}, []string{"//services/api", "//services/web", "//packages/auth"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := affectedPackages("/ws", cfg, packages, tt.changed)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d packages, want %v", len(got), tt.want)
+			}
+			for i, pkg := range got {
+				if pkg.Label != tt.want[i] {
+					t.Errorf("got[%d] = %s, want %s", i, pkg.Label, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExplainAffected(t *testing.T) {
+	packages := []Package{
+		{Label: "//services/api", Dir: "/ws/services/api"},
+		{Label: "//services/web", Dir: "/ws/services/web"},
+	}
+	cfg := AffectedConfig{Map: map[string][]string{"proto/**": {"//services/api"}}}
+
+	affected, unmatched := ExplainAffected("/ws", cfg, packages,
+		[]string{"services/api/main.go", "proto/a.proto", "docs/index.md"})
+	if len(affected) != 1 || affected[0].Package.Label != "//services/api" {
+		t.Fatalf("affected = %+v", affected)
+	}
+	want := []AffectedReason{{File: "services/api/main.go"}, {File: "proto/a.proto", Rule: "proto/**"}}
+	if got := affected[0].Reasons; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("reasons = %+v, want %+v", got, want)
+	}
+	if len(unmatched) != 1 || unmatched[0] != "docs/index.md" {
+		t.Errorf("unmatched = %v", unmatched)
+	}
+
+	// The root package contains every file, so none is unmatched
+	packages = append(packages, Package{Label: RootLabel, Dir: "/ws"})
+	affected, unmatched = ExplainAffected("/ws", cfg, packages, []string{"services/api/main.go", "docs/index.md"})
+	if len(affected) != 2 || affected[0].Package.Label != "//services/api" || affected[1].Package.Label != RootLabel {
+		t.Fatalf("with a root package: affected = %+v", affected)
+	}
+	if got := affected[1].Reasons; len(got) != 2 || got[0].File != "services/api/main.go" || got[1].File != "docs/index.md" {
+		t.Errorf("root reasons = %+v", got)
+	}
+	if len(unmatched) != 0 {
+		t.Errorf("with a root package: unmatched = %v", unmatched)
+	}
+}
+
+func TestExplainAffectedGeneratedFrom(t *testing.T) {
+	packages := []Package{
+		{Label: "//services/api", Dir: "/ws/services/api", GeneratedFrom: []string{"//proto/..."}},
+		{Label: "//services/web", Dir: "/ws/services/web"},
+	}
+	// The proto package itself needn't be selected
+	affected, unmatched := ExplainAffected("/ws", AffectedConfig{}, packages, []string{"proto/billing/v1/invoice.proto", "protobuf.md"})
+	if len(affected) != 1 || affected[0].Package.Label != "//services/api" {
+		t.Fatalf("affected = %+v", affected)
+	}
+	if r := affected[0].Reasons; len(r) != 1 || r[0].Rule != "generated_from //proto/..." {
+		t.Errorf("reasons = %+v", r)
+	}
+	if len(unmatched) != 1 || unmatched[0] != "protobuf.md" {
+		t.Errorf("unmatched = %v", unmatched)
+	}
+}
@@ -0,0 +1,221 @@
+package ux
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Agent is the server side of `ux agent`: it runs commands sent by Remote
+// clients and streams their output back. It is experimental.
+//
+// The protocol is HTTP with JSON bodies:
+//
+//	GET    /v1/info                 → {"shared": true}
+//	POST   /v1/sessions             → {"id": "..."}, for the package dir in
+//	                                  the X-Ux-Dir header, shipped as a
+//	                                  gzipped tar body unless shared
+//	POST   /v1/sessions/{id}/run    → the command's output, with its exit
+//	                                  code in the X-Ux-Exit-Code trailer
+//	DELETE /v1/sessions/{id}        → deletes a shipped package dir
+type Agent struct {
+	// Root is the agent's checkout of the workspace, where package dirs are
+	// found. Empty means clients ship each package's files instead.
+	Root string
+	// Token is the bearer token every request must carry. An agent without
+	// one rejects every request.
+	Token string
+
+	mu       sync.Mutex
+	sessions map[string]*agentSession
+}
+
+// agentSession is a package directory commands run in.
+type agentSession struct {
+	dir     string
+	shipped bool // dir is a temporary copy, removed when the session ends
+}
+
+// Handler returns the agent's HTTP handler.
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/info", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(agentInfo{Shared: a.Root != ""})
+	})
+	mux.HandleFunc("POST /v1/sessions", a.createSession)
+	mux.HandleFunc("POST /v1/sessions/{id}/run", a.runCommand)
+	mux.HandleFunc("DELETE /v1/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		s, ok := a.sessions[r.PathValue("id")]
+		delete(a.sessions, r.PathValue("id"))
+		a.mu.Unlock()
+		if ok && s.shipped {
+			os.RemoveAll(s.dir)
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.Token)) != 1 {
+			http.Error(w, "missing or wrong "+AgentTokenEnv, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Close deletes every shipped package dir still held by a session.
+func (a *Agent) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, s := range a.sessions {
+		if s.shipped {
+			os.RemoveAll(s.dir)
+		}
+		delete(a.sessions, id)
+	}
+}
+
+func (a *Agent) createSession(w http.ResponseWriter, r *http.Request) {
+	rel := filepath.FromSlash(r.Header.Get(dirHeader))
+	if !filepath.IsLocal(rel) {
+		http.Error(w, fmt.Sprintf("invalid package dir %q", rel), http.StatusBadRequest)
+		return
+	}
+	s := &agentSession{}
+	if a.Root != "" {
+		s.dir = filepath.Join(a.Root, rel)
+		if info, err := os.Stat(s.dir); err != nil || !info.IsDir() {
+			http.Error(w, fmt.Sprintf("no directory %s in the agent's checkout", filepath.ToSlash(rel)), http.StatusNotFound)
+			return
+		}
+	} else {
+		dir, err := os.MkdirTemp("", "ux-agent-")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := unpackDir(r.Body, dir); err != nil {
+			os.RemoveAll(dir)
+			http.Error(w, "unpacking package: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.dir, s.shipped = dir, true
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	a.mu.Lock()
+	if a.sessions == nil {
+		a.sessions = make(map[string]*agentSession)
+	}
+	a.sessions[hex.EncodeToString(id)] = s
+	a.mu.Unlock()
+	logger.Debug("agent session", "id", hex.EncodeToString(id), "dir", filepath.ToSlash(rel), "shipped", s.shipped)
+	json.NewEncoder(w).Encode(map[string]string{"id": hex.EncodeToString(id)})
+}
+
+func (a *Agent) runCommand(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	s, ok := a.sessions[r.PathValue("id")]
+	a.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	var c remoteCommand
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cwd := filepath.FromSlash(c.Cwd)
+	if c.Cwd != "" && !filepath.IsLocal(cwd) {
+		http.Error(w, fmt.Sprintf("invalid cwd %q", c.Cwd), http.StatusBadRequest)
+		return
+	}
+	if c.Shell == "" {
+		c.Shell = "sh"
+	}
+
+	logger.Debug("agent command", "dir", s.dir, "cmd", c.Cmd)
+	w.Header().Set("Trailer", exitCodeTrailer)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	out := &flushWriter{w: w}
+	cmd := exec.CommandContext(r.Context(), c.Shell, "-c", c.Cmd)
+	cmd.Dir = filepath.Join(s.dir, cwd)
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	killGroupOnCancel(cmd)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fmt.Fprintf(out, "ux agent: %v\n", err)
+	}
+	w.Header().Set(exitCodeTrailer, strconv.Itoa(exitCode(err)))
+}
+
+// flushWriter sends each write to the client as it happens. A command's
+// stdout and stderr share one, so writes are serialized.
+type flushWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// unpackDir extracts a gzipped tar written by packDir into dir. Only
+// regular files with paths inside dir are accepted.
+func unpackDir(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !filepath.IsLocal(name) {
+			return fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ux
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteRun(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "svc")
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	writeFile(t, filepath.Join(dir, "src", "main.txt"), "hello\n")
+	writeFile(t, filepath.Join(dir, ".gitignore"), "secret\n")
+	writeFile(t, filepath.Join(dir, "secret"), "")
+	pkg := Package{Label: "//svc", Dir: dir, Env: map[string]string{"GREETING": "hi"}, Tasks: map[string]Task{
+		"test": {Cmds: []string{"cat src/main.txt; echo $GREETING >&2", "ls; exit 3"}},
+		"src":  {Cmds: []string{"ls"}, Cwd: "src"},
+	}}
+
+	for _, shared := range []bool{false, true} {
+		agent := &Agent{Token: "s3cret"}
+		if shared {
+			agent.Root = root
+		}
+		srv := httptest.NewServer(agent.Handler())
+		t.Setenv(AgentTokenEnv, "s3cret")
+		remote := NewRemote(strings.TrimPrefix(srv.URL, "http://"))
+
+		r := executeBuffered("test", pkg, RunOptions{Remote: remote}, nil)
+		if r.Success || r.FailedStep != "ls; exit 3" || len(r.Steps) != 2 || r.Steps[1].ExitCode != 3 {
+			t.Errorf("shared=%v: result = %+v", shared, r)
+		}
+		if got := r.Steps[0].Output; got != "hello\nhi\n" {
+			t.Errorf("shared=%v: step 1 output = %q", shared, got)
+		}
+		// Ignored files aren't shipped
+		if got, want := r.Steps[1].Output, "src\nux.toml\n"; !shared && got != want {
+			t.Errorf("shipped listing = %q, want %q", got, want)
+		}
+		if r := executeBuffered("src", pkg, RunOptions{Remote: remote}, nil); !r.Success || r.Output != "main.txt\n" {
+			t.Errorf("shared=%v: cwd result = %+v", shared, r)
+		}
+		if len(agent.sessions) != 0 {
+			t.Errorf("shared=%v: %d sessions left open", shared, len(agent.sessions))
+		}
+
+		t.Setenv(AgentTokenEnv, "wrong")
+		if err := NewRemote(remote.Addr).Check(); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("wrong token: err = %v", err)
+		}
+		srv.Close()
+	}
+}
+
+func TestAgentWithoutTokenRejectsRequests(t *testing.T) {
+	srv := httptest.NewServer((&Agent{}).Handler())
+	defer srv.Close()
+	t.Setenv(AgentTokenEnv, "")
+	if err := NewRemote(strings.TrimPrefix(srv.URL, "http://")).Check(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want 401", err)
+	}
+}
+
+func TestUnpackDirRejectsEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+	gz.Close()
+
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "pkg")
+	if err := unpackDir(&buf, dst); err == nil {
+		t.Error("expected an error for an entry outside the dir")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+		t.Error("../evil was written")
+	}
+}
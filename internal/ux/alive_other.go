@@ -0,0 +1,17 @@
+//go:build !unix
+
+package ux
+
+import "os"
+
+// processAlive reports whether a process with the given ID is running.
+// Without signal 0, it relies on FindProcess, which on Windows opens the
+// process and fails if there is none.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package ux
+
+import (
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given ID is running, by
+// sending it signal 0.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return proc.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,30 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// collectArtifacts copies the package files matching globs into
+// <logDir>/<task>/<label>/, next to the failure log, replacing any artifacts
+// already there. It returns the directory, or "" if nothing matched.
+func collectArtifacts(logDir, task string, pkg Package, globs []string) (string, error) {
+	dest := filepath.Join(taskLogDir(logDir, task), labelFileName(pkg.Label))
+	if err := os.RemoveAll(dest); err != nil {
+		return "", err
+	}
+	files, err := globFiles(pkg.Dir, globs)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	for _, rel := range files {
+		src := filepath.Join(pkg.Dir, filepath.FromSlash(rel))
+		if err := copyFile(src, filepath.Join(dest, filepath.FromSlash(rel))); err != nil {
+			return dest, err
+		}
+	}
+	return dest, nil
+}
@@ -0,0 +1,32 @@
+package ux
+
+import "fmt"
+
+// BehaviorConfig is the root config's [behavior] table: workspace-wide
+// defaults for how runs behave.
+type BehaviorConfig struct {
+	// EmptySelection is what happens when a run selects no packages:
+	// EmptySelectionOK (the default) exits 0, EmptySelectionError exits
+	// non-zero as --strict does.
+	EmptySelection string `toml:"empty_selection"`
+}
+
+const (
+	EmptySelectionOK    = "ok"
+	EmptySelectionError = "error"
+)
+
+// validate reports an unknown [behavior] value.
+func (c BehaviorConfig) validate() error {
+	switch c.EmptySelection {
+	case "", EmptySelectionOK, EmptySelectionError:
+		return nil
+	}
+	return fmt.Errorf("[behavior] empty_selection must be %q or %q, got %q",
+		EmptySelectionOK, EmptySelectionError, c.EmptySelection)
+}
+
+// StrictEmptySelection reports whether an empty selection is an error.
+func (c BehaviorConfig) StrictEmptySelection() bool {
+	return c.EmptySelection == EmptySelectionError
+}
@@ -0,0 +1,42 @@
+package ux
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRootConfigBehavior(t *testing.T) {
+	tests := []struct {
+		value   string
+		strict  bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{`"ok"`, false, false},
+		{`"error"`, true, false},
+		{`"fail"`, false, true},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		content := "[workspace]\nmembers = []\n"
+		if tt.value != "" {
+			content += "\n[behavior]\nempty_selection = " + tt.value + "\n"
+		}
+		writeFile(t, filepath.Join(root, "ux.toml"), content)
+
+		cfg, err := LoadRootConfig(root)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "empty_selection") {
+				t.Errorf("empty_selection = %s: err = %v, want an empty_selection error", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("empty_selection = %s: %v", tt.value, err)
+		}
+		if got := cfg.Behavior.StrictEmptySelection(); got != tt.strict {
+			t.Errorf("empty_selection = %s: StrictEmptySelection() = %v, want %v", tt.value, got, tt.strict)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package ux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheEntry is the metadata stored alongside a cached task result.
+type cacheEntry struct {
+	Task       string    `json:"task"`
+	Label      string    `json:"label"`
+	Key        string    `json:"key"`
+	Time       time.Time `json:"time"`
+	DurationMs int64     `json:"duration_ms"`
+	// LogSHA256 and Outputs (slash-separated path → SHA-256) let
+	// `ux cache verify` detect entries changed since they were stored.
+	LogSHA256 string            `json:"log_sha256,omitempty"`
+	Outputs   map[string]string `json:"outputs,omitempty"`
+}
+
+// CacheDir returns the task cache directory for a workspace: .ux/cache, or
+// UX_CACHE_DIR, relative to the root unless absolute.
+func CacheDir(root string) string {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return envDir(root, dir)
+	}
+	return filepath.Join(root, StateDir, "cache")
+}
+
+// cacheEnabled reports whether a task's results are cached. Caching is on
+// when the task declares inputs or outputs, unless cache = false.
+func (c TaskConfig) cacheEnabled() bool {
+	if c.Cache != nil {
+		return *c.Cache
+	}
+	return len(c.Inputs) > 0 || len(c.Outputs) > 0
+}
+
+// cacheKey hashes everything that determines a task's result: its commands,
+// how they run, extra args, package env, and the contents of its input files.
+// The input files of the packages in also count too, such as those sharing
+// a run_once run, whose result is pkg's, and those it depends on (see
+// dependencyPackages).
+func cacheKey(task string, pkg Package, cfg TaskConfig, extraArgs []string, also []Package) (string, error) {
+	h := sha256.New()
+	writeTaskCommand(h, task, pkg, extraArgs)
+
+	if err := writeInputFiles(h, pkg, cfg); err != nil {
+		return "", err
+	}
+	for _, other := range also {
+		fmt.Fprintf(h, "package\x00%s\x00", other.Label)
+		if err := writeInputFiles(h, other, cfg); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dependencyPackages returns the packages of workspace that any of pkgs
+// depends on (Package.Deps) or generates code from (Package.GeneratedFrom),
+// directly or through others, in label order and leaving out pkgs
+// themselves. Labels not in workspace are skipped.
+func dependencyPackages(pkgs []Package, workspace []Package) []Package {
+	byLabel := make(map[string]Package, len(workspace))
+	for _, p := range workspace {
+		byLabel[p.Label] = p
+	}
+	seen := make(map[string]bool)
+	for _, p := range pkgs {
+		seen[p.Label] = true
+	}
+	var deps []Package
+	queue := append([]Package(nil), pkgs...)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		var next []Package
+		for _, label := range p.Deps {
+			if dep, ok := byLabel[label]; ok {
+				next = append(next, dep)
+			}
+		}
+		next = append(next, FilterByLabels(workspace, p.GeneratedFrom)...)
+		for _, dep := range next {
+			if seen[dep.Label] {
+				continue
+			}
+			seen[dep.Label] = true
+			deps = append(deps, dep)
+			queue = append(queue, dep)
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Label < deps[j].Label })
+	return deps
+}
+
+// writeInputFiles writes to h the path and hash of each of pkg's input
+// files for a task configured by cfg.
+func writeInputFiles(h io.Writer, pkg Package, cfg TaskConfig) error {
+	files, err := cacheInputFiles(pkg.Dir, cfg)
+	if err != nil {
+		return err
+	}
+	sums, err := hashFiles(pkg.root(), pkg.Dir, files)
+	if err != nil {
+		return err
+	}
+	for i, rel := range files {
+		fmt.Fprintf(h, "file\x00%s\x00%s\x00", rel, sums[i])
+	}
+	return nil
+}
+
+// hashFiles returns the hex SHA-256 of each of the files rels under dir,
+// from the running `ux daemon` of the workspace at root when there is one:
+// it remembers the hashes of files that haven't changed since it read them.
+func hashFiles(root, dir string, rels []string) ([]string, error) {
+	paths := make([]string, len(rels))
+	for i, rel := range rels {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(rel))
+	}
+	if sums, ok := daemonHashes(root, paths); ok {
+		return sums, nil
+	}
+	sums := make([]string, len(paths))
+	for i, path := range paths {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+		sums[i] = sum
+	}
+	return sums, nil
+}
+
+// writeTaskCommand writes to h what determines how a package's task runs:
+// its commands, cwd, shell, extra args, and the package env.
+func writeTaskCommand(h io.Writer, task string, pkg Package, extraArgs []string) {
+	t := pkg.Tasks[task]
+	fmt.Fprintf(h, "task\x00%s\x00cwd\x00%s\x00shell\x00%s\x00", task, t.Cwd, t.Shell)
+	for _, c := range t.Cmds {
+		fmt.Fprintf(h, "cmd\x00%s\x00", c)
+	}
+	for _, n := range t.Steps {
+		fmt.Fprintf(h, "step\x00%d\x00", n)
+	}
+	for _, a := range extraArgs {
+		fmt.Fprintf(h, "arg\x00%s\x00", a)
+	}
+	envKeys := make([]string, 0, len(pkg.Env))
+	for k := range pkg.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	for _, k := range envKeys {
+		fmt.Fprintf(h, "env\x00%s=%s\x00", k, pkg.Env[k])
+	}
+}
+
+// cacheInputFiles lists the package files that feed the cache key, as sorted
+// slash-separated paths. Without inputs globs, every file counts except
+// declared outputs. Files matched by .gitignore or .uxignore never count.
+func cacheInputFiles(dir string, cfg TaskConfig) ([]string, error) {
+	return walkPackageFiles(dir, ".", true, newIgnoreMatcher(dir), func(rel string) bool {
+		if len(cfg.Inputs) > 0 {
+			return matchAnyGlob(cfg.Inputs, rel)
+		}
+		return !matchAnyGlob(cfg.Outputs, rel)
+	})
+}
+
+// globFiles lists the package files matching globs such as task outputs.
+// These commonly live in directories discovery skips (dist, build, .next),
+// so each glob's static base is walked in full.
+func globFiles(dir string, globs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, g := range globs {
+		base := globBase(g)
+		if seen[base] {
+			continue
+		}
+		seen[base] = true
+		found, err := walkPackageFiles(dir, base, base == ".", nil, func(rel string) bool {
+			return matchAnyGlob(globs, rel)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// globBase returns the leading path of a glob that contains no wildcards:
+// "dist/**" → "dist", "dist" → "dist", "**/*.js" → ".".
+func globBase(pattern string) string {
+	segs := strings.Split(strings.Trim(strings.TrimPrefix(pattern, "./"), "/"), "/")
+	var base []string
+	for _, seg := range segs {
+		if strings.ContainsAny(seg, "*?[") {
+			break
+		}
+		base = append(base, seg)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	return path.Join(base...)
+}
+
+// walkPackageFiles returns sorted slash-separated paths, relative to dir, of
+// regular files under dir/sub accepted by keep. With skipJunk, hidden and
+// junk directories are skipped. A non-nil ignore also skips what its rules
+// exclude.
+func walkPackageFiles(dir, sub string, skipJunk bool, ignore *ignoreMatcher, keep func(rel string) bool) ([]string, error) {
+	start := filepath.Join(dir, filepath.FromSlash(sub))
+	var files []string
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			name := d.Name()
+			if p != start && (skipJunk && (strings.HasPrefix(name, ".") || skipDirs[name]) ||
+				ignore != nil && ignore.ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+			if ignore != nil {
+				ignore.load(rel)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if ignore != nil && ignore.ignored(rel, false) {
+			return nil
+		}
+		if keep(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// cacheEntryDir is where a cached result for a package's task is stored.
+func cacheEntryDir(cacheDir, task, label, key string) string {
+	return filepath.Join(cacheDir, task, labelFileName(label), key)
+}
+
+// restoreCached replays a cached result: outputs are copied back into the
+// package and the recorded log is returned open, for the caller to read and
+// close. ok is false on a cache miss.
+func restoreCached(cacheDir, task string, pkg Package, key string) (log *os.File, ok bool) {
+	entry := cacheEntryDir(cacheDir, task, pkg.Label, key)
+	log, err := os.Open(filepath.Join(entry, "output.log"))
+	if err != nil {
+		return nil, false
+	}
+	outputs := filepath.Join(entry, "outputs")
+	if _, err := os.Stat(outputs); err == nil {
+		if err := copyTree(outputs, pkg.Dir); err != nil {
+			log.Close()
+			return nil, false
+		}
+	}
+	return log, true
+}
+
+// storeCached saves a successful result: its log, metadata, and any files
+// matching the task's outputs globs.
+func storeCached(cacheDir, task string, cfg TaskConfig, r Result, key string) error {
+	entry := cacheEntryDir(cacheDir, task, r.Package.Label, key)
+	// Replace any earlier entries for this package so the cache doesn't grow unbounded
+	os.RemoveAll(filepath.Dir(entry))
+	if err := os.MkdirAll(entry, 0755); err != nil {
+		return err
+	}
+
+	outputs := make(map[string]string)
+	if len(cfg.Outputs) > 0 {
+		files, err := globFiles(r.Package.Dir, cfg.Outputs)
+		if err != nil {
+			return err
+		}
+		for _, rel := range files {
+			src := filepath.Join(r.Package.Dir, filepath.FromSlash(rel))
+			dst := filepath.Join(entry, "outputs", filepath.FromSlash(rel))
+			if err := copyFile(src, dst); err != nil {
+				return err
+			}
+			if outputs[rel], err = fileSHA256(dst); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The log goes in under a temporary name, hashed on the way
+	logPath := filepath.Join(entry, "output.log")
+	log, err := os.Create(logPath + ".tmp")
+	if err != nil {
+		return err
+	}
+	logSum := sha256.New()
+	err = r.WriteOutput(io.MultiWriter(log, logSum))
+	if cerr := log.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.MarshalIndent(cacheEntry{
+		Task:       task,
+		Label:      r.Package.Label,
+		Key:        key,
+		Time:       time.Now(),
+		DurationMs: r.Duration.Milliseconds(),
+		LogSHA256:  hex.EncodeToString(logSum.Sum(nil)),
+		Outputs:    outputs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(entry, "meta.json"), meta, 0644); err != nil {
+		return err
+	}
+	// In place last: its presence marks the entry as complete
+	return os.Rename(logPath+".tmp", logPath)
+}
+
+// copyTree copies every regular file under src into dst, preserving layout.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		return copyFile(p, filepath.Join(dst, rel))
+	})
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's contents.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// matchAnyGlob reports whether a slash-separated path matches any of the globs.
+func matchAnyGlob(globs []string, rel string) bool {
+	for _, g := range globs {
+		if matchGlob(g, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob where "**" matches
+// any number of path segments and other segments use path.Match syntax.
+// A trailing "/" or a bare directory name matches everything beneath it.
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	// A pattern naming a directory covers the files inside it
+	return true
+}
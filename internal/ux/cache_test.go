@@ -0,0 +1,238 @@
+package ux
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"dist/**", "dist/index.js", true},
+		{"dist/**", "dist/a/b/c.js", true},
+		{"dist/", "dist/a/b.js", true},
+		{"dist", "dist/a.js", true},
+		{"dist/**", "src/dist/a.js", false},
+		{"**/*.py", "a.py", true},
+		{"**/*.py", "pkg/sub/a.py", true},
+		{"**/*.py", "pkg/a.pyc", false},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/x/main.go", false},
+		{"./src/**", "src/main.go", true},
+		{"*.md", "README.md", true},
+		{"*.md", "docs/README.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"dist/**", "dist"},
+		{"dist", "dist"},
+		{"dist/", "dist"},
+		{".next/cache/*.json", ".next/cache"},
+		{"**/*.js", "."},
+		{"*.whl", "."},
+	}
+	for _, tt := range tests {
+		if got := globBase(tt.pattern); got != tt.want {
+			t.Errorf("globBase(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCacheInputFilesIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//pkg\"]\n")
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n/pkg/scratch/\n")
+	dir := filepath.Join(root, "pkg")
+	writeFile(t, filepath.Join(dir, ".gitignore"), "# generated\ngen/\n!keep.log\n")
+	writeFile(t, filepath.Join(dir, ".uxignore"), "notes.txt\n")
+	writeFile(t, filepath.Join(dir, "sub", ".gitignore"), "/local.txt\n")
+	for _, f := range []string{
+		"main.py", "app.log", "keep.log", "notes.txt", "local.txt",
+		"gen/out.py", "scratch/tmp.py", "sub/local.txt", "sub/other.txt", "sub/notes.txt",
+		"node_modules/x/index.js",
+	} {
+		writeFile(t, filepath.Join(dir, filepath.FromSlash(f)), f)
+	}
+
+	files, err := cacheInputFiles(dir, TaskConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".gitignore", ".uxignore", "keep.log", "local.txt", "main.py", "sub/.gitignore", "sub/other.txt"}
+	if !slices.Equal(files, want) {
+		t.Errorf("cacheInputFiles = %v, want %v", files, want)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\n")
+	pkg := func(name string, deps ...string) Package {
+		dir := filepath.Join(root, name)
+		writeFile(t, filepath.Join(dir, "main.py"), name)
+		return Package{Label: "//" + name, Dir: dir, Deps: deps, Tasks: map[string]Task{"test": {Cmds: []string{"pytest"}}}}
+	}
+	app, lib, core, other := pkg("app", "//lib"), pkg("lib", "//core"), pkg("core"), pkg("other")
+	workspace := []Package{app, lib, core, other}
+	cfg := TaskConfig{Inputs: []string{"**/*.py"}}
+	key := func(p Package, args ...string) string {
+		t.Helper()
+		k, err := cacheKey("test", p, cfg, args, dependencyPackages([]Package{p}, workspace))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	if deps := dependencyPackages([]Package{app}, workspace); len(deps) != 2 || deps[0].Label != "//core" || deps[1].Label != "//lib" {
+		t.Errorf("dependencyPackages(//app) = %v, want //core and //lib", deps)
+	}
+
+	base := key(app)
+	if key(app) != base {
+		t.Error("key changed without any change")
+	}
+	if key(app, "-x") == base {
+		t.Error("key ignores extra args")
+	}
+	writeFile(t, filepath.Join(other.Dir, "main.py"), "changed")
+	if key(app) != base {
+		t.Error("key changed with a package //app doesn't depend on")
+	}
+	writeFile(t, filepath.Join(core.Dir, "main.py"), "changed")
+	if key(app) == base {
+		t.Error("key ignores a transitive dependency's inputs")
+	}
+	base = key(app)
+	writeFile(t, filepath.Join(core.Dir, "notes.txt"), "not an input")
+	if key(app) != base {
+		t.Error("key changed with a file that isn't an input")
+	}
+	app.Tasks["test"] = Task{Cmds: []string{"pytest -x"}}
+	if key(app) == base {
+		t.Error("key ignores the command")
+	}
+}
+
+func TestStoreRestoreCached(t *testing.T) {
+	cacheDir := t.TempDir()
+	dir := t.TempDir()
+	pkg := Package{Label: "//web", Dir: dir}
+	cfg := TaskConfig{Outputs: []string{"dist/**"}}
+	writeFile(t, filepath.Join(dir, "dist", "app.js"), "built\n")
+
+	if _, ok := restoreCached(cacheDir, "build", pkg, "k1"); ok {
+		t.Fatal("restored from an empty cache")
+	}
+	if err := storeCached(cacheDir, "build", cfg, Result{Package: pkg, Success: true, Output: "compiled 1 file\n"}, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "dist")); err != nil {
+		t.Fatal(err)
+	}
+
+	log, ok := restoreCached(cacheDir, "build", pkg, "k1")
+	if !ok {
+		t.Fatal("cache miss after storing")
+	}
+	output, err := io.ReadAll(log)
+	log.Close()
+	if err != nil || string(output) != "compiled 1 file\n" {
+		t.Errorf("restored log = %q, %v", output, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "dist", "app.js")); err != nil || string(data) != "built\n" {
+		t.Errorf("restored output = %q, %v", data, err)
+	}
+	if _, ok := restoreCached(cacheDir, "build", pkg, "k2"); ok {
+		t.Error("restored under another key")
+	}
+
+	// A new entry replaces the package's old one
+	if err := storeCached(cacheDir, "build", cfg, Result{Package: pkg, Success: true}, "k2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restoreCached(cacheDir, "build", pkg, "k1"); ok {
+		t.Error("old entry still restorable")
+	}
+}
+
+func TestRunTaskCacheDeps(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\n")
+	appDir, libDir := filepath.Join(root, "app"), filepath.Join(root, "lib")
+	writeFile(t, filepath.Join(appDir, "ux.toml"), "")
+	writeFile(t, filepath.Join(appDir, "main.py"), "import lib\n")
+	writeFile(t, filepath.Join(libDir, "lib.py"), "x = 1\n")
+	app := Package{Label: "//app", Dir: appDir, Deps: []string{"//lib"}, Tasks: map[string]Task{"test": {Cmds: []string{"echo ran"}}}}
+	lib := Package{Label: "//lib", Dir: libDir}
+	opts := RunOptions{Quiet: true, CacheDir: t.TempDir(), LogDir: t.TempDir(), Workspace: []Package{app, lib}}
+	cfg := TaskConfig{Inputs: []string{"**/*.py"}}
+
+	cached := func() bool {
+		t.Helper()
+		results := RunTask("test", []Package{app}, cfg, opts)
+		if len(results) != 1 || !results[0].Success {
+			t.Fatalf("results = %+v", results)
+		}
+		return results[0].Cached
+	}
+	if cached() {
+		t.Fatal("first run was cached")
+	}
+	if !cached() {
+		t.Fatal("unchanged run wasn't cached")
+	}
+	writeFile(t, filepath.Join(libDir, "lib.py"), "x = 2\n")
+	if cached() {
+		t.Error("run after changing a dependency was cached")
+	}
+}
+
+func TestRunTaskCacheGeneratedFrom(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\n")
+	apiDir, protoDir := filepath.Join(root, "api"), filepath.Join(root, "proto")
+	writeFile(t, filepath.Join(apiDir, "ux.toml"), "")
+	writeFile(t, filepath.Join(protoDir, "ux.toml"), "")
+	writeFile(t, filepath.Join(protoDir, "a.proto"), "message A {}\n")
+	api := Package{Label: "//api", Dir: apiDir, GeneratedFrom: []string{"//proto"}, Tasks: map[string]Task{"generate": {Cmds: []string{"echo ran"}}}}
+	proto := Package{Label: "//proto", Dir: protoDir}
+	opts := RunOptions{Quiet: true, CacheDir: t.TempDir(), LogDir: t.TempDir(), Workspace: []Package{api, proto}}
+	cache := true
+	cfg := TaskConfig{Cache: &cache}
+
+	cached := func() bool {
+		t.Helper()
+		results := RunTask("generate", []Package{api}, cfg, opts)
+		if len(results) != 1 || !results[0].Success {
+			t.Fatalf("results = %+v", results)
+		}
+		return results[0].Cached
+	}
+	if cached() {
+		t.Fatal("first run was cached")
+	}
+	if !cached() {
+		t.Fatal("unchanged run wasn't cached")
+	}
+	writeFile(t, filepath.Join(protoDir, "a.proto"), "message A { string id = 1; }\n")
+	if cached() {
+		t.Error("run after changing a generated_from proto was cached")
+	}
+}
@@ -0,0 +1,287 @@
+package ux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheHitSamples is how many recent runs per package `ux cache status`
+// counts toward a task's hit rate.
+const cacheHitSamples = 10
+
+// CachedEntry is one stored result in the task cache, as found on disk.
+type CachedEntry struct {
+	Task  string
+	Label string // label file name, e.g. "services-api"
+	Key   string
+	Dir   string
+	Time  time.Time // when it was stored
+	Size  int64     // bytes on disk
+}
+
+// ListCache returns every entry in the task cache, sorted by task, package,
+// and key. An entry whose metadata can't be read is dated by its directory.
+func ListCache(cacheDir string) ([]CachedEntry, error) {
+	dirs, err := filepath.Glob(filepath.Join(cacheDir, "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CachedEntry
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		e := CachedEntry{
+			Task:  filepath.Base(filepath.Dir(filepath.Dir(dir))),
+			Label: filepath.Base(filepath.Dir(dir)),
+			Key:   filepath.Base(dir),
+			Dir:   dir,
+			Time:  info.ModTime(),
+		}
+		if meta, err := readCacheEntry(dir); err == nil && !meta.Time.IsZero() {
+			e.Time = meta.Time
+		}
+		e.Size, err = dirSize(dir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readCacheEntry reads an entry's meta.json.
+func readCacheEntry(dir string) (*cacheEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	var meta cacheEntry
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// CacheTaskStatus summarizes one task's cache for `ux cache status`.
+type CacheTaskStatus struct {
+	Task    string
+	Entries int
+	Size    int64
+	// Runs and Hits count recent recorded runs of the task and how many of
+	// them were replayed from the cache (see cacheHitSamples).
+	Runs, Hits int
+}
+
+// CacheStatus summarizes the cache per task, with hit rates from the run
+// history, sorted by task.
+func CacheStatus(entries []CachedEntry, history *History) []CacheTaskStatus {
+	byTask := make(map[string]*CacheTaskStatus)
+	for _, e := range entries {
+		s := byTask[e.Task]
+		if s == nil {
+			s = &CacheTaskStatus{Task: e.Task}
+			byTask[e.Task] = s
+		}
+		s.Entries++
+		s.Size += e.Size
+	}
+	var statuses []CacheTaskStatus
+	for task, s := range byTask {
+		for _, runs := range history.Tasks[task] {
+			if len(runs) > cacheHitSamples {
+				runs = runs[len(runs)-cacheHitSamples:]
+			}
+			for _, r := range runs {
+				s.Runs++
+				if r.Cached {
+					s.Hits++
+				}
+			}
+		}
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Task < statuses[j].Task })
+	return statuses
+}
+
+// CleanCache removes cache entries, only those of task if it's non-empty
+// and only those stored more than olderThan ago if it's positive. It
+// returns how many entries were removed and the bytes freed.
+func CleanCache(entries []CachedEntry, task string, olderThan time.Duration) (removed int, freed int64, err error) {
+	cutoff := time.Now().Add(-olderThan)
+	for _, e := range entries {
+		if task != "" && e.Task != task {
+			continue
+		}
+		if olderThan > 0 && e.Time.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(e.Dir); err != nil {
+			return removed, freed, err
+		}
+		// Drop the package and task directories once they're empty
+		os.Remove(filepath.Dir(e.Dir))
+		os.Remove(filepath.Dir(filepath.Dir(e.Dir)))
+		removed++
+		freed += e.Size
+	}
+	return removed, freed, nil
+}
+
+// CacheProblem is a corrupted cache entry found by VerifyCache.
+type CacheProblem struct {
+	Entry  CachedEntry
+	Reason string
+}
+
+// VerifyCache checks that each entry is complete and unchanged since it was
+// stored: its metadata matches its location, and its log and output files
+// match the checksums recorded with it. Entries stored before checksums
+// were recorded are only checked for completeness.
+func VerifyCache(entries []CachedEntry) []CacheProblem {
+	var problems []CacheProblem
+	for _, e := range entries {
+		if reason := verifyCacheEntry(e); reason != "" {
+			problems = append(problems, CacheProblem{Entry: e, Reason: reason})
+		}
+	}
+	return problems
+}
+
+// verifyCacheEntry returns why an entry is corrupted, or "".
+func verifyCacheEntry(e CachedEntry) string {
+	log, err := os.ReadFile(filepath.Join(e.Dir, "output.log"))
+	if err != nil {
+		return "incomplete: no output.log"
+	}
+	meta, err := readCacheEntry(e.Dir)
+	if err != nil {
+		return fmt.Sprintf("unreadable meta.json: %v", err)
+	}
+	if meta.Task != e.Task || labelFileName(meta.Label) != e.Label || meta.Key != e.Key {
+		return fmt.Sprintf("meta.json describes %s %s, not this entry", meta.Task, meta.Label)
+	}
+	if meta.LogSHA256 == "" {
+		return "" // stored without checksums
+	}
+	if sum := sha256.Sum256(log); hex.EncodeToString(sum[:]) != meta.LogSHA256 {
+		return "output.log was modified"
+	}
+	var stored []string
+	outputs := filepath.Join(e.Dir, "outputs")
+	filepath.WalkDir(outputs, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			rel, _ := filepath.Rel(outputs, p)
+			stored = append(stored, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	for _, rel := range stored {
+		if _, ok := meta.Outputs[rel]; !ok {
+			return fmt.Sprintf("unexpected output %s", rel)
+		}
+	}
+	rels := make([]string, 0, len(meta.Outputs))
+	for rel := range meta.Outputs {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		sum, err := fileSHA256(filepath.Join(outputs, filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Sprintf("missing output %s", rel)
+		}
+		if sum != meta.Outputs[rel] {
+			return fmt.Sprintf("output %s was modified", rel)
+		}
+	}
+	return ""
+}
+
+// ParseAge parses an age such as "7d", "2w", or any time.ParseDuration
+// value ("36h").
+func ParseAge(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		v, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(v * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 7d, 2w, or 36h)", s)
+	}
+	return d, nil
+}
+
+// PrintCacheStatus prints `ux cache status`.
+func PrintCacheStatus(cacheDir string, statuses []CacheTaskStatus) {
+	fmt.Printf("\n%s %s\n\n", styleHeader.Render("ux cache"), styleDim.Render(cacheDir))
+	if len(statuses) == 0 {
+		fmt.Printf("  %s\n\n", styleDim.Render("empty"))
+		return
+	}
+	fmt.Printf("  %s\n", styleDim.Render(fmt.Sprintf("%-20s %8s %10s   %s", "task", "entries", "size", "hit rate (recent runs)")))
+	var entries int
+	var size int64
+	for _, s := range statuses {
+		rate := styleDim.Render("no runs recorded")
+		if s.Runs > 0 {
+			rate = fmt.Sprintf("%3.0f%% %s", float64(s.Hits)/float64(s.Runs)*100, styleDim.Render(fmt.Sprintf("of %d", s.Runs)))
+		}
+		fmt.Printf("  %-20s %8d %10s   %s\n", s.Task, s.Entries, FormatBytes(s.Size), rate)
+		entries += s.Entries
+		size += s.Size
+	}
+	fmt.Printf("\n  %s\n\n", styleDim.Render(fmt.Sprintf("%d entries, %s total", entries, FormatBytes(size))))
+}
+
+// FormatBytes formats a size in bytes: "512 B", "4.2 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
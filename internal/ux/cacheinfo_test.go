@@ -0,0 +1,82 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifyAndCleanCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "dist", "app.js"), "console.log(1)\n")
+	pkg := Package{Label: "//web", Dir: dir}
+	cfg := TaskConfig{Outputs: []string{"dist/**"}}
+	for _, task := range []string{"build", "lint"} {
+		if err := storeCached(cacheDir, task, cfg, Result{Package: pkg, Success: true, Output: "ok\n"}, "k1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := ListCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Task != "build" || entries[0].Label != "web" || entries[0].Size == 0 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if problems := VerifyCache(entries); len(problems) != 0 {
+		t.Fatalf("fresh cache has problems: %+v", problems)
+	}
+
+	writeFile(t, filepath.Join(entries[0].Dir, "outputs", "dist", "app.js"), "tampered\n")
+	os.Remove(filepath.Join(entries[1].Dir, "output.log"))
+	problems := VerifyCache(entries)
+	if len(problems) != 2 || problems[0].Reason != "output dist/app.js was modified" || problems[1].Reason != "incomplete: no output.log" {
+		t.Errorf("problems = %+v", problems)
+	}
+
+	if removed, _, err := CleanCache(entries, "lint", time.Hour); err != nil || removed != 0 {
+		t.Errorf("clean of new entries removed %d, %v", removed, err)
+	}
+	if removed, _, err := CleanCache(entries, "lint", 0); err != nil || removed != 1 {
+		t.Errorf("clean --task lint removed %d, %v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "lint")); !os.IsNotExist(err) {
+		t.Errorf("empty task directory left behind")
+	}
+	if left, _ := ListCache(cacheDir); len(left) != 1 || left[0].Task != "build" {
+		t.Errorf("left = %+v", left)
+	}
+}
+
+func TestCacheStatus(t *testing.T) {
+	entries := []CachedEntry{{Task: "build", Size: 10}, {Task: "build", Size: 5}}
+	history := &History{Tasks: map[string]map[string][]HistoryEntry{
+		"build": {"//a": {{Cached: false}, {Cached: true}}, "//b": {{Cached: true}}},
+	}}
+	got := CacheStatus(entries, history)
+	if len(got) != 1 || got[0].Entries != 2 || got[0].Size != 15 || got[0].Runs != 3 || got[0].Hits != 2 {
+		t.Errorf("status = %+v", got)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":   7 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"36h":  36 * time.Hour,
+		"1.5d": 36 * time.Hour,
+	}
+	for in, want := range tests {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "x", "-1d", "3x"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) succeeded", in)
+		}
+	}
+}
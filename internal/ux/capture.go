@@ -0,0 +1,174 @@
+package ux
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultOutputMemory is how many bytes of a package's output are kept in
+// memory before it spills to a file (see Result.OutputFile).
+const DefaultOutputMemory = 1 << 20
+
+// outputCapture collects a package's output in memory until it outgrows a
+// limit, then moves it to a file and appends the rest there, so a chatty
+// task across many packages doesn't hold all of their output at once.
+type outputCapture struct {
+	dir     string // where to spill; "" for the system temp dir
+	pattern string // spill file name, for os.CreateTemp
+	limit   int
+	buf     bytes.Buffer
+	file    *os.File
+	size    int64
+	failed  bool // spilling failed, so everything stays in memory
+}
+
+// newOutputCapture returns a capture for task's output on pkg, spilling
+// into the task's log directory when the run has one.
+func newOutputCapture(task string, pkg Package, opts RunOptions) *outputCapture {
+	c := &outputCapture{pattern: "ux-" + labelFileName(pkg.Label) + "-*.out", limit: opts.OutputMemory}
+	if c.limit <= 0 {
+		c.limit = DefaultOutputMemory
+	}
+	if opts.LogDir != "" {
+		c.dir = taskLogDir(opts.LogDir, task)
+		c.pattern = labelFileName(pkg.Label) + "-*.out"
+	}
+	return c
+}
+
+func (c *outputCapture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	if c.file == nil && !c.failed && c.buf.Len()+len(p) > c.limit {
+		c.spill()
+	}
+	if c.file == nil {
+		return c.buf.Write(p)
+	}
+	if _, err := c.file.Write(p); err != nil {
+		Warnf("cannot write output to %s: %v", c.file.Name(), err)
+	}
+	return len(p), nil
+}
+
+// spill moves the output so far to a new file, which takes the rest.
+func (c *outputCapture) spill() {
+	if c.dir != "" {
+		if err := os.MkdirAll(c.dir, 0755); err != nil {
+			Warnf("cannot spill output to disk: %v", err)
+			c.failed = true
+			return
+		}
+	}
+	f, err := os.CreateTemp(c.dir, c.pattern)
+	if err == nil {
+		_, err = f.Write(c.buf.Bytes())
+	}
+	if err != nil {
+		Warnf("cannot spill output to disk: %v", err)
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		c.failed = true
+		return
+	}
+	c.file = f
+	c.buf = bytes.Buffer{}
+}
+
+// Len is how many bytes have been written, in memory or not.
+func (c *outputCapture) Len() int64 {
+	return c.size
+}
+
+// since returns the output written after the first offset bytes, or "" once
+// it has spilled to a file.
+func (c *outputCapture) since(offset int64) string {
+	if c.file != nil {
+		return ""
+	}
+	return string(c.buf.Bytes()[offset:])
+}
+
+// finish returns the output held in memory, or the file it spilled to.
+func (c *outputCapture) finish() (output, file string) {
+	if c.file == nil {
+		return c.buf.String(), ""
+	}
+	c.file.Close()
+	return "", c.file.Name()
+}
+
+// HasOutput reports whether the command output anything.
+func (r Result) HasOutput() bool {
+	return r.Output != "" || r.OutputFile != ""
+}
+
+// WriteOutput copies the result's output to w, from memory or from
+// OutputFile.
+func (r Result) WriteOutput(w io.Writer) error {
+	if r.OutputFile == "" {
+		_, err := io.WriteString(w, r.Output)
+		return err
+	}
+	f, err := os.Open(r.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// writeOutputLines writes the result's output to w a line at a time, each
+// after prefix, leaving out trailing blank lines.
+func writeOutputLines(w io.Writer, r Result, prefix string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(r.WriteOutput(pw))
+	}()
+	defer pr.Close()
+	br := bufio.NewReader(pr)
+	blank := 0 // blank lines held back until a line follows them
+	for {
+		line, err := br.ReadString('\n')
+		if line == "\n" || (line == "" && err == nil) {
+			blank++
+			continue
+		}
+		if line != "" {
+			for ; blank > 0; blank-- {
+				io.WriteString(w, prefix+"\n")
+			}
+			io.WriteString(w, prefix+line)
+			if line[len(line)-1] != '\n' {
+				io.WriteString(w, "\n")
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// limitedBuffer keeps what's written to it until it outgrows limit, and
+// then nothing.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+	over  bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.over || b.Len()+len(p) > b.limit {
+		b.over = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,86 @@
+package ux
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExecuteBufferedSpillsOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	pkg := Package{Label: "//svc/api", Dir: dir, Tasks: map[string]Task{
+		"test": {Cmds: []string{"echo small", "seq 1 1000"}},
+	}}
+	var want strings.Builder
+	want.WriteString("small\n")
+	for i := 1; i <= 1000; i++ {
+		want.WriteString(strconv.Itoa(i) + "\n")
+	}
+
+	logDir := t.TempDir()
+	r := executeBuffered("test", pkg, RunOptions{OutputMemory: 64, LogDir: logDir}, nil)
+	if !r.Success || r.Output != "" || r.OutputFile == "" {
+		t.Fatalf("result = success %v, output %q, file %q; want the output spilled", r.Success, r.Output, r.OutputFile)
+	}
+	if filepath.Dir(r.OutputFile) != filepath.Join(logDir, "test") {
+		t.Errorf("spilled to %s, want the task's log directory", r.OutputFile)
+	}
+	data, err := os.ReadFile(r.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want.String() {
+		t.Errorf("spilled output has %d bytes, want %d", len(data), want.Len())
+	}
+	// Steps keep their own output only while it's in memory
+	if r.Steps[0].Output != "small\n" || r.Steps[1].Output != "" {
+		t.Errorf("step outputs = %q, %q", r.Steps[0].Output, r.Steps[1].Output)
+	}
+	var out bytes.Buffer
+	if err := r.WriteOutput(&out); err != nil || out.String() != want.String() {
+		t.Errorf("WriteOutput = %d bytes, %v", out.Len(), err)
+	}
+
+	// A successful result's spilled output is what the cache stores
+	cacheDir := t.TempDir()
+	if err := storeCached(cacheDir, "test", TaskConfig{}, r, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	log, ok := restoreCached(cacheDir, "test", pkg, "k1")
+	if !ok {
+		t.Fatal("no cached result")
+	}
+	defer log.Close()
+	out.Reset()
+	out.ReadFrom(log)
+	if out.String() != want.String() {
+		t.Errorf("cached log has %d bytes, want %d", out.Len(), want.Len())
+	}
+
+	// Output under the limit stays in memory
+	if r := executeBuffered("test", pkg, RunOptions{LogDir: logDir}, nil); r.OutputFile != "" || r.Output != want.String() {
+		t.Errorf("small output spilled to %q", r.OutputFile)
+	}
+}
+
+func TestWriteOutputLines(t *testing.T) {
+	for _, r := range []Result{
+		{Output: "a\n\nb\n\n\n"},
+		{OutputFile: filepath.Join(t.TempDir(), "out")},
+	} {
+		if r.OutputFile != "" {
+			writeFile(t, r.OutputFile, "a\n\nb")
+		}
+		var out bytes.Buffer
+		if err := writeOutputLines(&out, r, "  "); err != nil {
+			t.Fatal(err)
+		}
+		if want := "  a\n  \n  b\n"; out.String() != want {
+			t.Errorf("got %q, want %q", out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CleanTarget is something `ux clean` removes.
+type CleanTarget struct {
+	Path string
+	Size int64
+}
+
+// CleanTargets lists the ux-generated state in a workspace: the .ux
+// directory (cache, history, last-run summaries, run and discovery state),
+// the cache when UX_CACHE_DIR moves it elsewhere, and each run's logs when
+// [logs] dir moves them out of .ux.
+func CleanTargets(root string, logs LogsConfig) ([]CleanTarget, error) {
+	var targets []CleanTarget
+	add := func(path string) error {
+		size, err := dirSize(path)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, CleanTarget{Path: path, Size: size})
+		return nil
+	}
+
+	stateDir := filepath.Join(root, StateDir)
+	if _, err := os.Stat(stateDir); err == nil {
+		if err := add(stateDir); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if cache := CacheDir(root); !strings.HasPrefix(cache, stateDir+string(filepath.Separator)) {
+		if _, err := os.Stat(cache); err == nil {
+			if err := add(cache); err != nil {
+				return nil, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	runs, err := logs.listRuns(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		path := logs.RunDir(root, run)
+		if strings.HasPrefix(path, stateDir+string(filepath.Separator)) {
+			continue // removed with .ux
+		}
+		if err := add(path); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// RemoveTargets deletes what CleanTargets listed and returns the bytes freed.
+func RemoveTargets(targets []CleanTarget) (freed int64, err error) {
+	for _, t := range targets {
+		if err := os.RemoveAll(t.Path); err != nil {
+			return freed, err
+		}
+		freed += t.Size
+	}
+	return freed, nil
+}
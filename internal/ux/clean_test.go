@@ -0,0 +1,41 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanTargets(t *testing.T) {
+	root := t.TempDir()
+	if targets, err := CleanTargets(root, LogsConfig{}); err != nil || len(targets) != 0 {
+		t.Fatalf("empty workspace: targets = %+v, err = %v", targets, err)
+	}
+
+	writeFile(t, filepath.Join(root, StateDir, "history.json"), "{}")
+	logs := LogsConfig{Dir: "logs"}
+	writeFile(t, filepath.Join(root, "logs", "20250101-120000-1", "test", "a.log"), "fail\n")
+	writeFile(t, filepath.Join(root, "logs", "notes.txt"), "not a run\n")
+
+	targets, err := CleanTargets(root, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Path != filepath.Join(root, StateDir) || targets[0].Size != 2 ||
+		targets[1].Path != logs.RunDir(root, "20250101-120000-1") {
+		t.Fatalf("targets = %+v", targets)
+	}
+	if got, _ := CleanTargets(root, LogsConfig{}); len(got) != 1 {
+		t.Errorf("the default log directory is shared and shouldn't be cleaned; targets = %+v", got)
+	}
+
+	if freed, err := RemoveTargets(targets); err != nil || freed != targets[0].Size+targets[1].Size {
+		t.Fatalf("freed %d, err = %v", freed, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "logs", "notes.txt")); err != nil {
+		t.Errorf("removed a file that isn't a run's logs: %v", err)
+	}
+	if targets, _ := CleanTargets(root, logs); len(targets) != 0 {
+		t.Errorf("after removing, targets = %+v", targets)
+	}
+}
@@ -1,11 +1,18 @@
 package ux
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 )
@@ -15,39 +22,373 @@ type RootConfig struct {
 	Workspace WorkspaceConfig          `toml:"workspace"`
 	Tasks     map[string]TaskConfig    `toml:"tasks"`
 	Defaults  map[string]TypeDefaults  `toml:"defaults"`
+	Hooks     HooksConfig              `toml:"hooks"`
+	Docs      DocsConfig               `toml:"docs"`
+	Logs      LogsConfig               `toml:"logs"`
+	Affected  AffectedConfig           `toml:"affected"`
+	Behavior  BehaviorConfig           `toml:"behavior"`
+	Docker    DockerConfig             `toml:"docker"`
+	Executors ExecutorsConfig          `toml:"executors"`
+	Discovery DiscoveryConfig          `toml:"discovery"`
+	// Resources defines resource classes packages can name with [package]
+	// resources, as weights: heavy = 4. It adds to the built-in classes.
+	Resources map[string]int `toml:"resources"`
+	// RootTasks are tasks run once from the workspace root, in the same
+	// forms as a package's [tasks].
+	RootTasks map[string]interface{} `toml:"root-tasks"`
+	Types     map[string]TypeConfig  `toml:"types"`
+
+	// Which file each "<type>.<task>" default and each [types] entry came
+	// from, when includes are in play; see `ux why`.
+	defaultFrom map[string]string
+	typeFrom    map[string]string
 }
 
 type WorkspaceConfig struct {
 	Members []string `toml:"members"`
+	// Include lists workspace-relative config files (e.g. "apps/ux.toml")
+	// whose members, tasks, defaults, and hooks are merged into this one.
+	Include []string `toml:"include"`
+	// BuiltinDefaults enables ux's built-in tasks per type. Defaults to true.
+	BuiltinDefaults *bool `toml:"builtin_defaults"`
+	// Plugins names type plugins to load: "proto" runs ux-plugin-proto.
+	Plugins []string `toml:"plugins"`
+	// DiscoveryCache keeps directory listings and parsed package configs in
+	// .ux/index.json, so discovery only rereads what changed.
+	DiscoveryCache bool `toml:"discovery_cache"`
+	// Rescan ignores the discovery index and a running daemon, reading
+	// everything again (ux list --no-cache).
+	Rescan bool `toml:"-" json:"-"`
+	// MembersFrom names workspace manifests (go.work, Cargo.toml) whose
+	// modules or crates are added to Members.
+	MembersFrom []string `toml:"members_from"`
+	// ScopedNames renames packages that share their directory's name to
+	// the end of their path, long enough to be unique (services/api).
+	ScopedNames bool `toml:"scoped_names"`
 }
 
 type TaskConfig struct {
 	Parallel bool `toml:"parallel"`
+	// DependsOn lists tasks to run first: "lint" on the same package,
+	// "^build" on the package's deps.
+	DependsOn []string `toml:"depends_on"`
+	// Inputs and Outputs are package-relative globs ("src/**", "dist/").
+	// Declaring either enables caching; Cache overrides that either way.
+	Inputs  []string `toml:"inputs"`
+	Outputs []string `toml:"outputs"`
+	Cache   *bool    `toml:"cache"`
+	// OnFailureCollect are package-relative globs of debugging artifacts
+	// (test reports, screenshots) copied next to the failure log.
+	OnFailureCollect []string `toml:"on_failure_collect"`
+	// AllowFailure reports the task's failures without failing the run, so
+	// a new check can be introduced before every package passes it.
+	AllowFailure bool `toml:"allow_failure"`
+	// TTY runs the task's commands on a pseudo-terminal, like --pty.
+	TTY bool `toml:"tty"`
+	// Confirm asks before running the task (deploy, db-reset), unless --yes
+	// is given.
+	Confirm bool `toml:"confirm"`
+	// Description says what the task does, for `ux list` and for runs of
+	// tasks no selected package defines.
+	Description string `toml:"description"`
 }
 
 // TypeDefaults defines default tasks for a package type (e.g., python, go).
 type TypeDefaults struct {
-	Tasks map[string]interface{} `toml:"tasks"`
+	// Extends names a base type whose built-in and default tasks this type
+	// starts from, e.g. extends = "python" for [defaults.python-service].
+	Extends string                 `toml:"extends"`
+	Tasks   map[string]interface{} `toml:"tasks"`
+
+	include []string // set only for the [defaults] include entry
+}
+
+// defaultsIncludeKey is the [defaults] entry naming shared defaults files.
+const defaultsIncludeKey = "include"
+
+// UnmarshalTOML decodes a [defaults.<type>] table, or the [defaults] include
+// entry, which the decoder sees as one more type.
+func (d *TypeDefaults) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if tasks, ok := v["tasks"]; ok {
+			m, ok := tasks.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("[defaults.<type>] tasks must be a table")
+			}
+			d.Tasks = m
+		}
+		if ext, ok := v["extends"]; ok {
+			s, ok := ext.(string)
+			if !ok || s == "" {
+				return fmt.Errorf("[defaults.<type>] extends must name a type")
+			}
+			d.Extends = s
+		}
+	case string:
+		d.include = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("[defaults] include must list file paths")
+			}
+			d.include = append(d.include, s)
+		}
+	default:
+		return fmt.Errorf("[defaults] entries must be tables, or include")
+	}
+	return nil
 }
 
 // Package is a resolved workspace member with its tasks.
 type Package struct {
 	Name        string
+	Description string // [package] description, shown by `ux list`
 	Type        string // "python", "go", etc. May be empty for legacy packages.
 	Dir         string
-	Label       string // e.g. //packages/ingest
-	Tasks       map[string][]string
-	TaskSources map[string]string // "default" or "override" per task name
+	Label       string   // e.g. //packages/ingest
+	Deps        []string // labels of workspace packages this one depends on
+	// Owners are who to ask about the package, from [package] owners or
+	// else CODEOWNERS, e.g. ["@acme/data"].
+	Owners []string
+	// FlakyTasks are the tasks [package] flaky_tasks marks as flaky, whose
+	// failures --quarantine doesn't count.
+	FlakyTasks []string
+	// GeneratedFrom are the targets the package generates code from:
+	// changes under them affect it, and its build runs generate first.
+	GeneratedFrom []string
+	Env           map[string]string // extra environment for the package's tasks
+	Tasks         map[string]Task
+	// Weight is how much of the --jobs budget the package takes in a
+	// parallel task, from [package] weight or resources. Zero means 1.
+	Weight int
+	// Exclusive packages never run alongside another package, even in a
+	// parallel task ([package] parallel_safe = false), e.g. because they
+	// bind a fixed port.
+	Exclusive bool
+	// TaskSources says where each task came from: "builtin", "default",
+	// "override", "root-tasks", or the label of the package it was inherited
+	// from via extends.
+	TaskSources map[string]string
+
+	markers   []string          // marker files of the package's type, to notice removal
+	skipped   map[string]string // task → what skipped it, for `ux why`
+	typeChain []string          // Type preceded by the types it extends, base first
+	resources string            // [package] resources, resolved into Weight
+
+	// namedInConfig is whether [package] name set Name, rather than it
+	// being the directory's name or a scoped one (see checkPackageNames).
+	namedInConfig bool
+}
+
+// Task is a resolved task: its commands and how to run them.
+//
+// In TOML a task is a string (one command), an array of steps run in order,
+// or a table: { cmd = "pytest", cwd = "src", shell = "bash", mutex = "db" }.
+// run_once = true in the table runs it once for all packages that would run
+// it identically (see Task.RunOnce).
+// A step is a command, or an array of commands run concurrently:
+// [["ruff check .", "mypy ."], "pytest"].
+type Task struct {
+	Cmds  []string // every command, in declaration order
+	Cwd   string   // relative to the package dir, or absolute; empty means the package dir
+	Shell string   // runs each command as "<shell> -c <cmd>"; empty means "sh"
+	// Steps is how many consecutive Cmds make up each step; a step of more
+	// than one runs its commands concurrently. Nil means one command per step.
+	Steps []int
+	// Mutex names a shared resource (e.g. "database"): in a parallel task,
+	// packages whose task has the same mutex never run at the same time.
+	Mutex string
+	// Description says what the task does in this package, for `ux list`.
+	Description string
+	// WhenExists lists paths (globs allowed) relative to the package dir
+	// that must all exist for this definition to apply; see applies.
+	WhenExists []string
+	// RunOnce runs the task once for every package in a run that would run
+	// the same commands in the same directory, e.g. a linter over the whole
+	// repo, instead of once per package.
+	RunOnce bool
+}
+
+// applies reports whether the task's when condition holds in dir. A
+// definition that doesn't apply is skipped, leaving whatever a lower layer
+// (type defaults, built-ins) defines for the task, if anything.
+func (t Task) applies(dir string) bool {
+	for _, pattern := range t.WhenExists {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dir returns the directory the task runs in for the package in pkgDir. Cwd
+// is relative to the package, unless it's absolute, as with
+// cwd = "{workspace_root}".
+func (t Task) dir(pkgDir string) string {
+	if filepath.IsAbs(t.Cwd) {
+		return t.Cwd
+	}
+	return filepath.Join(pkgDir, t.Cwd)
+}
+
+// ArgsPlaceholder marks where in a task command the extra args given after
+// "--" go: test = "pytest {args} tests/". ${args} is left to the shell.
+const ArgsPlaceholder = "{args}"
+
+// replaceArgs returns c with args in place of each {args} that isn't part
+// of a shell ${args}, and whether there was any.
+func replaceArgs(c, args string) (string, bool) {
+	var b strings.Builder
+	placed := false
+	for {
+		i := strings.Index(c, ArgsPlaceholder)
+		if i < 0 {
+			break
+		}
+		end := i + len(ArgsPlaceholder)
+		if i > 0 && c[i-1] == '$' {
+			b.WriteString(c[:end])
+		} else {
+			b.WriteString(c[:i])
+			b.WriteString(args)
+			placed = true
+		}
+		c = c[end:]
+	}
+	b.WriteString(c)
+	return b.String(), placed
+}
+
+// CheckArgs returns an error if extra args can't be given to task in one of
+// packages: those ArgsFor gives them to must take them (see TakesArgs).
+func CheckArgs(task string, packages []Package, args, types []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	for _, pkg := range packages {
+		if ArgsFor(pkg, args, types) == nil {
+			continue
+		}
+		if t := pkg.Tasks[task]; !t.TakesArgs() {
+			return fmt.Errorf("cannot pass extra args (--) to multi-step task %q in %s (%d commands); mark where they go with %s",
+				task, pkg.Label, len(t.Cmds), ArgsPlaceholder)
+		}
+	}
+	return nil
 }
 
-// Marker files mapped to their type, checked in priority order.
-var markerPriority = []struct {
-	file     string
-	typeName string
-}{
+// TakesArgs reports whether the task can be given extra args: some of its
+// commands place them with {args}, or it has only one command to append
+// them to.
+func (t Task) TakesArgs() bool {
+	return len(t.Cmds) == 1 || t.placesArgs()
+}
+
+// placesArgs reports whether any of the task's commands has {args}.
+func (t Task) placesArgs() bool {
+	for _, c := range t.Cmds {
+		if _, ok := replaceArgs(c, ""); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ArgsFor returns the extra args pkg gets: args, unless types is set and
+// doesn't include the package's type (--args-for).
+func ArgsFor(pkg Package, args, types []string) []string {
+	if types != nil && !slices.Contains(types, pkg.Type) {
+		return nil
+	}
+	return args
+}
+
+// withArgs returns cmds, some of the task's, with args in place of {args}
+// (removing it when there are none), or, if no command of the task has
+// {args}, appended to each.
+func (t Task) withArgs(cmds, args []string) []string {
+	joined := strings.Join(args, " ")
+	placed := t.placesArgs()
+	result := make([]string, len(cmds))
+	for i, c := range cmds {
+		switch {
+		case placed:
+			c, _ = replaceArgs(c, joined)
+		case joined != "":
+			c += " " + joined
+		}
+		result[i] = c
+	}
+	return result
+}
+
+// steps returns the task's commands grouped into steps.
+func (t Task) steps() [][]string {
+	if t.Steps == nil {
+		steps := make([][]string, len(t.Cmds))
+		for i, c := range t.Cmds {
+			steps[i] = []string{c}
+		}
+		return steps
+	}
+	steps := make([][]string, 0, len(t.Steps))
+	i := 0
+	for _, n := range t.Steps {
+		steps = append(steps, t.Cmds[i:i+n])
+		i += n
+	}
+	return steps
+}
+
+// Marker files mapped to their built-in type, checked in priority order.
+// Dockerfile stays last, since many packages of other types have one.
+var markerPriority = []typeMarker{
 	{"pyproject.toml", "python"},
 	{"go.mod", "go"},
 	{"Cargo.toml", "rust"},
+	{"package.json", "node"},
+	{"buf.yaml", "proto"},
+	{"main.tf", "terraform"},
+	{".terraform.lock.hcl", "terraform"},
+	{"Dockerfile", "docker"},
+}
+
+// builtinDefaults are the tasks a detected type gets when neither the root
+// [defaults.<type>.tasks] nor the package's own ux.toml define them.
+var builtinDefaults = map[string]map[string]Task{
+	"go": {
+		"lint": {Cmds: []string{"go vet ./..."}},
+		"test": {Cmds: []string{"go test ./..."}},
+	},
+	"proto": {
+		"fmt":      {Cmds: []string{"buf format --diff --exit-code"}},
+		"lint":     {Cmds: []string{"buf lint"}},
+		"generate": {Cmds: []string{"buf generate"}},
+	},
+	"python": {
+		"lint": {Cmds: []string{"ruff check ."}},
+		"test": {Cmds: []string{"pytest"}},
+	},
+	"rust": {
+		"test": {Cmds: []string{"cargo test"}},
+	},
+	// terraform locks state while planning, so plans hold a mutex and never
+	// run concurrently, even when [tasks.plan] is parallel.
+	"terraform": {
+		"fmt":      {Cmds: []string{"terraform fmt -check -diff"}},
+		"validate": {Cmds: []string{"terraform init -backend=false -input=false", "terraform validate"}},
+		"plan":     {Cmds: []string{"terraform init -input=false", "terraform plan -input=false"}, Mutex: "terraform"},
+	},
+	"docker": {
+		"build": {Cmds: []string{"docker build -t {package_name} ."}},
+	},
+	"node": {
+		"build": {Cmds: []string{"npm run build --if-present"}},
+		"lint":  {Cmds: []string{"npm run lint --if-present"}},
+		"test":  {Cmds: []string{"npm run test --if-present"}},
+	},
 }
 
 // Directories to skip during recursive walks.
@@ -62,110 +403,176 @@ func FindWorkspaceRoot() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return FindWorkspaceRootFrom(dir)
+}
+
+// FindWorkspaceRootFrom walks up from dir looking for a ux.toml with
+// [workspace]. A workspace file included by a workspace further up belongs
+// to that outer workspace, so the search continues past it.
+func FindWorkspaceRootFrom(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	start := dir
+	root := ""
+	var broken error // the nearest workspace file that doesn't parse
 	for {
 		path := filepath.Join(dir, "ux.toml")
-		if _, err := os.Stat(path); err == nil {
-			var probe struct {
-				Workspace *WorkspaceConfig `toml:"workspace"`
-			}
-			if _, err := toml.DecodeFile(path, &probe); err == nil && probe.Workspace != nil {
-				return dir, nil
+		if isWorkspaceFile(path) {
+			if root == "" || includesFile(dir, filepath.Join(root, "ux.toml")) {
+				root = dir
 			}
+		} else if broken == nil {
+			broken = brokenWorkspaceFile(start, path)
 		}
 		parent := filepath.Dir(dir)
 		if parent == dir {
-			return "", fmt.Errorf("no workspace root found (looking for ux.toml with [workspace])")
+			break
 		}
 		dir = parent
 	}
+	if root == "" && broken != nil {
+		return "", broken
+	}
+	if root == "" {
+		return "", fmt.Errorf("no workspace root found (looking for ux.toml with [workspace])")
+	}
+	return root, nil
+}
+
+// brokenWorkspaceFile returns why the ux.toml at path doesn't parse, if it
+// looks like it's meant to hold [workspace], relative to dir.
+func brokenWorkspaceFile(dir, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.Contains(data, []byte("[workspace")) {
+		return nil
+	}
+	var probe map[string]any
+	if _, err := toml.Decode(string(data), &probe); err != nil {
+		return ConfigErrors{newConfigError(dir, path, string(data), err)}
+	}
+	return nil
 }
 
-// LoadRootConfig parses the root ux.toml.
+// isWorkspaceFile reports whether path is a ux.toml with a [workspace] table.
+func isWorkspaceFile(path string) bool {
+	var probe struct {
+		Workspace *WorkspaceConfig `toml:"workspace"`
+	}
+	_, err := toml.DecodeFile(path, &probe)
+	return err == nil && probe.Workspace != nil
+}
+
+// LoadRootConfig parses the root ux.toml and merges any included files.
 func LoadRootConfig(root string) (*RootConfig, error) {
 	var cfg RootConfig
-	_, err := toml.DecodeFile(filepath.Join(root, "ux.toml"), &cfg)
+	if err := decodeConfigFile(root, filepath.Join(root, "ux.toml"), &cfg); err != nil {
+		return nil, configErrors(err, "parsing root ux.toml")
+	}
+	cfg.applyEnv()
+	if err := cfg.Behavior.validate(); err != nil {
+		return nil, err
+	}
+	shared, err := cfg.includeSharedDefaults(root)
 	if err != nil {
-		return nil, fmt.Errorf("parsing root ux.toml: %w", err)
+		return nil, err
+	}
+	m := newConfigMerger(root, &cfg)
+	for key, file := range shared {
+		m.defaultFrom[key] = file
+	}
+	if err := m.includeAll(cfg.Workspace.Include, []string{"ux.toml"}); err != nil {
+		return nil, err
+	}
+	cfg.defaultFrom, cfg.typeFrom = m.defaultFrom, m.typeFrom
+	if err := cfg.Discovery.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.addManifestMembers(root); err != nil {
+		return nil, err
 	}
 	return &cfg, nil
 }
 
 // DiscoverPackages resolves workspace members into packages.
 // It finds directories that have a ux.toml OR a recognized marker file
-// (pyproject.toml, go.mod, Cargo.toml) and resolves their tasks using
+// (pyproject.toml, go.mod, Cargo.toml, package.json) and resolves their tasks using
 // type defaults + per-package overrides.
 func DiscoverPackages(root string, cfg *RootConfig) ([]Package, error) {
 	var packages []Package
-	seen := make(map[string]bool)
-
 	defaults := resolveDefaults(cfg.Defaults)
+	types, err := loadPackageTypes(root, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, member := range cfg.Workspace.Members {
-		label := strings.TrimPrefix(member, "//")
+	var index *discoveryIndex
+	if cfg.Workspace.DiscoveryCache {
+		index = loadDiscoveryIndex(root, types, cfg.Workspace.Rescan)
+	}
 
-		if strings.HasSuffix(label, "/...") {
-			baseDir := strings.TrimSuffix(label, "/...")
-			absBase := filepath.Join(root, baseDir)
+	// Walk every member pattern at once, then load the packages found. A
+	// running `ux daemon` keeps the walk warm.
+	var dirs []string
+	var ok bool
+	if !cfg.Workspace.Rescan {
+		dirs, ok = daemonPackageDirs(root, cfg.Workspace.Members, types)
+	}
+	if !ok {
+		walker := newDirWalker(root, types, index)
+		dirs = findPackageDirs(root, cfg.Workspace.Members, types, walker)
+		walker.record()
+		logger.Debug("walked members", "members", len(cfg.Workspace.Members), "dirs", len(walker.listed),
+			"cached", walker.cached, "candidates", len(dirs))
+	}
 
-			err := filepath.Walk(absBase, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return nil
-				}
-				if !info.IsDir() {
-					return nil
-				}
-				// Skip hidden and junk directories
-				name := info.Name()
-				if name != "." && strings.HasPrefix(name, ".") {
-					return filepath.SkipDir
-				}
-				if skipDirs[name] {
-					return filepath.SkipDir
-				}
-				// Don't treat the workspace root as a package
-				if path == root {
-					return nil
-				}
-				// Don't treat the base dir itself as a package (e.g., packages/)
-				if path == absBase {
-					return nil
-				}
-				if seen[path] {
-					return nil
-				}
-				if !isPackageDir(path) {
-					return nil
-				}
-				seen[path] = true
-				pkg, err := resolvePackage(root, path, defaults)
-				if err != nil {
-					return fmt.Errorf("loading %s: %w", path, err)
-				}
-				if pkg != nil {
-					packages = append(packages, *pkg)
-				}
-				return nil
-			})
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			dir := filepath.Join(root, label)
-			if seen[dir] {
-				continue
-			}
-			if !isPackageDir(dir) {
-				continue
-			}
-			seen[dir] = true
-			pkg, err := resolvePackage(root, dir, defaults)
-			if err != nil {
-				return nil, fmt.Errorf("loading %s: %w", dir, err)
-			}
-			if pkg != nil {
-				packages = append(packages, *pkg)
-			}
+	var wg sync.WaitGroup
+	resolved := make([]*Package, len(dirs))
+	errs := make([]error, len(dirs))
+	sem := make(chan struct{}, discoverWorkers)
+	for i, dir := range dirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			resolved[i], errs[i] = resolvePackage(root, dir, defaults, types, index)
+		}()
+	}
+	wg.Wait()
+	if index != nil {
+		logger.Debug("resolved packages", "configs", len(index.used), "indexed", index.reused)
+	}
+	if err := index.save(root); err != nil {
+		Warnf("cannot save the discovery index: %v", err)
+	}
+	// Report every broken config at once, not just the first
+	var broken []*ConfigError
+	for i, err := range errs {
+		if err != nil {
+			broken = append(broken, asConfigError(root, dirs[i], err))
+		}
+	}
+	if len(broken) > 0 {
+		return nil, collectConfigErrors(broken)
+	}
+	for i, pkg := range resolved {
+		if pkg == nil {
+			logger.Debug("not a package: no type and no tasks", "dir", dirs[i])
+			continue
 		}
+		logger.Debug("package", "label", pkg.Label, "type", pkg.Type, "tasks", strings.Join(slices.Sorted(maps.Keys(pkg.Tasks)), ","))
+		packages = append(packages, *pkg)
+	}
+	checkPackageNames(packages, cfg.Workspace.ScopedNames)
+	expandPackageNames(packages, types.docker)
+
+	packages = withRootTasks(root, cfg, packages)
+	withCodeowners(root, packages)
+	expandWorkspaceVars(root, packages)
+	if err := resolveWeights(cfg.Resources, packages); err != nil {
+		return nil, err
 	}
 
 	sort.Slice(packages, func(i, j int) bool {
@@ -174,94 +581,479 @@ func DiscoverPackages(root string, cfg *RootConfig) ([]Package, error) {
 	return packages, nil
 }
 
-// isPackageDir returns true if the directory has a ux.toml or a recognized marker file.
-func isPackageDir(dir string) bool {
-	if _, err := os.Stat(filepath.Join(dir, "ux.toml")); err == nil {
-		return true
+// findPackageDirs walks the members patterns and returns the sorted
+// directories that hold a package: a ux.toml or a marker file.
+func findPackageDirs(root string, members []string, types *packageTypes, walker *dirWalker) []string {
+	negations := memberNegations(members)
+	var mu sync.Mutex
+	found := make(map[string]bool)
+	consider := func(dir string, l dirListing) {
+		rel, _ := filepath.Rel(root, dir)
+		if dir == root || !types.isPackageListing(dir, l) {
+			return
+		}
+		if label := "//" + filepath.ToSlash(rel); memberExcluded(negations, label) {
+			logger.Debug("excluded by a ! members entry", "label", label)
+			return
+		}
+		mu.Lock()
+		found[dir] = true
+		mu.Unlock()
 	}
-	for _, m := range markerPriority {
-		if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+	var wg sync.WaitGroup
+	for _, member := range members {
+		if strings.HasPrefix(member, "!") {
+			continue
+		}
+		label := strings.TrimPrefix(member, "//")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if baseDir, ok := strings.CutSuffix(label, "/..."); ok {
+				// The base dir itself isn't a package (e.g., packages/)
+				walker.walk(filepath.Join(root, baseDir), consider)
+			} else if dir := filepath.Join(root, label); dir == root {
+				// The root is a package only when it's a member itself
+				if l, err := walker.list(dir); err == nil && types.isPackageListing(dir, l) {
+					mu.Lock()
+					found[dir] = true
+					mu.Unlock()
+				}
+			} else if l, err := walker.list(dir); err == nil {
+				consider(dir, l)
+			}
+		}()
+	}
+	wg.Wait()
+
+	dirs := make([]string, 0, len(found))
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// memberNegations returns the patterns of "!"-prefixed members entries,
+// without the "!".
+func memberNegations(members []string) []string {
+	var negations []string
+	for _, m := range members {
+		if pattern, ok := strings.CutPrefix(m, "!"); ok {
+			negations = append(negations, pattern)
+		}
+	}
+	return negations
+}
+
+// memberExcluded reports whether a negated members pattern excludes label.
+// Unlike a members entry, "//dir/..." excludes dir itself as well.
+func memberExcluded(negations []string, label string) bool {
+	path := strings.TrimPrefix(label, "//")
+	for _, n := range negations {
+		pattern := strings.TrimPrefix(n, "//")
+		if pattern == "..." {
+			return true
+		}
+		base, recursive := strings.CutSuffix(pattern, "/...")
+		if path == base || recursive && strings.HasPrefix(path, base+"/") {
 			return true
 		}
 	}
 	return false
 }
 
-// detectType checks for marker files and returns the detected type, or "".
+// detectType checks for built-in marker files and returns the detected type, or "".
 func detectType(dir string) string {
+	return builtinTypes(false).detectType(dir)
+}
+
+// present reports whether the package is still in the workspace: its
+// directory has a ux.toml or a marker file.
+func (pkg Package) present() bool {
+	return pkg.presenceFile() != ""
+}
+
+// presenceFile returns the path of the file that makes the package's
+// directory one (see present), or "" if there's none.
+func (pkg Package) presenceFile() string {
+	files := []string{"ux.toml"}
 	for _, m := range markerPriority {
-		if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
-			return m.typeName
+		files = append(files, m.file)
+	}
+	for _, file := range append(files, pkg.markers...) {
+		path := filepath.Join(pkg.Dir, file)
+		if _, err := os.Stat(path); err == nil {
+			return path
 		}
 	}
 	return ""
 }
 
+// root returns the workspace root the package's label is relative to, or ""
+// if its Dir doesn't end in its label.
+func (pkg Package) root() string {
+	rel := filepath.FromSlash(strings.TrimPrefix(pkg.Label, "//"))
+	if pkg.Label == RootLabel {
+		return pkg.Dir
+	}
+	if root, ok := strings.CutSuffix(pkg.Dir, string(filepath.Separator)+rel); ok && rel != "" {
+		return root
+	}
+	return ""
+}
+
 // resolveDefaults pre-parses the [defaults.<type>.tasks] sections into resolved commands.
-func resolveDefaults(raw map[string]TypeDefaults) map[string]map[string][]string {
-	result := make(map[string]map[string][]string)
+func resolveDefaults(raw map[string]TypeDefaults) map[string]map[string]Task {
+	result := make(map[string]map[string]Task)
 	for typeName, td := range raw {
 		result[typeName] = parseTasks(td.Tasks)
 	}
 	return result
 }
 
-// parseTasks converts raw TOML task values (string or []string) to resolved []string commands.
-func parseTasks(raw map[string]interface{}) map[string][]string {
+// parseTasks converts raw TOML task values (string, []string, or table) to resolved tasks.
+func parseTasks(raw map[string]interface{}) map[string]Task {
 	if raw == nil {
 		return nil
 	}
-	tasks := make(map[string][]string)
+	tasks := make(map[string]Task)
 	for name, v := range raw {
 		switch val := v.(type) {
-		case string:
-			tasks[name] = []string{val}
-		case []interface{}:
-			var cmds []string
-			for _, item := range val {
-				if s, ok := item.(string); ok {
-					cmds = append(cmds, s)
-				}
+		case string, []interface{}:
+			t := Task{}
+			t.Cmds, t.Steps = parseCommands(val)
+			tasks[name] = t
+		case map[string]interface{}:
+			// Without cmd, it's settings such as the root's
+			// lint = { parallel = true }, not a command override
+			if _, ok := val["cmd"]; !ok {
+				continue
 			}
-			tasks[name] = cmds
+			t := Task{}
+			t.Cmds, t.Steps = parseCommands(val["cmd"])
+			t.Cwd, _ = val["cwd"].(string)
+			t.Shell, _ = val["shell"].(string)
+			t.Mutex, _ = val["mutex"].(string)
+			t.Description, _ = val["description"].(string)
+			t.RunOnce, _ = val["run_once"].(bool)
+			if when, ok := val["when"].(map[string]interface{}); ok {
+				t.WhenExists = stringList(when["exists"])
+			}
+			tasks[name] = t
 		}
 	}
 	return tasks
 }
 
+// packageTaskKeys are the keys a task table in a package's ux.toml can set.
+var packageTaskKeys = map[string]bool{
+	"cmd": true, "cwd": true, "shell": true, "mutex": true,
+	"description": true, "run_once": true, "when": true,
+}
+
+// rootTaskKeys are the TaskConfig keys only the workspace ux.toml's [tasks]
+// reads.
+var rootTaskKeys = map[string]bool{
+	"parallel": true, "depends_on": true, "inputs": true, "outputs": true, "cache": true,
+	"on_failure_collect": true, "allow_failure": true, "tty": true, "confirm": true,
+}
+
+// checkPackageTasks returns an error for a task table in a package's
+// [tasks] that would be silently ignored: one without cmd, or with a key
+// that only applies workspace-wide or that ux doesn't know.
+func checkPackageTasks(raw map[string]interface{}) error {
+	for _, name := range slices.Sorted(maps.Keys(raw)) {
+		val, ok := raw[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range slices.Sorted(maps.Keys(val)) {
+			switch {
+			case packageTaskKeys[key]:
+			case rootTaskKeys[key]:
+				return fmt.Errorf("[tasks.%s] sets %s, which only the workspace ux.toml's [tasks] can set", name, key)
+			default:
+				return fmt.Errorf("[tasks.%s] has unknown key %q", name, key)
+			}
+		}
+		if _, ok := val["cmd"]; !ok {
+			return fmt.Errorf("[tasks.%s] has no cmd", name)
+		}
+	}
+	return nil
+}
+
+// stringList converts a raw TOML string or array of strings to a list.
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		var list []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// expand returns a copy of the task with placeholders replaced in its
+// commands, by cmd, and its cwd, by path.
+func (t Task) expand(cmd, path *strings.Replacer) Task {
+	cmds := make([]string, len(t.Cmds))
+	for i, c := range t.Cmds {
+		cmds[i] = cmd.Replace(c)
+	}
+	t.Cmds = cmds
+	t.Cwd = path.Replace(t.Cwd)
+	return t
+}
+
+// placeholderReplacers returns replacers for pairs of placeholders and
+// their values: one for commands, which shell-quotes each value that needs
+// it, and one for cwd and env values, which uses them as they are.
+func placeholderReplacers(pairs ...string) (cmd, raw *strings.Replacer) {
+	quoted := slices.Clone(pairs)
+	for i := 1; i < len(quoted); i += 2 {
+		quoted[i] = shellWord(quoted[i])
+	}
+	return strings.NewReplacer(quoted...), strings.NewReplacer(pairs...)
+}
+
+// shellWord returns s as one sh word: as is when every character is
+// plainly literal to the shell, as most names, labels, and paths are, and
+// otherwise single-quoted. Empty values stay empty.
+func shellWord(s string) string {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("@%+=:,./_-", c)) {
+			return shellQuote(s)
+		}
+	}
+	return s
+}
+
+// parseCommands converts a raw command value (a string, or an array of
+// strings and arrays of strings) to a command list and its step sizes (see
+// Task.Steps), which are nil unless a step runs commands concurrently.
+func parseCommands(v interface{}) ([]string, []int) {
+	switch val := v.(type) {
+	case string:
+		return []string{val}, nil
+	case []interface{}:
+		var cmds []string
+		var steps []int
+		grouped := false
+		for _, item := range val {
+			switch step := item.(type) {
+			case string:
+				cmds = append(cmds, step)
+				steps = append(steps, 1)
+			case []interface{}:
+				n := 0
+				for _, c := range step {
+					if s, ok := c.(string); ok {
+						cmds = append(cmds, s)
+						n++
+					}
+				}
+				if n > 0 {
+					steps = append(steps, n)
+					grouped = grouped || n > 1
+				}
+			}
+		}
+		if !grouped {
+			steps = nil
+		}
+		return cmds, steps
+	}
+	return nil, nil
+}
+
+// packageFile is a per-package ux.toml.
+type packageFile struct {
+	Package struct {
+		Name        string   `toml:"name"`
+		Description string   `toml:"description"`
+		Owners      []string `toml:"owners"`
+		Type        string   `toml:"type"`
+		Deps        []string `toml:"deps"`
+		// GeneratedFrom lists targets whose files the package generates
+		// code from, e.g. ["//proto"].
+		GeneratedFrom []string `toml:"generated_from"`
+		Extends       string   `toml:"extends"`
+		// ParallelSafe = false runs the package alone, even in a parallel task.
+		ParallelSafe *bool  `toml:"parallel_safe"`
+		Weight       int    `toml:"weight"`
+		Resources    string `toml:"resources"`
+		// SkipTasks opts the package out of tasks its type would give it.
+		SkipTasks []string `toml:"skip_tasks"`
+		// FlakyTasks are tasks known to fail intermittently here; --quarantine
+		// reports their failures without failing the run.
+		FlakyTasks []string `toml:"flaky_tasks"`
+	} `toml:"package"`
+	Tasks     map[string]interface{} `toml:"tasks"`
+	Env       map[string]string      `toml:"env"`
+	Workspace *WorkspaceConfig       `toml:"workspace"`
+}
+
+// inheritedConfig is what a package receives from its extends chain.
+type inheritedConfig struct {
+	tasks   map[string]Task
+	sources map[string]string // task → label of the package that defined it
+	env     map[string]string
+}
+
+// resolveExtends loads the tasks and env of the package at label, layered on
+// top of whatever it extends in turn. chain holds the labels leading here.
+func resolveExtends(root, label string, chain []string) (*inheritedConfig, error) {
+	for _, l := range chain {
+		if l == label {
+			return nil, fmt.Errorf("extends cycle: %s", strings.Join(append(chain, label), " → "))
+		}
+	}
+	extender := chain[len(chain)-1]
+	rel, ok := extendsDir(label)
+	if !ok {
+		return nil, &ConfigError{File: labelConfigFile(extender), Key: "package.extends",
+			Message: fmt.Sprintf("%q is not a package label (//dir)", label)}
+	}
+	path := filepath.Join(root, rel, "ux.toml")
+	var raw packageFile
+	if err := decodeConfigFile(root, path, &raw); os.IsNotExist(err) {
+		return nil, &ConfigError{File: labelConfigFile(extender), Key: "package.extends",
+			Message: fmt.Sprintf("no package %s to extend (no %s)", label, configRel(root, path))}
+	} else if err != nil {
+		return nil, fmt.Errorf("extends %s: %w", label, err)
+	}
+	if err := checkPackageTasks(raw.Tasks); err != nil {
+		return nil, &ConfigError{File: configRel(root, path), Message: err.Error()}
+	}
+
+	inherited := &inheritedConfig{
+		tasks:   make(map[string]Task),
+		sources: make(map[string]string),
+		env:     make(map[string]string),
+	}
+	if raw.Package.Extends != "" {
+		base, err := resolveExtends(root, raw.Package.Extends, append(chain, label))
+		if err != nil {
+			return nil, err
+		}
+		inherited = base
+	}
+	for k, v := range parseTasks(raw.Tasks) {
+		inherited.tasks[k] = v
+		inherited.sources[k] = label
+	}
+	for k, v := range raw.Env {
+		inherited.env[k] = v
+	}
+	return inherited, nil
+}
+
+// extendsDir returns the workspace-relative directory of an extends label,
+// or false unless label is a plain "//dir" label inside the workspace.
+func extendsDir(label string) (string, bool) {
+	rel, ok := strings.CutPrefix(label, "//")
+	if !ok || rel == "" || strings.ContainsAny(rel, ":\\") || path.Clean(rel) != rel || rel == "..." || strings.HasSuffix(rel, "/...") {
+		return "", false
+	}
+	dir := filepath.FromSlash(rel)
+	return dir, filepath.IsLocal(dir)
+}
+
+// labelConfigFile returns the workspace-relative path of the ux.toml of
+// the package at label.
+func labelConfigFile(label string) string {
+	return path.Join(strings.TrimPrefix(label, "//"), "ux.toml")
+}
+
 // resolvePackage loads a package from a directory, merging type defaults with per-package overrides.
 //
 // Resolution order (highest priority first):
 //  1. Per-package [tasks] in ux.toml
-//  2. Type defaults from root [defaults.<type>.tasks]
+//  2. Tasks inherited through [package] extends, nearest base first
+//  3. Type defaults from root [defaults.<type>.tasks]
+//  4. Built-in defaults for the type
+//
+// Layers 3 and 4 repeat for each type a [defaults.<type>] extends, the
+// base type below the type extending it.
 //
 // Type is determined by: explicit type in ux.toml > auto-detected from marker files.
-func resolvePackage(root, dir string, defaults map[string]map[string][]string) (*Package, error) {
+func resolvePackage(root, dir string, defaults map[string]map[string]Task, types *packageTypes, index *discoveryIndex) (*Package, error) {
 	rel, _ := filepath.Rel(root, dir)
 	label := "//" + filepath.ToSlash(rel)
 
-	var name, explicitType string
-	var overrideTasks map[string][]string
+	var name, description, explicitType, resources string
+	var weight int
+	var deps, generatedFrom, owners, flakyTasks []string
+	var exclusive bool
+	var overrideTasks map[string]Task
+	var inherited *inheritedConfig
+	skip := make(map[string]string)
+	env := make(map[string]string)
 
 	// Try loading ux.toml
-	uxPath := filepath.Join(dir, "ux.toml")
-	if _, err := os.Stat(uxPath); err == nil {
-		var raw struct {
-			Package struct {
-				Name string `toml:"name"`
-				Type string `toml:"type"`
-			} `toml:"package"`
-			Tasks map[string]interface{} `toml:"tasks"`
-		}
-		if _, err := toml.DecodeFile(uxPath, &raw); err != nil {
-			return nil, err
+	raw, found, err := index.readPackageFile(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		// A workspace file's [tasks] configures tasks workspace-wide; it
+		// doesn't define any for the directory it sits in.
+		if raw.Workspace == nil {
+			name = raw.Package.Name
+			description = raw.Package.Description
+			owners = raw.Package.Owners
+			flakyTasks = raw.Package.FlakyTasks
+			explicitType = raw.Package.Type
+			deps = raw.Package.Deps
+			generatedFrom = raw.Package.GeneratedFrom
+			exclusive = raw.Package.ParallelSafe != nil && !*raw.Package.ParallelSafe
+			for _, target := range generatedFrom {
+				if !strings.HasPrefix(target, "//") {
+					return nil, fmt.Errorf("[package] generated_from: %q is not a label (//dir or //dir/...)", target)
+				}
+			}
+			weight, resources = raw.Package.Weight, raw.Package.Resources
+			if weight < 0 {
+				return nil, fmt.Errorf("[package] weight must be positive, got %d", weight)
+			}
+			if weight != 0 && resources != "" {
+				return nil, fmt.Errorf("[package] sets both weight and resources; use one")
+			}
+			if err := checkPackageTasks(raw.Tasks); err != nil {
+				return nil, err
+			}
+			overrideTasks = parseTasks(raw.Tasks)
+			for _, task := range raw.Package.SkipTasks {
+				if _, ok := overrideTasks[task]; ok {
+					return nil, fmt.Errorf("[package] skip_tasks lists %q, which [tasks] defines", task)
+				}
+				skip[task] = "[package] skip_tasks"
+			}
+			if raw.Package.Extends != "" {
+				inherited, err = resolveExtends(root, raw.Package.Extends, []string{label})
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range inherited.env {
+					env[k] = v
+				}
+			}
+			for k, v := range raw.Env {
+				env[k] = v
+			}
 		}
-		name = raw.Package.Name
-		explicitType = raw.Package.Type
-		overrideTasks = parseTasks(raw.Tasks)
 	}
 
 	// Default name to directory basename
+	namedInConfig := name != ""
 	if name == "" {
 		name = filepath.Base(dir)
 	}
@@ -269,46 +1061,170 @@ func resolvePackage(root, dir string, defaults map[string]map[string][]string) (
 	// Determine type: explicit > auto-detect
 	pkgType := explicitType
 	if pkgType == "" {
-		pkgType = detectType(dir)
+		pkgType = types.detectType(dir)
+		// A Dockerfile is the weakest marker: a package that defines its own
+		// tasks doesn't turn into a docker package by gaining one
+		if pkgType == "docker" && (len(overrideTasks) > 0 || inherited != nil) {
+			pkgType = ""
+		}
 	}
 
 	// No type and no explicit tasks → not a usable package
-	if pkgType == "" && len(overrideTasks) == 0 {
+	if pkgType == "" && len(overrideTasks) == 0 && (inherited == nil || len(inherited.tasks) == 0) {
 		return nil, nil
 	}
 
-	// Merge: start with type defaults, then apply per-package overrides
-	tasks := make(map[string][]string)
+	// Merge: start with built-in then type defaults, then inherited tasks,
+	// then apply per-package overrides
+	tasks := make(map[string]Task)
 	taskSources := make(map[string]string)
+	manager := "" // node packages only
 
-	if pkgType != "" {
-		if dt, ok := defaults[pkgType]; ok {
-			for k, v := range dt {
-				tasks[k] = v
-				taskSources[k] = "default"
+	// A type extending another starts from the base's tasks, base first
+	for _, t := range types.chain(pkgType) {
+		for k, v := range types.tasks[t] {
+			if !v.applies(dir) {
+				continue
+			}
+			tasks[k] = v
+			taskSources[k] = "builtin"
+		}
+		if t == "node" {
+			manager = detectPackageManager(root, dir)
+			nodeBuiltinTasks(manager, dir, tasks, taskSources)
+		}
+		for k, v := range defaults[t] {
+			if !v.applies(dir) {
+				continue
+			}
+			tasks[k] = v
+			taskSources[k] = "default"
+		}
+	}
+	if inherited != nil {
+		for k, v := range inherited.tasks {
+			if !v.applies(dir) {
+				continue
 			}
+			tasks[k] = v
+			taskSources[k] = inherited.sources[k]
 		}
 	}
 	for k, v := range overrideTasks {
+		if !v.applies(dir) {
+			continue
+		}
 		tasks[k] = v
 		taskSources[k] = "override"
 	}
 
+	if err := readSkipMarkers(dir, types.markerFiles(pkgType), skip); err != nil {
+		return nil, err
+	}
+	for task := range skip {
+		delete(tasks, task)
+		delete(taskSources, task)
+	}
+
 	// No tasks resolved → skip
 	if len(tasks) == 0 {
 		return nil, nil
 	}
 
+	// Fill in per-package placeholders so type defaults can mention the
+	// package. {package_name} waits for checkPackageNames (see
+	// expandPackageNames).
+	cmdVars, rawVars := placeholderReplacers(
+		"{package_dir}", dir,
+		"{package_label}", label,
+		"{package_type}", pkgType,
+		"{package_manager}", manager,
+	)
+	for k, t := range tasks {
+		tasks[k] = t.expand(cmdVars, rawVars)
+	}
+	for k, v := range env {
+		env[k] = rawVars.Replace(v)
+	}
+
 	return &Package{
-		Name:        name,
-		Type:        pkgType,
-		Dir:         dir,
-		Label:       label,
-		Tasks:       tasks,
-		TaskSources: taskSources,
+		Name:          name,
+		Description:   description,
+		Owners:        owners,
+		FlakyTasks:    flakyTasks,
+		Type:          pkgType,
+		Dir:           dir,
+		Label:         label,
+		Deps:          deps,
+		GeneratedFrom: generatedFrom,
+		Env:           env,
+		Tasks:         tasks,
+		TaskSources:   taskSources,
+		Weight:        weight,
+		Exclusive:     exclusive,
+		markers:       types.markerFiles(pkgType),
+		skipped:       skip,
+		typeChain:     types.chain(pkgType),
+		resources:     resources,
+		namedInConfig: namedInConfig,
 	}, nil
 }
 
+// SkipMarker is a directive comment, like Go's //go: directives, that lets a
+// package without a ux.toml opt out of type default tasks from its marker
+// file: "//ux:skip deploy lint" in go.mod, "#ux:skip deploy" in Cargo.toml or
+// pyproject.toml.
+const SkipMarker = "ux:skip"
+
+// readSkipMarkers adds the tasks named by SkipMarker lines in dir's marker
+// files to skip, recording the file each came from.
+func readSkipMarkers(dir string, markers []string, skip map[string]string) error {
+	for _, file := range markers {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			rest, ok := strings.CutPrefix(line, "//"+SkipMarker)
+			if !ok {
+				rest, ok = strings.CutPrefix(line, "#"+SkipMarker)
+			}
+			if !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				continue
+			}
+			tasks := strings.Fields(rest)
+			if len(tasks) == 0 {
+				return fmt.Errorf("%s: %s needs the tasks to skip", file, SkipMarker)
+			}
+			for _, task := range tasks {
+				skip[task] = file
+			}
+		}
+	}
+	return nil
+}
+
+// SplitTarget splits a Bazel-style "//label:task" target into its package
+// filter and task. "//:task" names the workspace root, and the label may be
+// a "//dir/..." pattern. ok is false unless arg has that form with both
+// parts non-empty.
+func SplitTarget(arg string) (filter, task string, ok bool) {
+	if !strings.HasPrefix(arg, "//") {
+		return "", "", false
+	}
+	filter, task, ok = strings.Cut(arg, ":")
+	if !ok || task == "" || strings.ContainsAny(task, ":/") {
+		return "", "", false
+	}
+	if filter == "//" {
+		filter = RootLabel
+	}
+	return filter, task, true
+}
+
 // IsFilterArg returns true if an argument looks like a package filter rather than
 // a task name or flag. Matches: //-prefixed, ".", "...", "./...", "./" prefixed,
 // bare paths containing "/", or any bare name not starting with "-" (e.g. "cli").
@@ -388,6 +1304,63 @@ func FilterByLabels(packages []Package, filters []string) []Package {
 	return result
 }
 
+// OnlyPackagesEnv names the environment variable holding a comma-separated
+// list of labels (or //dir/... patterns) that every selection is narrowed to.
+const OnlyPackagesEnv = "UX_ONLY_PACKAGES"
+
+// OnlyPackages returns the filters from $UX_ONLY_PACKAGES and whether it is set.
+// Set but empty means no package may run.
+func OnlyPackages() ([]string, bool) {
+	raw, ok := os.LookupEnv(OnlyPackagesEnv)
+	if !ok {
+		return nil, false
+	}
+	var filters []string
+	for _, f := range strings.Split(raw, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			if !strings.HasPrefix(f, "//") {
+				f = "//" + f
+			}
+			filters = append(filters, f)
+		}
+	}
+	return filters, true
+}
+
+// ReadTargets reads newline-separated targets (labels, //dir/... patterns, or
+// paths) from r, such as a list written by another tool. Blank lines and
+// lines starting with # are skipped.
+func ReadTargets(r io.Reader) ([]string, error) {
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !IsFilterArg(line) {
+			return nil, fmt.Errorf("line %d: %q is not a target", n, line)
+		}
+		targets = append(targets, line)
+	}
+	return targets, scanner.Err()
+}
+
+// IntersectLabels keeps the packages matched by any of the filters, preserving order.
+func IntersectLabels(packages []Package, filters []string) []Package {
+	keep := make(map[string]bool)
+	for _, pkg := range FilterByLabels(packages, filters) {
+		keep[pkg.Label] = true
+	}
+	var result []Package
+	for _, pkg := range packages {
+		if keep[pkg.Label] {
+			result = append(result, pkg)
+		}
+	}
+	return result
+}
+
 // FilterByLabel filters packages by a //label or //label/... pattern.
 // //... matches all packages.
 func FilterByLabel(packages []Package, filter string) []Package {
@@ -420,6 +1393,83 @@ func FilterByLabel(packages []Package, filter string) []Package {
 	return result
 }
 
+// maxSuggestions caps how many "did you mean" labels are offered.
+const maxSuggestions = 3
+
+// SuggestLabels returns what a filter that matched no packages was probably
+// meant to be: its wildcard expansion if that matches (see
+// SuggestFilterExpansion), otherwise the closest labels by edit distance,
+// comparing against package paths and names. Wildcard filters are compared
+// against directories. It returns nil if nothing is close.
+func SuggestLabels(packages []Package, resolvedFilter string) []string {
+	if s := SuggestFilterExpansion(packages, resolvedFilter); s != "" {
+		return []string{s}
+	}
+	query, wildcard := strings.CutSuffix(strings.TrimPrefix(resolvedFilter, "//"), "/...")
+
+	type candidate struct{ text, label string }
+	var candidates []candidate
+	for _, pkg := range packages {
+		path := strings.TrimPrefix(pkg.Label, "//")
+		if wildcard {
+			for dir := filepath.ToSlash(filepath.Dir(path)); dir != "."; dir = filepath.ToSlash(filepath.Dir(dir)) {
+				candidates = append(candidates, candidate{dir, "//" + dir + "/..."})
+			}
+			continue
+		}
+		candidates = append(candidates, candidate{path, pkg.Label})
+		if pkg.Name != "" {
+			candidates = append(candidates, candidate{pkg.Name, pkg.Label})
+		}
+	}
+
+	limit := min(max(len(query)/3, 1), 3)
+	best := limit + 1
+	var suggestions []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		d := editDistance(query, c.text)
+		switch {
+		case d > best:
+			continue
+		case d < best:
+			best = d
+			suggestions = suggestions[:0]
+			seen = make(map[string]bool)
+		}
+		if !seen[c.label] {
+			seen[c.label] = true
+			suggestions = append(suggestions, c.label)
+		}
+	}
+	sort.Strings(suggestions)
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
 // SuggestFilterExpansion returns a non-empty suggestion if the given resolved
 // filter (e.g. "//packages") matches no packages but sub-packages exist that
 // would be matched by the wildcard expansion (e.g. "//packages/...").
@@ -441,29 +1491,3 @@ func SuggestFilterExpansion(packages []Package, resolvedFilter string) string {
 	}
 	return ""
 }
-
-// FilterAffected keeps only packages that have changed files vs origin/main.
-func FilterAffected(root string, packages []Package) ([]Package, error) {
-	raw, err := gitDiffFiles(root)
-	if err != nil {
-		return nil, err
-	}
-
-	changedFiles := strings.Split(strings.TrimSpace(raw), "\n")
-	if len(changedFiles) == 1 && changedFiles[0] == "" {
-		return nil, nil
-	}
-
-	var result []Package
-	for _, pkg := range packages {
-		rel, _ := filepath.Rel(root, pkg.Dir)
-		prefix := filepath.ToSlash(rel) + "/"
-		for _, f := range changedFiles {
-			if strings.HasPrefix(f, prefix) {
-				result = append(result, pkg)
-				break
-			}
-		}
-	}
-	return result, nil
-}
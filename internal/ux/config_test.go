@@ -1,9 +1,36 @@
 package ux
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
+func TestSplitTarget(t *testing.T) {
+	tests := []struct {
+		arg, filter, task string
+		ok                bool
+	}{
+		{"//services/api:test", "//services/api", "test", true},
+		{"//services/...:lint", "//services/...", "lint", true},
+		{"//:docs", RootLabel, "docs", true},
+		{"//services/api", "", "", false},
+		{"//services/api:", "", "", false},
+		{"//a:b:c", "", "", false},
+		{"services/api:test", "", "", false},
+	}
+	for _, tt := range tests {
+		filter, task, ok := SplitTarget(tt.arg)
+		if filter != tt.filter || task != tt.task || ok != tt.ok {
+			t.Errorf("SplitTarget(%q) = %q, %q, %v; want %q, %q, %v", tt.arg, filter, task, ok, tt.filter, tt.task, tt.ok)
+		}
+	}
+}
+
 func TestIsFilterArg(t *testing.T) {
 	tests := []struct {
 		arg  string
@@ -102,6 +129,57 @@ func TestSuggestFilterExpansion(t *testing.T) {
 	}
 }
 
+func TestSuggestLabels(t *testing.T) {
+	packages := []Package{
+		{Label: "//cli", Name: "cli"},
+		{Label: "//packages/ingest", Name: "ingest"},
+		{Label: "//packages/auth", Name: "auth-lib"},
+		{Label: "//services/api", Name: "api"},
+		{Label: "//services/app", Name: "app"},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{"typo in label", "//packages/ingset", []string{"//packages/ingest"}},
+		{"typo in directory", "//servics/api", []string{"//services/api"}},
+		{"package name", "//auth-lb", []string{"//packages/auth"}},
+		{"ties are all offered", "//services/ap", []string{"//services/api", "//services/app"}},
+		{"wildcard compares directories", "//pakages/...", []string{"//packages/..."}},
+		{"expansion wins", "//services", []string{"//services/..."}},
+		{"nothing close", "//xyzzy", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SuggestLabels(packages, tt.filter)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SuggestLabels(%q) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"ingest", "ingset", 2},
+		{"api", "app", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
 func TestResolveFilter(t *testing.T) {
 	tests := []struct {
 		name string
@@ -201,3 +279,584 @@ func TestResolveFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTasks(t *testing.T) {
+	raw := map[string]interface{}{
+		"lint": "ruff check .",
+		"test": []interface{}{"uv sync", "uv run pytest"},
+		"docs": map[string]interface{}{
+			"cmd":   "make html",
+			"cwd":   "docs",
+			"shell": "bash",
+		},
+		"steps": map[string]interface{}{
+			"cmd": []interface{}{"a", "b"},
+		},
+		"check": []interface{}{[]interface{}{"ruff check .", "mypy ."}, "pytest"},
+		"e2e": map[string]interface{}{
+			"cmd":         "playwright test",
+			"description": "Browser tests",
+			"mutex":       "browser",
+		},
+		"build": map[string]interface{}{"parallel": true},
+	}
+
+	got := parseTasks(raw)
+	if build, ok := got["build"]; ok {
+		t.Errorf("settings-only table parsed as a task: %+v", build)
+	}
+
+	if cmds := got["lint"].Cmds; len(cmds) != 1 || cmds[0] != "ruff check ." {
+		t.Errorf("lint cmds = %v", cmds)
+	}
+	if cmds := got["test"].Cmds; len(cmds) != 2 || cmds[1] != "uv run pytest" {
+		t.Errorf("test cmds = %v", cmds)
+	}
+	docs := got["docs"]
+	if len(docs.Cmds) != 1 || docs.Cmds[0] != "make html" || docs.Cwd != "docs" || docs.Shell != "bash" {
+		t.Errorf("docs = %+v", docs)
+	}
+	if cmds := got["steps"].Cmds; len(cmds) != 2 || got["steps"].Steps != nil {
+		t.Errorf("steps = %+v", got["steps"])
+	}
+	if e2e := got["e2e"]; e2e.Description != "Browser tests" || e2e.Mutex != "browser" {
+		t.Errorf("e2e = %+v", e2e)
+	}
+	check := got["check"]
+	if steps := check.steps(); len(steps) != 2 || len(steps[0]) != 2 || steps[0][1] != "mypy ." || steps[1][0] != "pytest" {
+		t.Errorf("check steps = %v", steps)
+	}
+}
+
+func TestResolvePackagePlaceholders(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "services", "api")
+	writeFile(t, filepath.Join(dir, "go.mod"), "module api\n")
+	defaults := map[string]map[string]Task{
+		"go": {"build": {Cmds: []string{"docker build -t {package_name} {package_dir}", "echo {package_label} {package_type} {other}"}}},
+	}
+
+	pkg, err := resolvePackage(root, dir, defaults, builtinTypes(false), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	expandPackageNames([]Package{*pkg}, DockerConfig{})
+	cmds := pkg.Tasks["build"].Cmds
+	if want := "docker build -t api " + dir; cmds[0] != want {
+		t.Errorf("cmds[0] = %q, want %q", cmds[0], want)
+	}
+	if want := "echo //services/api go {other}"; cmds[1] != want {
+		t.Errorf("cmds[1] = %q, want %q", cmds[1], want)
+	}
+	if got := defaults["go"]["build"].Cmds[0]; got != "docker build -t {package_name} {package_dir}" {
+		t.Errorf("defaults were modified: %q", got)
+	}
+}
+
+func TestResolvePackagePlaceholdersQuoted(t *testing.T) {
+	// Values are one shell word in commands, whatever they contain, and
+	// used as they are in cwd and env
+	root := t.TempDir()
+	dir := filepath.Join(root, "it's $(touch pwned); a dir")
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[tasks]\nshow = \"printf '%s|' {package_dir} $DIR\"\n\n[env]\nDIR = \"{package_dir}\"\n")
+
+	pkg, err := resolvePackage(root, dir, nil, builtinTypes(false), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	if got := pkg.Env["DIR"]; got != dir {
+		t.Errorf("env DIR = %q, want %q", got, dir)
+	}
+	r := executeBuffered("show", *pkg, RunOptions{}, nil)
+	if want := dir + "|" + strings.Fields(dir)[0] + "|"; !r.Success || !strings.HasPrefix(r.Output, want) {
+		t.Errorf("result = %+v, want output starting %q", r, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Error("the directory name ran as a command")
+	}
+	if got := shellWord("services/api-v2"); got != "services/api-v2" {
+		t.Errorf("shellWord left a plain path quoted: %q", got)
+	}
+}
+
+func TestResolvePackageRejectsIgnoredTaskKeys(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "svc")
+	for _, tt := range []struct {
+		tasks, want string
+	}{
+		{`deploy = { cmd = "./deploy.sh", confirm = true }`, "[tasks.deploy] sets confirm, which only the workspace ux.toml's [tasks] can set"},
+		{`lint = { cmd = "ruff check .", allow_failure = true }`, "[tasks.lint] sets allow_failure"},
+		{`test = { cmd = "pytest", mutx = "db" }`, `[tasks.test] has unknown key "mutx"`},
+		{`test = { cwd = "src" }`, "[tasks.test] has no cmd"},
+	} {
+		writeFile(t, filepath.Join(dir, "ux.toml"), "[tasks]\n"+tt.tasks+"\n")
+		_, err := resolvePackage(root, dir, nil, builtinTypes(false), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.tasks, err, tt.want)
+		}
+	}
+
+	// Bases are checked too, and blamed for it
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[package]\nextends = \"//base\"\n")
+	writeFile(t, filepath.Join(root, "base", "ux.toml"), "[tasks]\ntest = { cmd = \"pytest\", tty = true }\n")
+	_, err := resolvePackage(root, dir, nil, builtinTypes(false), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "base/ux.toml: [tasks.test] sets tty") {
+		t.Errorf("base: error = %v", err)
+	}
+}
+
+func TestOnlyPackages(t *testing.T) {
+	packages := []Package{
+		{Label: "//packages/core"},
+		{Label: "//packages/ingest"},
+		{Label: "//services/api"},
+		{Label: "//services/worker"},
+	}
+
+	t.Setenv(OnlyPackagesEnv, " //services/api, packages/..., ,//missing")
+	only, ok := OnlyPackages()
+	if !ok {
+		t.Fatal("OnlyPackages() not set")
+	}
+
+	var got []string
+	for _, pkg := range IntersectLabels(packages, only) {
+		got = append(got, pkg.Label)
+	}
+	want := []string{"//packages/core", "//packages/ingest", "//services/api"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("IntersectLabels = %v, want %v", got, want)
+	}
+
+	t.Setenv(OnlyPackagesEnv, "")
+	only, ok = OnlyPackages()
+	if !ok || len(IntersectLabels(packages, only)) != 0 {
+		t.Errorf("empty %s should select nothing, got %v", OnlyPackagesEnv, only)
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	got, err := ReadTargets(strings.NewReader("//services/api\n\n  # owned by payments\n  packages/ingest  \n//libs/...\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"//services/api", "packages/ingest", "//libs/..."}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("ReadTargets = %v, want %v", got, want)
+	}
+
+	if got, err := ReadTargets(strings.NewReader("\n# nothing flaky\n")); err != nil || len(got) != 0 {
+		t.Errorf("ReadTargets of an empty list = %v, %v", got, err)
+	}
+	if _, err := ReadTargets(strings.NewReader("//a\n--affected\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadTargets with a flag = %v, want an error for line 2", err)
+	}
+}
+
+func TestResolvePackageBuiltinDefaults(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "svc")
+	writeFile(t, filepath.Join(dir, "go.mod"), "module svc\n")
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[tasks]\nbuild = \"make\"\n")
+	defaults := map[string]map[string]Task{
+		"go": {"lint": {Cmds: []string{"golangci-lint run"}}},
+	}
+
+	pkg, err := resolvePackage(root, dir, defaults, builtinTypes(true), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	want := map[string]string{
+		"build": "override",
+		"lint":  "default",
+		"test":  "builtin",
+	}
+	for task, source := range want {
+		if got := pkg.TaskSources[task]; got != source {
+			t.Errorf("TaskSources[%q] = %q, want %q", task, got, source)
+		}
+	}
+	if got := pkg.Tasks["test"].Cmds[0]; got != "go test ./..." {
+		t.Errorf("builtin test = %q", got)
+	}
+
+	// Without built-ins a marker-only package needs root defaults to have tasks
+	bare := filepath.Join(root, "bare")
+	writeFile(t, filepath.Join(bare, "Cargo.toml"), "")
+	if pkg, err := resolvePackage(root, bare, nil, builtinTypes(false), nil); err != nil || pkg != nil {
+		t.Errorf("resolvePackage without builtins = %v, %v; want nil, nil", pkg, err)
+	}
+}
+
+func TestResolvePackageWhenExists(t *testing.T) {
+	root := t.TempDir()
+	defaults := resolveDefaults(map[string]TypeDefaults{"python": {Tasks: map[string]interface{}{
+		"typecheck": map[string]interface{}{"cmd": "mypy .", "when": map[string]interface{}{"exists": "mypy.ini"}},
+		"test":      map[string]interface{}{"cmd": "pytest -n auto", "when": map[string]interface{}{"exists": []interface{}{"conftest.py", "tests/*.py"}}},
+	}}})
+
+	typed := filepath.Join(root, "typed")
+	writeFile(t, filepath.Join(typed, "pyproject.toml"), "")
+	writeFile(t, filepath.Join(typed, "mypy.ini"), "")
+	writeFile(t, filepath.Join(typed, "conftest.py"), "")
+	writeFile(t, filepath.Join(typed, "tests", "test_a.py"), "")
+	plain := filepath.Join(root, "plain")
+	writeFile(t, filepath.Join(plain, "pyproject.toml"), "")
+	writeFile(t, filepath.Join(plain, "conftest.py"), "")
+
+	tests := []struct {
+		dir, typecheck, test string
+	}{
+		{typed, "mypy .", "pytest -n auto"},
+		// An unmet condition leaves the built-in test in place
+		{plain, "", "pytest"},
+	}
+	for _, tt := range tests {
+		pkg, err := resolvePackage(root, tt.dir, defaults, builtinTypes(true), nil)
+		if err != nil {
+			t.Fatalf("resolvePackage: %v", err)
+		}
+		got := ""
+		if tc, ok := pkg.Tasks["typecheck"]; ok {
+			got = tc.Cmds[0]
+		}
+		if got != tt.typecheck {
+			t.Errorf("%s typecheck = %q, want %q", pkg.Label, got, tt.typecheck)
+		}
+		if got := pkg.Tasks["test"].Cmds[0]; got != tt.test {
+			t.Errorf("%s test = %q, want %q", pkg.Label, got, tt.test)
+		}
+	}
+}
+
+func TestResolvePackageSkipTasks(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "svc")
+	writeFile(t, filepath.Join(dir, "go.mod"), "module svc\n")
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[package]\nskip_tasks = [\"lint\"]\n")
+	defaults := map[string]map[string]Task{
+		"go": {"lint": {Cmds: []string{"golangci-lint run"}}, "deploy": {Cmds: []string{"./deploy.sh"}}},
+	}
+
+	pkg, err := resolvePackage(root, dir, defaults, builtinTypes(true), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	if _, ok := pkg.Tasks["lint"]; ok {
+		t.Error("lint is in skip_tasks but still defined")
+	}
+	if _, ok := pkg.Tasks["deploy"]; !ok {
+		t.Error("deploy is not skipped but missing")
+	}
+
+	// A marker in go.mod skips without a ux.toml
+	marked := filepath.Join(root, "marked")
+	writeFile(t, filepath.Join(marked, "go.mod"), "module marked\n\n//ux:skip deploy\n")
+	pkg, err = resolvePackage(root, marked, defaults, builtinTypes(true), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	if _, ok := pkg.Tasks["deploy"]; ok || pkg.skipped["deploy"] != "go.mod" {
+		t.Errorf("deploy = %v, skipped by %q; want skipped by go.mod", pkg.Tasks["deploy"], pkg.skipped["deploy"])
+	}
+	if _, ok := pkg.Tasks["lint"]; !ok {
+		t.Error("lint is not skipped but missing")
+	}
+
+	// A bare marker is an error rather than skipping everything
+	writeFile(t, filepath.Join(marked, "go.mod"), "module marked\n\n//ux:skip\n")
+	if _, err := resolvePackage(root, marked, defaults, builtinTypes(true), nil); err == nil {
+		t.Error("expected an error for a marker naming no tasks")
+	}
+
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[package]\nskip_tasks = [\"lint\"]\n[tasks]\nlint = \"true\"\n")
+	if _, err := resolvePackage(root, dir, defaults, builtinTypes(true), nil); err == nil {
+		t.Error("expected an error for a task both skipped and defined")
+	}
+}
+
+func TestResolvePackageMetadata(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "api")
+	writeFile(t, filepath.Join(dir, "ux.toml"), `
+[package]
+type = "go"
+description = "Public HTTP API"
+resources = "heavy"
+`)
+	pkg, err := resolvePackage(root, dir, nil, builtinTypes(true), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	if pkg.Description != "Public HTTP API" || pkg.resources != "heavy" {
+		t.Errorf("description = %q, resources = %q", pkg.Description, pkg.resources)
+	}
+
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[package]\ntype = \"go\"\nweight = 2\nresources = \"heavy\"\n")
+	if _, err := resolvePackage(root, dir, nil, builtinTypes(true), nil); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Errorf("weight and resources: err = %v", err)
+	}
+}
+
+func TestResolvePackageExtends(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "presets", "base", "ux.toml"), `
+[tasks]
+lint = "ruff check ."
+test = "pytest"
+
+[env]
+PYTHONDONTWRITEBYTECODE = "1"
+LEVEL = "base"
+`)
+	writeFile(t, filepath.Join(root, "presets", "service", "ux.toml"), `
+[package]
+extends = "//presets/base"
+
+[tasks]
+test = "pytest -x {package_name}"
+
+[env]
+LEVEL = "service"
+`)
+	dir := filepath.Join(root, "services", "api")
+	writeFile(t, filepath.Join(dir, "ux.toml"), `
+[package]
+extends = "//presets/service"
+
+[tasks]
+lint = "ruff check src"
+
+[env]
+PORT = "8080"
+`)
+
+	pkg, err := resolvePackage(root, dir, nil, builtinTypes(false), nil)
+	if err != nil {
+		t.Fatalf("resolvePackage: %v", err)
+	}
+	expandPackageNames([]Package{*pkg}, DockerConfig{})
+	if got := pkg.Tasks["test"].Cmds[0]; got != "pytest -x api" {
+		t.Errorf("test = %q, want inherited and expanded", got)
+	}
+	if got := pkg.TaskSources["test"]; got != "//presets/service" {
+		t.Errorf("test source = %q", got)
+	}
+	if got := pkg.Tasks["lint"].Cmds[0]; got != "ruff check src" || pkg.TaskSources["lint"] != "override" {
+		t.Errorf("lint = %q (%s), want local override", got, pkg.TaskSources["lint"])
+	}
+	wantEnv := map[string]string{"PYTHONDONTWRITEBYTECODE": "1", "LEVEL": "service", "PORT": "8080"}
+	for k, v := range wantEnv {
+		if pkg.Env[k] != v {
+			t.Errorf("env %s = %q, want %q", k, pkg.Env[k], v)
+		}
+	}
+}
+
+func TestResolvePackageExtendsCycle(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a", "ux.toml"), "[package]\nextends = \"//b\"\n")
+	writeFile(t, filepath.Join(root, "b", "ux.toml"), "[package]\nextends = \"//a\"\n")
+
+	_, err := resolvePackage(root, filepath.Join(root, "a"), nil, builtinTypes(false), nil)
+	if err == nil || !strings.Contains(err.Error(), "extends cycle") {
+		t.Errorf("resolvePackage error = %v, want extends cycle", err)
+	}
+}
+
+func TestResolvePackageExtendsInvalidLabel(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "")
+	dir := filepath.Join(root, "svc")
+	for _, tt := range []struct {
+		extends, want string
+	}{
+		{"presets/base", `svc/ux.toml: "presets/base" is not a package label`},
+		{"//../outside", `svc/ux.toml: "//../outside" is not a package label`},
+		{"//presets/../../outside", "is not a package label"},
+		{"//presets/...", "is not a package label"},
+		{"//presets:test", "is not a package label"},
+		{"//presets/missing", "svc/ux.toml: no package //presets/missing to extend (no presets/missing/ux.toml)"},
+	} {
+		writeFile(t, filepath.Join(dir, "ux.toml"), fmt.Sprintf("[package]\nextends = %q\n", tt.extends))
+		_, err := resolvePackage(root, dir, nil, builtinTypes(false), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("extends %q: error = %v, want %q", tt.extends, err, tt.want)
+		}
+	}
+
+	// A bad label in a base package is reported against the base
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[package]\nextends = \"//presets/base\"\n")
+	writeFile(t, filepath.Join(root, "presets", "base", "ux.toml"), "[package]\nextends = \"//..\"\n")
+	_, err := resolvePackage(root, dir, nil, builtinTypes(false), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "presets/base/ux.toml: ") {
+		t.Errorf("nested: error = %v", err)
+	}
+}
+
+func TestDiscoverPackagesRootTasks(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//libs/..."]
+
+[root-tasks]
+docs = "mkdocs build"
+`)
+	writeFile(t, filepath.Join(root, "libs", "a", "go.mod"), "module a\n")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 2 || packages[0].Label != RootLabel {
+		t.Fatalf("got %d packages, first %s; want the root package first", len(packages), packages[0].Label)
+	}
+	rootPkg := packages[0]
+	if rootPkg.Dir != root || rootPkg.Tasks["docs"].Cmds[0] != "mkdocs build" || rootPkg.TaskSources["docs"] != "root-tasks" {
+		t.Errorf("root package = %+v", rootPkg)
+	}
+
+	// When the root is itself a member, root tasks are added to it
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//."]
+
+[root-tasks]
+docs = "mkdocs build"
+`)
+	writeFile(t, filepath.Join(root, "go.mod"), "module root\n")
+	cfg, err = LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err = DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 1 || packages[0].Type != "go" {
+		t.Fatalf("got %+v, want the root go package", packages)
+	}
+	if _, ok := packages[0].Tasks["docs"]; !ok {
+		t.Error("root tasks missing from the root package")
+	}
+	if _, ok := packages[0].Tasks["test"]; !ok {
+		t.Error("root package lost its type's tasks")
+	}
+}
+
+func TestDiscoverPackagesRootTaskSettings(t *testing.T) {
+	// The root's [tasks] configures tasks workspace-wide, even when the
+	// root is itself a package; it doesn't replace the root's own commands
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//.", "//libs/..."]
+
+[tasks]
+lint = { parallel = true }
+test = { parallel = false }
+`)
+	writeFile(t, filepath.Join(root, "go.mod"), "module root\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "go.mod"), "module a\n")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Tasks["lint"].Parallel {
+		t.Error("[tasks] lint isn't parallel")
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(packages))
+	}
+	for _, pkg := range packages {
+		for _, task := range []string{"lint", "test"} {
+			if cmds := pkg.Tasks[task].Cmds; len(cmds) == 0 || pkg.TaskSources[task] != "builtin" {
+				t.Errorf("%s %s = %v from %q, want the go default", pkg.Label, task, cmds, pkg.TaskSources[task])
+			}
+		}
+	}
+}
+
+func TestDiscoverPackagesNegation(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//services/...", "//tools/gen", "!//services/experimental/...", "!//services/legacy", "!//tools/gen"]
+`)
+	for _, dir := range []string{"services/api", "services/experimental", "services/experimental/x", "services/legacy", "services/legacy/sub", "tools/gen"} {
+		writeFile(t, filepath.Join(root, filepath.FromSlash(dir), "go.mod"), "module m\n")
+	}
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, pkg := range packages {
+		labels = append(labels, pkg.Label)
+	}
+	if want := []string{"//services/api", "//services/legacy/sub"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("packages = %v, want %v", labels, want)
+	}
+	if membersCover(cfg.Workspace.Members, "//services/experimental/x") || !membersCover(cfg.Workspace.Members, "//services/api") {
+		t.Error("membersCover ignores negations")
+	}
+}
+
+func TestDiscoverPackagesWorkspaceVars(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//app\"]\n")
+	writeFile(t, filepath.Join(root, "app", "ux.toml"), `
+[package]
+name = "app"
+
+[env]
+VERSION = "{git_short_sha}"
+
+[tasks]
+build = "docker build -t app:{git_sha} --label branch={git_branch} {workspace_root}"
+`)
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", root}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	sha := gitOutput(root, "rev-parse", "HEAD")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := packages[0]
+	if want := "docker build -t app:" + sha + " --label branch=main " + root; pkg.Tasks["build"].Cmds[0] != want {
+		t.Errorf("build = %q, want %q", pkg.Tasks["build"].Cmds[0], want)
+	}
+	if v := pkg.Env["VERSION"]; v == "" || !strings.HasPrefix(sha, v) {
+		t.Errorf("VERSION = %q, want a prefix of %s", v, sha)
+	}
+}
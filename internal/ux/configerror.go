@@ -0,0 +1,175 @@
+package ux
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigError is a config file ux couldn't use, located for a person to fix:
+// the file relative to the workspace root and, for TOML errors, where in it.
+type ConfigError struct {
+	File    string // workspace-relative path, e.g. services/api/ux.toml
+	Line    int    // 1-based; 0 when the error isn't tied to a line
+	Col     int    // 1-based; 0 when unknown
+	Key     string // the key being decoded, e.g. package.owners, if known
+	Message string
+	Snippet string // the offending line and the one before it, numbered, with a caret
+}
+
+func (e *ConfigError) Error() string {
+	loc := e.File
+	if e.Line > 0 {
+		loc += ":" + strconv.Itoa(e.Line)
+		if e.Col > 0 {
+			loc += ":" + strconv.Itoa(e.Col)
+		}
+	}
+	msg := loc + ": " + e.Message
+	if e.Key != "" {
+		msg += fmt.Sprintf(" (key %s)", e.Key)
+	}
+	return msg
+}
+
+// ConfigErrors are all the broken configs found in one discovery, so they
+// can be fixed in one go rather than one run at a time.
+type ConfigErrors []*ConfigError
+
+func (errs ConfigErrors) Error() string {
+	var b strings.Builder
+	if len(errs) == 1 {
+		b.WriteString("broken config:")
+	} else {
+		fmt.Fprintf(&b, "%d broken configs:", len(errs))
+	}
+	for _, e := range errs {
+		b.WriteString("\n  " + e.Error())
+		if e.Snippet != "" {
+			b.WriteString("\n" + e.Snippet)
+		}
+	}
+	return b.String()
+}
+
+// configErrors reports a lone *ConfigError as ConfigErrors, so it's shown
+// with its snippet, and adds context to any other error.
+func configErrors(err error, context string) error {
+	if e, ok := err.(*ConfigError); ok {
+		return ConfigErrors{e}
+	}
+	return fmt.Errorf("%s: %w", context, err)
+}
+
+// tomlErrorPattern matches the errors toml reports while decoding values,
+// which, unlike syntax errors, aren't a toml.ParseError.
+var tomlErrorPattern = regexp.MustCompile(`^toml: (?:line (\d+) )?\(last key "([^"]*)"\): (.*)$`)
+
+// decodeConfigFile decodes the TOML file at path into v, describing a
+// malformed file with a *ConfigError.
+func decodeConfigFile(root, path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := toml.Decode(string(data), v); err != nil {
+		return newConfigError(root, path, string(data), err)
+	}
+	return nil
+}
+
+// newConfigError describes err, from decoding the config at path whose
+// contents are data.
+func newConfigError(root, path, data string, err error) *ConfigError {
+	e := &ConfigError{File: configRel(root, path), Message: err.Error()}
+	var pe toml.ParseError
+	if errors.As(err, &pe) {
+		e.Line, e.Col, e.Key, e.Message = pe.Position.Line, pe.Position.Col, pe.LastKey, pe.Message
+	} else if m := tomlErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Key, e.Message = m[2], m[3]
+	}
+	lines := strings.Split(data, "\n")
+	if e.Line < 1 || e.Line > len(lines) {
+		e.Line, e.Col = 0, 0
+		return e
+	}
+	if e.Col == 0 && e.Key != "" {
+		// Point at the key on its line, if it's written there
+		name := e.Key[strings.LastIndex(e.Key, ".")+1:]
+		if i := strings.Index(lines[e.Line-1], name); i >= 0 {
+			e.Col = i + 1
+		}
+	}
+	e.Snippet = configSnippet(lines, e.Line, e.Col)
+	return e
+}
+
+// configSnippet shows line n of lines and the one before it, numbered, with
+// a caret under column col when it's known.
+func configSnippet(lines []string, n, col int) string {
+	var b strings.Builder
+	width := len(strconv.Itoa(n))
+	for i := max(n-1, 1); i <= n; i++ {
+		fmt.Fprintf(&b, "    %*d | %s\n", width, i, strings.TrimRight(lines[i-1], "\r"))
+	}
+	if col > 0 {
+		// Keep tabs, so the caret lines up however they're displayed
+		prefix := []rune(lines[n-1])[:min(col-1, len([]rune(lines[n-1])))]
+		pad := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, string(prefix))
+		fmt.Fprintf(&b, "    %*s | %s^\n", width, "", pad)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// asConfigError describes an error resolving the package in dir as a
+// problem with its ux.toml, unless it already names the file at fault.
+func asConfigError(root, dir string, err error) *ConfigError {
+	var e *ConfigError
+	if errors.As(err, &e) {
+		return e
+	}
+	return &ConfigError{File: configRel(root, filepath.Join(dir, "ux.toml")), Message: err.Error()}
+}
+
+// collectConfigErrors dedupes errs, which may repeat when packages extend
+// the same broken config, and sorts them by file and line.
+func collectConfigErrors(errs []*ConfigError) ConfigErrors {
+	seen := make(map[string]bool)
+	var result ConfigErrors
+	for _, e := range errs {
+		if key := e.Error(); !seen[key] {
+			seen[key] = true
+			result = append(result, e)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].Line < result[j].Line
+	})
+	return result
+}
+
+// configRel is path relative to the workspace root, or path itself when
+// it's outside it.
+func configRel(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
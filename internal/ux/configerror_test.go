@@ -0,0 +1,55 @@
+package ux
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverReportsEveryBrokenConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//libs/...\"]\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "ux.toml"), "[package]\nowners = \"@acme/data\"\n")
+	writeFile(t, filepath.Join(root, "libs", "b", "ux.toml"), "[tasks]\n\ttest = \"go test\n")
+	writeFile(t, filepath.Join(root, "libs", "c", "ux.toml"), "[package]\nweight = -1\n[tasks]\ntest = \"true\"\n")
+	writeFile(t, filepath.Join(root, "libs", "ok", "ux.toml"), "[tasks]\ntest = \"true\"\n")
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DiscoverPackages(root, cfg)
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("DiscoverPackages error = %v, want ConfigErrors", err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"libs/a/ux.toml:2:1: incompatible types: TOML value has type string; destination has type slice (key package.owners)",
+		"libs/b/ux.toml:2:17: strings cannot contain newlines (key tasks.test)",
+		"libs/c/ux.toml: [package] weight must be positive, got -1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if want := "    1 | [tasks]\n    2 | \ttest = \"go test\n      | \t               ^"; errs[1].Snippet != want {
+		t.Errorf("snippet:\n%s\nwant:\n%s", errs[1].Snippet, want)
+	}
+	if !strings.HasPrefix(err.Error(), "3 broken configs:\n  libs/a/ux.toml:2:1: ") {
+		t.Errorf("message:\n%s", err)
+	}
+}
+
+func TestFindWorkspaceRootBrokenFile(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//a\" \"//b\"]\n")
+	_, err := FindWorkspaceRootFrom(root)
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || errs[0].Error() != `ux.toml:2:18: expected a comma (',') or array terminator (']'), but got '"' (key workspace.members)` {
+		t.Errorf("FindWorkspaceRootFrom error = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package ux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ConfirmPrompt returns the question to ask before running stages, naming
+// each task with [tasks] confirm = true and how many packages it runs on,
+// or "" if no stage needs confirmation.
+func ConfirmPrompt(stages []Stage, cfg map[string]TaskConfig) string {
+	var order []string
+	counts := make(map[string]int)
+	for _, stage := range stages {
+		if !cfg[stage.Task].Confirm {
+			continue
+		}
+		if _, ok := counts[stage.Task]; !ok {
+			order = append(order, stage.Task)
+		}
+		counts[stage.Task] += len(stage.Packages)
+	}
+	if len(order) == 0 {
+		return ""
+	}
+	parts := make([]string, len(order))
+	for i, task := range order {
+		noun := "packages"
+		if counts[task] == 1 {
+			noun = "package"
+		}
+		parts[i] = fmt.Sprintf("%s on %d %s", task, counts[task], noun)
+	}
+	return "run " + strings.Join(parts, ", then ") + "?"
+}
+
+// Confirm asks prompt on the terminal and reports whether the answer was
+// yes. Without a terminal to ask on, it returns an error pointing at --yes.
+func Confirm(prompt string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("can't ask %q without a terminal; pass --yes to run anyway", prompt)
+	}
+	return confirm(os.Stdin, os.Stderr, prompt)
+}
+
+// confirm writes prompt to w and reads a y/N answer from r. Anything but y
+// or yes, including an empty line, is no.
+func confirm(r io.Reader, w io.Writer, prompt string) (bool, error) {
+	fmt.Fprintf(w, "%s %s ", styleWarning.Render(prompt), styleDim.Render("[y/N]"))
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,34 @@
+package ux
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirmPrompt(t *testing.T) {
+	pkgs := func(n int) []Package { return make([]Package, n) }
+	cfg := map[string]TaskConfig{"deploy": {Confirm: true}, "db-reset": {Confirm: true}}
+	tests := []struct {
+		stages []Stage
+		want   string
+	}{
+		{[]Stage{{Task: "build", Packages: pkgs(3)}}, ""},
+		{[]Stage{{Task: "build", Packages: pkgs(3)}, {Task: "deploy", Packages: pkgs(12)}}, "run deploy on 12 packages?"},
+		{[]Stage{{Task: "db-reset", Packages: pkgs(1)}, {Task: "deploy", Packages: pkgs(2)}, {Task: "deploy", Packages: pkgs(1)}}, "run db-reset on 1 package, then deploy on 3 packages?"},
+	}
+	for _, tt := range tests {
+		if got := ConfirmPrompt(tt.stages, cfg); got != tt.want {
+			t.Errorf("ConfirmPrompt = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "YES\n": true, "\n": false, "n\n": false, "": false, "y": true} {
+		got, err := confirm(strings.NewReader(answer), io.Discard, "run deploy?")
+		if err != nil || got != want {
+			t.Errorf("answer %q = %v, %v; want %v", answer, got, err, want)
+		}
+	}
+}
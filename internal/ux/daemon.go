@@ -0,0 +1,409 @@
+package ux
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DaemonEnv, set to "off", stops ux from using a running `ux daemon`.
+const DaemonEnv = "UX_DAEMON"
+
+// daemonProtocol changes whenever requests or responses do, so a client
+// ignores a daemon from another ux version instead of misreading it.
+const daemonProtocol = 4
+
+// daemonDrainInterval is how often an idle daemon reads pending change
+// events, so they don't pile up past the kernel's queue limit.
+const daemonDrainInterval = time.Second
+
+// DaemonSocketPath returns where the workspace's daemon listens: .ux/daemon.sock,
+// or a path in the temp dir when that's too long for a unix socket.
+func DaemonSocketPath(root string) string {
+	path := filepath.Join(root, StateDir, "daemon.sock")
+	if len(path) < 100 {
+		return path
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(os.TempDir(), "ux-daemon-"+hex.EncodeToString(sum[:6])+".sock")
+}
+
+// daemonRequest is one request to a daemon, sent as JSON on its own
+// connection.
+type daemonRequest struct {
+	Protocol int    `json:"protocol"`
+	Op       string `json:"op"` // "discover", "hash", "changed", or "stop"
+	// Members and Markers are for "discover": the [workspace] members and
+	// the marker files of every package type, in priority order.
+	Members []string `json:"members,omitempty"`
+	Markers []string `json:"markers,omitempty"`
+	Skip    []string `json:"skip,omitempty"`  // [discovery] skip, for "discover"
+	Paths   []string `json:"paths,omitempty"` // absolute, for "hash"
+	Base    string   `json:"base,omitempty"`  // the base ref, for "changed"
+	// Follow is [discovery] follow_symlinks, for "discover".
+	Follow bool `json:"follow,omitempty"`
+}
+
+// daemonResponse is a daemon's answer; only the field for the request's op
+// is set.
+type daemonResponse struct {
+	Error string   `json:"error,omitempty"`
+	Dirs  []string `json:"dirs,omitempty"`  // package dirs, workspace-relative and slash-separated
+	Sums  []string `json:"sums,omitempty"`  // hex SHA-256 of each path
+	Files []string `json:"files,omitempty"` // files changed vs the base ref
+}
+
+// daemonDown records the roots whose daemon didn't answer, so a run tries
+// each only once.
+var daemonDown sync.Map
+
+// callDaemon sends req to the daemon of the workspace at root, reporting
+// false when there's none or it fails; callers then do the work themselves.
+func callDaemon(root string, req daemonRequest) (*daemonResponse, bool) {
+	if root == "" || os.Getenv(DaemonEnv) == "off" {
+		return nil, false
+	}
+	if _, down := daemonDown.Load(root); down {
+		return nil, false
+	}
+	conn, err := net.DialTimeout("unix", DaemonSocketPath(root), time.Second)
+	if err != nil {
+		daemonDown.Store(root, true)
+		return nil, false
+	}
+	defer conn.Close()
+	req.Protocol = daemonProtocol
+	var resp daemonResponse
+	if err := json.NewEncoder(conn).Encode(req); err == nil {
+		err = json.NewDecoder(conn).Decode(&resp)
+	}
+	if err == nil && resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	if err != nil {
+		logger.Debug("daemon", "op", req.Op, "err", err)
+		return nil, false
+	}
+	return &resp, true
+}
+
+// daemonPackageDirs asks the daemon for the package dirs members hold.
+func daemonPackageDirs(root string, members []string, types *packageTypes) ([]string, bool) {
+	markers := make([]string, len(types.markers))
+	for i, m := range types.markers {
+		markers[i] = m.file
+	}
+	resp, ok := callDaemon(root, daemonRequest{Op: "discover", Members: members, Markers: markers, Skip: types.skip, Follow: types.followSymlinks})
+	if !ok {
+		return nil, false
+	}
+	dirs := make([]string, len(resp.Dirs))
+	for i, rel := range resp.Dirs {
+		dirs[i] = filepath.Join(root, filepath.FromSlash(rel))
+	}
+	logger.Debug("discovered by the daemon", "candidates", len(dirs))
+	return dirs, true
+}
+
+// daemonHashes asks the daemon for the SHA-256 of each path.
+func daemonHashes(root string, paths []string) ([]string, bool) {
+	resp, ok := callDaemon(root, daemonRequest{Op: "hash", Paths: paths})
+	if !ok || len(resp.Sums) != len(paths) {
+		return nil, false
+	}
+	return resp.Sums, true
+}
+
+// daemonChangedFiles asks the daemon for the files changed vs base.
+func daemonChangedFiles(root, base string) ([]string, bool) {
+	resp, ok := callDaemon(root, daemonRequest{Op: "changed", Base: base})
+	if !ok {
+		return nil, false
+	}
+	return resp.Files, true
+}
+
+// StopDaemon asks the workspace's daemon to exit.
+func StopDaemon(root string) error {
+	conn, err := net.DialTimeout("unix", DaemonSocketPath(root), time.Second)
+	if err != nil {
+		return fmt.Errorf("no daemon is running for %s", root)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Protocol: daemonProtocol, Op: "stop"}); err != nil {
+		return err
+	}
+	var resp daemonResponse
+	return json.NewDecoder(conn).Decode(&resp)
+}
+
+// ListenDaemon listens on the workspace's daemon socket, replacing a stale
+// one left by a daemon that didn't exit cleanly.
+func ListenDaemon(root string) (net.Listener, error) {
+	path := DaemonSocketPath(root)
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already running for %s", root)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	return net.Listen("unix", path)
+}
+
+// Daemon is the server side of `ux daemon`: it keeps what every run of ux
+// in a workspace would otherwise redo warm in memory, and answers runs
+// over a unix socket (see DaemonSocketPath). It remembers
+//
+//   - the directory listings discovery walks, until the kernel reports a
+//     change in the directory;
+//   - the hashes of files that feed cache keys, until a file's size or
+//     modification time changes;
+//   - the files changed vs the base ref, until HEAD or its merge base with
+//     the base ref moves.
+//
+// Change events are read before each request is answered, so a run never
+// sees a listing older than the changes made before it started.
+type Daemon struct {
+	Root string
+
+	mu      sync.Mutex
+	watcher *dirWatcher
+	walker  *dirWalker // nil until the first discovery
+	markers []string   // the marker files walker records
+
+	hashMu sync.Mutex
+	hashes map[string]hashedFile // by absolute path
+
+	gitMu   sync.Mutex
+	gitRefs string // the base ref, HEAD, and their merge base when changed was computed
+	changed []string
+
+	done chan struct{}
+}
+
+// hashedFile is a file hash, valid while the file's size and modification
+// time are unchanged.
+type hashedFile struct {
+	size  int64
+	mtime int64
+	sum   string
+}
+
+// NewDaemon returns a daemon for the workspace at root. It fails where the
+// kernel can't report directory changes.
+func NewDaemon(root string) (*Daemon, error) {
+	w, err := newDirWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &Daemon{Root: root, watcher: w, hashes: make(map[string]hashedFile), done: make(chan struct{})}, nil
+}
+
+// Warm walks the workspace's members, so the first run is fast too.
+func (d *Daemon) Warm(cfg *RootConfig) error {
+	types, err := loadPackageTypes(d.Root, cfg)
+	if err != nil {
+		return err
+	}
+	markers := make([]string, len(types.markers))
+	for i, m := range types.markers {
+		markers[i] = m.file
+	}
+	_, err = d.discover(cfg.Workspace.Members, markers, types.skip, types.followSymlinks)
+	return err
+}
+
+// Serve answers requests on ln until it's closed or a client asks the
+// daemon to stop.
+func (d *Daemon) Serve(ln net.Listener) error {
+	go func() {
+		tick := time.NewTicker(daemonDrainInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-tick.C:
+				d.mu.Lock()
+				d.drain()
+				d.mu.Unlock()
+			}
+		}
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			var req daemonRequest
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				return
+			}
+			json.NewEncoder(conn).Encode(d.answer(req))
+			if req.Op == "stop" {
+				ln.Close()
+			}
+		}()
+	}
+}
+
+// Close stops watching the workspace.
+func (d *Daemon) Close() {
+	close(d.done)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watcher.close()
+}
+
+func (d *Daemon) answer(req daemonRequest) daemonResponse {
+	if req.Protocol != daemonProtocol {
+		return daemonResponse{Error: fmt.Sprintf("daemon speaks protocol %d, not %d; restart it", daemonProtocol, req.Protocol)}
+	}
+	var resp daemonResponse
+	var err error
+	switch req.Op {
+	case "discover":
+		resp.Dirs, err = d.discover(req.Members, req.Markers, req.Skip, req.Follow)
+	case "hash":
+		resp.Sums, err = d.hash(req.Paths)
+	case "changed":
+		resp.Files, err = d.changedFiles(req.Base)
+	case "stop":
+	default:
+		err = fmt.Errorf("unknown op %q", req.Op)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	logger.Debug("daemon request", "op", req.Op, "err", err)
+	return resp
+}
+
+// drain forgets the listings of directories that changed since the last
+// call. If the kernel dropped events, it forgets everything.
+func (d *Daemon) drain() {
+	overflowed, err := d.watcher.drain(func(dir string, self bool) {
+		if d.walker != nil {
+			d.walker.forget(dir, self)
+		}
+	})
+	if err != nil || overflowed {
+		logger.Debug("daemon lost change events; rereading everything", "err", err)
+		d.reset()
+	}
+}
+
+// reset drops every listing and watch.
+func (d *Daemon) reset() {
+	d.walker = nil
+	d.watcher.close()
+	if w, err := newDirWatcher(); err == nil {
+		d.watcher = w
+	}
+}
+
+func (d *Daemon) discover(members, markers, skip []string, follow bool) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.drain()
+	types := &packageTypes{markers: make([]typeMarker, len(markers)), skip: skip, followSymlinks: follow}
+	for i, m := range markers {
+		types.markers[i] = typeMarker{file: m}
+	}
+	if d.walker == nil || !slices.Equal(d.markers, markers) {
+		// Listings record marker files, so new ones mean reading again
+		d.walker = newDirWalker(d.Root, types, nil)
+		d.markers = markers
+	}
+	d.walker.skip, d.walker.follow = skip, follow
+	var watchMu sync.Mutex
+	var watchErr error
+	d.walker.watch = func(dir string) error {
+		err := d.watcher.add(dir)
+		if err != nil {
+			watchMu.Lock()
+			watchErr = cmp.Or(watchErr, err)
+			watchMu.Unlock()
+		}
+		return err
+	}
+	dirs := findPackageDirs(d.Root, members, types, d.walker)
+	if watchErr != nil {
+		// A directory we can't watch could change unnoticed
+		d.reset()
+		return nil, watchErr
+	}
+	for i, dir := range dirs {
+		rel, _ := filepath.Rel(d.Root, dir)
+		dirs[i] = filepath.ToSlash(rel)
+	}
+	return dirs, nil
+}
+
+func (d *Daemon) hash(paths []string) ([]string, error) {
+	sums := make([]string, len(paths))
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		mtime := info.ModTime().UnixNano()
+		d.hashMu.Lock()
+		h, ok := d.hashes[path]
+		d.hashMu.Unlock()
+		if ok && h.size == info.Size() && h.mtime == mtime {
+			sums[i] = h.sum
+			continue
+		}
+		if sums[i], err = fileSHA256(path); err != nil {
+			return nil, err
+		}
+		// A write in the same mtime tick as the read would go unnoticed
+		if time.Since(info.ModTime()) >= racyWindow {
+			d.hashMu.Lock()
+			d.hashes[path] = hashedFile{size: info.Size(), mtime: mtime, sum: sums[i]}
+			d.hashMu.Unlock()
+		}
+	}
+	return sums, nil
+}
+
+func (d *Daemon) changedFiles(base string) ([]string, error) {
+	if base == "" {
+		base = DefaultBaseRef
+	}
+	d.gitMu.Lock()
+	defer d.gitMu.Unlock()
+	// Without a merge base, changedFiles diffs base against the working
+	// tree, which changes with every edit, so that result isn't kept.
+	var refs string
+	head := gitOutput(d.Root, "rev-parse", "HEAD")
+	if mergeBase := gitOutput(d.Root, "merge-base", base, "HEAD"); head != "" && mergeBase != "" {
+		refs = base + "\n" + head + "\n" + mergeBase
+	}
+	if refs != "" && refs == d.gitRefs {
+		return d.changed, nil
+	}
+	files, err := changedFiles(d.Root, base)
+	if err != nil {
+		return nil, err
+	}
+	d.gitRefs, d.changed = refs, files
+	return files, nil
+}
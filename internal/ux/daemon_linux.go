@@ -0,0 +1,84 @@
+package ux
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchEvents are the inotify events that change a directory's listing,
+// or mean the directory itself is gone.
+const watchEvents = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+// dirWatcher reports changes to the entries of watched directories, using
+// a non-blocking inotify instance that's read on demand.
+type dirWatcher struct {
+	fd   int
+	mu   sync.Mutex
+	dirs map[int]string // watch descriptor → directory
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("watching for changes: %w", err)
+	}
+	return &dirWatcher{fd: fd, dirs: make(map[int]string)}, nil
+}
+
+// add starts watching dir. Watching a directory again under a new path,
+// after it moved, updates the path its events report.
+func (w *dirWatcher) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, watchEvents)
+	if errors.Is(err, unix.ENOSPC) {
+		return fmt.Errorf("watching %s: out of inotify watches; raise fs.inotify.max_user_watches", dir)
+	}
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", dir, err)
+	}
+	w.mu.Lock()
+	w.dirs[wd] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// drain calls changed for each directory with pending events, with self
+// set when the directory itself was removed or moved. It reports whether
+// the kernel dropped events because too many were pending.
+func (w *dirWatcher) drain(changed func(dir string, self bool)) (overflowed bool, err error) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if errors.Is(err, unix.EAGAIN) {
+			return overflowed, nil
+		}
+		if err != nil {
+			return overflowed, err
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+			if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+				overflowed = true
+				continue
+			}
+			w.mu.Lock()
+			dir, ok := w.dirs[int(ev.Wd)]
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(ev.Wd))
+			}
+			w.mu.Unlock()
+			if ok {
+				changed(dir, ev.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_IGNORED) != 0)
+			}
+		}
+	}
+}
+
+func (w *dirWatcher) close() {
+	unix.Close(w.fd)
+}
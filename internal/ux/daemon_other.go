@@ -0,0 +1,18 @@
+//go:build !linux
+
+package ux
+
+import "errors"
+
+// dirWatcher is unavailable: directory changes are only watched on Linux.
+type dirWatcher struct{}
+
+func newDirWatcher() (*dirWatcher, error) {
+	return nil, errors.New("ux daemon needs inotify, so it only runs on Linux")
+}
+
+func (w *dirWatcher) add(dir string) error { return nil }
+
+func (w *dirWatcher) drain(changed func(dir string, self bool)) (bool, error) { return false, nil }
+
+func (w *dirWatcher) close() {}
@@ -0,0 +1,120 @@
+package ux
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDaemon(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//libs/...\"]\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "go.mod"), "module a\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "x", "x.go"), "package x\n")
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDaemon(root)
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := d.Warm(cfg); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := ListenDaemon(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- d.Serve(ln) }()
+	t.Cleanup(func() {
+		if err := StopDaemon(root); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+		d.Close()
+	})
+	daemonDown.Delete(root)
+
+	discover := func() []string {
+		t.Helper()
+		packages, err := DiscoverPackages(root, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, pkg := range packages {
+			labels = append(labels, pkg.Label)
+		}
+		return labels
+	}
+	if got := discover(); !reflect.DeepEqual(got, []string{"//libs/a"}) {
+		t.Fatalf("discovery = %v", got)
+	}
+	if _, down := daemonDown.Load(root); down {
+		t.Fatal("the daemon didn't answer")
+	}
+
+	// Changes made before a run are seen by it, in new and removed
+	// directories alike
+	writeFile(t, filepath.Join(root, "libs", "b", "go.mod"), "module b\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "x", "go.mod"), "module x\n")
+	if got := discover(); !reflect.DeepEqual(got, []string{"//libs/a", "//libs/a/x", "//libs/b"}) {
+		t.Errorf("discovery after adding packages = %v", got)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "libs", "a")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "libs", "a", "x", "x.go"), "package x\n")
+	if got := discover(); !reflect.DeepEqual(got, []string{"//libs/b"}) {
+		t.Errorf("discovery after removing packages = %v", got)
+	}
+
+	// Hashes match the files' contents, before and after they change
+	path := filepath.Join(root, "libs", "b", "go.mod")
+	for _, content := range []string{"module b\n", "module b\n\ngo 1.24\n"} {
+		writeFile(t, path, content)
+		got, err := hashFiles(root, filepath.Join(root, "libs", "b"), []string{"go.mod"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := fileSHA256(path)
+		if !reflect.DeepEqual(got, []string{want}) {
+			t.Errorf("hash of %q = %v, want %s", content, got, want)
+		}
+	}
+}
+
+func TestDaemonChangedFilesWithoutMergeBase(t *testing.T) {
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", root, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeFile(t, filepath.Join(root, "a.txt"), "a\n")
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	git("checkout", "-q", "--orphan", "other")
+	git("commit", "-q", "-m", "unrelated")
+
+	// With no merge base, the diff is against the working tree, so it
+	// can't be kept until HEAD moves
+	d := &Daemon{Root: root}
+	if files, err := d.changedFiles("main"); err != nil || len(files) != 0 {
+		t.Fatalf("changed files = %v, %v; want none", files, err)
+	}
+	writeFile(t, filepath.Join(root, "a.txt"), "edited\n")
+	if files, err := d.changedFiles("main"); err != nil || !reflect.DeepEqual(files, []string{"a.txt"}) {
+		t.Errorf("changed files after an edit = %v, %v; want [a.txt]", files, err)
+	}
+}
@@ -0,0 +1,42 @@
+package ux
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// LogLevelEnv names the environment variable that sets the log level when
+// --log-level isn't given.
+const LogLevelEnv = "UX_LOG_LEVEL"
+
+// logger records what ux decides and why: discovery, filtering, git
+// commands, and scheduling. It discards everything until EnableLogging.
+var logger = slog.New(slog.DiscardHandler)
+
+// EnableLogging sends log records at level ("debug", "info", "warn", or
+// "error") and above to stderr, tagged with the run ID, so stdout keeps only
+// summaries and JSON. Call it before anything runs concurrently.
+func EnableLogging(level, runID string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q (use debug, info, warn, or error)", level)
+	}
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l})).With("run", runID)
+	return nil
+}
+
+// Logger returns ux's logger, for the CLI's own decisions.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// gitCommand returns a git command run in dir, logging it.
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	logger.Debug("git", "dir", dir, "args", strings.Join(args, " "))
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd
+}
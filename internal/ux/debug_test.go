@@ -0,0 +1,26 @@
+package ux
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestEnableLogging(t *testing.T) {
+	defer func(l *slog.Logger) { logger = l }(logger)
+	if logger.Enabled(context.Background(), slog.LevelError) {
+		t.Error("logging is on before EnableLogging")
+	}
+	if err := EnableLogging("verbose", "run"); err == nil {
+		t.Error("accepted an unknown level")
+	}
+	if err := EnableLogging("debug", "run"); err != nil {
+		t.Fatal(err)
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug records are dropped at --log-level debug")
+	}
+	if err := EnableLogging("WARN", "run"); err != nil || logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Errorf("at warn: err %v, info enabled %v", err, logger.Enabled(context.Background(), slog.LevelInfo))
+	}
+}
@@ -0,0 +1,459 @@
+package ux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoverWorkers bounds how many directories discovery reads at once.
+// Discovery is I/O-bound, so this is well above the CPU count: on a network
+// filesystem most of a directory read is spent waiting.
+const discoverWorkers = 32
+
+// racyWindow is how recently a directory may have changed and still have
+// its listing cached. A change within the same mtime tick as the listing
+// would otherwise go unnoticed on filesystems with coarse timestamps.
+const racyWindow = 2 * time.Second
+
+// dirListing is what discovery needs from a directory: the subdirectories to
+// walk and which of the files that make a package are present.
+type dirListing struct {
+	ModTime int64    `json:"mtime"` // UnixNano
+	Dirs    []string `json:"dirs,omitempty"`
+	Files   []string `json:"files,omitempty"` // ux.toml and marker files
+	// Links are the symlinks that could lead to directories, walked only
+	// with [discovery] follow_symlinks.
+	Links []string `json:"links,omitempty"`
+}
+
+// discoveryIndexVersion changes when listings record something new, so
+// older ones are read again.
+const discoveryIndexVersion = 1
+
+// discoveryIndex is .ux/index.json: the listings of the directories members
+// patterns walk, reused while a directory's mtime is unchanged, and each
+// package's parsed ux.toml, reused while the file's size and mtime are.
+// Adding or removing an entry updates its directory's mtime, so only the
+// subtrees under changed directories are read again.
+type discoveryIndex struct {
+	Version int `json:"version"`
+	// Files are the names listings record; a change invalidates the listings.
+	Files   []string                 `json:"files"`
+	Dirs    map[string]dirListing    `json:"dirs"`              // by workspace-relative path
+	Configs map[string]indexedConfig `json:"configs,omitempty"` // by package dir, likewise
+
+	mu      sync.Mutex
+	changed bool            // the file needs writing
+	used    map[string]bool // configs read this run
+	reused  int             // configs parsed by an earlier run
+}
+
+// indexedConfig is a package's parsed ux.toml, with the size and mtime of
+// the file it was parsed from.
+type indexedConfig struct {
+	ModTime int64       `json:"mtime"` // UnixNano
+	Size    int64       `json:"size"`
+	Config  packageFile `json:"config"`
+}
+
+func discoveryIndexPath(root string) string {
+	return filepath.Join(root, StateDir, "index.json")
+}
+
+// loadDiscoveryIndex reads the index, or returns an empty one if it is
+// missing, unreadable, or rescan is set. Listings that recorded other files
+// than types' markers are dropped.
+func loadDiscoveryIndex(root string, types *packageTypes, rescan bool) *discoveryIndex {
+	c := &discoveryIndex{used: make(map[string]bool)}
+	if data, err := os.ReadFile(discoveryIndexPath(root)); err == nil && !rescan {
+		json.Unmarshal(data, c)
+	}
+	files := discoveryFiles(types)
+	if !slices.Equal(c.Files, files) || c.Version != discoveryIndexVersion {
+		c.Dirs = nil
+	}
+	c.Files = files
+	c.Version = discoveryIndexVersion
+	c.changed = rescan
+	return c
+}
+
+// readPackageFile parses the ux.toml in dir, if there is one, through the
+// index when c isn't nil.
+func (c *discoveryIndex) readPackageFile(root, dir string) (raw packageFile, found bool, err error) {
+	path := filepath.Join(dir, "ux.toml")
+	info, err := os.Stat(path)
+	if err != nil {
+		return raw, false, nil
+	}
+	if c == nil {
+		err = decodeConfigFile(root, path, &raw)
+		return raw, err == nil, err
+	}
+
+	rel := slashRel(root, dir)
+	mtime := info.ModTime().UnixNano()
+	c.mu.Lock()
+	indexed, ok := c.Configs[rel]
+	c.used[rel] = true
+	c.mu.Unlock()
+	if ok && indexed.ModTime == mtime && indexed.Size == info.Size() {
+		c.mu.Lock()
+		c.reused++
+		c.mu.Unlock()
+		return indexed.Config, true, nil
+	}
+	if err := decodeConfigFile(root, path, &raw); err != nil {
+		return raw, false, err
+	}
+	// A write in the same mtime tick as the read would go unnoticed
+	if time.Since(info.ModTime()) >= racyWindow {
+		c.mu.Lock()
+		if c.Configs == nil {
+			c.Configs = make(map[string]indexedConfig)
+		}
+		c.Configs[rel] = indexedConfig{ModTime: mtime, Size: info.Size(), Config: raw}
+		c.changed = true
+		c.mu.Unlock()
+	}
+	return raw, true, nil
+}
+
+// save replaces the index file if anything in it changed, atomically so
+// concurrent runs never read a partial one. Configs no package read this
+// run are dropped.
+func (c *discoveryIndex) save(root string) error {
+	if c == nil {
+		return nil
+	}
+	for rel := range c.Configs {
+		if !c.used[rel] {
+			delete(c.Configs, rel)
+			c.changed = true
+		}
+	}
+	if !c.changed {
+		return nil
+	}
+	path := discoveryIndexPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "index-*.json")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// discoveryFiles returns the sorted names listings record: ux.toml and the
+// types' marker files.
+func discoveryFiles(types *packageTypes) []string {
+	files := []string{"ux.toml"}
+	for _, m := range types.markers {
+		if !slices.Contains(files, m.file) {
+			files = append(files, m.file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// DiscoveryConfig extends how packages are found ([discovery]).
+type DiscoveryConfig struct {
+	// Skip names more directories discovery doesn't descend into, beyond
+	// hidden ones and the usual dependency and build directories. Globs
+	// match a directory's name: ["coverage", "tmp*"].
+	Skip []string `toml:"skip"`
+	// Markers maps more marker files to the type they identify:
+	// "deno.json" = "deno". They're checked before every other marker.
+	Markers map[string]string `toml:"markers"`
+	// FollowSymlinks walks symlinks to directories, such as vendored
+	// packages shared between trees. A link back into a directory it's
+	// already inside is left out.
+	FollowSymlinks bool `toml:"follow_symlinks"`
+}
+
+// dirWalker lists directories for discovery, concurrently and at most once
+// per directory per run, through the discovery index when it's enabled.
+type dirWalker struct {
+	root   string
+	wanted map[string]bool
+	skip   []string // [discovery] skip, applied as the walk descends
+	follow bool     // [discovery] follow_symlinks
+	sem    chan struct{}
+	cache  *discoveryIndex // nil when disabled
+	// watch, if set, is called before a directory is first read, so a
+	// `ux daemon` hears of changes to it from then on.
+	watch func(dir string) error
+
+	mu      sync.Mutex
+	listed  map[string]dirListing // this run's listings, by relative path
+	changed bool                  // a listing differs from the index
+	cached  int                   // listings reused from the index
+}
+
+// newDirWalker returns a walker that reuses listings from index, if it
+// isn't nil.
+func newDirWalker(root string, types *packageTypes, index *discoveryIndex) *dirWalker {
+	w := &dirWalker{
+		root:   root,
+		wanted: make(map[string]bool),
+		skip:   types.skip,
+		follow: types.followSymlinks,
+		sem:    make(chan struct{}, discoverWorkers),
+		cache:  index,
+		listed: make(map[string]dirListing),
+	}
+	for _, f := range discoveryFiles(types) {
+		w.wanted[f] = true
+	}
+	return w
+}
+
+// list returns dir's listing: from this run, from the cache if dir's mtime
+// matches, or by reading it.
+func (w *dirWalker) list(dir string) (dirListing, error) {
+	rel := slashRel(w.root, dir)
+	w.mu.Lock()
+	l, ok := w.listed[rel]
+	w.mu.Unlock()
+	if ok {
+		return l, nil
+	}
+
+	w.sem <- struct{}{}
+	defer func() { <-w.sem }()
+	if w.watch != nil {
+		if err := w.watch(dir); err != nil {
+			return dirListing{}, err
+		}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return dirListing{}, err
+	}
+	mtime := info.ModTime().UnixNano()
+	var cached map[string]dirListing
+	if w.cache != nil {
+		cached = w.cache.Dirs
+	}
+	l, ok = cached[rel]
+	hit := ok && l.ModTime == mtime
+	if !hit {
+		if l, err = w.read(dir, mtime); err != nil {
+			return dirListing{}, err
+		}
+	}
+
+	if time.Since(info.ModTime()) < racyWindow {
+		// Too new to trust next run; leave it out of the cache file
+		l.ModTime = 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listed[rel] = l
+	if hit {
+		w.cached++
+	}
+	if prev, ok := cached[rel]; !ok || prev.ModTime != l.ModTime {
+		w.changed = true
+	}
+	return l, nil
+}
+
+// read lists dir from disk. Hidden and junk directories aren't walked.
+// Symlinks are listed whether or not they're followed, and resolved as
+// they're walked.
+func (w *dirWalker) read(dir string, mtime int64) (dirListing, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return dirListing{}, err
+	}
+	l := dirListing{ModTime: mtime}
+	for _, e := range entries {
+		name := e.Name()
+		if w.wanted[name] {
+			l.Files = append(l.Files, name)
+		}
+		if strings.HasPrefix(name, ".") || skipDirs[name] {
+			continue
+		}
+		if e.IsDir() {
+			l.Dirs = append(l.Dirs, name)
+		} else if e.Type()&fs.ModeSymlink != 0 {
+			l.Links = append(l.Links, name)
+		}
+	}
+	return l, nil
+}
+
+// skips reports whether [discovery] skip leaves out directories named
+// name. Listings keep them, so the index and daemon don't depend on it.
+func (w *dirWalker) skips(name string) bool {
+	return skipsDir(w.skip, name)
+}
+
+// skipsDir reports whether any of the [discovery] skip globs matches a
+// directory's name. The globs are checked by DiscoveryConfig.validate.
+func skipsDir(skip []string, name string) bool {
+	return slices.ContainsFunc(skip, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+}
+
+// validate checks that every skip glob is well-formed, which path.Match
+// otherwise only reports by never matching.
+func (c DiscoveryConfig) validate() error {
+	for _, pattern := range c.Skip {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("[discovery] skip: invalid glob %q", pattern)
+		}
+	}
+	return nil
+}
+
+// walk calls visit for every directory below base (not base itself) that
+// discovery descends into, concurrently. Unreadable directories are skipped.
+func (w *dirWalker) walk(base string, visit func(dir string, l dirListing)) {
+	var baseReal string
+	if w.follow {
+		var err error
+		if baseReal, err = filepath.EvalSymlinks(base); err != nil {
+			return
+		}
+	}
+	var wg sync.WaitGroup
+	var descend func(dir string, chain *walkPath)
+	descend = func(dir string, chain *walkPath) {
+		defer wg.Done()
+		l, err := w.list(dir)
+		if err != nil {
+			return
+		}
+		if dir != base {
+			visit(dir, l)
+		}
+		for _, sub := range l.Dirs {
+			if w.skips(sub) {
+				continue
+			}
+			var next *walkPath
+			if w.follow {
+				next = &walkPath{filepath.Join(chain.real, sub), chain}
+			}
+			wg.Add(1)
+			go descend(filepath.Join(dir, sub), next)
+		}
+		if !w.follow {
+			return
+		}
+		for _, sub := range l.Links {
+			if w.skips(sub) {
+				continue
+			}
+			real, err := filepath.EvalSymlinks(filepath.Join(dir, sub))
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(real); err != nil || !info.IsDir() || chain.contains(real) {
+				continue
+			}
+			wg.Add(1)
+			go descend(filepath.Join(dir, sub), &walkPath{real, chain})
+		}
+	}
+	wg.Add(1)
+	descend(base, &walkPath{real: baseReal})
+	wg.Wait()
+}
+
+// walkPath is the chain of real directories a symlink-following walk is
+// inside, innermost first, so a link back into one isn't walked forever.
+type walkPath struct {
+	real string
+	up   *walkPath
+}
+
+func (p *walkPath) contains(real string) bool {
+	for ; p != nil; p = p.up {
+		if p.real == real {
+			return true
+		}
+	}
+	return false
+}
+
+// forget drops dir's listing, so the next walk reads it again. With
+// subtree, the listings below it are dropped too.
+func (w *dirWalker) forget(dir string, subtree bool) {
+	rel := slashRel(w.root, dir)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.listed, rel)
+	if !subtree {
+		return
+	}
+	for r := range w.listed {
+		if rel == "" || strings.HasPrefix(r, rel+"/") {
+			delete(w.listed, r)
+		}
+	}
+}
+
+// record puts this run's listings in the index if any changed. Directories
+// no longer walked are dropped.
+func (w *dirWalker) record() {
+	if w.cache == nil || !w.changed && len(w.listed) == len(w.cache.Dirs) {
+		return
+	}
+	w.cache.Dirs = make(map[string]dirListing, len(w.listed))
+	for rel, l := range w.listed {
+		if l.ModTime != 0 {
+			w.cache.Dirs[rel] = l
+		}
+	}
+	w.cache.changed = true
+}
+
+// isPackageListing reports whether a directory with listing l has a ux.toml
+// or a marker file. Markers with a path are looked up on disk.
+func (t *packageTypes) isPackageListing(dir string, l dirListing) bool {
+	if slices.Contains(l.Files, "ux.toml") {
+		return true
+	}
+	for _, m := range t.markers {
+		if strings.ContainsAny(m.file, `/\`) {
+			if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+				return true
+			}
+		} else if slices.Contains(l.Files, m.file) {
+			return true
+		}
+	}
+	return false
+}
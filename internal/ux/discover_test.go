@@ -0,0 +1,258 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoverPackagesCache(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//libs/...\"]\ndiscovery_cache = true\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "go.mod"), "module a\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "internal", "x.go"), "package x\n")
+	old := time.Now().Add(-time.Hour)
+	for _, dir := range []string{"libs", "libs/a", "libs/a/internal"} {
+		if err := os.Chtimes(filepath.Join(root, dir), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	discover := func() []string {
+		t.Helper()
+		cfg, err := LoadRootConfig(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packages, err := DiscoverPackages(root, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, pkg := range packages {
+			labels = append(labels, pkg.Label)
+		}
+		return labels
+	}
+
+	if got := discover(); !reflect.DeepEqual(got, []string{"//libs/a"}) {
+		t.Fatalf("first discovery = %v", got)
+	}
+	if _, err := os.Stat(discoveryIndexPath(root)); err != nil {
+		t.Fatalf("discovery index not written: %v", err)
+	}
+
+	// A listing is reused while its directory's mtime is unchanged...
+	writeFile(t, filepath.Join(root, "libs", "b", "go.mod"), "module b\n")
+	if err := os.Chtimes(filepath.Join(root, "libs"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if got := discover(); !reflect.DeepEqual(got, []string{"//libs/a"}) {
+		t.Errorf("discovery with an unchanged mtime = %v, want the cached listing", got)
+	}
+
+	// ...and reread once it changes
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(root, "libs"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := discover(); !reflect.DeepEqual(got, []string{"//libs/a", "//libs/b"}) {
+		t.Errorf("discovery after a change = %v", got)
+	}
+}
+
+func TestDiscoveryIndexConfigs(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//libs/...\"]\ndiscovery_cache = true\n")
+	config := filepath.Join(root, "libs", "a", "ux.toml")
+	writeFile(t, config, "[tasks]\ntest = \"go test\"\n")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(config, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	testCmd := func(rescan bool) string {
+		t.Helper()
+		cfg, err := LoadRootConfig(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Workspace.Rescan = rescan
+		packages, err := DiscoverPackages(root, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(packages) != 1 {
+			t.Fatalf("packages = %v", packages)
+		}
+		return packages[0].Tasks["test"].Cmds[0]
+	}
+	if got := testCmd(false); got != "go test" {
+		t.Fatalf("test = %q", got)
+	}
+
+	// While the file's size and mtime are unchanged, its parsed config
+	// comes from the index...
+	data, err := os.ReadFile(discoveryIndexPath(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(data), `"go test"`, `"go vet"`, 1)
+	if tampered == string(data) {
+		t.Fatalf("index has no config for libs/a: %s", data)
+	}
+	writeFile(t, discoveryIndexPath(root), tampered)
+	if got := testCmd(false); got != "go vet" {
+		t.Errorf("test with an unchanged config = %q, want the indexed one", got)
+	}
+
+	// ...unless a rescan ignores the index, or the file changes
+	if got := testCmd(true); got != "go test" {
+		t.Errorf("test after a rescan = %q", got)
+	}
+	writeFile(t, discoveryIndexPath(root), tampered)
+	writeFile(t, config, "[tasks]\ntest = \"go test ./...\"\n")
+	if err := os.Chtimes(config, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if got := testCmd(false); got != "go test ./..." {
+		t.Errorf("test after the config changed = %q", got)
+	}
+}
+
+func BenchmarkDiscoverPackages(b *testing.B) {
+	root := b.TempDir()
+	writeFile(b, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//pkgs/...\"]\n")
+	for i := range 500 {
+		dir := filepath.Join(root, "pkgs", fmt.Sprintf("g%d", i%20), fmt.Sprintf("p%d", i))
+		writeFile(b, filepath.Join(dir, "pyproject.toml"), "[project]\nname = \"p\"\n")
+		writeFile(b, filepath.Join(dir, "ux.toml"), "[tasks]\ntest = \"pytest\"\nlint = [\"ruff check .\", \"mypy .\"]\n")
+		writeFile(b, filepath.Join(dir, "src", "mod", "x.py"), "x = 1\n")
+	}
+	// Older than the racy window, so the index keeps everything
+	old := time.Now().Add(-time.Hour)
+	filepath.WalkDir(root, func(path string, _ os.DirEntry, _ error) error {
+		return os.Chtimes(path, old, old)
+	})
+
+	for _, index := range []bool{false, true} {
+		b.Run(fmt.Sprintf("index=%v", index), func(b *testing.B) {
+			cfg, err := LoadRootConfig(root)
+			if err != nil {
+				b.Fatal(err)
+			}
+			cfg.Workspace.DiscoveryCache = index
+			if _, err := DiscoverPackages(root, cfg); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for range b.N {
+				if _, err := DiscoverPackages(root, cfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoveryConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `[workspace]
+members = ["//apps/...", "//coverage/..."]
+
+[discovery]
+skip = ["coverage", "tmp*"]
+
+[discovery.markers]
+"deno.json" = "deno"
+
+[defaults.deno.tasks]
+test = "deno test"
+`)
+	writeFile(t, filepath.Join(root, "apps", "web", "deno.json"), "{}\n")
+	writeFile(t, filepath.Join(root, "apps", "web", "package.json"), "{}\n")
+	writeFile(t, filepath.Join(root, "apps", "tmp-scratch", "go.mod"), "module scratch\n")
+	writeFile(t, filepath.Join(root, "apps", "api", "coverage", "go.mod"), "module report\n")
+	writeFile(t, filepath.Join(root, "apps", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "coverage", "html", "go.mod"), "module html\n")
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, pkg := range packages {
+		got[pkg.Label] = pkg.Type
+	}
+	// Only directories below a member are skipped; //coverage/... names one
+	want := map[string]string{"//apps/api": "go", "//apps/web": "deno", "//coverage/html": "go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discovered %v, want %v", got, want)
+	}
+
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//apps/...\"]\n\n[discovery]\nskip = [\"tmp[\"]\n")
+	if _, err := LoadRootConfig(root); err == nil || !strings.Contains(err.Error(), `invalid glob "tmp["`) {
+		t.Errorf("invalid skip glob: err = %v", err)
+	}
+}
+
+func TestDiscoveryFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	shared := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `[workspace]
+members = ["//apps/..."]
+
+[discovery]
+follow_symlinks = true
+`)
+	writeFile(t, filepath.Join(root, "apps", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(shared, "auth", "go.mod"), "module auth\n")
+	for link, target := range map[string]string{
+		"apps/api/third_party": shared,                         // outside the workspace
+		"apps/api/loop":        filepath.Join(root, "apps"),    // back up the tree
+		"apps/dangling":        filepath.Join(root, "missing"), // nowhere
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skip("symlinks unsupported:", err)
+		}
+	}
+	// A link back to the shared tree from inside it
+	if err := os.Symlink(shared, filepath.Join(shared, "auth", "self")); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func() []string {
+		cfg, err := LoadRootConfig(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packages, err := DiscoverPackages(root, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, pkg := range packages {
+			labels = append(labels, pkg.Label)
+		}
+		return labels
+	}
+	want := []string{"//apps/api", "//apps/api/third_party/auth"}
+	if got := labels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("following symlinks discovered %v, want %v", got, want)
+	}
+
+	writeFile(t, filepath.Join(root, "ux.toml"), `[workspace]
+members = ["//apps/..."]
+`)
+	if got := labels(); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("without follow_symlinks discovered %v, want %v", got, want[:1])
+	}
+}
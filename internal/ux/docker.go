@@ -0,0 +1,105 @@
+package ux
+
+import (
+	"slices"
+	"strings"
+)
+
+// DockerConfig is the root config's [docker] table, which parameterizes the
+// built-in tasks of docker packages (directories with a Dockerfile).
+type DockerConfig struct {
+	// Registry prefixes image names: "ghcr.io/acme" builds
+	// ghcr.io/acme/<package name>. Without it, images are only built
+	// locally and there's no push task.
+	Registry string `toml:"registry"`
+	// Tag is the image tag. Defaults to "latest"; "{git_short_sha}" tags
+	// each image with the commit it was built from.
+	Tag string `toml:"tag"`
+	// Cache builds with buildx and a BuildKit cache kept in the registry
+	// at <image>:buildcache, so CI runners reuse layers. Needs Registry.
+	Cache bool `toml:"cache"`
+}
+
+// dockerCacheTag is the tag the BuildKit registry cache is kept under.
+const dockerCacheTag = "buildcache"
+
+// image returns the package's image reference, without a tag.
+func (c DockerConfig) image(name string) string {
+	if c.Registry == "" {
+		return name
+	}
+	return c.Registry + "/" + name
+}
+
+// dockerImageName turns a package name into an image path Docker accepts:
+// lowercase, with each path component made of letters and digits joined by
+// ".", "_", "__", or dashes. Anything else becomes a dash, so "My App" is
+// my-app.
+func dockerImageName(name string) string {
+	alnum := func(c rune) bool { return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' }
+	var parts []string
+	for _, part := range strings.Split(strings.ToLower(name), "/") {
+		var b strings.Builder
+		for part != "" {
+			i := strings.IndexFunc(part, func(c rune) bool { return !alnum(c) })
+			if i < 0 {
+				i = len(part)
+			}
+			b.WriteString(part[:i])
+			part = part[i:]
+			j := strings.IndexFunc(part, alnum)
+			if j < 0 {
+				break // trailing separators
+			}
+			sep := part[:j]
+			part = part[j:]
+			if b.Len() == 0 {
+				continue // leading separators
+			}
+			if sep != "." && sep != "_" && sep != "__" && strings.Trim(sep, "-") != "" {
+				sep = "-"
+			}
+			b.WriteString(sep)
+		}
+		if b.Len() > 0 {
+			parts = append(parts, b.String())
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// tag returns the configured tag, or "latest".
+func (c DockerConfig) tag() string {
+	if c.Tag == "" {
+		return "latest"
+	}
+	return c.Tag
+}
+
+// dockerBuiltinTasks rewrites a docker package's built-in build task to
+// tag its image per [docker], using the registry cache if enabled, and adds
+// a push task when a registry is set and nothing skips it. Tasks replaced
+// by [types.docker], defaults, or the package are left alone.
+func dockerBuiltinTasks(cfg DockerConfig, name string, tasks map[string]Task, sources, skipped map[string]string) {
+	t, ok := tasks["build"]
+	if !ok || sources["build"] != "builtin" || !slices.Equal(t.Cmds, builtinDefaults["docker"]["build"].Cmds) {
+		return
+	}
+	name = dockerImageName(name)
+	if name == "" {
+		return
+	}
+	image := shellWord(cfg.image(name))
+	ref := image + ":" + cfg.tag()
+	build := "docker build -t " + ref + " ."
+	if cfg.Cache && cfg.Registry != "" {
+		cache := "type=registry,ref=" + image + ":" + dockerCacheTag
+		build = "docker buildx build --cache-from " + cache + " --cache-to " + cache + ",mode=max --load -t " + ref + " ."
+	}
+	t.Cmds = []string{build}
+	tasks["build"] = t
+	if _, ok := tasks["push"]; !ok && skipped["push"] == "" && cfg.Registry != "" {
+		tasks["push"] = Task{Cmds: []string{"docker push " + ref}}
+		sources["push"] = "builtin"
+	}
+}
@@ -0,0 +1,96 @@
+package ux
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePackageDocker(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "images", "api")
+	writeFile(t, filepath.Join(dir, "Dockerfile"), "FROM scratch\n")
+
+	types := builtinTypes(true)
+	pkg, err := resolvePackage(root, dir, nil, types, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expandPackageNames([]Package{*pkg}, types.docker)
+	if pkg.Type != "docker" || len(pkg.Tasks) != 1 || pkg.Tasks["build"].Cmds[0] != "docker build -t api:latest ." {
+		t.Errorf("without [docker]: type %q, tasks %v", pkg.Type, pkg.Tasks)
+	}
+
+	types.docker = DockerConfig{Registry: "ghcr.io/acme", Tag: "{git_short_sha}", Cache: true}
+	pkg, err = resolvePackage(root, dir, nil, types, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expandPackageNames([]Package{*pkg}, types.docker)
+	wantBuild := "docker buildx build --cache-from type=registry,ref=ghcr.io/acme/api:buildcache" +
+		" --cache-to type=registry,ref=ghcr.io/acme/api:buildcache,mode=max --load -t ghcr.io/acme/api:{git_short_sha} ."
+	if got := pkg.Tasks["build"].Cmds[0]; got != wantBuild {
+		t.Errorf("build = %q, want %q", got, wantBuild)
+	}
+	if got := pkg.Tasks["push"].Cmds[0]; got != "docker push ghcr.io/acme/api:{git_short_sha}" || pkg.TaskSources["push"] != "builtin" {
+		t.Errorf("push = %q (%s)", got, pkg.TaskSources["push"])
+	}
+
+	// Another marker wins, and an overridden build is left alone
+	writeFile(t, filepath.Join(dir, "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[package]\ntype = \"docker\"\n\n[tasks]\nbuild = \"make image\"\n")
+	if pkg, err = resolvePackage(root, dir, nil, types, nil); err != nil {
+		t.Fatal(err)
+	}
+	expandPackageNames([]Package{*pkg}, types.docker)
+	if pkg.Tasks["build"].Cmds[0] != "make image" {
+		t.Errorf("overridden build = %v", pkg.Tasks["build"])
+	}
+	if types.detectType(dir) != "go" {
+		t.Errorf("detectType = %q, want go", types.detectType(dir))
+	}
+
+	// A package with its own tasks stays untyped when it gains a Dockerfile
+	tool := filepath.Join(root, "tools", "gen")
+	writeFile(t, filepath.Join(tool, "Dockerfile"), "FROM scratch\n")
+	writeFile(t, filepath.Join(tool, "ux.toml"), "[tasks]\ngen = \"./gen.sh\"\n")
+	if pkg, err = resolvePackage(root, tool, nil, types, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pkg.Tasks["build"]; pkg.Type != "" || ok {
+		t.Errorf("ux.toml package with a Dockerfile: type %q, tasks %v", pkg.Type, pkg.Tasks)
+	}
+}
+
+func TestDockerImageName(t *testing.T) {
+	for name, want := range map[string]string{
+		"api":           "api",
+		"services/api":  "services/api",
+		"My App":        "my-app",
+		"my_app.v2":     "my_app.v2",
+		"a__b--c":       "a__b--c",
+		"a._b":          "a-b",
+		"_web_":         "web",
+		"Tools/ Gen!/x": "tools/gen/x",
+		"!!!":           "",
+	} {
+		if got := dockerImageName(name); got != want {
+			t.Errorf("dockerImageName(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	// The built-in tasks use the cleaned-up name, even if it's unusual
+	tasks := map[string]Task{"build": builtinDefaults["docker"]["build"]}
+	sources := map[string]string{"build": "builtin"}
+	dockerBuiltinTasks(DockerConfig{Registry: "ghcr.io/acme"}, "My App", tasks, sources, nil)
+	if got := tasks["build"].Cmds[0]; got != "docker build -t ghcr.io/acme/my-app:latest ." {
+		t.Errorf("build = %q", got)
+	}
+	if got := tasks["push"].Cmds[0]; got != "docker push ghcr.io/acme/my-app:latest" {
+		t.Errorf("push = %q", got)
+	}
+	tasks = map[string]Task{"build": builtinDefaults["docker"]["build"]}
+	dockerBuiltinTasks(DockerConfig{Registry: "registry.local/my team"}, "api", tasks, sources, nil)
+	if got := tasks["build"].Cmds[0]; got != "docker build -t 'registry.local/my team/api':latest ." {
+		t.Errorf("build with an odd registry = %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package ux
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DocsConfig controls `ux export docs`.
+type DocsConfig struct {
+	// Path is the workspace-relative output file. Defaults to WORKSPACE.md.
+	Path string `toml:"path"`
+}
+
+// DefaultDocsPath is where `ux export docs` writes when [docs] path is unset.
+const DefaultDocsPath = "WORKSPACE.md"
+
+// GenerateDocs renders a markdown overview of the workspace: a package index
+// with owners, each package's tasks, and a dependency diagram. The output is
+// deterministic, and paths in commands are relative to the workspace root,
+// so it can be checked in and compared in CI on any machine.
+func GenerateDocs(packages []Package) string {
+	var b strings.Builder
+
+	b.WriteString("# Workspace\n\n")
+	b.WriteString("<!-- Generated by `ux export docs`. Do not edit by hand. -->\n\n")
+
+	b.WriteString("## Packages\n\n")
+	b.WriteString("| Package | Name | Type | Owners | Tasks |\n")
+	b.WriteString("|---------|------|------|--------|-------|\n")
+	for _, pkg := range packages {
+		fmt.Fprintf(&b, "| [`%s`](#%s) | %s | %s | %s | %s |\n",
+			pkg.Label, docsAnchor(pkg.Label), pkg.Name, orDash(pkg.Type), orDash(strings.Join(pkg.Owners, ", ")),
+			strings.Join(sortedTaskNames(pkg), ", "))
+	}
+
+	for _, pkg := range packages {
+		fmt.Fprintf(&b, "\n### %s\n\n", pkg.Label)
+		if pkg.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", pkg.Description)
+		}
+		if len(pkg.Deps) > 0 {
+			fmt.Fprintf(&b, "Depends on: %s\n\n", strings.Join(backtickAll(pkg.Deps), ", "))
+		}
+		if len(pkg.GeneratedFrom) > 0 {
+			fmt.Fprintf(&b, "Generated from: %s\n\n", strings.Join(backtickAll(pkg.GeneratedFrom), ", "))
+		}
+		b.WriteString("| Task | Command | Source |\n")
+		b.WriteString("|------|---------|--------|\n")
+		for _, task := range sortedTaskNames(pkg) {
+			var steps []string
+			for _, step := range docsTask(pkg, task).steps() {
+				steps = append(steps, strings.Join(backtickAll(step), " ∥ "))
+			}
+			cmds := strings.Join(steps, " → ")
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", task, cmds, orDash(pkg.TaskSources[task]))
+		}
+	}
+
+	var edges []string
+	for _, pkg := range packages {
+		for _, dep := range pkg.Deps {
+			edges = append(edges, fmt.Sprintf("  %s[%q] --> %s[%q]", mermaidID(pkg.Label), pkg.Label, mermaidID(dep), dep))
+		}
+	}
+	if len(edges) > 0 {
+		sort.Strings(edges)
+		b.WriteString("\n## Dependencies\n\n```mermaid\ngraph LR\n")
+		b.WriteString(strings.Join(edges, "\n"))
+		b.WriteString("\n```\n")
+	}
+
+	return b.String()
+}
+
+// docsTask returns a package's task with the workspace root in its
+// commands ({package_dir}, for one) made relative: "./services/api".
+func docsTask(pkg Package, task string) Task {
+	t := pkg.Tasks[task]
+	root := pkg.root()
+	if root == "" {
+		return t
+	}
+	r := strings.NewReplacer(root+string(filepath.Separator), "./", root, ".")
+	return t.expand(r, r)
+}
+
+// sortedTaskNames returns a package's task names in alphabetical order.
+func sortedTaskNames(pkg Package) []string {
+	names := make([]string, 0, len(pkg.Tasks))
+	for t := range pkg.Tasks {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// docsAnchor is the GitHub heading anchor for a label heading.
+func docsAnchor(label string) string {
+	return strings.NewReplacer("/", "", ".", "").Replace(strings.ToLower(label))
+}
+
+// mermaidID turns a label into a valid mermaid node id.
+func mermaidID(label string) string {
+	id := strings.TrimPrefix(label, "//")
+	return "pkg_" + strings.NewReplacer("/", "_", "-", "_", ".", "_").Replace(id)
+}
+
+func backtickAll(items []string) []string {
+	out := make([]string, len(items))
+	for i, s := range items {
+		out[i] = "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+	}
+	return out
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
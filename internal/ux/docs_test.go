@@ -0,0 +1,43 @@
+package ux
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDocs(t *testing.T) {
+	generate := func(root string) string {
+		api := Package{
+			Label: "//services/api", Name: "api", Type: "go", Dir: filepath.Join(root, "services", "api"),
+			Owners: []string{"@acme/backend", "@alice"}, Deps: []string{"//libs/auth"},
+			Tasks: map[string]Task{
+				"build": {Cmds: []string{"docker build -t api " + filepath.Join(root, "services", "api")}},
+				"lint":  {Cmds: []string{"golangci-lint run --config " + filepath.Join(root, ".golangci.yml")}},
+			},
+			TaskSources: map[string]string{"build": "package ux.toml"},
+		}
+		auth := Package{
+			Label: "//libs/auth", Name: "auth", Dir: filepath.Join(root, "libs", "auth"),
+			Tasks: map[string]Task{"test": {Cmds: []string{"go test ./..."}}},
+		}
+		return GenerateDocs([]Package{api, auth})
+	}
+
+	docs := generate("/home/alice/ws")
+	if other := generate("/ci/build/ws"); other != docs {
+		t.Errorf("docs depend on where the workspace is:\n%s\n---\n%s", docs, other)
+	}
+	for _, want := range []string{
+		"| [`//services/api`](#servicesapi) | api | go | @acme/backend, @alice | build, lint |",
+		"| [`//libs/auth`](#libsauth) | auth | - | - | test |",
+		"| build | `docker build -t api ./services/api` | package ux.toml |",
+		"| lint | `golangci-lint run --config ./.golangci.yml` | - |",
+		"Depends on: `//libs/auth`",
+		`pkg_services_api["//services/api"] --> pkg_libs_auth["//libs/auth"]`,
+	} {
+		if !strings.Contains(docs, want) {
+			t.Errorf("docs are missing %q:\n%s", want, docs)
+		}
+	}
+}
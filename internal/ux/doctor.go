@@ -0,0 +1,282 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// CheckStatus is the outcome of one `ux doctor` check.
+type CheckStatus int
+
+const (
+	CheckPass CheckStatus = iota
+	CheckWarn
+	CheckFail
+)
+
+// Check is one `ux doctor` finding.
+type Check struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+}
+
+// shellBuiltins are command words that aren't looked up on PATH.
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "cd": true, "command": true, "echo": true, "eval": true,
+	"exec": true, "exit": true, "export": true, "false": true, "for": true, "if": true,
+	"printf": true, "pwd": true, "set": true, "source": true, "test": true, "true": true,
+	"unset": true, "while": true, "{": true, "(": true, "!": true,
+}
+
+// Doctor checks that the workspace can be run here: git and the base ref
+// for --affected, the workspace config, every members entry, go.work or
+// Cargo workspace members missing from it, package types, and the tools
+// task commands invoke.
+func Doctor(root string) []Check {
+	checks := doctorGit(root)
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		return append(checks, Check{"config", CheckFail, err.Error()})
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		return append(checks, Check{"packages", CheckFail, err.Error()})
+	}
+	checks = append(checks, Check{"config", CheckPass, fmt.Sprintf("%d packages", len(packages))})
+	checks = append(checks, doctorMembers(root, cfg.Workspace.Members, packages)...)
+	checks = append(checks, doctorManifests(root, cfg.Workspace.MembersFrom, packages)...)
+	checks = append(checks, doctorTypes(packages)...)
+	return append(checks, doctorTools(packages)...)
+}
+
+// doctorGit checks for git, a repository, and the base ref.
+func doctorGit(root string) []Check {
+	if _, err := exec.LookPath("git"); err != nil {
+		return []Check{{"git", CheckFail, "git not found on PATH; --affected and `ux adopt` need it"}}
+	}
+	out, _ := gitCommand(root, "--version").Output()
+	checks := []Check{{"git", CheckPass, strings.TrimSpace(string(out))}}
+	if err := gitCommand(root, "rev-parse", "--git-dir").Run(); err != nil {
+		return append(checks, Check{"base ref", CheckWarn, "workspace is not a git repository; --affected won't work"})
+	}
+	baseRef := BaseRef()
+	if err := gitCommand(root, "rev-parse", "--verify", "--quiet", baseRef).Run(); err != nil {
+		hint := "git fetch"
+		if remote, branch, ok := strings.Cut(baseRef, "/"); ok {
+			hint = "git fetch " + remote + " " + branch
+		}
+		return append(checks, Check{"base ref", CheckWarn,
+			fmt.Sprintf("%s not found; --affected needs it (%s)", baseRef, hint)})
+	}
+	return append(checks, Check{"base ref", CheckPass, baseRef})
+}
+
+// doctorMembers flags members entries whose directory is missing (fail)
+// or that resolve to no packages (warn), and "!" entries whose directory is
+// missing (warn).
+func doctorMembers(root string, members []string, packages []Package) []Check {
+	var checks []Check
+	for _, member := range members {
+		if negation, ok := strings.CutPrefix(member, "!"); ok {
+			dir, _ := strings.CutSuffix(strings.TrimPrefix(negation, "//"), "/...")
+			if _, err := os.Stat(filepath.Join(root, dir)); err != nil {
+				checks = append(checks, Check{"members", CheckWarn, fmt.Sprintf("%s: directory %s doesn't exist", member, dir)})
+			}
+			continue
+		}
+		path := strings.TrimPrefix(member, "//")
+		dir, recursive := strings.CutSuffix(path, "/...")
+		if _, err := os.Stat(filepath.Join(root, dir)); err != nil {
+			checks = append(checks, Check{"members", CheckFail, fmt.Sprintf("%s: directory %s doesn't exist", member, dir)})
+			continue
+		}
+		found := false
+		for _, pkg := range packages {
+			label := strings.TrimPrefix(pkg.Label, "//")
+			if label == dir || recursive && strings.HasPrefix(label, dir+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			checks = append(checks, Check{"members", CheckWarn, fmt.Sprintf("%s matches no packages", member)})
+		}
+	}
+	if len(checks) == 0 {
+		checks = append(checks, Check{"members", CheckPass, fmt.Sprintf("%d entries", len(members))})
+	}
+	return checks
+}
+
+// doctorManifests suggests members_from for a go.work or workspace
+// Cargo.toml at the root that lists members the workspace doesn't have.
+func doctorManifests(root string, membersFrom []string, packages []Package) []Check {
+	var checks []Check
+	for _, manifest := range []string{"go.work", "Cargo.toml"} {
+		if slices.Contains(membersFrom, manifest) || slices.Contains(membersFrom, "//"+manifest) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, manifest)); err != nil {
+			continue
+		}
+		members, err := manifestMembers(root, manifest)
+		if err != nil {
+			continue // e.g. a Cargo.toml for a single crate
+		}
+		var missing []string
+		for _, m := range members {
+			if !slices.ContainsFunc(packages, func(pkg Package) bool { return pkg.Label == m }) {
+				missing = append(missing, m)
+			}
+		}
+		if len(missing) > 0 {
+			checks = append(checks, Check{"members", CheckWarn,
+				fmt.Sprintf("%s lists members the workspace lacks (%s); add members_from = [%q] to [workspace]",
+					manifest, labelList(missing), manifest)})
+		}
+	}
+	return checks
+}
+
+// doctorTypes flags packages with no type, which get no default tasks.
+func doctorTypes(packages []Package) []Check {
+	var untyped []string
+	for _, pkg := range packages {
+		if pkg.Type == "" && pkg.Label != RootLabel {
+			untyped = append(untyped, pkg.Label)
+		}
+	}
+	if len(untyped) == 0 {
+		return []Check{{"types", CheckPass, "every package has a type"}}
+	}
+	return []Check{{"types", CheckWarn, fmt.Sprintf("no type, so no default tasks: %s", labelList(untyped))}}
+}
+
+// doctorTools checks that the program each task command starts with can be
+// found: on PATH, or relative to the task's directory for a path.
+func doctorTools(packages []Package) []Check {
+	missing := make(map[string][]string) // tool -> labels using it
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		for _, t := range pkg.Tasks {
+			dir := t.dir(pkg.Dir)
+			for _, c := range t.Cmds {
+				tool := commandTool(c)
+				if tool == "" || found[tool] {
+					continue
+				}
+				if toolExists(tool, dir) {
+					if !strings.Contains(tool, "/") {
+						found[tool] = true
+					}
+					continue
+				}
+				if !slices.Contains(missing[tool], pkg.Label) {
+					missing[tool] = append(missing[tool], pkg.Label)
+				}
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return []Check{{"tools", CheckPass, fmt.Sprintf("%d found", len(found))}}
+	}
+	tools := make([]string, 0, len(missing))
+	for tool := range missing {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	var checks []Check
+	for _, tool := range tools {
+		checks = append(checks, Check{"tools", CheckFail, fmt.Sprintf("%s not found (used by %s)", tool, labelList(missing[tool]))})
+	}
+	return checks
+}
+
+// commandTool returns the program a shell command starts with, skipping
+// leading VAR=value assignments, or "" for shell builtins.
+func commandTool(cmd string) string {
+	for _, word := range strings.Fields(cmd) {
+		word = strings.Trim(word, `"'`)
+		if name, _, ok := strings.Cut(word, "="); ok && name != "" && !strings.ContainsAny(name, "/$") {
+			continue
+		}
+		if shellBuiltins[word] || strings.ContainsAny(word, "$`") {
+			return ""
+		}
+		return word
+	}
+	return ""
+}
+
+// toolExists reports whether tool can be run from dir.
+func toolExists(tool, dir string) bool {
+	if !strings.Contains(tool, "/") {
+		_, err := exec.LookPath(tool)
+		return err == nil
+	}
+	if !filepath.IsAbs(tool) {
+		tool = filepath.Join(dir, tool)
+	}
+	info, err := os.Stat(tool)
+	return err == nil && !info.IsDir()
+}
+
+// labelList joins labels for a check's detail, eliding after a few.
+func labelList(labels []string) string {
+	const shown = 3
+	sort.Strings(labels)
+	if len(labels) > shown {
+		return fmt.Sprintf("%s and %d more", strings.Join(labels[:shown], ", "), len(labels)-shown)
+	}
+	return strings.Join(labels, ", ")
+}
+
+// PrintChecks prints `ux doctor` results and a count of each status.
+func PrintChecks(checks []Check) {
+	fmt.Printf("\n%s\n\n", styleHeader.Render("ux doctor"))
+	var passed, warned, failed int
+	for _, c := range checks {
+		icon, style := iconSuccess, styleDim
+		switch c.Status {
+		case CheckWarn:
+			icon, style = styleWarning.Render("!"), lipgloss.NewStyle()
+			warned++
+		case CheckFail:
+			icon, style = iconFail, styleFail
+			failed++
+		default:
+			passed++
+		}
+		// A detail running over several lines, like a config error's
+		// snippet, continues under its first line
+		lines := strings.Split(c.Detail, "\n")
+		for i, line := range lines {
+			lines[i] = style.Render(line)
+		}
+		detail := strings.Join(lines, "\n"+strings.Repeat(" ", 15))
+		fmt.Printf("  %s %-10s %s\n", icon, c.Name, detail)
+	}
+	fmt.Printf("\n  %s  %s  %s\n\n",
+		styleSuccess.Render(fmt.Sprintf("%d passed", passed)),
+		styleWarning.Render(fmt.Sprintf("%d warnings", warned)),
+		styleFail.Render(fmt.Sprintf("%d failed", failed)))
+}
+
+// ChecksFailed reports whether any check failed.
+func ChecksFailed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package ux
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandTool(t *testing.T) {
+	tests := map[string]string{
+		"uv run pytest":                "uv",
+		"CGO_ENABLED=0 go build ./...": "go",
+		"./scripts/lint.sh --fix":      "./scripts/lint.sh",
+		"cd src && make":               "",
+		"$TOOL check":                  "",
+		"":                             "",
+	}
+	for cmd, want := range tests {
+		if got := commandTool(cmd); got != want {
+			t.Errorf("commandTool(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestDoctorMembers(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "services", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "legacy", "README"), "")
+	packages := []Package{{Label: "//services/api"}}
+
+	checks := doctorMembers(root, []string{"//services/...", "//legacy/...", "//gone"}, packages)
+	if len(checks) != 2 {
+		t.Fatalf("got %+v, want a warning and a failure", checks)
+	}
+	if c := checks[0]; c.Status != CheckWarn || !strings.Contains(c.Detail, "//legacy/...") {
+		t.Errorf("checks[0] = %+v", c)
+	}
+	if c := checks[1]; c.Status != CheckFail || !strings.Contains(c.Detail, "//gone") {
+		t.Errorf("checks[1] = %+v", c)
+	}
+}
+
+func TestDoctorTools(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "scripts", "lint.sh"), "#!/bin/sh\n")
+	packages := []Package{{Label: "//a", Dir: dir, Tasks: map[string]Task{
+		"lint": {Cmds: []string{"./scripts/lint.sh", "sh -c true"}},
+		"test": {Cmds: []string{"ux-no-such-tool run", "./scripts/missing.sh"}},
+	}}}
+
+	checks := doctorTools(packages)
+	var details []string
+	for _, c := range checks {
+		if c.Status != CheckFail {
+			t.Errorf("unexpected %+v", c)
+		}
+		details = append(details, c.Detail)
+	}
+	want := []string{"./scripts/missing.sh not found (used by //a)", "ux-no-such-tool not found (used by //a)"}
+	if strings.Join(details, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", details, want)
+	}
+}
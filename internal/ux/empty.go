@@ -0,0 +1,173 @@
+package ux
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// EmptySelection explains why a run selected no packages, for
+// PrintEmptySelection.
+type EmptySelection struct {
+	// Task is the task being run, or "" for commands that don't run one.
+	Task string
+	// Misses are the target filters that matched no packages.
+	Misses []FilterMiss
+	// Narrowed names what narrowed a non-empty selection to nothing:
+	// "--affected", "--rerun-failed", "--targets-from", "--owner", or
+	// OnlyPackagesEnv.
+	Narrowed string
+	// Selected are the packages left, none of which defines Task. It's
+	// empty when filters or Narrowed left nothing.
+	Selected []Package
+	// All is every package in the workspace.
+	All []Package
+	// Tasks is the root [tasks] config, for task descriptions.
+	Tasks map[string]TaskConfig
+}
+
+// FilterMiss is a target filter that matched no packages.
+type FilterMiss struct {
+	Arg      string // as given
+	Resolved string // as a label, e.g. //services/apx
+	// Suggestions are the labels it was probably meant to be (SuggestLabels).
+	Suggestions []string
+	// Parent is the nearest directory above the filter that holds packages,
+	// as a wildcard label ("//services/..."), and Siblings are the packages
+	// under it. Both are empty if only the workspace root does.
+	Parent   string
+	Siblings []string
+}
+
+// maxSiblings caps how many packages under a missed filter's parent are listed.
+const maxSiblings = 5
+
+// NewFilterMiss describes a filter that matched none of packages.
+func NewFilterMiss(packages []Package, arg, resolved string) FilterMiss {
+	m := FilterMiss{Arg: arg, Resolved: resolved, Suggestions: SuggestLabels(packages, resolved)}
+	dir := strings.TrimSuffix(strings.TrimPrefix(resolved, "//"), "/...")
+	for dir = path.Dir(dir); dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		for _, pkg := range packages {
+			if strings.HasPrefix(pkg.Label, "//"+dir+"/") {
+				m.Siblings = append(m.Siblings, pkg.Label)
+			}
+		}
+		if len(m.Siblings) > 0 {
+			m.Parent = "//" + dir + "/..."
+			sort.Strings(m.Siblings)
+			break
+		}
+	}
+	return m
+}
+
+// Message is the one-line warning for a filter that matched nothing, with
+// suggestions if there are any.
+func (m FilterMiss) Message() string {
+	msg := fmt.Sprintf("filter %q matched no packages", m.Arg)
+	if len(m.Suggestions) > 0 {
+		msg += "; " + DidYouMean(m.Suggestions)
+	}
+	return msg
+}
+
+// PrintEmptySelection writes one warning to w explaining why nothing was
+// selected: each filter that matched nothing, with suggestions and the
+// packages near it; what else narrowed the selection; and, when packages
+// were selected but none defines the task, which packages do and what
+// tasks exist instead.
+func PrintEmptySelection(w io.Writer, e EmptySelection) {
+	header := "no packages selected"
+	if e.Task != "" {
+		header = "nothing to run for " + styleSuccess.Render(e.Task)
+	}
+	fmt.Fprintf(w, "%s %s\n", styleWarning.Render("warning:"), header)
+
+	for _, m := range e.Misses {
+		fmt.Fprintf(w, "  %s\n", m.Message())
+		if m.Parent != "" {
+			more := ""
+			siblings := m.Siblings
+			if len(siblings) > maxSiblings {
+				more = styleDim.Render(fmt.Sprintf(" and %d more", len(siblings)-maxSiblings))
+				siblings = siblings[:maxSiblings]
+			}
+			fmt.Fprintf(w, "    %s %s%s\n", styleDim.Render("packages under "+m.Parent+":"), styledLabels(siblings), more)
+		}
+	}
+
+	switch e.Narrowed {
+	case "":
+	case "--affected":
+		fmt.Fprintf(w, "  --affected: no selected package has changes vs %s\n", BaseRef())
+	case "--rerun-failed":
+		fmt.Fprintf(w, "  --rerun-failed: none of the packages that failed last time are selected\n")
+	case "--targets-from":
+		fmt.Fprintf(w, "  --targets-from: the list of targets is empty\n")
+	case "--owner":
+		fmt.Fprintf(w, "  --owner: no selected package has that owner\n")
+	default:
+		fmt.Fprintf(w, "  %s excludes every selected package\n", e.Narrowed)
+	}
+
+	if e.Task == "" || len(e.Selected) == 0 {
+		return
+	}
+	var definers []string
+	for _, pkg := range e.All {
+		if _, ok := pkg.Tasks[e.Task]; ok {
+			definers = append(definers, pkg.Label)
+		}
+	}
+	if len(definers) == 0 {
+		msg := fmt.Sprintf("no package in the workspace defines %s", styleSuccess.Render(e.Task))
+		if s := suggestTask(e.All, e.Task); s != "" {
+			msg += "; did you mean " + styleSuccess.Render(s) + "?"
+		}
+		fmt.Fprintf(w, "  %s\n", msg)
+	} else {
+		more := ""
+		if len(definers) > maxSiblings {
+			more = styleDim.Render(fmt.Sprintf(" and %d more", len(definers)-maxSiblings))
+			definers = definers[:maxSiblings]
+		}
+		fmt.Fprintf(w, "  the %d selected package(s) don't define %s; it's defined in %s%s\n",
+			len(e.Selected), styleSuccess.Render(e.Task), styledLabels(definers), more)
+	}
+	printAvailableTasks(w, e.Selected, e.Tasks)
+}
+
+// styledLabels joins labels, highlighted.
+func styledLabels(labels []string) string {
+	styled := make([]string, len(labels))
+	for i, l := range labels {
+		styled[i] = styleLabel.Render(l)
+	}
+	return strings.Join(styled, ", ")
+}
+
+// suggestTask returns the task name closest to task that some package
+// defines, or "" if none is close. Task names are short, so two edits (a
+// swapped pair of letters) still count as close.
+func suggestTask(packages []Package, task string) string {
+	best, bestDist := "", min(max(len(task)/3, 2), 3)+1
+	seen := make(map[string]bool)
+	var names []string
+	for _, pkg := range packages {
+		for name := range pkg.Tasks {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if d := editDistance(task, name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
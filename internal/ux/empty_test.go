@@ -0,0 +1,73 @@
+package ux
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintEmptySelection(t *testing.T) {
+	test := map[string]Task{"test": {}}
+	all := []Package{
+		{Label: "//services/api", Tasks: test},
+		{Label: "//services/web", Tasks: map[string]Task{"lint": {}}},
+		{Label: "//lib", Tasks: test},
+	}
+
+	tests := []struct {
+		name string
+		e    EmptySelection
+		want []string
+	}{
+		{
+			name: "filter miss",
+			e:    EmptySelection{Task: "test", Misses: []FilterMiss{NewFilterMiss(all, "services/apx", "//services/apx")}},
+			want: []string{
+				"nothing to run for test",
+				`filter "services/apx" matched no packages; did you mean //services/api?`,
+				"packages under //services/...: //services/api, //services/web",
+			},
+		},
+		{
+			name: "narrowed",
+			e:    EmptySelection{Task: "test", Narrowed: "--affected"},
+			want: []string{"--affected: no selected package has changes vs origin/main"},
+		},
+		{
+			name: "task elsewhere",
+			e:    EmptySelection{Task: "test", Selected: all[1:2], All: all},
+			want: []string{"the 1 selected package(s) don't define test; it's defined in //services/api, //lib", "available tasks:", "lint"},
+		},
+		{
+			name: "no such task",
+			e:    EmptySelection{Task: "tset", Selected: all, All: all},
+			want: []string{"no package in the workspace defines tset; did you mean test?"},
+		},
+		{
+			name: "no task",
+			e:    EmptySelection{Misses: []FilterMiss{NewFilterMiss(all, "//zzz", "//zzz")}},
+			want: []string{"no packages selected", `filter "//zzz" matched no packages`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintEmptySelection(&buf, tt.e)
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output lacks %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNewFilterMissParent(t *testing.T) {
+	all := []Package{{Label: "//a/b/c"}, {Label: "//x"}}
+	if m := NewFilterMiss(all, "//a/b/d/...", "//a/b/d/..."); m.Parent != "//a/b/..." || len(m.Siblings) != 1 {
+		t.Errorf("miss = %+v, want parent //a/b/...", m)
+	}
+	if m := NewFilterMiss(all, "//q", "//q"); m.Parent != "" || m.Siblings != nil {
+		t.Errorf("miss = %+v, want no parent for a top-level filter", m)
+	}
+}
@@ -0,0 +1,124 @@
+package ux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Environment describes the machine a run executed on, so runs from
+// different machines (a laptop and CI) can be compared at a glance.
+type Environment struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	CPUs int    `json:"cpus"`
+	// Tools maps each toolchain used by the run's package types to its version.
+	Tools map[string]string `json:"tools,omitempty"`
+	// Fingerprint is a short hash of everything above.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// typeTools are the toolchain commands whose versions matter for each
+// package type, with the arguments that print the version.
+var typeTools = map[string][][]string{
+	"go":        {{"go", "version"}},
+	"python":    {{"python3", "--version"}},
+	"rust":      {{"rustc", "--version"}, {"cargo", "--version"}},
+	"node":      {{"node", "--version"}, {"npm", "--version"}},
+	"docker":    {{"docker", "--version"}},
+	"terraform": {{"terraform", "version"}},
+}
+
+// toolVersionTimeout bounds each version probe.
+const toolVersionTimeout = 5 * time.Second
+
+// CaptureEnvironment records the current OS, architecture, CPU count, and
+// the versions of the toolchains the given packages' types use. Tools that
+// aren't installed are left out.
+func CaptureEnvironment(root string, packages []Package) *Environment {
+	env := &Environment{
+		OS:    runtime.GOOS,
+		Arch:  runtime.GOARCH,
+		CPUs:  runtime.NumCPU(),
+		Tools: make(map[string]string),
+	}
+	seen := make(map[string]bool)
+	for _, pkg := range packages {
+		for _, t := range pkg.typeChain {
+			for _, probe := range typeTools[t] {
+				if seen[probe[0]] {
+					continue
+				}
+				seen[probe[0]] = true
+				if v := toolVersion(root, probe); v != "" {
+					env.Tools[probe[0]] = v
+				}
+			}
+		}
+	}
+	env.Fingerprint = env.fingerprint()
+	return env
+}
+
+// toolVersion runs a version command and extracts the version from its output.
+func toolVersion(dir string, probe []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, probe[0], probe[1:]...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	return parseToolVersion(string(out))
+}
+
+// parseToolVersion picks the version number out of a tool's version line:
+// "go version go1.24.1 linux/amd64" → "1.24.1", "v20.11.0" → "20.11.0".
+// If none is found, the whole first line is returned.
+func parseToolVersion(out string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	for _, field := range strings.Fields(line) {
+		v := strings.TrimPrefix(strings.TrimPrefix(field, "go"), "v")
+		if v != "" && v[0] >= '0' && v[0] <= '9' && strings.Contains(v, ".") {
+			return v
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// fingerprint hashes the environment's fields into a short stable ID.
+func (e *Environment) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00", e.OS, e.Arch, e.CPUs)
+	tools := make([]string, 0, len(e.Tools))
+	for t := range e.Tools {
+		tools = append(tools, t)
+	}
+	sort.Strings(tools)
+	for _, t := range tools {
+		fmt.Fprintf(h, "%s=%s\x00", t, e.Tools[t])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// String formats the environment on one line:
+// "linux/amd64, 8 CPUs, go 1.24.1, node 20.11.0".
+func (e *Environment) String() string {
+	parts := []string{fmt.Sprintf("%s/%s", e.OS, e.Arch), fmt.Sprintf("%d CPUs", e.CPUs)}
+	tools := make([]string, 0, len(e.Tools))
+	for t := range e.Tools {
+		tools = append(tools, t)
+	}
+	sort.Strings(tools)
+	for _, t := range tools {
+		parts = append(parts, t+" "+e.Tools[t])
+	}
+	return strings.Join(parts, ", ")
+}
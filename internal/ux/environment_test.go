@@ -0,0 +1,36 @@
+package ux
+
+import "testing"
+
+func TestParseToolVersion(t *testing.T) {
+	tests := []struct {
+		out, want string
+	}{
+		{"go version go1.24.1 linux/amd64\n", "1.24.1"},
+		{"Python 3.12.1\n", "3.12.1"},
+		{"cargo 1.75.0 (1d8b05cdd 2023-11-20)\n", "1.75.0"},
+		{"v20.11.0\n", "20.11.0"},
+		{"10.2.4\n", "10.2.4"},
+		{"custom build\nmore\n", "custom build"},
+	}
+	for _, tt := range tests {
+		if got := parseToolVersion(tt.out); got != tt.want {
+			t.Errorf("parseToolVersion(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestEnvironmentFingerprint(t *testing.T) {
+	a := &Environment{OS: "linux", Arch: "amd64", CPUs: 8, Tools: map[string]string{"go": "1.24.1", "node": "20.11.0"}}
+	b := &Environment{OS: "linux", Arch: "amd64", CPUs: 8, Tools: map[string]string{"node": "20.11.0", "go": "1.24.1"}}
+	if a.fingerprint() != b.fingerprint() {
+		t.Error("fingerprint depends on map order")
+	}
+	b.Tools["go"] = "1.23.0"
+	if a.fingerprint() == b.fingerprint() {
+		t.Error("fingerprint ignores tool versions")
+	}
+	if got, want := a.String(), "linux/amd64, 8 CPUs, go 1.24.1, node 20.11.0"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package ux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalHost is the [executors] hosts entry for this machine.
+const LocalHost = "local"
+
+// ExecutorsConfig is the root [executors] table: machines that parallel
+// tasks spread their packages across, over ssh.
+type ExecutorsConfig struct {
+	// Hosts are ssh destinations ("ci-worker-1", "user@host"), configured
+	// for non-interactive login. LocalHost runs packages here.
+	Hosts []string `toml:"hosts"`
+	// Dir is the workspace's checkout on the hosts, kept in sync with this
+	// one. Defaults to this workspace's own path.
+	Dir string `toml:"dir"`
+}
+
+// Executors assigns the packages of one parallel task to hosts.
+type Executors struct {
+	dir   string
+	hosts []string
+	host  map[string]string // label → host
+}
+
+// PlanExecutors spreads packages across cfg.Hosts so each host's expected
+// total duration is about the same: longest packages first, each to the
+// host with the least work so far. Durations come from history; a package
+// without one counts as the average of those that have one. root is the
+// default Dir.
+func PlanExecutors(cfg ExecutorsConfig, root, task string, packages []Package, history *History) *Executors {
+	e := &Executors{dir: cfg.Dir, hosts: cfg.Hosts, host: make(map[string]string, len(packages))}
+	if e.dir == "" {
+		e.dir = root
+	}
+	if len(cfg.Hosts) == 0 {
+		return e
+	}
+
+	expected := make(map[string]time.Duration, len(packages))
+	var total time.Duration
+	var known int
+	for _, pkg := range packages {
+		if d, ok := history.ExpectedDuration(task, pkg.Label); ok {
+			expected[pkg.Label] = d
+			total += d
+			known++
+		}
+	}
+	guess := time.Second
+	if known > 0 {
+		guess = total / time.Duration(known)
+	}
+	order := make([]Package, len(packages))
+	copy(order, packages)
+	for _, pkg := range order {
+		if _, ok := expected[pkg.Label]; !ok {
+			expected[pkg.Label] = guess
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return expected[order[i].Label] > expected[order[j].Label]
+	})
+
+	load := make([]time.Duration, len(cfg.Hosts))
+	for _, pkg := range order {
+		least := 0
+		for h := range load {
+			if load[h] < load[least] {
+				least = h
+			}
+		}
+		load[least] += expected[pkg.Label]
+		e.host[pkg.Label] = cfg.Hosts[least]
+		logger.Debug("assigned", "task", task, "package", pkg.Label, "host", cfg.Hosts[least], "expected", expected[pkg.Label])
+	}
+	return e
+}
+
+// Describe says how many packages each host was assigned, in [executors]
+// hosts order, e.g. "ci-worker-1 (12), ci-worker-2 (11)".
+func (e *Executors) Describe() string {
+	counts := make(map[string]int)
+	for _, h := range e.host {
+		counts[h]++
+	}
+	var parts []string
+	for _, h := range e.hosts {
+		if counts[h] > 0 {
+			parts = append(parts, fmt.Sprintf("%s (%d)", h, counts[h]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hostFor returns the host label runs on, or "" to run it here.
+func (e *Executors) hostFor(label string) string {
+	if e == nil || e.host[label] == LocalHost {
+		return ""
+	}
+	return e.host[label]
+}
+
+// onRemoteHost reports whether label runs on another machine.
+func (e *Executors) onRemoteHost(label string) bool {
+	return e.hostFor(label) != ""
+}
+
+// sshExecutor runs commands on a host over ssh, in its checkout at dir.
+// Without a terminal, nothing on the host notices when ssh goes away, so
+// each command runs under sshScript's watcher: cancelling closes ssh's
+// stdin, and so the remote one, and the watcher then kills the command's
+// process group there. The local ssh is killed too, in case it hangs.
+type sshExecutor struct {
+	host, dir string
+}
+
+func (s sshExecutor) run(ctx context.Context, c remoteCommand, stdout, stderr io.Writer) (int, error) {
+	// Held open until the run ends or is cancelled; closing it is what
+	// the remote watcher waits for
+	stdin, hold, err := os.Pipe()
+	if err != nil {
+		return -1, err
+	}
+	defer stdin.Close()
+	defer hold.Close()
+	stop := context.AfterFunc(ctx, func() { hold.Close() })
+	defer stop()
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "BatchMode=yes", s.host, "exec sh -c "+shellQuote(sshScript(s.dir, c)))
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = groupWaitDelay
+	err = cmd.Run()
+	if code := exitCode(err); code >= 0 {
+		return code, nil
+	}
+	return -1, err
+}
+
+// sshScript is the sh script that runs c in the checkout at dir: in a
+// session of its own (where the host has setsid), in the background, while
+// a watcher waits for stdin to close and then kills the session. Without
+// job control a background job's stdin is /dev/null, so the watcher reads
+// the script's stdin through fd 3.
+func sshScript(dir string, c remoteCommand) string {
+	command := "env"
+	for _, kv := range c.Env {
+		command += " " + shellQuote(kv)
+	}
+	command += " " + shellQuote(c.Shell) + " -c " + shellQuote(c.Cmd)
+	return strings.Join([]string{
+		"cd " + shellQuote(path.Join(dir, c.Dir, c.Cwd)) + " || exit 1",
+		"command -v setsid >/dev/null 2>&1 || setsid() { \"$@\"; }",
+		"exec 3<&0",
+		"setsid " + command + " &",
+		"pid=$!",
+		`(cat <&3 >/dev/null 2>&1; kill -KILL -"$pid" "$pid") >/dev/null 2>&1 &`,
+		"watcher=$!",
+		"exec 3<&-",
+		`wait "$pid"`,
+		"code=$?",
+		`kill "$watcher" 2>/dev/null`,
+		`exit "$code"`,
+	}, "\n")
+}
+
+// shellQuote quotes s as one word for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
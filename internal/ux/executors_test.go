@@ -0,0 +1,67 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanExecutors(t *testing.T) {
+	history := &History{Tasks: map[string]map[string][]HistoryEntry{"test": {}}}
+	var packages []Package
+	for label, secs := range map[string]int{"//a": 60, "//b": 50, "//c": 30, "//d": 20, "//e": 10} {
+		history.Tasks["test"][label] = []HistoryEntry{{Success: true, Duration: time.Duration(secs) * time.Second}}
+		packages = append(packages, Package{Label: label})
+	}
+	// Unknown durations count as the average (34s)
+	packages = append(packages, Package{Label: "//new"})
+
+	e := PlanExecutors(ExecutorsConfig{Hosts: []string{"w1", "w2", LocalHost}}, "/ws", "test", packages, history)
+	want := map[string]string{"//a": "w1", "//b": "w2", "//new": "local", "//c": "local", "//d": "w2", "//e": "w1"}
+	for label, host := range want {
+		if got := e.host[label]; got != host {
+			t.Errorf("%s on %q, want %q", label, got, host)
+		}
+	}
+	if e.hostFor("//c") != "" || e.hostFor("//a") != "w1" || e.dir != "/ws" {
+		t.Errorf("hostFor(//c) = %q, hostFor(//a) = %q, dir = %q", e.hostFor("//c"), e.hostFor("//a"), e.dir)
+	}
+	if got := e.Describe(); got != "w1 (2), w2 (2), local (2)" {
+		t.Errorf("Describe() = %q", got)
+	}
+	var none *Executors
+	if none.hostFor("//a") != "" {
+		t.Error("a nil Executors runs everything here")
+	}
+}
+
+// fakeSSH puts on PATH a stand-in ssh that runs the remote script locally.
+func fakeSSH(t *testing.T) {
+	bin := t.TempDir()
+	writeFile(t, filepath.Join(bin, "ssh"), "#!/bin/sh\nshift 3\nexec sh -c \"$1\"\n")
+	if err := os.Chmod(filepath.Join(bin, "ssh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSSHExecutor(t *testing.T) {
+	fakeSSH(t)
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "svc", "ux.toml"), "")
+	writeFile(t, filepath.Join(root, "svc", "src", "main.go"), "")
+	pkg := Package{Label: "//svc", Dir: filepath.Join(root, "svc"), Env: map[string]string{"MSG": "it's here"}, Tasks: map[string]Task{
+		"test": {Cmds: []string{`echo "$MSG"; pwd; exit 3`}, Cwd: "src"},
+	}}
+	opts := RunOptions{Executors: &Executors{dir: root, host: map[string]string{"//svc": "w1"}}}
+	r := executePackage("test", pkg, TaskConfig{}, opts)
+	want := "it's here\n" + filepath.Join(root, "svc", "src") + "\n"
+	if r.Success || r.Steps[0].ExitCode != 3 || r.Output != want || r.Host != "w1" {
+		t.Errorf("result = %+v, want exit 3 with output %q on w1", r, want)
+	}
+	if got := shellQuote("a'b"); got != `'a'\''b'` {
+		t.Errorf("shellQuote = %s", got)
+	}
+}
@@ -0,0 +1,99 @@
+package ux
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FlakyStats summarizes how unreliable a package's recorded runs of one
+// task have been, for `ux flaky`.
+type FlakyStats struct {
+	Task     string
+	Label    string
+	Runs     int
+	Failures int // runs that failed, including ones a retry passed
+	Retried  int // failures that passed on a flake-gate retry
+	// Flips counts how often the outcome changed from one run to the next.
+	// A broken package fails every time and rarely flips; a flaky one flips
+	// often.
+	Flips       int
+	LastFailure time.Time
+	// Quarantined is whether the package marks the task flaky
+	// ([package] flaky_tasks); the caller fills it in from the workspace.
+	Quarantined bool
+}
+
+// FailureRate is the fraction of recorded runs that failed.
+func (s FlakyStats) FailureRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Runs)
+}
+
+// Flaky summarizes every package that has failed a recorded run of task, or
+// of any task if task is "", worst first: most flips, then most failures.
+// Cached replays aren't runs, so they're left out.
+func (h *History) Flaky(task string) []FlakyStats {
+	var stats []FlakyStats
+	for _, name := range h.TaskNames() {
+		if task != "" && name != task {
+			continue
+		}
+		for label, entries := range h.Tasks[name] {
+			s := FlakyStats{Task: name, Label: label}
+			var prev *HistoryEntry
+			for i, e := range entries {
+				if e.Cached {
+					continue
+				}
+				s.Runs++
+				if !e.Success {
+					s.Failures++
+					s.LastFailure = e.Time
+				}
+				if e.Flaky {
+					s.Retried++
+				}
+				if prev != nil && e.Success != prev.Success {
+					s.Flips++
+				}
+				prev = &entries[i]
+			}
+			if s.Failures > 0 {
+				stats = append(stats, s)
+			}
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Flips != b.Flips {
+			return a.Flips > b.Flips
+		}
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		return a.Task < b.Task
+	})
+	return stats
+}
+
+// PrintFlaky prints the flakiest packages (for `ux flaky`).
+func PrintFlaky(stats []FlakyStats) {
+	fmt.Printf("\n%s\n\n", styleHeader.Render("ux flaky"))
+	fmt.Printf("  %s\n", styleDim.Render(fmt.Sprintf("%-40s %-10s %6s %8s %7s %7s  %s", "package", "task", "runs", "failed", "retried", "flips", "last failure")))
+	for _, s := range stats {
+		last := styleDim.Render(s.LastFailure.Local().Format("2006-01-02 15:04"))
+		if s.Quarantined {
+			last += styleFlaky.Render("  quarantined")
+		}
+		fmt.Printf("  %s %-10s %6d %s %7d %7d  %s\n",
+			styleLabel.Render(fmt.Sprintf("%-40s", s.Label)), s.Task, s.Runs,
+			styleFail.Render(fmt.Sprintf("%7.0f%%", s.FailureRate()*100)), s.Retried, s.Flips, last)
+	}
+	fmt.Println()
+}
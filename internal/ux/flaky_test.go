@@ -0,0 +1,79 @@
+package ux
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryFlaky(t *testing.T) {
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	flaky := Package{Label: "//flaky"}
+	broken := Package{Label: "//broken"}
+	solid := Package{Label: "//solid"}
+	for _, pass := range []bool{true, false, true, true, false, true} {
+		h.Record("test", []Result{
+			{Package: flaky, Success: pass},
+			{Package: broken},
+			{Package: solid, Success: true},
+		})
+	}
+	h.Record("test", []Result{{Package: broken, Success: true, Cached: true}})
+	h.Record("test", []Result{{Package: flaky, Success: true, Flaky: true}})
+	h.Record("lint", []Result{{Package: solid}})
+
+	stats := h.Flaky("")
+	want := []FlakyStats{
+		{Task: "test", Label: "//flaky", Runs: 7, Failures: 3, Retried: 1, Flips: 5},
+		{Task: "test", Label: "//broken", Runs: 6, Failures: 6},
+		{Task: "lint", Label: "//solid", Runs: 1, Failures: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d stats, want %d: %+v", len(stats), len(want), stats)
+	}
+	for i := range want {
+		got := stats[i]
+		got.LastFailure = want[i].LastFailure
+		if got != want[i] {
+			t.Errorf("stats[%d] = %+v, want %+v", i, got, want[i])
+		}
+		if stats[i].LastFailure.IsZero() {
+			t.Errorf("stats[%d] has no last failure", i)
+		}
+	}
+	if got := h.Flaky("lint"); len(got) != 1 || got[0].Label != "//solid" {
+		t.Errorf("Flaky(lint) = %+v", got)
+	}
+}
+
+func TestRunTaskQuarantine(t *testing.T) {
+	var packages []Package
+	for _, name := range []string{"quarantined", "broken"} {
+		dir := filepath.Join(t.TempDir(), name)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, FlakyTasks: []string{"test"}, Tasks: map[string]Task{
+			"test": {Cmds: []string{"false"}},
+			"lint": {Cmds: []string{"false"}},
+		}})
+	}
+	packages[1].FlakyTasks = nil
+
+	results := RunTask("test", packages, TaskConfig{}, RunOptions{Quiet: true, Quarantine: true, LogDir: t.TempDir()})
+	if q := results[0]; !q.Quarantined || !q.Allowed || q.Failed() {
+		t.Errorf("//quarantined = %+v, want a quarantined failure", q)
+	}
+	if b := results[1]; b.Quarantined || !b.Failed() {
+		t.Errorf("//broken = %+v, want a failure", b)
+	}
+
+	// Only the tasks marked flaky are quarantined, and only with --quarantine
+	for _, r := range RunTask("lint", packages[:1], TaskConfig{}, RunOptions{Quiet: true, Quarantine: true, LogDir: t.TempDir()}) {
+		if !r.Failed() {
+			t.Errorf("lint = %+v, want a failure", r)
+		}
+	}
+	for _, r := range RunTask("test", packages[:1], TaskConfig{}, RunOptions{Quiet: true, LogDir: t.TempDir()}) {
+		if !r.Failed() {
+			t.Errorf("test without --quarantine = %+v, want a failure", r)
+		}
+	}
+}
@@ -0,0 +1,565 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// sectionOrder is the order `ux config fmt` puts top-level tables in. Tables not
+// listed follow, alphabetically.
+var sectionOrder = []string{
+	"workspace", "package", "env", "tasks", "root-tasks", "defaults", "types",
+	"resources", "affected", "behavior", "docs", "hooks", "logs",
+}
+
+// keyOrder is the order `ux config fmt` puts well-known keys in, within any table.
+// Other keys (task names, env vars) follow, alphabetically.
+var keyOrder = []string{
+	"name", "type", "description", "owners", "extends", "members", "members_from", "include", "plugins",
+	"builtin_defaults", "deps", "generated_from", "weight", "resources", "parallel_safe", "skip_tasks", "flaky_tasks", "markers",
+	"cmd", "cwd", "shell", "parallel", "mutex", "run_once", "when", "depends_on", "inputs", "outputs",
+	"cache", "on_failure_collect", "allow_failure", "tasks",
+}
+
+// ConfigFiles lists the workspace's config files as workspace-relative
+// paths: the root ux.toml, the files it includes, and each package's ux.toml.
+func ConfigFiles(root string, packages []Package) []string {
+	files := []string{"ux.toml"}
+	for _, inc := range includedFiles(root) {
+		if rel := slashRel(root, inc); !slices.Contains(files, rel) {
+			files = append(files, rel)
+		}
+	}
+	for _, pkg := range packages {
+		path := filepath.Join(pkg.Dir, "ux.toml")
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if rel := slashRel(root, path); !slices.Contains(files, rel) {
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files[1:])
+	return files
+}
+
+// FormatConfig returns a ux.toml file in canonical form: tables in a fixed
+// order, keys sorted within each blank-line-separated group, one space
+// around "=", inline tables as "{ k = v }", and multi-line arrays one
+// element per line. Comments stay with the line they precede. It fails
+// rather than return anything that decodes differently from src.
+func FormatConfig(src []byte) ([]byte, error) {
+	var before map[string]interface{}
+	if err := toml.Unmarshal(src, &before); err != nil {
+		return nil, err
+	}
+	p := &fmtParser{src: strings.ReplaceAll(string(src), "\r\n", "\n")}
+	doc, err := p.parseDocument()
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line(), err)
+	}
+	out := []byte(doc.render())
+
+	var after map[string]interface{}
+	if err := toml.Unmarshal(out, &after); err != nil || !reflect.DeepEqual(before, after) {
+		return nil, fmt.Errorf("cannot format without changing its meaning")
+	}
+	return out, nil
+}
+
+// fmtSection is a table header and the entries under it. The root table has
+// no header.
+type fmtSection struct {
+	comments []string // lines right above the header
+	header   string   // "[a.b]" or "[[a]]"
+	path     []string // unquoted header keys, for ordering
+	array    bool
+	comment  string // after the header, on its line
+	groups   []*fmtGroup
+}
+
+// fmtGroup is a run of entries not separated by blank lines. Entries are
+// sorted within a group but never moved between groups.
+type fmtGroup struct {
+	entries []*fmtEntry
+	trailer []string // comment lines after the last entry
+}
+
+type fmtEntry struct {
+	comments []string
+	key      string // normalized key text
+	sortKey  string // first key segment, unquoted
+	value    *fmtValue
+	comment  string
+}
+
+type fmtValue struct {
+	scalar    string // verbatim, for anything but arrays and inline tables
+	isArray   bool
+	elems     []*fmtElem
+	closing   []string // comment lines before an array's "]"
+	multiline bool     // the array spanned several lines
+	isTable   bool
+	entries   []*fmtEntry
+}
+
+type fmtElem struct {
+	comments []string
+	value    *fmtValue
+	comment  string
+}
+
+type fmtDoc struct {
+	sections []*fmtSection
+}
+
+// render prints the document, ordering its tables unless it has arrays of
+// tables, whose sub-tables depend on position.
+func (d *fmtDoc) render() string {
+	rest := d.sections[1:]
+	if !slices.ContainsFunc(rest, func(s *fmtSection) bool { return s.array }) {
+		sort.SliceStable(rest, func(i, j int) bool {
+			ri, rj := orderRank(sectionOrder, rest[i].path[0]), orderRank(sectionOrder, rest[j].path[0])
+			if ri != rj {
+				return ri < rj
+			}
+			return slices.Compare(rest[i].path, rest[j].path) < 0
+		})
+	}
+	var blocks []string
+	for _, s := range d.sections {
+		if block := s.render(); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func (s *fmtSection) render() string {
+	var b strings.Builder
+	for _, c := range s.comments {
+		b.WriteString(c + "\n")
+	}
+	if s.header != "" {
+		b.WriteString(withComment(s.header, s.comment) + "\n")
+	}
+	first := true
+	for _, g := range s.groups {
+		if len(g.entries) == 0 && len(g.trailer) == 0 {
+			continue
+		}
+		if !first {
+			b.WriteString("\n")
+		}
+		first = false
+		sortEntries(g.entries)
+		for _, e := range g.entries {
+			for _, c := range e.comments {
+				b.WriteString(c + "\n")
+			}
+			b.WriteString(withComment(e.key+" = "+e.value.render(""), e.comment) + "\n")
+		}
+		for _, c := range g.trailer {
+			b.WriteString(c + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (v *fmtValue) render(indent string) string {
+	switch {
+	case v.isTable:
+		if len(v.entries) == 0 {
+			return "{}"
+		}
+		sortEntries(v.entries)
+		fields := make([]string, len(v.entries))
+		for i, e := range v.entries {
+			fields[i] = e.key + " = " + e.value.render(indent)
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	case v.isArray:
+		commented := len(v.closing) > 0 || slices.ContainsFunc(v.elems, func(e *fmtElem) bool {
+			return len(e.comments) > 0 || e.comment != ""
+		})
+		if !v.multiline && !commented {
+			items := make([]string, len(v.elems))
+			for i, e := range v.elems {
+				items[i] = e.value.render(indent)
+			}
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+		inner := indent + "  "
+		var b strings.Builder
+		b.WriteString("[\n")
+		for i, e := range v.elems {
+			for _, c := range e.comments {
+				b.WriteString(inner + c + "\n")
+			}
+			item := inner + e.value.render(inner)
+			if i < len(v.elems)-1 {
+				item += ","
+			}
+			b.WriteString(withComment(item, e.comment) + "\n")
+		}
+		for _, c := range v.closing {
+			b.WriteString(inner + c + "\n")
+		}
+		return b.String() + indent + "]"
+	}
+	return v.scalar
+}
+
+func withComment(line, comment string) string {
+	if comment == "" {
+		return line
+	}
+	return line + " " + comment
+}
+
+func sortEntries(entries []*fmtEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		ri, rj := orderRank(keyOrder, entries[i].sortKey), orderRank(keyOrder, entries[j].sortKey)
+		if ri != rj {
+			return ri < rj
+		}
+		return entries[i].sortKey < entries[j].sortKey
+	})
+}
+
+// orderRank is name's position in order, or len(order) if it's not listed.
+func orderRank(order []string, name string) int {
+	if i := slices.Index(order, name); i >= 0 {
+		return i
+	}
+	return len(order)
+}
+
+// fmtParser reads the subset of TOML layout `ux config fmt` needs to keep: which
+// lines are comments and blank, and the structure of each value. Values
+// are validated by the TOML decoder before parsing.
+type fmtParser struct {
+	src string
+	pos int
+}
+
+func (p *fmtParser) line() int {
+	return strings.Count(p.src[:p.pos], "\n") + 1
+}
+
+func (p *fmtParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *fmtParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *fmtParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// readComment reads a "#" comment to the end of the line.
+func (p *fmtParser) readComment() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.src) - p.pos
+	}
+	c := strings.TrimRight(p.src[p.pos:p.pos+end], " \t")
+	p.pos += end
+	return c
+}
+
+// endLine expects the end of a line, with an optional comment.
+func (p *fmtParser) endLine() (string, error) {
+	p.skipSpaces()
+	var comment string
+	if p.peek() == '#' {
+		comment = p.readComment()
+	}
+	if p.eof() {
+		return comment, nil
+	}
+	if p.peek() != '\n' {
+		return "", fmt.Errorf("unexpected %q", p.peek())
+	}
+	p.pos++
+	return comment, nil
+}
+
+func (p *fmtParser) parseDocument() (*fmtDoc, error) {
+	sec := &fmtSection{groups: []*fmtGroup{{}}}
+	doc := &fmtDoc{sections: []*fmtSection{sec}}
+	var pending []string
+	for !p.eof() {
+		p.skipSpaces()
+		group := sec.groups[len(sec.groups)-1]
+		switch p.peek() {
+		case '\n':
+			p.pos++
+			group.trailer = append(group.trailer, pending...)
+			pending = nil
+			if len(group.entries) > 0 || len(group.trailer) > 0 {
+				sec.groups = append(sec.groups, &fmtGroup{})
+			}
+		case '#':
+			pending = append(pending, p.readComment())
+			if _, err := p.endLine(); err != nil {
+				return nil, err
+			}
+		case '[':
+			next, err := p.parseHeader()
+			if err != nil {
+				return nil, err
+			}
+			next.comments, pending = pending, nil
+			sec = next
+			doc.sections = append(doc.sections, sec)
+		default:
+			e, err := p.parseEntry()
+			if err != nil {
+				return nil, err
+			}
+			if e.comment, err = p.endLine(); err != nil {
+				return nil, err
+			}
+			e.comments, pending = pending, nil
+			group.entries = append(group.entries, e)
+		}
+	}
+	last := sec.groups[len(sec.groups)-1]
+	last.trailer = append(last.trailer, pending...)
+	return doc, nil
+}
+
+func (p *fmtParser) parseHeader() (*fmtSection, error) {
+	s := &fmtSection{groups: []*fmtGroup{{}}}
+	p.pos++
+	if p.peek() == '[' {
+		s.array = true
+		p.pos++
+	}
+	p.skipSpaces()
+	key, segs, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	closing := "]"
+	if s.array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, fmt.Errorf("expected %s", closing)
+	}
+	p.pos += len(closing)
+	s.header = strings.Repeat("[", len(closing)) + key + closing
+	s.path = segs
+	if s.comment, err = p.endLine(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *fmtParser) parseEntry() (*fmtEntry, error) {
+	key, segs, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.peek() != '=' {
+		return nil, fmt.Errorf("expected = after %s", key)
+	}
+	p.pos++
+	p.skipSpaces()
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &fmtEntry{key: key, sortKey: segs[0], value: v}, nil
+}
+
+// parseKey reads a bare, quoted, or dotted key, returning it with spacing
+// around dots removed, and its unquoted segments.
+func (p *fmtParser) parseKey() (string, []string, error) {
+	var parts, segs []string
+	for {
+		p.skipSpaces()
+		start := p.pos
+		switch p.peek() {
+		case '"', '\'':
+			if err := p.skipString(); err != nil {
+				return "", nil, err
+			}
+			raw := p.src[start:p.pos]
+			parts = append(parts, raw)
+			segs = append(segs, strings.Trim(raw, `"'`))
+		default:
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return "", nil, fmt.Errorf("expected a key")
+			}
+			parts = append(parts, p.src[start:p.pos])
+			segs = append(segs, p.src[start:p.pos])
+		}
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return strings.Join(parts, "."), segs, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *fmtParser) parseValue() (*fmtValue, error) {
+	start := p.pos
+	switch p.peek() {
+	case '"', '\'':
+		if err := p.skipString(); err != nil {
+			return nil, err
+		}
+		return &fmtValue{scalar: p.src[start:p.pos]}, nil
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+	for !p.eof() && !strings.ContainsRune(",]}#\n", rune(p.peek())) {
+		p.pos++
+	}
+	scalar := strings.TrimRight(p.src[start:p.pos], " \t")
+	if scalar == "" {
+		return nil, fmt.Errorf("expected a value")
+	}
+	return &fmtValue{scalar: scalar}, nil
+}
+
+// skipString moves past a basic, literal, or multi-line string.
+func (p *fmtParser) skipString() error {
+	q := p.src[p.pos : p.pos+1]
+	delim := q
+	if strings.HasPrefix(p.src[p.pos:], q+q+q) {
+		delim = q + q + q
+	}
+	p.pos += len(delim)
+	for !p.eof() {
+		if q == `"` && p.peek() == '\\' {
+			p.pos += 2
+			continue
+		}
+		if len(delim) == 1 && p.peek() == '\n' {
+			break
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			// A multi-line string may end with up to two extra quotes
+			for i := 0; len(delim) == 3 && i < 2 && p.peek() == q[0]; i++ {
+				p.pos++
+			}
+			return nil
+		}
+		p.pos++
+	}
+	return fmt.Errorf("unterminated string")
+}
+
+// skipBlank moves past whitespace, newlines, and comment lines inside an
+// array, returning the comments.
+func (p *fmtParser) skipBlank(v *fmtValue) []string {
+	var comments []string
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t':
+			p.pos++
+		case '\n':
+			v.multiline = true
+			p.pos++
+		case '#':
+			comments = append(comments, p.readComment())
+		default:
+			return comments
+		}
+	}
+	return comments
+}
+
+func (p *fmtParser) parseArray() (*fmtValue, error) {
+	v := &fmtValue{isArray: true}
+	p.pos++
+	for {
+		pending := p.skipBlank(v)
+		if p.peek() == ']' {
+			p.pos++
+			v.closing = pending
+			return v, nil
+		}
+		if p.eof() {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		e := &fmtElem{comments: pending, value: item}
+		v.elems = append(v.elems, e)
+		p.skipSpaces()
+		if p.peek() == '#' {
+			e.comment = p.readComment()
+		}
+		if more := p.skipBlank(v); len(more) > 0 {
+			e.comment = strings.TrimSpace(e.comment + " " + strings.Join(more, " "))
+		}
+		if p.peek() == ',' {
+			p.pos++
+			p.skipSpaces()
+			if p.peek() == '#' && e.comment == "" {
+				e.comment = p.readComment()
+			}
+		} else if p.peek() != ']' {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *fmtParser) parseInlineTable() (*fmtValue, error) {
+	v := &fmtValue{isTable: true}
+	p.pos++
+	for {
+		p.skipSpaces()
+		if p.peek() == '}' {
+			p.pos++
+			return v, nil
+		}
+		e, err := p.parseEntry()
+		if err != nil {
+			return nil, err
+		}
+		v.entries = append(v.entries, e)
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
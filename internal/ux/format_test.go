@@ -0,0 +1,102 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFormatConfig(t *testing.T) {
+	src := `# Workspace config
+[tasks]
+test={parallel=false,depends_on=["build"],cmd="pytest"}   # serial
+build = {outputs=["dist/**"], parallel=true}
+
+# deploys go last
+deploy = "make deploy"
+[ workspace ]
+members=[
+    "//b", # second
+    "//a"
+]
+include = ["ci.toml","release.toml"]
+[defaults.python.tasks]
+lint = [["ruff check .","mypy ."],"pytest"]
+[defaults.go.tasks]
+lint = 'go vet ./...'
+`
+	want := `[workspace]
+members = [
+  "//b", # second
+  "//a"
+]
+include = ["ci.toml", "release.toml"]
+
+# Workspace config
+[tasks]
+build = { parallel = true, outputs = ["dist/**"] }
+test = { cmd = "pytest", parallel = false, depends_on = ["build"] } # serial
+
+# deploys go last
+deploy = "make deploy"
+
+[defaults.go.tasks]
+lint = 'go vet ./...'
+
+[defaults.python.tasks]
+lint = [["ruff check .", "mypy ."], "pytest"]
+`
+	got, err := FormatConfig([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("FormatConfig:\n%s\nwant:\n%s", got, want)
+	}
+	again, err := FormatConfig(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(got) {
+		t.Errorf("FormatConfig is not idempotent:\n%s", again)
+	}
+
+	if _, err := FormatConfig([]byte("[tasks]\ntest = \n")); err == nil {
+		t.Error("FormatConfig accepted invalid TOML")
+	}
+}
+
+func TestFormatConfigMigrated(t *testing.T) {
+	pkg := migratedPackage{name: "web", pkgType: "node", deps: []string{"//lib"}, scripts: map[string]string{"test": "vitest"}}
+	for _, src := range []string{
+		generateRootTomlWithDefaults([]string{"//apps/web", "//lib"}, []string{"build", "test"},
+			map[string]bool{"test": true}, map[string]turboTask{"build": {DependsOn: []string{"^build"}, Outputs: []string{"dist/**"}}},
+			map[string]map[string]string{"node": {"lint": "npm run lint"}}),
+		generateMinimalPackageToml(pkg, nil),
+	} {
+		got, err := FormatConfig([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != src {
+			t.Errorf("generated config isn't canonical:\n%s\nformatted:\n%s", src, got)
+		}
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//a\", \"//b\"]\ninclude = [\"ci/ux.toml\"]\n")
+	writeFile(t, filepath.Join(root, "ci", "ux.toml"), "[tasks]\nci = { parallel = true }\n")
+	writeFile(t, filepath.Join(root, "a", "ux.toml"), "[package]\nname = \"a\"\n")
+	if err := os.MkdirAll(filepath.Join(root, "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	packages := []Package{{Label: "//a", Dir: filepath.Join(root, "a")}, {Label: "//b", Dir: filepath.Join(root, "b")}}
+	got := ConfigFiles(root, packages)
+	want := []string{"ux.toml", "a/ux.toml", "ci/ux.toml"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ConfigFiles = %v, want %v", got, want)
+	}
+}
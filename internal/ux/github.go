@@ -0,0 +1,137 @@
+package ux
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// OutputGitHub is the --output mode for GitHub Actions logs.
+const OutputGitHub = "github"
+
+// GitHubStepSummaryEnv names the file GitHub Actions renders as the job
+// summary; ux appends a markdown table of each task's results to it.
+const GitHubStepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// PrintGitHubGroups prints each package's output as a collapsible
+// ::group:: in the Actions log, and an ::error:: annotation for every failed
+// package naming the step that failed and the package's owners.
+func PrintGitHubGroups(w io.Writer, task string, results []Result) {
+	sorted := sortedResults(results)
+	for _, r := range sorted {
+		fmt.Fprintf(w, "::group::%s %s %s (%s)\n", githubIcon(r), task, r.Package.Label, fmtDuration(r.Duration))
+		if err := writeOutputLines(w, r, ""); err != nil {
+			Warnf("cannot read the output of %s: %v", r.Package.Label, err)
+		}
+		fmt.Fprintln(w, "::endgroup::")
+	}
+	for _, r := range sorted {
+		if !r.Failed() && !r.Allowed {
+			continue
+		}
+		msg := task + " failed"
+		if r.FailedStep != "" {
+			msg += ": " + r.FailedStep
+		}
+		if len(r.Package.Owners) > 0 {
+			msg += " (owners: " + strings.Join(r.Package.Owners, ", ") + ")"
+		}
+		// Allowed failures don't fail the run, so they're only warnings
+		level := "error"
+		if r.Quarantined {
+			level, msg = "warning", msg+" (quarantined)"
+		} else if r.Allowed {
+			level, msg = "warning", msg+" (allowed)"
+		}
+		title := r.Package.Label + " " + task
+		fmt.Fprintf(w, "::%s title=%s::%s\n", level, escapeGitHubProperty(title), escapeGitHubData(msg))
+	}
+}
+
+// AppendGitHubStepSummary appends a markdown table of a task's results to
+// $GITHUB_STEP_SUMMARY, if set.
+func AppendGitHubStepSummary(task string, results []Result) error {
+	path := os.Getenv(GitHubStepSummaryEnv)
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(githubSummaryMarkdown(task, results)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// githubSummaryMarkdown renders a task's results as a markdown section.
+func githubSummaryMarkdown(task string, results []Result) string {
+	var b strings.Builder
+	var passed, failed, allowed int
+	fmt.Fprintf(&b, "### ux %s\n\n", task)
+	b.WriteString("| | Package | Duration | Failed step |\n|---|---|---|---|\n")
+	for _, r := range sortedResults(results) {
+		switch {
+		case r.Success:
+			passed++
+		case r.Allowed:
+			allowed++
+		case !r.Removed:
+			failed++
+		}
+		dur := fmtDuration(r.Duration)
+		if r.Cached {
+			dur += " (cached)"
+		}
+		step := ""
+		if !r.Success && !r.Removed && r.FailedStep != "" {
+			step = "`" + strings.ReplaceAll(r.FailedStep, "|", `\|`) + "`"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", githubIcon(r), r.Package.Label, dur, step)
+	}
+	fmt.Fprintf(&b, "\n**%d passed, %d failed", passed, failed)
+	if allowed > 0 {
+		fmt.Fprintf(&b, " (+%d allowed)", allowed)
+	}
+	b.WriteString("**\n\n")
+	return b.String()
+}
+
+// githubIcon is the plain-text status icon used in Actions logs and summaries.
+func githubIcon(r Result) string {
+	switch {
+	case r.Flaky:
+		return "~"
+	case r.Removed:
+		return "−"
+	case r.Allowed:
+		return "!"
+	case !r.Success:
+		return "✗"
+	}
+	return "✓"
+}
+
+// sortedResults returns results sorted by label.
+func sortedResults(results []Result) []Result {
+	sorted := make([]Result, len(results))
+	copy(sorted, results)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Package.Label < sorted[j].Package.Label
+	})
+	return sorted
+}
+
+// escapeGitHubData escapes a workflow command's message.
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a workflow command's property value.
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
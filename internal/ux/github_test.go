@@ -0,0 +1,55 @@
+package ux
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func githubResults() []Result {
+	return []Result{
+		{Package: Package{Label: "//b"}, FailedStep: "go test ./...", Output: "FAIL x\n", Duration: time.Second},
+		{Package: Package{Label: "//a"}, Success: true, Output: "ok\n", Duration: 2 * time.Second},
+	}
+}
+
+func TestPrintGitHubGroups(t *testing.T) {
+	var out bytes.Buffer
+	PrintGitHubGroups(&out, "test", githubResults())
+	want := "::group::✓ test //a (2.0s)\nok\n::endgroup::\n" +
+		"::group::✗ test //b (1.0s)\nFAIL x\n::endgroup::\n" +
+		"::error title=//b test::test failed: go test ./...\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestAppendGitHubStepSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv(GitHubStepSummaryEnv, path)
+	if err := AppendGitHubStepSummary("lint", githubResults()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendGitHubStepSummary("test", githubResults()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{"### ux lint", "### ux test", "| ✗ | `//b` | 1.0s | `go test ./...` |", "**1 passed, 1 failed**"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestEscapeGitHubProperty(t *testing.T) {
+	if got := escapeGitHubProperty("a:b,c%\n"); got != "a%3Ab%2Cc%25%0A" {
+		t.Errorf("got %q", got)
+	}
+}
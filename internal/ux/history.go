@@ -0,0 +1,137 @@
+package ux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateDir is the workspace-relative directory where ux keeps run state.
+const StateDir = ".ux"
+
+// maxHistoryEntries caps how many runs are kept per package and task.
+const maxHistoryEntries = 50
+
+// estimateSamples is how many recent runs ExpectedDuration averages.
+const estimateSamples = 10
+
+// History is the persisted record of past runs, stored in .ux/history.json.
+type History struct {
+	// Tasks maps task name → package label → runs, oldest first.
+	Tasks map[string]map[string][]HistoryEntry `json:"tasks"`
+}
+
+// HistoryEntry is the outcome of one task run on one package.
+type HistoryEntry struct {
+	Time     time.Time     `json:"time"`
+	Success  bool          `json:"success"`
+	Duration time.Duration `json:"duration"`
+	Cached   bool          `json:"cached,omitempty"`
+	// Flaky runs failed and then passed on a flake-gate retry. They're
+	// recorded as failures, so they count toward the flake rate.
+	Flaky bool `json:"flaky,omitempty"`
+}
+
+func historyPath(root string) string {
+	return filepath.Join(root, StateDir, "history.json")
+}
+
+// LoadHistory reads the run history for a workspace. A missing file yields an empty history.
+func LoadHistory(root string) (*History, error) {
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	data, err := os.ReadFile(historyPath(root))
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return &History{Tasks: make(map[string]map[string][]HistoryEntry)}, fmt.Errorf("parsing %s: %w", historyPath(root), err)
+	}
+	if h.Tasks == nil {
+		h.Tasks = make(map[string]map[string][]HistoryEntry)
+	}
+	return h, nil
+}
+
+// Save writes the history back to .ux/history.json.
+func (h *History) Save(root string) error {
+	path := historyPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Record appends the results of a run. Flaky results count as failures so
+// they keep contributing to the package's flake rate; interrupted ones
+// aren't recorded.
+func (h *History) Record(task string, results []Result) {
+	byLabel := h.Tasks[task]
+	if byLabel == nil {
+		byLabel = make(map[string][]HistoryEntry)
+		h.Tasks[task] = byLabel
+	}
+	now := time.Now()
+	for _, r := range results {
+		if r.Removed || r.Interrupted {
+			continue
+		}
+		entries := append(byLabel[r.Package.Label], HistoryEntry{
+			Time:     now,
+			Success:  r.Success && !r.Flaky,
+			Duration: r.Duration,
+			Cached:   r.Cached,
+			Flaky:    r.Flaky,
+		})
+		if len(entries) > maxHistoryEntries {
+			entries = entries[len(entries)-maxHistoryEntries:]
+		}
+		byLabel[r.Package.Label] = entries
+	}
+}
+
+// FailureRate returns the fraction of recorded runs of task on label that
+// failed, along with the number of runs it is based on.
+func (h *History) FailureRate(task, label string) (float64, int) {
+	entries := h.Tasks[task][label]
+	if len(entries) == 0 {
+		return 0, 0
+	}
+	var failed int
+	for _, e := range entries {
+		if !e.Success {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(entries)), len(entries)
+}
+
+// ExpectedDuration estimates how long task takes on label from the average
+// of its most recent successful runs. Cached replays don't count. ok is false
+// when there are no such runs.
+func (h *History) ExpectedDuration(task, label string) (d time.Duration, ok bool) {
+	if h == nil {
+		return 0, false
+	}
+	entries := h.Tasks[task][label]
+	var total time.Duration
+	var n int
+	for i := len(entries) - 1; i >= 0 && n < estimateSamples; i-- {
+		if e := entries[i]; e.Success && !e.Cached {
+			total += e.Duration
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return total / time.Duration(n), true
+}
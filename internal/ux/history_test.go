@@ -0,0 +1,89 @@
+package ux
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryFailureRate(t *testing.T) {
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	pkg := Package{Label: "//services/api"}
+
+	for i := 0; i < maxHistoryEntries+10; i++ {
+		h.Record("test", []Result{{Package: pkg, Success: i%10 != 0}})
+	}
+	if got := len(h.Tasks["test"]["//services/api"]); got != maxHistoryEntries {
+		t.Fatalf("recorded %d entries, want cap of %d", got, maxHistoryEntries)
+	}
+
+	rate, samples := h.FailureRate("test", "//services/api")
+	if samples != maxHistoryEntries {
+		t.Errorf("samples = %d, want %d", samples, maxHistoryEntries)
+	}
+	if rate != 0.1 {
+		t.Errorf("rate = %v, want 0.1", rate)
+	}
+
+	if _, samples := h.FailureRate("lint", "//services/api"); samples != 0 {
+		t.Errorf("unknown task samples = %d, want 0", samples)
+	}
+}
+
+func TestWithinFlakeGate(t *testing.T) {
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	flaky := Package{Label: "//flaky"}
+	broken := Package{Label: "//broken"}
+	stable := Package{Label: "//stable"}
+	for i := 0; i < 10; i++ {
+		h.Record("test", []Result{
+			{Package: flaky, Success: i != 0},
+			{Package: broken, Success: false},
+			{Package: stable, Success: true},
+		})
+	}
+	h.Record("test", []Result{{Package: Package{Label: "//new"}, Success: false}})
+
+	opts := RunOptions{FlakeGate: 0.2, History: h}
+	tests := []struct {
+		label string
+		want  bool
+	}{
+		{"//flaky", true},
+		{"//broken", false},
+		{"//stable", false},
+		{"//new", false}, // too few samples
+	}
+	for _, tt := range tests {
+		if got := withinFlakeGate("test", tt.label, opts); got != tt.want {
+			t.Errorf("withinFlakeGate(%q) = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+
+	if withinFlakeGate("test", "//flaky", RunOptions{History: h}) {
+		t.Error("withinFlakeGate with gate disabled = true, want false")
+	}
+}
+
+func TestExpectedDuration(t *testing.T) {
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	pkg := Package{Label: "//services/api"}
+
+	if _, ok := h.ExpectedDuration("test", pkg.Label); ok {
+		t.Error("ExpectedDuration with no runs: ok = true")
+	}
+
+	// Old runs beyond the sample window, failures, and cache hits are ignored
+	for i := 0; i < 5; i++ {
+		h.Record("test", []Result{{Package: pkg, Success: true, Duration: time.Hour}})
+	}
+	for i := 0; i < estimateSamples; i++ {
+		h.Record("test", []Result{{Package: pkg, Success: true, Duration: time.Duration(i%2+1) * time.Second}})
+	}
+	h.Record("test", []Result{{Package: pkg, Success: false, Duration: time.Minute}})
+	h.Record("test", []Result{{Package: pkg, Success: true, Cached: true, Duration: time.Millisecond}})
+
+	d, ok := h.ExpectedDuration("test", pkg.Label)
+	if !ok || d != 1500*time.Millisecond {
+		t.Errorf("ExpectedDuration = %v, %v; want 1.5s, true", d, ok)
+	}
+}
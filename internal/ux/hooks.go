@@ -0,0 +1,136 @@
+package ux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// HooksConfig holds workspace-level commands run around each invocation.
+type HooksConfig struct {
+	BeforeRun string `toml:"before_run"`
+	AfterRun  string `toml:"after_run"`
+	// OnPackageAdded and OnPackageRemoved run once per package that appeared
+	// or disappeared since the previous invocation.
+	OnPackageAdded   string `toml:"on_package_added"`
+	OnPackageRemoved string `toml:"on_package_removed"`
+}
+
+// RunHook runs a workspace hook command from the workspace root with output
+// streamed to the terminal. env entries ("KEY=value") are added to its environment.
+func RunHook(root, name, command string, env ...string) error {
+	if command == "" {
+		return nil
+	}
+	fmt.Printf("\n  %s\n", styleDim.Render("→ "+name+": "+command))
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = root
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
+
+// graphEntry is a package as recorded in .ux/packages.json.
+type graphEntry struct {
+	Label string `json:"label"`
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Dir   string `json:"dir"`
+}
+
+func graphPath(root string) string {
+	return filepath.Join(root, StateDir, "packages.json")
+}
+
+// RunPackageHooks compares the discovered packages with the graph recorded by
+// the previous task run, runs on_package_added / on_package_removed for each
+// difference, and records the new graph. The first run only records, and
+// without either hook nothing is recorded. A package whose hook fails is
+// left out of the update so it fires again next time.
+func RunPackageHooks(root string, hooks HooksConfig, packages []Package) error {
+	if hooks.OnPackageAdded == "" && hooks.OnPackageRemoved == "" {
+		return nil
+	}
+	current := make(map[string]graphEntry, len(packages))
+	for _, pkg := range packages {
+		current[pkg.Label] = graphEntry{Label: pkg.Label, Name: pkg.Name, Type: pkg.Type, Dir: pkg.Dir}
+	}
+
+	previous := make(map[string]graphEntry)
+	data, err := os.ReadFile(graphPath(root))
+	if err == nil {
+		var entries []graphEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("parsing %s: %w", graphPath(root), err)
+		}
+		for _, e := range entries {
+			previous[e.Label] = e
+		}
+	}
+	firstRun := os.IsNotExist(err)
+	if err != nil && !firstRun {
+		return err
+	}
+
+	record := make(map[string]graphEntry, len(current))
+	for label, e := range current {
+		record[label] = e
+	}
+	if !firstRun {
+		for _, e := range sortedEntries(current) {
+			if _, ok := previous[e.Label]; ok || hooks.OnPackageAdded == "" {
+				continue
+			}
+			if err := RunHook(root, "on_package_added", hooks.OnPackageAdded, e.env()...); err != nil {
+				Warnf("%s: %v", e.Label, err)
+				delete(record, e.Label)
+			}
+		}
+		for _, e := range sortedEntries(previous) {
+			if _, ok := current[e.Label]; ok || hooks.OnPackageRemoved == "" {
+				continue
+			}
+			if err := RunHook(root, "on_package_removed", hooks.OnPackageRemoved, e.env()...); err != nil {
+				Warnf("%s: %v", e.Label, err)
+				record[e.Label] = e
+			}
+		}
+	}
+
+	out, err := json.MarshalIndent(sortedEntries(record), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(graphPath(root)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(graphPath(root), out, 0644)
+}
+
+// env describes the package to a lifecycle hook.
+func (e graphEntry) env() []string {
+	return []string{
+		"UX_PACKAGE_LABEL=" + e.Label,
+		"UX_PACKAGE_NAME=" + e.Name,
+		"UX_PACKAGE_TYPE=" + e.Type,
+		"UX_PACKAGE_DIR=" + e.Dir,
+	}
+}
+
+func sortedEntries(m map[string]graphEntry) []graphEntry {
+	entries := make([]graphEntry, 0, len(m))
+	for _, e := range m {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Label < entries[j].Label
+	})
+	return entries
+}
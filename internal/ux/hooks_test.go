@@ -0,0 +1,55 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPackageHooks(t *testing.T) {
+	root := t.TempDir()
+	events := filepath.Join(t.TempDir(), "events")
+	hooks := HooksConfig{
+		OnPackageAdded:   `echo "added $UX_PACKAGE_LABEL $UX_PACKAGE_TYPE" >> ` + events,
+		OnPackageRemoved: `echo "removed $UX_PACKAGE_LABEL" >> ` + events,
+	}
+	api := Package{Label: "//api", Name: "api", Type: "go", Dir: filepath.Join(root, "api")}
+	web := Package{Label: "//web", Name: "web", Type: "node", Dir: filepath.Join(root, "web")}
+	run := func(hooks HooksConfig, packages ...Package) string {
+		t.Helper()
+		os.Remove(events)
+		if err := RunPackageHooks(root, hooks, packages); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(events)
+		return strings.TrimSpace(string(data))
+	}
+
+	if got := run(HooksConfig{}, api); got != "" {
+		t.Errorf("without hooks, ran %q", got)
+	}
+	if _, err := os.Stat(graphPath(root)); !os.IsNotExist(err) {
+		t.Errorf("without hooks, recorded the package list (%v)", err)
+	}
+	if got := run(hooks, api); got != "" {
+		t.Errorf("first run ran %q, want it to only record", got)
+	}
+	if got := run(hooks, api, web); got != "added //web node" {
+		t.Errorf("after adding //web, ran %q", got)
+	}
+	if got := run(hooks, api, web); got != "" {
+		t.Errorf("without changes, ran %q", got)
+	}
+	if got := run(hooks, web); got != "removed //api" {
+		t.Errorf("after removing //api, ran %q", got)
+	}
+
+	// A failed hook fires again next time
+	failing := hooks
+	failing.OnPackageAdded = "exit 1"
+	run(failing, api, web)
+	if got := run(hooks, api, web); got != "added //api go" {
+		t.Errorf("after a failed hook, ran %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package ux
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileNames are read from every directory a cache key walks, and from
+// the package's parents up to the repository or workspace root. Their
+// patterns, in .gitignore syntax, name files that never feed a cache key.
+var ignoreFileNames = []string{".gitignore", ".uxignore"}
+
+// ignoreRule is one pattern line from an ignore file.
+type ignoreRule struct {
+	base    string   // slash-separated dir of the ignore file, relative to the matcher's top
+	segs    []string // pattern split on "/", relative to base
+	negate  bool     // "!pattern" re-includes a path
+	dirOnly bool     // "pattern/" matches only directories
+}
+
+// ignoreMatcher applies .gitignore/.uxignore rules to paths within one
+// package. As with git, the last matching rule wins and a rule in a
+// subdirectory only applies beneath it.
+type ignoreMatcher struct {
+	dir    string // package dir
+	prefix string // package dir relative to top, slash-separated
+	rules  []ignoreRule
+}
+
+// newIgnoreMatcher loads the ignore files of dir's parents, from the
+// enclosing git repository or workspace root down. Ignore files in dir and
+// below are loaded by the walk as it enters each directory (see load).
+func newIgnoreMatcher(dir string) *ignoreMatcher {
+	m := &ignoreMatcher{dir: dir}
+	var parents []string
+	top := dir
+	for !isIgnoreTop(top) {
+		parent := filepath.Dir(top)
+		if parent == top {
+			// Outside any repository or workspace: only the package's own files count
+			parents, top = nil, dir
+			break
+		}
+		top = parent
+		parents = append(parents, top)
+	}
+	m.prefix = slashRel(top, dir)
+	for i := len(parents) - 1; i >= 0; i-- {
+		m.loadDir(parents[i], slashRel(top, parents[i]))
+	}
+	return m
+}
+
+// isIgnoreTop reports whether dir is where git stops looking for ignore
+// files: a repository root, or else the workspace root.
+func isIgnoreTop(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	return isWorkspaceFile(filepath.Join(dir, "ux.toml"))
+}
+
+// slashRel returns target relative to base as a slash-separated path, or ""
+// when they're the same directory.
+func slashRel(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// load reads the ignore files in the package subdirectory rel.
+func (m *ignoreMatcher) load(rel string) {
+	if rel == "." {
+		rel = ""
+	}
+	m.loadDir(filepath.Join(m.dir, filepath.FromSlash(rel)), path.Join(m.prefix, rel))
+}
+
+func (m *ignoreMatcher) loadDir(dir, base string) {
+	for _, name := range ignoreFileNames {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		m.rules = append(m.rules, parseIgnoreRules(string(data), base)...)
+	}
+}
+
+// parseIgnoreRules parses .gitignore syntax: blank lines and "#" comments
+// are skipped, "!" negates, a trailing "/" matches only directories, and a
+// pattern with any other "/" is anchored to the file's directory.
+func parseIgnoreRules(data, base string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if r.negate = strings.HasPrefix(line, "!"); r.negate {
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		line, r.dirOnly = strings.CutSuffix(line, "/")
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		if !anchored {
+			line = "**/" + line
+		}
+		r.base = base
+		r.segs = strings.Split(line, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// ignored reports whether the package path rel (slash-separated) is
+// excluded by the rules loaded so far.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	full := path.Join(m.prefix, rel)
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := full
+		if r.base != "" {
+			var ok bool
+			if sub, ok = strings.CutPrefix(full, r.base+"/"); !ok {
+				continue
+			}
+		}
+		if matchSegments(r.segs, strings.Split(sub, "/")) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,249 @@
+package ux
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configMerger folds included workspace files into the root config. It
+// remembers which file each setting came from so conflicts can name both.
+type configMerger struct {
+	root        string
+	cfg         *RootConfig
+	taskFrom    map[string]string
+	defaultFrom map[string]string // "<type>.<task>" → file
+	typeFrom    map[string]string
+	hooksFrom   string
+	loaded      map[string]bool
+}
+
+func newConfigMerger(root string, cfg *RootConfig) *configMerger {
+	m := &configMerger{
+		root:        root,
+		cfg:         cfg,
+		taskFrom:    make(map[string]string),
+		defaultFrom: make(map[string]string),
+		typeFrom:    make(map[string]string),
+		loaded:      map[string]bool{"ux.toml": true},
+	}
+	for name := range cfg.Tasks {
+		m.taskFrom[name] = "ux.toml"
+	}
+	for typeName, td := range cfg.Defaults {
+		for task := range td.Tasks {
+			m.defaultFrom[typeName+"."+task] = "ux.toml"
+		}
+	}
+	for name := range cfg.Types {
+		m.typeFrom[name] = "ux.toml"
+	}
+	if cfg.Hooks != (HooksConfig{}) {
+		m.hooksFrom = "ux.toml"
+	}
+	return m
+}
+
+// includePath normalizes an include entry ("apps/ux.toml" or "//apps/ux.toml")
+// to a clean workspace-relative path.
+func includePath(entry string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimPrefix(entry, "//")))
+}
+
+// includeAll merges each included file, then the files it includes in turn.
+// stack is the chain of files leading here, used to report include cycles.
+func (m *configMerger) includeAll(includes, stack []string) error {
+	for _, entry := range includes {
+		rel := includePath(entry)
+		if slices.Contains(stack, rel) {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(stack, rel), " → "))
+		}
+		if m.loaded[rel] {
+			continue
+		}
+		m.loaded[rel] = true
+
+		var inc RootConfig
+		if err := decodeConfigFile(m.root, filepath.Join(m.root, filepath.FromSlash(rel)), &inc); err != nil {
+			return configErrors(err, "parsing included "+rel)
+		}
+		if err := m.merge(rel, &inc); err != nil {
+			return err
+		}
+		if err := m.includeAll(inc.Workspace.Include, append(stack, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge adds one included file's members, members_from, plugins, tasks,
+// defaults, types, and hooks. Members, members_from, and plugins are
+// unioned; any other setting defined differently in two files is a conflict.
+func (m *configMerger) merge(file string, inc *RootConfig) error {
+	for _, member := range inc.Workspace.Members {
+		if !slices.Contains(m.cfg.Workspace.Members, member) {
+			m.cfg.Workspace.Members = append(m.cfg.Workspace.Members, member)
+		}
+	}
+	for _, plugin := range inc.Workspace.Plugins {
+		if !slices.Contains(m.cfg.Workspace.Plugins, plugin) {
+			m.cfg.Workspace.Plugins = append(m.cfg.Workspace.Plugins, plugin)
+		}
+	}
+	for _, manifest := range inc.Workspace.MembersFrom {
+		if !slices.Contains(m.cfg.Workspace.MembersFrom, manifest) {
+			m.cfg.Workspace.MembersFrom = append(m.cfg.Workspace.MembersFrom, manifest)
+		}
+	}
+
+	for name, tc := range inc.Tasks {
+		if prev, ok := m.cfg.Tasks[name]; ok && !reflect.DeepEqual(prev, tc) {
+			return fmt.Errorf("task %q is configured differently in %s and %s", name, m.taskFrom[name], file)
+		}
+		if m.cfg.Tasks == nil {
+			m.cfg.Tasks = make(map[string]TaskConfig)
+		}
+		m.cfg.Tasks[name] = tc
+		if _, ok := m.taskFrom[name]; !ok {
+			m.taskFrom[name] = file
+		}
+	}
+
+	for typeName, td := range inc.Defaults {
+		if td.include != nil {
+			return fmt.Errorf("%s: [defaults] include is only supported in the root ux.toml", file)
+		}
+		if m.cfg.Defaults == nil {
+			m.cfg.Defaults = make(map[string]TypeDefaults)
+		}
+		merged := m.cfg.Defaults[typeName]
+		if td.Extends != "" {
+			if merged.Extends != "" && merged.Extends != td.Extends {
+				return fmt.Errorf("[defaults.%s] extends %q, but %s says %q", typeName, merged.Extends, file, td.Extends)
+			}
+			merged.Extends = td.Extends
+		}
+		if merged.Tasks == nil {
+			merged.Tasks = make(map[string]interface{})
+		}
+		for task, v := range td.Tasks {
+			key := typeName + "." + task
+			if prev, ok := merged.Tasks[task]; ok && !reflect.DeepEqual(prev, v) {
+				return fmt.Errorf("default %s task %q is defined differently in %s and %s", typeName, task, m.defaultFrom[key], file)
+			}
+			merged.Tasks[task] = v
+			if _, ok := m.defaultFrom[key]; !ok {
+				m.defaultFrom[key] = file
+			}
+		}
+		m.cfg.Defaults[typeName] = merged
+	}
+
+	for name, tc := range inc.Types {
+		if prev, ok := m.cfg.Types[name]; ok && !reflect.DeepEqual(prev, tc) {
+			return fmt.Errorf("type %q is defined differently in %s and %s", name, m.typeFrom[name], file)
+		}
+		if m.cfg.Types == nil {
+			m.cfg.Types = make(map[string]TypeConfig)
+		}
+		m.cfg.Types[name] = tc
+		if _, ok := m.typeFrom[name]; !ok {
+			m.typeFrom[name] = file
+		}
+	}
+
+	if inc.Hooks != (HooksConfig{}) {
+		if m.hooksFrom != "" && m.cfg.Hooks != inc.Hooks {
+			return fmt.Errorf("[hooks] is defined differently in %s and %s", m.hooksFrom, file)
+		}
+		m.cfg.Hooks = inc.Hooks
+		m.hooksFrom = file
+	}
+	return nil
+}
+
+// includeSharedDefaults merges the files named by [defaults] include into
+// cfg.Defaults and returns which file each "<type>.<task>" it added came
+// from. A shared file has the root's [defaults.<type>.tasks] layout. The
+// root's own defaults take precedence, then files in the order listed.
+func (cfg *RootConfig) includeSharedDefaults(root string) (map[string]string, error) {
+	inc := cfg.Defaults[defaultsIncludeKey]
+	if inc.include == nil {
+		return nil, nil
+	}
+	delete(cfg.Defaults, defaultsIncludeKey)
+	from := make(map[string]string)
+	for _, entry := range inc.include {
+		rel := includePath(entry)
+		var shared struct {
+			Defaults map[string]TypeDefaults `toml:"defaults"`
+		}
+		if err := decodeConfigFile(root, filepath.Join(root, filepath.FromSlash(rel)), &shared); err != nil {
+			return nil, configErrors(err, "parsing [defaults] include "+rel)
+		}
+		if shared.Defaults[defaultsIncludeKey].include != nil {
+			return nil, fmt.Errorf("%s: [defaults] include is only supported in the root ux.toml", rel)
+		}
+		for typeName, td := range shared.Defaults {
+			merged := cfg.Defaults[typeName]
+			if merged.Extends == "" {
+				merged.Extends = td.Extends
+			}
+			if merged.Tasks == nil {
+				merged.Tasks = make(map[string]interface{})
+			}
+			for task, v := range td.Tasks {
+				if _, ok := merged.Tasks[task]; ok {
+					continue
+				}
+				merged.Tasks[task] = v
+				from[typeName+"."+task] = rel
+			}
+			cfg.Defaults[typeName] = merged
+		}
+	}
+	return from, nil
+}
+
+// includesFile reports whether the workspace at root includes the config file
+// at target (an absolute path), directly or through other includes.
+func includesFile(root, target string) bool {
+	return slices.Contains(includedFiles(root), target)
+}
+
+// includedFiles returns the absolute paths of the config files the workspace
+// at root includes, directly or through other includes. Files that can't be
+// read are listed but not followed.
+func includedFiles(root string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	var walk func(path string)
+	walk = func(path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		var probe struct {
+			Workspace struct {
+				Include []string `toml:"include"`
+			} `toml:"workspace"`
+		}
+		if _, err := toml.DecodeFile(path, &probe); err != nil {
+			return
+		}
+		for _, entry := range probe.Workspace.Include {
+			inc := filepath.Join(root, filepath.FromSlash(includePath(entry)))
+			if !slices.Contains(files, inc) {
+				files = append(files, inc)
+			}
+			walk(inc)
+		}
+	}
+	walk(filepath.Join(root, "ux.toml"))
+	return files
+}
@@ -0,0 +1,129 @@
+package ux
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lairoai/ux/internal/uxtest"
+)
+
+var writeFile = uxtest.WriteFile
+
+func TestLoadRootConfigInclude(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//libs/..."]
+include = ["apps/ux.toml"]
+
+[tasks]
+test = { parallel = true }
+`)
+	writeFile(t, filepath.Join(root, "apps", "ux.toml"), `
+[workspace]
+members = ["//apps/...", "//libs/..."]
+include = ["//services/ux.toml"]
+
+[tasks]
+test = { parallel = true }
+build = { parallel = false }
+
+[defaults.go.tasks]
+test = "go test ./..."
+`)
+	writeFile(t, filepath.Join(root, "services", "ux.toml"), `
+[workspace]
+members = ["//services/..."]
+`)
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatalf("LoadRootConfig: %v", err)
+	}
+	if got, want := strings.Join(cfg.Workspace.Members, " "), "//libs/... //apps/... //services/..."; got != want {
+		t.Errorf("members = %q, want %q", got, want)
+	}
+	if _, ok := cfg.Tasks["build"]; !ok {
+		t.Error("included task build missing")
+	}
+	if cfg.Defaults["go"].Tasks["test"] != "go test ./..." {
+		t.Errorf("included defaults = %v", cfg.Defaults)
+	}
+}
+
+func TestLoadRootConfigIncludeConflict(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+include = ["apps/ux.toml"]
+
+[tasks]
+test = { parallel = true }
+`)
+	writeFile(t, filepath.Join(root, "apps", "ux.toml"), `
+[workspace]
+[tasks]
+test = { parallel = false }
+`)
+
+	_, err := LoadRootConfig(root)
+	if err == nil || !strings.Contains(err.Error(), "ux.toml and apps/ux.toml") {
+		t.Errorf("LoadRootConfig error = %v, want conflict naming both files", err)
+	}
+}
+
+func TestLoadRootConfigIncludeCycle(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\ninclude = [\"a/ux.toml\"]\n")
+	writeFile(t, filepath.Join(root, "a", "ux.toml"), "[workspace]\ninclude = [\"b/ux.toml\"]\n")
+	writeFile(t, filepath.Join(root, "b", "ux.toml"), "[workspace]\ninclude = [\"a/ux.toml\"]\n")
+
+	_, err := LoadRootConfig(root)
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("LoadRootConfig error = %v, want include cycle", err)
+	}
+}
+
+func TestLoadRootConfigSharedDefaults(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `[workspace]
+members = ["//libs/..."]
+
+[defaults]
+include = "tools/ux-defaults.toml"
+
+[defaults.python.tasks]
+test = "uv run pytest -x"
+`)
+	writeFile(t, filepath.Join(root, "tools", "ux-defaults.toml"), `[defaults.python.tasks]
+lint = "uv run ruff check"
+test = "uv run pytest"
+
+[defaults.go.tasks]
+test = "go test ./..."
+`)
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Defaults["include"]; ok {
+		t.Error("include left in Defaults")
+	}
+	if got := cfg.Defaults["python"].Tasks; got["test"] != "uv run pytest -x" || got["lint"] != "uv run ruff check" {
+		t.Errorf("python defaults = %v; want the root's test and the shared lint", got)
+	}
+	if got := cfg.Defaults["go"].Tasks["test"]; got != "go test ./..." {
+		t.Errorf("go test default = %v", got)
+	}
+	if cfg.defaultFrom["python.lint"] != "tools/ux-defaults.toml" || cfg.defaultFrom["python.test"] != "ux.toml" {
+		t.Errorf("defaultFrom = %v", cfg.defaultFrom)
+	}
+
+	// Only the root config may include shared defaults
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = []\ninclude = [\"apps/ux.toml\"]\n")
+	writeFile(t, filepath.Join(root, "apps", "ux.toml"), "[defaults]\ninclude = [\"tools/ux-defaults.toml\"]\n")
+	if _, err := LoadRootConfig(root); err == nil || !strings.Contains(err.Error(), "only supported in the root") {
+		t.Errorf("include from an included file: err = %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// RunInteractive runs task on pkg with ux's own stdin, stdout, and stderr
+// handed to its commands, capturing and prefixing nothing, so debuggers,
+// REPLs, and dev servers work as if run by hand (--interactive). Steps run
+// in order, and the commands of a concurrent step one at a time, since they
+// would share the terminal. It returns the exit status of the first command
+// that fails, 1 if one couldn't be started or was killed, or 0.
+func RunInteractive(task string, pkg Package, args []string) int {
+	t := pkg.Tasks[task]
+	shell := t.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+
+	// Ctrl-C reaches the command through the terminal; ux waits for it to
+	// exit rather than dying first. Handled, not ignored, so commands
+	// still get the default behavior.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGQUIT)
+	defer signal.Stop(sigs)
+
+	for _, cmdStr := range t.withArgs(t.Cmds, args) {
+		logger.Debug("interactive", "task", task, "package", pkg.Label, "cmd", cmdStr)
+		cmd := exec.Command(shell, "-c", cmdStr)
+		cmd.Dir = t.dir(pkg.Dir)
+		cmd.Env = pkg.environ()
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		err := cmd.Run()
+		if code := exitCode(err); code > 0 {
+			return code
+		} else if code < 0 {
+			fmt.Fprintf(os.Stderr, "ux: %s: %v\n", cmdStr, err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunInteractive(t *testing.T) {
+	dir := t.TempDir()
+	pkg := Package{Label: "//pkg", Dir: dir, Env: map[string]string{"NAME": "api"}, Tasks: map[string]Task{
+		"serve": {Cmds: []string{`echo "$NAME {args}" > out`, "exit 4", "touch never"}},
+	}}
+
+	if code := RunInteractive("serve", pkg, []string{"--debug"}); code != 4 {
+		t.Errorf("exit status = %d, want 4", code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil || string(data) != "api --debug\n" {
+		t.Errorf("out = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "never")); err == nil {
+		t.Error("ran a step after the failing one")
+	}
+}
@@ -0,0 +1,191 @@
+package ux
+
+import (
+	"io"
+	"sync"
+)
+
+// lineMerger merges the output of several lineWriters into one writer a whole
+// line at a time, so a line from stderr never lands in the middle of a line
+// from stdout.
+type lineMerger struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// newWriter returns a writer for one output stream (e.g. a command's stdout).
+func (m *lineMerger) newWriter() *lineWriter {
+	return &lineWriter{merger: m}
+}
+
+func (m *lineMerger) writeLine(line []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.out.Write(line)
+}
+
+// escape sequence states of an escParser.
+const (
+	escNone = iota
+	escStart
+	escCSI
+	escOSC
+	escOSCEnd
+)
+
+// escParser follows the escape sequences in a stream of output a byte at a
+// time, so a sequence split across writes is still recognized.
+type escParser struct {
+	seq   []byte // escape sequence being read
+	state int
+}
+
+// active reports whether an escape sequence has started but not ended.
+func (e *escParser) active() bool {
+	return e.state != escNone
+}
+
+// next consumes one byte and reports whether it's part of an escape
+// sequence. For the byte that ends one, it also returns the whole sequence,
+// which is only valid until the next call.
+func (e *escParser) next(c byte) (inSeq bool, seq []byte) {
+	if e.state == escNone {
+		if c != 0x1b {
+			return false, nil
+		}
+		e.state = escStart
+		e.seq = append(e.seq[:0], c)
+		return true, nil
+	}
+	e.seq = append(e.seq, c)
+	switch e.state {
+	case escStart:
+		switch c {
+		case '[':
+			e.state = escCSI
+		case ']':
+			e.state = escOSC
+		default:
+			// Two-byte sequences (save/restore cursor, etc.)
+			e.state = escNone
+		}
+	case escCSI:
+		// Parameter and intermediate bytes come before the final one
+		if c >= 0x40 && c <= 0x7e {
+			e.state = escNone
+		}
+	case escOSC:
+		// Operating system commands (titles, hyperlinks) end with BEL or ESC \
+		if c == 0x07 {
+			e.state = escNone
+		} else if c == 0x1b {
+			e.state = escOSCEnd
+		}
+	case escOSCEnd:
+		e.state = escNone
+	}
+	if e.state != escNone {
+		return true, nil
+	}
+	return true, e.seq
+}
+
+// lineWriter assembles a stream of output into lines, handling what
+// terminal-oriented tools emit:
+//
+//   - partial lines are held until their newline arrives, even across writes
+//   - a carriage return without a newline (progress bars) discards the line
+//     written so far, as the terminal would overwrite it
+//   - color (SGR) sequences are kept unless color is disabled; cursor
+//     movement and other control sequences are dropped, with erase-line
+//     treated like a carriage return
+//
+// Call Flush once the stream ends to emit a trailing partial line.
+type lineWriter struct {
+	merger *lineMerger
+	line   []byte
+	esc    escParser
+	cr     bool // saw \r; the line is reset unless \n follows
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if w.cr && !w.esc.active() {
+			w.cr = false
+			if c != '\n' {
+				w.line = w.line[:0]
+			}
+		}
+		if inSeq, seq := w.esc.next(c); inSeq {
+			if seq != nil {
+				w.sequence(seq)
+			}
+			continue
+		}
+		switch c {
+		case '\n':
+			w.emit()
+		case '\r':
+			w.cr = true
+		case '\b':
+			if len(w.line) > 0 {
+				w.line = w.line[:len(w.line)-1]
+			}
+		default:
+			w.line = append(w.line, c)
+		}
+	}
+	return len(p), nil
+}
+
+// sequence applies a complete escape sequence to the line. Only control
+// sequences (CSI) have an effect; the rest are dropped.
+func (w *lineWriter) sequence(seq []byte) {
+	if len(seq) < 3 || seq[1] != '[' {
+		return
+	}
+	final := seq[len(seq)-1]
+	params := string(seq[2 : len(seq)-1])
+	switch {
+	case final == 'm' && colorEnabled:
+		w.line = append(w.line, seq...)
+	case final == 'K' && params == "2", final == 'G':
+		// Erase line / move to column: the line is being redrawn
+		w.line = w.line[:0]
+	}
+}
+
+func (w *lineWriter) emit() {
+	w.merger.writeLine(append(w.line, '\n'))
+	w.line = w.line[:0]
+}
+
+// Flush emits any partial line left at the end of the stream.
+// A final carriage return leaves the line in place, as on a terminal.
+func (w *lineWriter) Flush() {
+	w.cr = false
+	if len(w.line) > 0 {
+		w.emit()
+	}
+}
+
+// ansiStripper writes to w without escape sequences, for log files that are
+// read in editors rather than terminals. A sequence split across writes is
+// still removed.
+type ansiStripper struct {
+	w   io.Writer
+	esc escParser
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		if inSeq, _ := s.esc.next(c); !inSeq {
+			out = append(out, c)
+		}
+	}
+	if _, err := s.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package ux
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"partial lines", []string{"hel", "lo\nwor", "ld"}, "hello\nworld\n"},
+		{"carriage return progress", []string{"10%\r50%\r", "100%\rdone\n"}, "done\n"},
+		{"crlf split across writes", []string{"one\r", "\ntwo\r\n"}, "one\ntwo\n"},
+		{"trailing carriage return", []string{"done\r"}, "done\n"},
+		{"colors kept", []string{"\x1b[31mred\x1b[0m\n"}, "\x1b[31mred\x1b[0m\n"},
+		{"escape split across writes", []string{"a\x1b[3", "2mb\n"}, "a\x1b[32mb\n"},
+		{"cursor movement dropped", []string{"a\x1b[1Ab\x1b7c\n"}, "abc\n"},
+		{"erase line redraws", []string{"downloading\x1b[2K\x1b[1Gdone\n"}, "done\n"},
+		{"title dropped", []string{"\x1b]0;npm\x07ok\n"}, "ok\n"},
+		{"colored redraw after carriage return", []string{"50%\r\x1b[32m", "100%\x1b[0m\n"}, "\x1b[32m100%\x1b[0m\n"},
+		{"backspace", []string{"ab\bc\n"}, "ac\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := (&lineMerger{out: &out}).newWriter()
+			for _, c := range tt.chunks {
+				w.Write([]byte(c))
+			}
+			w.Flush()
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLineMergerKeepsLinesWhole(t *testing.T) {
+	var out bytes.Buffer
+	m := &lineMerger{out: &out}
+	stdout, stderr := m.newWriter(), m.newWriter()
+	stdout.Write([]byte("out: par"))
+	stderr.Write([]byte("err line\n"))
+	stdout.Write([]byte("tial\n"))
+	if want := "err line\nout: partial\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestLineWriterNoColor(t *testing.T) {
+	colorEnabled = false
+	defer func() { colorEnabled = true }()
+
+	var out bytes.Buffer
+	w := (&lineMerger{out: &out}).newWriter()
+	w.Write([]byte("\x1b[1;31mred\x1b[0m\n"))
+	if out.String() != "red\n" {
+		t.Errorf("got %q, want %q", out.String(), "red\n")
+	}
+}
+
+func TestANSIStripper(t *testing.T) {
+	var out bytes.Buffer
+	s := &ansiStripper{w: &out}
+	for _, chunk := range []string{"\x1b[1;31mred\x1b[0m plain\n", "split\x1b[3", "2m green\x1b]8;;http://x\x1b\\link\n"} {
+		if n, err := s.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	if want := "red plain\nsplit greenlink\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
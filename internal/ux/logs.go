@@ -0,0 +1,96 @@
+package ux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// LogsConfig is the root config's [logs] table: where failure logs and
+// collected artifacts go, and how many runs of them are kept.
+type LogsConfig struct {
+	// Dir is the log directory, relative to the workspace root unless
+	// absolute. Defaults to .ux/logs. A directory outside the workspace
+	// may be shared with other checkouts, so runs go in a subdirectory
+	// keyed by the workspace root there.
+	Dir string `toml:"dir"`
+	// Keep is how many runs' logs to keep. Defaults to DefaultLogKeep.
+	Keep int `toml:"keep"`
+}
+
+// DefaultLogKeep is how many runs' logs are kept when [logs] keep is unset.
+const DefaultLogKeep = 20
+
+// runIDPattern matches run IDs, so pruning never touches anything else in
+// the log directory.
+var runIDPattern = regexp.MustCompile(`^\d{8}-\d{6}-\d+$`)
+
+// NewRunID returns an ID for a run that sorts by start time:
+// "20250101-120000-4242" (date, time, process ID).
+func NewRunID() string {
+	return fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+}
+
+// baseDir returns the directory holding the workspace's runs' logs.
+func (c LogsConfig) baseDir(root string) string {
+	dir := c.Dir
+	switch {
+	case dir == "":
+		return filepath.Join(root, StateDir, "logs")
+	case !filepath.IsAbs(dir):
+		dir = filepath.Join(root, dir)
+	}
+	if rel, err := filepath.Rel(root, dir); err == nil && filepath.IsLocal(rel) {
+		return dir
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, filepath.Base(root)+"-"+hex.EncodeToString(sum[:6]))
+}
+
+// RunDir returns the directory for one run's logs: <dir>/<run-id>.
+func (c LogsConfig) RunDir(root, runID string) string {
+	return filepath.Join(c.baseDir(root), runID)
+}
+
+// listRuns returns the IDs of the runs with logs, oldest first.
+func (c LogsConfig) listRuns(root string) ([]string, error) {
+	entries, err := os.ReadDir(c.baseDir(root))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, e := range entries {
+		if e.IsDir() && runIDPattern.MatchString(e.Name()) {
+			runs = append(runs, e.Name())
+		}
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// PruneLogs removes all but the newest runs' logs, keeping [logs] keep.
+func PruneLogs(root string, cfg LogsConfig) error {
+	keep := cfg.Keep
+	if keep <= 0 {
+		keep = DefaultLogKeep
+	}
+	runs, err := cfg.listRuns(root)
+	if err != nil || len(runs) <= keep {
+		return err
+	}
+	base := cfg.baseDir(root)
+	for _, run := range runs[:len(runs)-keep] {
+		if err := os.RemoveAll(filepath.Join(base, run)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogsRunDir(t *testing.T) {
+	if got := (LogsConfig{Dir: ".ux/logs"}).RunDir("/ws", "id"); got != "/ws/.ux/logs/id" {
+		t.Errorf("relative dir: got %s", got)
+	}
+	if got := (LogsConfig{Dir: "/ws/logs"}).RunDir("/ws", "id"); got != "/ws/logs/id" {
+		t.Errorf("absolute dir in the workspace: got %s", got)
+	}
+	// Dirs outside the workspace get a subdirectory per workspace
+	shared := (LogsConfig{Dir: "/var/log/ux"}).RunDir("/ws", "id")
+	if dir, _ := filepath.Split(shared); !strings.HasPrefix(dir, "/var/log/ux/ws-") {
+		t.Errorf("absolute dir: got %s", shared)
+	}
+	if other := (LogsConfig{Dir: "/var/log/ux"}).RunDir("/other/ws", "id"); other == shared {
+		t.Errorf("two workspaces share %s", shared)
+	}
+	if got := (LogsConfig{Dir: "../logs"}).RunDir("/ws", "id"); !strings.HasPrefix(got, "/logs/ws-") {
+		t.Errorf("dir outside the workspace: got %s", got)
+	}
+	if got := (LogsConfig{}).RunDir("/ws", "id"); got != "/ws/.ux/logs/id" {
+		t.Errorf("default dir: got %s", got)
+	}
+	if id := NewRunID(); !runIDPattern.MatchString(id) {
+		t.Errorf("NewRunID() = %q doesn't match the run ID pattern", id)
+	}
+}
+
+func TestPruneLogs(t *testing.T) {
+	root := t.TempDir()
+	cfg := LogsConfig{Dir: "logs", Keep: 2}
+	runs := []string{"20250101-120000-1", "20250102-120000-1", "20250103-120000-1", "20250103-120000-2"}
+	for _, run := range runs {
+		if err := os.MkdirAll(filepath.Join(cfg.RunDir(root, run), "test"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Old-style task directories aren't runs and are left alone
+	if err := os.MkdirAll(filepath.Join(root, "logs", "test"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PruneLogs(root, cfg); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := []string{"20250103-120000-1", "20250103-120000-2", "test"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	if err := PruneLogs(t.TempDir(), LogsConfig{Dir: "missing"}); err != nil {
+		t.Errorf("missing log dir: %v", err)
+	}
+}
+
+func TestPruneLogsDefaultDir(t *testing.T) {
+	root := t.TempDir()
+	cfg := LogsConfig{Keep: 1}
+	runs := []string{"20250101-120000-1", "20250102-120000-1"}
+	for _, run := range runs {
+		if err := os.MkdirAll(cfg.RunDir(root, run), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := PruneLogs(root, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.RunDir(root, runs[0])); !os.IsNotExist(err) {
+		t.Errorf("old run: err = %v, want it pruned", err)
+	}
+	if _, err := os.Stat(cfg.RunDir(root, runs[1])); err != nil {
+		t.Errorf("newest run: %v", err)
+	}
+}
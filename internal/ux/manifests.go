@@ -0,0 +1,153 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// memberManifests reads the member directories listed by each kind of
+// workspace manifest [workspace] members_from can name, relative to the
+// manifest's directory.
+var memberManifests = map[string]func(path string) ([]string, error){
+	"go.work":    goWorkMembers,
+	"Cargo.toml": cargoMembers,
+}
+
+// addManifestMembers adds the members listed by each members_from manifest
+// to the workspace members, skipping ones already there.
+func (cfg *RootConfig) addManifestMembers(root string) error {
+	for _, manifest := range cfg.Workspace.MembersFrom {
+		members, err := manifestMembers(root, manifest)
+		if err != nil {
+			return fmt.Errorf("members_from: %w", err)
+		}
+		for _, m := range members {
+			if !slices.Contains(cfg.Workspace.Members, m) {
+				cfg.Workspace.Members = append(cfg.Workspace.Members, m)
+			}
+		}
+	}
+	return nil
+}
+
+// manifestMembers returns the members a go.work or workspace Cargo.toml
+// lists, as labels. The manifest is a workspace-relative path. A module at
+// the workspace root is left out: the root is never a package.
+func manifestMembers(root, manifest string) ([]string, error) {
+	rel := filepath.FromSlash(strings.TrimPrefix(manifest, "//"))
+	read, ok := memberManifests[filepath.Base(rel)]
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a go.work or Cargo.toml", manifest)
+	}
+	file := filepath.Join(root, rel)
+	dirs, err := read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifest, err)
+	}
+	var labels []string
+	for _, dir := range dirs {
+		abs := filepath.Join(filepath.Dir(file), filepath.FromSlash(dir))
+		r, err := filepath.Rel(root, abs)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: member %s is outside the workspace", manifest, dir)
+		}
+		if r == "." {
+			continue
+		}
+		if label := "//" + filepath.ToSlash(r); !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// goWorkMembers returns the module directories of a go.work's use
+// directives, in either form: `use ./a` or a `use ( ... )` block.
+func goWorkMembers(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case line == "use (":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "use "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "use "))
+		default:
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs, nil
+}
+
+// cargoMembers returns the crate directories of a Cargo.toml's [workspace]:
+// its members, with globs expanded to directories holding a Cargo.toml,
+// less its exclude entries.
+func cargoMembers(file string) ([]string, error) {
+	var manifest struct {
+		Workspace *struct {
+			Members []string `toml:"members"`
+			Exclude []string `toml:"exclude"`
+		} `toml:"workspace"`
+	}
+	if _, err := toml.DecodeFile(file, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Workspace == nil {
+		return nil, fmt.Errorf("no [workspace] table")
+	}
+	base := filepath.Dir(file)
+	var dirs []string
+	for _, m := range manifest.Workspace.Members {
+		matches := []string{m}
+		if strings.ContainsAny(m, "*?[") {
+			found, err := filepath.Glob(filepath.Join(base, filepath.FromSlash(m)))
+			if err != nil {
+				return nil, fmt.Errorf("member %q: %w", m, err)
+			}
+			matches = nil
+			for _, f := range found {
+				if _, err := os.Stat(filepath.Join(f, "Cargo.toml")); err == nil {
+					rel, _ := filepath.Rel(base, f)
+					matches = append(matches, filepath.ToSlash(rel))
+				}
+			}
+		}
+		for _, dir := range matches {
+			dir = path.Clean(dir)
+			excluded := slices.ContainsFunc(manifest.Workspace.Exclude, func(ex string) bool {
+				return path.Clean(ex) == dir
+			})
+			if !excluded {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs, nil
+}
@@ -0,0 +1,94 @@
+package ux
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestManifestMembers(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "go.work"), `go 1.24
+
+use .
+use ./tools/gen // code generator
+use (
+	./services/api
+	"./services/web"
+	// ./services/old
+)
+`)
+	writeFile(t, filepath.Join(root, "rust", "Cargo.toml"), `[workspace]
+members = ["crates/*", "bin/cli"]
+exclude = ["crates/scratch"]
+`)
+	for _, c := range []string{"core", "scratch", "util"} {
+		writeFile(t, filepath.Join(root, "rust", "crates", c, "Cargo.toml"), "[package]\n")
+	}
+	writeFile(t, filepath.Join(root, "rust", "crates", "notes", "README.md"), "not a crate\n")
+	writeFile(t, filepath.Join(root, "single", "Cargo.toml"), "[package]\nname = \"single\"\n")
+
+	tests := []struct {
+		manifest string
+		want     []string
+		err      string
+	}{
+		{"go.work", []string{"//tools/gen", "//services/api", "//services/web"}, ""},
+		{"//rust/Cargo.toml", []string{"//rust/crates/core", "//rust/crates/util", "//rust/bin/cli"}, ""},
+		{"single/Cargo.toml", nil, "no [workspace] table"},
+		{"package.json", nil, "expected a go.work or Cargo.toml"},
+	}
+	for _, tt := range tests {
+		got, err := manifestMembers(root, tt.manifest)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("manifestMembers(%s) error = %v, want %q", tt.manifest, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("manifestMembers(%s): %v", tt.manifest, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("manifestMembers(%s) = %v, want %v", tt.manifest, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRootConfigMembersFrom(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `[workspace]
+members = ["//services/api"]
+members_from = ["go.work"]
+`)
+	writeFile(t, filepath.Join(root, "go.work"), "go 1.24\n\nuse (\n\t./services/api\n\t./lib\n)\n")
+	writeFile(t, filepath.Join(root, "services", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "lib", "go.mod"), "module lib\n")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"//services/api", "//lib"}; !slices.Equal(cfg.Workspace.Members, want) {
+		t.Errorf("Members = %v, want %v", cfg.Workspace.Members, want)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, pkg := range packages {
+		labels = append(labels, pkg.Label)
+	}
+	if want := []string{"//lib", "//services/api"}; !slices.Equal(labels, want) {
+		t.Errorf("packages = %v, want %v", labels, want)
+	}
+
+	if checks := doctorManifests(root, nil, packages[:1]); len(checks) != 1 || !strings.Contains(checks[0].Detail, "//services/api") {
+		t.Errorf("doctorManifests = %+v, want a warning about //services/api", checks)
+	}
+	if checks := doctorManifests(root, cfg.Workspace.MembersFrom, packages[:1]); len(checks) != 0 {
+		t.Errorf("doctorManifests with members_from = %+v, want none", checks)
+	}
+}
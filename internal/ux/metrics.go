@@ -0,0 +1,250 @@
+package ux
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// labelEscaper escapes a Prometheus label value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsTimeout bounds a metrics push, so an unreachable endpoint can't
+// hold up the end of a CI job.
+const metricsTimeout = 10 * time.Second
+
+// statsdPacketSize keeps each statsd datagram under a typical MTU.
+const statsdPacketSize = 1400
+
+// CheckMetricsTarget reports whether target is something PushMetrics can
+// push to: a pushgateway's http:// or https:// URL, or statsd://host:port.
+func CheckMetricsTarget(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("--metrics-push: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("--metrics-push: %q has no host", target)
+		}
+	case "statsd":
+		if _, _, err := net.SplitHostPort(u.Host); err != nil {
+			return fmt.Errorf("--metrics-push: %q needs a host and port, like statsd://localhost:8125", target)
+		}
+	default:
+		return fmt.Errorf("--metrics-push: %q is neither a pushgateway (http://, https://) nor statsd:// URL", target)
+	}
+	return nil
+}
+
+// PushMetrics sends a run's metrics to target (see CheckMetricsTarget):
+// each package's duration, outcome, and whether it was cached, and totals
+// for the run.
+func PushMetrics(target, task string, start, end time.Time, results []Result) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	var ran []Result
+	for _, r := range results {
+		if !r.Removed {
+			ran = append(ran, r)
+		}
+	}
+	sort.SliceStable(ran, func(i, j int) bool {
+		return ran[i].Package.Label < ran[j].Package.Label
+	})
+	if u.Scheme == "statsd" {
+		return pushStatsd(u.Host, task, end.Sub(start), ran)
+	}
+	return pushGateway(u, task, end.Sub(start), ran)
+}
+
+// pushGateway replaces the metrics of the run's group on a Prometheus
+// pushgateway. The group is job="ux", unless the URL already names one
+// (…/metrics/job/<job>/…), and the task, so runs of different tasks don't
+// replace each other's metrics.
+func pushGateway(u *url.URL, task string, elapsed time.Duration, results []Result) error {
+	if !strings.Contains(u.Path, "/metrics/job/") {
+		u = u.JoinPath("metrics", "job", "ux")
+	}
+	if !strings.Contains(u.Path+"/", "/task/") && !strings.Contains(u.Path, "/task@base64/") {
+		if strings.Contains(task, "/") {
+			u.Path = strings.TrimSuffix(u.Path, "/") + "/task@base64/" + base64.RawURLEncoding.EncodeToString([]byte(task))
+		} else {
+			u = u.JoinPath("task", task)
+		}
+	}
+
+	var b bytes.Buffer
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	sample := func(name string, value float64, labels ...string) {
+		b.WriteString(name)
+		if len(labels) > 0 {
+			b.WriteByte('{')
+			for i := 0; i < len(labels); i += 2 {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				fmt.Fprintf(&b, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+			}
+			b.WriteByte('}')
+		}
+		fmt.Fprintf(&b, " %g\n", value)
+	}
+	perPackage := func(name, help string, value func(Result) float64) {
+		metric(name, help)
+		for _, r := range results {
+			sample(name, value(r), "package", r.Package.Label, "task", r.Task)
+		}
+	}
+	perPackage("ux_package_duration_seconds", "How long the package's task took.", func(r Result) float64 {
+		return r.Duration.Seconds()
+	})
+	perPackage("ux_package_success", "1 if the package's task passed, 0 if it failed.", func(r Result) float64 {
+		return boolValue(r.Success)
+	})
+	perPackage("ux_package_cached", "1 if the package's result was replayed from the task cache.", func(r Result) float64 {
+		return boolValue(r.Cached)
+	})
+	perPackage("ux_package_flaky", "1 if the package's task passed only on a retry.", func(r Result) float64 {
+		return boolValue(r.Flaky)
+	})
+
+	passed, failed, allowed, cached := runCounts(results)
+	metric("ux_run_duration_seconds", "How long the run took.")
+	sample("ux_run_duration_seconds", elapsed.Seconds())
+	metric("ux_run_packages", "Packages in the run, by outcome.")
+	sample("ux_run_packages", float64(passed), "status", "passed")
+	sample("ux_run_packages", float64(failed), "status", "failed")
+	sample("ux_run_packages", float64(allowed), "status", "allowed")
+	sample("ux_run_packages", float64(cached), "status", "cached")
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Timeout: metricsTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", u.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// pushStatsd sends the run's metrics to a statsd server over UDP, named
+// ux.<task>.<package>.<metric>, with a package's path segments as levels.
+func pushStatsd(addr, task string, elapsed time.Duration, results []Result) error {
+	conn, err := net.DialTimeout("udp", addr, metricsTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var lines []string
+	for _, r := range results {
+		prefix := "ux." + statsdName(r.Task) + "." + statsdPath(r.Package.Label)
+		lines = append(lines, fmt.Sprintf("%s.duration:%d|ms", prefix, r.Duration.Milliseconds()))
+		switch {
+		case r.Success:
+			lines = append(lines, prefix+".passed:1|c")
+		case r.Failed():
+			lines = append(lines, prefix+".failed:1|c")
+		case r.Allowed:
+			lines = append(lines, prefix+".allowed:1|c")
+		}
+		if r.Cached {
+			lines = append(lines, prefix+".cached:1|c")
+		}
+	}
+	passed, failed, allowed, cached := runCounts(results)
+	prefix := "ux." + statsdName(task) + ".run"
+	lines = append(lines,
+		fmt.Sprintf("%s.duration:%d|ms", prefix, elapsed.Milliseconds()),
+		fmt.Sprintf("%s.passed:%d|c", prefix, passed),
+		fmt.Sprintf("%s.failed:%d|c", prefix, failed),
+		fmt.Sprintf("%s.allowed:%d|c", prefix, allowed),
+		fmt.Sprintf("%s.cached:%d|c", prefix, cached))
+
+	var packet strings.Builder
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdPacketSize {
+			if _, err := conn.Write([]byte(packet.String())); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	_, err = conn.Write([]byte(packet.String()))
+	return err
+}
+
+// runCounts returns how many results passed, failed, failed but were
+// allowed to, and were cached.
+func runCounts(results []Result) (passed, failed, allowed, cached int) {
+	for _, r := range results {
+		switch {
+		case r.Success:
+			passed++
+		case r.Failed():
+			failed++
+		case r.Allowed:
+			allowed++
+		}
+		if r.Cached {
+			cached++
+		}
+	}
+	return passed, failed, allowed, cached
+}
+
+// statsdPath turns a label into dot-separated statsd levels:
+// //services/api becomes services.api, and //. becomes root.
+func statsdPath(label string) string {
+	rel := strings.TrimPrefix(label, "//")
+	if rel == "" || rel == "." {
+		return "root"
+	}
+	parts := strings.Split(rel, "/")
+	for i, p := range parts {
+		parts[i] = statsdName(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// statsdName replaces the characters statsd treats specially, and dots, so
+// s is one level of a metric name.
+func statsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', '#', ',', ' ', '/':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,125 @@
+package ux
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func metricsResults() []Result {
+	return []Result{
+		{Package: Package{Label: "//services/api"}, Task: "test", Success: true, Duration: 1500 * time.Millisecond},
+		{Package: Package{Label: "//libs/core"}, Task: "test", Success: true, Cached: true},
+		{Package: Package{Label: `//libs/odd"name`}, Task: "test", Duration: 2 * time.Second},
+		{Package: Package{Label: "//gone"}, Task: "test", Removed: true},
+		{Package: Package{Label: "//libs/wip"}, Task: "test", Allowed: true},
+	}
+}
+
+func TestPushMetricsPushgateway(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+	}))
+	defer srv.Close()
+
+	start := time.Unix(1700000000, 0)
+	if err := PushMetrics(srv.URL, "test", start, start.Add(3*time.Second), metricsResults()); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/ux/task/test" {
+		t.Errorf("pushed with %s %s", method, path)
+	}
+	for _, want := range []string{
+		"# TYPE ux_package_duration_seconds gauge\n",
+		`ux_package_duration_seconds{package="//services/api",task="test"} 1.5` + "\n",
+		`ux_package_success{package="//libs/odd\"name",task="test"} 0` + "\n",
+		`ux_package_cached{package="//libs/core",task="test"} 1` + "\n",
+		"ux_run_duration_seconds 3\n",
+		`ux_run_packages{status="passed"} 2` + "\n",
+		`ux_run_packages{status="failed"} 1` + "\n",
+		`ux_run_packages{status="allowed"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "//gone") {
+		t.Errorf("removed package pushed:\n%s", body)
+	}
+
+	// A URL naming a group still gets the task, so tasks don't replace
+	// each other's metrics
+	if err := PushMetrics(srv.URL+"/metrics/job/ci/branch/main", "test", start, start, metricsResults()); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/metrics/job/ci/branch/main/task/test" {
+		t.Errorf("pushed to %s", path)
+	}
+	if err := PushMetrics(srv.URL+"/metrics/job/ci/task/all", "test", start, start, metricsResults()); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/metrics/job/ci/task/all" {
+		t.Errorf("pushed to %s", path)
+	}
+}
+
+func TestPushMetricsStatsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Unix(1700000000, 0)
+	if err := PushMetrics("statsd://"+conn.LocalAddr().String(), "test", start, start.Add(3*time.Second), metricsResults()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(string(buf[:n]), "\n")
+	want := []string{
+		"ux.test.libs.core.duration:0|ms",
+		"ux.test.libs.core.passed:1|c",
+		"ux.test.libs.core.cached:1|c",
+		`ux.test.libs.odd"name.duration:2000|ms`,
+		`ux.test.libs.odd"name.failed:1|c`,
+		"ux.test.libs.wip.duration:0|ms",
+		"ux.test.libs.wip.allowed:1|c",
+		"ux.test.services.api.duration:1500|ms",
+		"ux.test.services.api.passed:1|c",
+		"ux.test.run.duration:3000|ms",
+		"ux.test.run.passed:2|c",
+		"ux.test.run.failed:1|c",
+		"ux.test.run.allowed:1|c",
+		"ux.test.run.cached:1|c",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statsd lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckMetricsTarget(t *testing.T) {
+	for target, ok := range map[string]bool{
+		"http://pushgateway:9091":  true,
+		"https://pg.example.com":   true,
+		"statsd://localhost:8125":  true,
+		"statsd://localhost":       false,
+		"pushgateway:9091":         false,
+		"udp://localhost:8125":     false,
+		"http:///metrics/job/test": false,
+	} {
+		if err := CheckMetricsTarget(target); (err == nil) != ok {
+			t.Errorf("CheckMetricsTarget(%q) = %v", target, err)
+		}
+	}
+}
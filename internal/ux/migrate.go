@@ -10,21 +10,37 @@ import (
 )
 
 type packageJSON struct {
-	Name       string            `json:"name"`
-	Workspaces json.RawMessage   `json:"workspaces"`
-	Scripts    map[string]string `json:"scripts"`
+	Name            string            `json:"name"`
+	Workspaces      json.RawMessage   `json:"workspaces"`
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	PackageManager  string            `json:"packageManager"` // e.g. "pnpm@9.1.0"
 }
 
 type turboJSON struct {
 	Tasks map[string]json.RawMessage `json:"tasks"`
+	// Pipeline is the turbo 1.x name for tasks.
+	Pipeline map[string]json.RawMessage `json:"pipeline"`
+}
+
+// turboTask is the subset of a turbo.json task definition that ux migrates.
+type turboTask struct {
+	DependsOn []string `json:"dependsOn"`
+	Inputs    []string `json:"inputs"`
+	Outputs   []string `json:"outputs"`
+	Cache     *bool    `json:"cache"`
 }
 
 // migratedPackage holds a workspace member's info during migration.
 type migratedPackage struct {
 	dir     string
 	name    string
+	npmName string // full package.json name, e.g. @acme/core
 	pkgType string
 	scripts map[string]string
+	npmDeps []string // package.json dependency names
+	deps    []string // labels of workspace packages it depends on
 }
 
 // RunMigrate reads a turborepo workspace and generates ux.toml files.
@@ -44,7 +60,14 @@ func RunMigrate(dir string) error {
 		return fmt.Errorf("parsing workspaces: %w", err)
 	}
 	if len(workspacePatterns) == 0 {
-		return fmt.Errorf("root package.json has no workspaces defined")
+		// pnpm lists workspaces in its own file instead
+		workspacePatterns, err = readPnpmWorkspace(filepath.Join(dir, "pnpm-workspace.yaml"))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading pnpm-workspace.yaml: %w", err)
+		}
+	}
+	if len(workspacePatterns) == 0 {
+		return fmt.Errorf("root package.json has no workspaces defined, and there's no pnpm-workspace.yaml")
 	}
 
 	// 2. Try to read turbo.json for task definitions
@@ -55,6 +78,7 @@ func RunMigrate(dir string) error {
 
 	// 4. Collect all task names from turbo.json + root scripts
 	taskNames := collectTaskNames(turbo, rootPkg.Scripts)
+	turboTasks := parseTurboTasks(turbo)
 
 	// 5. Convert npm workspace patterns to //... labels
 	members := convertWorkspacePatterns(workspacePatterns)
@@ -78,20 +102,36 @@ func RunMigrate(dir string) error {
 			if idx := strings.LastIndex(name, "/"); idx >= 0 {
 				name = name[idx+1:]
 			}
+			var npmDeps []string
+			for dep := range memberPkg.Dependencies {
+				npmDeps = append(npmDeps, dep)
+			}
+			for dep := range memberPkg.DevDependencies {
+				npmDeps = append(npmDeps, dep)
+			}
 			allPkgs = append(allPkgs, migratedPackage{
 				dir:     memberDir,
 				name:    name,
+				npmName: memberPkg.Name,
 				pkgType: detectType(memberDir),
 				scripts: memberPkg.Scripts,
+				npmDeps: npmDeps,
 			})
 		}
 	}
+	resolveWorkspaceDeps(dir, allPkgs)
 
 	// 7. Find common scripts per type → these become [defaults.<type>.tasks]
 	typeDefaults := findTypeDefaults(allPkgs)
 
-	// 8. Generate and write root ux.toml (now with defaults)
-	rootToml := generateRootTomlWithDefaults(members, taskNames, serialTasks, typeDefaults)
+	// 8. Generate root ux.toml (now with defaults), then per-package configs
+	rootToml := generateRootTomlWithDefaults(members, taskNames, serialTasks, turboTasks, typeDefaults)
+	return writeMigratedConfigs(dir, rootToml, allPkgs, typeDefaults)
+}
+
+// writeMigratedConfigs writes the root ux.toml and a minimal ux.toml (type +
+// overrides only) for each package, skipping files that already exist.
+func writeMigratedConfigs(dir, rootToml string, pkgs []migratedPackage, typeDefaults map[string]map[string]string) error {
 	rootPath := filepath.Join(dir, "ux.toml")
 	if written, err := writeFileIfNew(rootPath, rootToml); err != nil {
 		return err
@@ -101,9 +141,8 @@ func RunMigrate(dir string) error {
 		fmt.Printf("  %s  ux.toml %s\n", styleDim.Render("~"), styleDim.Render("(already exists, skipped)"))
 	}
 
-	// 9. Generate per-package ux.toml (minimal: type + overrides only)
 	var migrated int
-	for _, pkg := range allPkgs {
+	for _, pkg := range pkgs {
 		rel, _ := filepath.Rel(dir, pkg.dir)
 		pkgToml := generateMinimalPackageToml(pkg, typeDefaults)
 		pkgPath := filepath.Join(pkg.dir, "ux.toml")
@@ -169,7 +208,7 @@ func findCommonScripts(pkgs []migratedPackage) map[string]string {
 	return common
 }
 
-func generateRootTomlWithDefaults(members, taskNames []string, serialTasks map[string]bool, typeDefaults map[string]map[string]string) string {
+func generateRootTomlWithDefaults(members, taskNames []string, serialTasks map[string]bool, turboTasks map[string]turboTask, typeDefaults map[string]map[string]string) string {
 	var b strings.Builder
 
 	b.WriteString("[workspace]\nmembers = [\n")
@@ -183,8 +222,21 @@ func generateRootTomlWithDefaults(members, taskNames []string, serialTasks map[s
 	b.WriteString("]\n\n[tasks]\n")
 
 	for _, name := range taskNames {
-		parallel := !serialTasks[name]
-		b.WriteString(fmt.Sprintf("%s = { parallel = %v }\n", name, parallel))
+		fields := []string{fmt.Sprintf("parallel = %v", !serialTasks[name])}
+		t := turboTasks[name]
+		if len(t.DependsOn) > 0 {
+			fields = append(fields, "depends_on = "+tomlStringArray(t.DependsOn))
+		}
+		if len(t.Inputs) > 0 {
+			fields = append(fields, "inputs = "+tomlStringArray(t.Inputs))
+		}
+		if len(t.Outputs) > 0 {
+			fields = append(fields, "outputs = "+tomlStringArray(t.Outputs))
+		}
+		if t.Cache != nil && !*t.Cache {
+			fields = append(fields, "cache = false")
+		}
+		b.WriteString(fmt.Sprintf("%s = { %s }\n", name, strings.Join(fields, ", ")))
 	}
 
 	// Write [defaults.<type>.tasks] sections
@@ -223,6 +275,9 @@ func generateMinimalPackageToml(pkg migratedPackage, typeDefaults map[string]map
 	if pkg.pkgType != "" {
 		b.WriteString(fmt.Sprintf("type = %q\n", pkg.pkgType))
 	}
+	if len(pkg.deps) > 0 {
+		b.WriteString(fmt.Sprintf("deps = %s\n", tomlStringArray(pkg.deps)))
+	}
 
 	// Figure out which scripts need to be in [tasks] (overrides + extras)
 	defaults := typeDefaults[pkg.pkgType]
@@ -294,6 +349,9 @@ func readTurboJSON(path string) (*turboJSON, error) {
 	if err := json.Unmarshal(data, &t); err != nil {
 		return nil, err
 	}
+	if t.Tasks == nil {
+		t.Tasks = t.Pipeline
+	}
 	return &t, nil
 }
 
@@ -314,7 +372,10 @@ func collectTaskNames(turbo *turboJSON, scripts map[string]string) []string {
 	seen := make(map[string]bool)
 	if turbo != nil {
 		for name := range turbo.Tasks {
-			seen[name] = true
+			// Package-scoped tasks (web#deploy) aren't workspace tasks
+			if !strings.Contains(name, "#") {
+				seen[name] = true
+			}
 		}
 	}
 	for _, cmd := range scripts {
@@ -330,6 +391,73 @@ func collectTaskNames(turbo *turboJSON, scripts map[string]string) []string {
 	return names
 }
 
+// parseTurboTasks reads the dependsOn, inputs, outputs, and cache settings of
+// each turbo.json task. Entries with no ux equivalent are dropped: package-scoped
+// tasks and deps (pkg#task), $ENV and $TURBO_DEFAULT$ tokens, and !negated globs.
+func parseTurboTasks(turbo *turboJSON) map[string]turboTask {
+	result := make(map[string]turboTask)
+	if turbo == nil {
+		return result
+	}
+	for name, raw := range turbo.Tasks {
+		if strings.Contains(name, "#") {
+			continue
+		}
+		var t turboTask
+		if err := json.Unmarshal(raw, &t); err != nil {
+			continue
+		}
+		result[name] = turboTask{
+			DependsOn: keepTurboEntries(t.DependsOn),
+			Inputs:    keepTurboEntries(t.Inputs),
+			Outputs:   keepTurboEntries(t.Outputs),
+			Cache:     t.Cache,
+		}
+	}
+	return result
+}
+
+// keepTurboEntries drops turbo-specific entries ux can't express.
+func keepTurboEntries(entries []string) []string {
+	var kept []string
+	for _, e := range entries {
+		if strings.Contains(e, "#") || strings.HasPrefix(e, "$") || strings.HasPrefix(e, "!") {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// resolveWorkspaceDeps maps each package's package.json dependencies onto
+// the workspace packages they name, filling in deps as //labels.
+func resolveWorkspaceDeps(root string, pkgs []migratedPackage) {
+	labels := make(map[string]string)
+	for _, pkg := range pkgs {
+		rel, _ := filepath.Rel(root, pkg.dir)
+		labels[pkg.npmName] = "//" + filepath.ToSlash(rel)
+	}
+	for i := range pkgs {
+		var deps []string
+		for _, name := range pkgs[i].npmDeps {
+			if label, ok := labels[name]; ok && name != pkgs[i].npmName {
+				deps = append(deps, label)
+			}
+		}
+		sort.Strings(deps)
+		pkgs[i].deps = deps
+	}
+}
+
+// tomlStringArray formats strings as an inline TOML array.
+func tomlStringArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
 func extractTurboTaskName(cmd string) string {
 	parts := strings.Fields(cmd)
 	for i, p := range parts {
@@ -0,0 +1,170 @@
+package ux
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// makefileNames are the files make reads by default, in its lookup order.
+var makefileNames = []string{"GNUmakefile", "makefile", "Makefile"}
+
+// RunMigrateMake reads a Makefile-based monorepo and generates ux.toml files.
+// Every directory below dir with a Makefile becomes a package whose .PHONY
+// targets are tasks that run `make <target>`. The root Makefile, which
+// usually just fans out to the subdirectories, is not migrated.
+func RunMigrateMake(dir string) error {
+	fmt.Printf("\n%s\n\n", styleHeader.Render("ux migrate --from make"))
+
+	dirs, err := findMakefileDirs(dir)
+	if err != nil {
+		return err
+	}
+
+	var allPkgs []migratedPackage
+	var members []string
+	seen := make(map[string]bool)
+	for _, pkgDir := range dirs {
+		targets, err := readPhonyTargets(findMakefile(pkgDir))
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		scripts := make(map[string]string, len(targets))
+		for _, t := range targets {
+			scripts[t] = "make " + t
+			seen[t] = true
+		}
+		rel, _ := filepath.Rel(dir, pkgDir)
+		members = append(members, "//"+filepath.ToSlash(rel))
+		allPkgs = append(allPkgs, migratedPackage{
+			dir:     pkgDir,
+			name:    filepath.Base(pkgDir),
+			pkgType: detectType(pkgDir),
+			scripts: scripts,
+		})
+	}
+	if len(allPkgs) == 0 {
+		return fmt.Errorf("no Makefiles with .PHONY targets found below %s", dir)
+	}
+
+	var taskNames []string
+	for name := range seen {
+		taskNames = append(taskNames, name)
+	}
+	sort.Strings(taskNames)
+
+	typeDefaults := findTypeDefaults(allPkgs)
+	rootToml := generateRootTomlWithDefaults(members, taskNames, nil, nil, typeDefaults)
+	return writeMigratedConfigs(dir, rootToml, allPkgs, typeDefaults)
+}
+
+// findMakefileDirs returns the directories below root that contain a
+// Makefile, sorted, skipping hidden and dependency directories.
+func findMakefileDirs(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || skipDirs[name] {
+			return filepath.SkipDir
+		}
+		if findMakefile(path) != "" {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs, err
+}
+
+// findMakefile returns the path of the Makefile make would use in dir, or
+// "" if there is none.
+func findMakefile(dir string) string {
+	for _, name := range makefileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// readPhonyTargets returns the targets declared .PHONY in a Makefile, in
+// order of first appearance. Targets that aren't plain names (variables,
+// patterns, paths) can't be task names and are dropped.
+func readPhonyTargets(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []string
+	seen := make(map[string]bool)
+	var line strings.Builder
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := scanner.Text()
+		// Join backslash continuations into one logical line
+		if cont, ok := strings.CutSuffix(text, "\\"); ok {
+			line.WriteString(cont + " ")
+			continue
+		}
+		line.WriteString(text)
+		logical := line.String()
+		line.Reset()
+
+		if strings.HasPrefix(logical, "\t") {
+			continue // recipe line
+		}
+		if i := strings.Index(logical, "#"); i >= 0 {
+			logical = logical[:i]
+		}
+		rest, ok := strings.CutPrefix(strings.TrimSpace(logical), ".PHONY")
+		if !ok {
+			continue
+		}
+		rest, ok = strings.CutPrefix(strings.TrimSpace(rest), ":")
+		if !ok {
+			continue
+		}
+		for _, t := range strings.Fields(rest) {
+			if isTaskName(t) && !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return targets, nil
+}
+
+// isTaskName reports whether s can be used as a bare TOML key and task name.
+func isTaskName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPhonyTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	writeFile(t, path, `BIN := app
+
+.PHONY: build test # main targets
+.PHONY: lint \
+	fmt test
+
+build:
+	go build -o $(BIN) .
+
+.PHONY: $(BIN) %.o docker/push clean
+clean:
+	rm -f $(BIN)
+`)
+	got, err := readPhonyTargets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"build", "test", "lint", "fmt", "clean"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readPhonyTargets = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTurboTasksPipeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "turbo.json")
+	writeFile(t, path, `{
+  "pipeline": {
+    "build": { "dependsOn": ["^build", "$NODE_ENV"], "outputs": ["dist/**", "!dist/cache/**"] },
+    "test": { "dependsOn": ["build", "web#codegen"] },
+    "web#deploy": { "dependsOn": ["build"] }
+  }
+}`)
+	turbo, err := readTurboJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := parseTurboTasks(turbo)
+
+	want := map[string]turboTask{
+		"build": {DependsOn: []string{"^build"}, Outputs: []string{"dist/**"}},
+		"test":  {DependsOn: []string{"build"}},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("parseTurboTasks = %+v, want %+v", tasks, want)
+	}
+
+	root := generateRootTomlWithDefaults([]string{"//apps/..."}, collectTaskNames(turbo, nil), nil, tasks, nil)
+	for _, line := range []string{
+		`build = { parallel = true, depends_on = ["^build"], outputs = ["dist/**"] }`,
+		`test = { parallel = true, depends_on = ["build"] }`,
+	} {
+		if !strings.Contains(root, line) {
+			t.Errorf("root config missing %q:\n%s", line, root)
+		}
+	}
+}
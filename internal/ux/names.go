@@ -0,0 +1,116 @@
+package ux
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// checkPackageNames warns about packages named alike, which clash in
+// {package_name}, release tags, and wherever else a name stands for one
+// package. With scope ([workspace] scoped_names), those named after their
+// directory are renamed instead to the end of their path, just long enough
+// to be unique (services/api and tools/api rather than api twice). Names
+// set by [package] name are never changed. Labels that flatten to the same
+// log file name are warned about too.
+func checkPackageNames(packages []Package, scope bool) {
+	byName := make(map[string][]int)
+	for i, pkg := range packages {
+		byName[pkg.Name] = append(byName[pkg.Name], i)
+	}
+	scoped := make(map[int]string)
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		group := byName[name]
+		if len(group) < 2 {
+			continue
+		}
+		var labels, named, suggested []string
+		for _, i := range group {
+			pkg := packages[i]
+			s := scopedName(pkg.Label, packages, group, byName)
+			if pkg.namedInConfig || pkg.Label == RootLabel {
+				named = append(named, pkg.Label)
+			} else if scope {
+				scoped[i] = s
+				continue
+			}
+			labels = append(labels, pkg.Label)
+			suggested = append(suggested, fmt.Sprintf("%q", s))
+		}
+		switch {
+		case len(labels) < 2:
+		case len(named) == len(labels):
+			Warnf("%s share the [package] name %q; give each its own, such as %s",
+				strings.Join(labels, ", "), name, strings.Join(suggested, ", "))
+		default:
+			Warnf("%s share the name %q; set [package] name, or [workspace] scoped_names = true to name them %s",
+				strings.Join(labels, ", "), name, strings.Join(suggested, ", "))
+		}
+	}
+	for i, name := range scoped {
+		logger.Debug("scoped package name", "label", packages[i].Label, "name", name)
+		packages[i].Name = name
+	}
+	warnLogNameClashes(packages)
+}
+
+// expandPackageNames fills in {package_name} in the packages' task commands,
+// cwd, and env once checkPackageNames has settled their names, scoped ones
+// included, and gives docker packages the image tasks named after them.
+func expandPackageNames(packages []Package, docker DockerConfig) {
+	for i := range packages {
+		pkg := &packages[i]
+		if slices.Contains(pkg.typeChain, "docker") {
+			dockerBuiltinTasks(docker, pkg.Name, pkg.Tasks, pkg.TaskSources, pkg.skipped)
+		}
+		cmd, raw := placeholderReplacers("{package_name}", pkg.Name)
+		for name, t := range pkg.Tasks {
+			pkg.Tasks[name] = t.expand(cmd, raw)
+		}
+		for k, v := range pkg.Env {
+			pkg.Env[k] = raw.Replace(v)
+		}
+	}
+}
+
+// scopedName is the shortest end of label's path, at least a parent and
+// the directory, that no other package in group ends with and no package
+// is named. It's the whole path if nothing shorter is.
+func scopedName(label string, packages []Package, group []int, byName map[string][]int) string {
+	parts := strings.Split(strings.TrimPrefix(label, "//"), "/")
+	for k := 2; k < len(parts); k++ {
+		name := strings.Join(parts[len(parts)-k:], "/")
+		if _, taken := byName[name]; taken {
+			continue
+		}
+		clash := false
+		for _, i := range group {
+			other := strings.TrimPrefix(packages[i].Label, "//")
+			if packages[i].Label != label && (other == name || strings.HasSuffix(other, "/"+name)) {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return name
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// warnLogNameClashes warns about packages whose logs, spilled output, and
+// cache entries would share a file: labels flatten to file names with "/"
+// as "-", so //a/b-c and //a-b/c clash.
+func warnLogNameClashes(packages []Package) {
+	byFile := make(map[string][]string)
+	for _, pkg := range packages {
+		file := labelFileName(pkg.Label)
+		byFile[file] = append(byFile[file], pkg.Label)
+	}
+	for _, file := range slices.Sorted(maps.Keys(byFile)) {
+		if labels := byFile[file]; len(labels) > 1 {
+			Warnf("%s share the log file name %s; rename a directory to tell their logs apart", strings.Join(labels, ", "), file)
+		}
+	}
+}
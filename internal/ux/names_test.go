@@ -0,0 +1,72 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverPackagesDuplicateNames(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//services/...\", \"//tools/...\", \"//web\"]\n\n[docker]\nregistry = \"ghcr.io/acme\"\n")
+	writeFile(t, filepath.Join(root, "services", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "tools", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "tools", "legacy", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "services", "web", "Dockerfile"), "FROM scratch\n")
+	writeFile(t, filepath.Join(root, "web", "Dockerfile"), "FROM scratch\n")
+	// Named in its config, so it keeps the name the others would have had
+	writeFile(t, filepath.Join(root, "services", "gateway", "ux.toml"), "[package]\nname = \"api\"\ntype = \"go\"\n")
+	writeFile(t, filepath.Join(root, "services", "gateway", "go.mod"), "module gateway\n")
+
+	discover := func(scoped bool) map[string]string {
+		cfg, err := LoadRootConfig(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Workspace.ScopedNames = scoped
+		packages, err := DiscoverPackages(root, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make(map[string]string)
+		for _, pkg := range packages {
+			names[pkg.Label] = pkg.Name
+			// Docker images are named after the package, scoped or not
+			if _, ok := pkg.Tasks["build"]; ok && pkg.Type == "docker" {
+				ref := "ghcr.io/acme/" + pkg.Name + ":latest"
+				if got := pkg.Tasks["build"].Cmds[0]; got != "docker build -t "+ref+" ." {
+					t.Errorf("scoped=%v: %s builds %q", scoped, pkg.Label, got)
+				}
+				if got := pkg.Tasks["push"].Cmds[0]; got != "docker push "+ref {
+					t.Errorf("scoped=%v: %s pushes %q", scoped, pkg.Label, got)
+				}
+			}
+		}
+		return names
+	}
+
+	// Without scoped_names, clashes are only warned about
+	want := map[string]string{
+		"//services/api":     "api",
+		"//services/gateway": "api",
+		"//services/web":     "web",
+		"//tools/api":        "api",
+		"//tools/legacy/api": "api",
+		"//web":              "web",
+	}
+	if got := discover(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+
+	want = map[string]string{
+		"//services/api":     "services/api",
+		"//services/gateway": "api",
+		"//services/web":     "services/web",
+		"//tools/api":        "tools/api",
+		"//tools/legacy/api": "legacy/api",
+		"//web":              "web",
+	}
+	if got := discover(true); !reflect.DeepEqual(got, want) {
+		t.Errorf("scoped names = %v, want %v", got, want)
+	}
+}
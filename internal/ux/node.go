@@ -0,0 +1,122 @@
+package ux
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// nodeLockfiles map a lockfile to the package manager that writes it, in
+// the order they're checked.
+var nodeLockfiles = []struct{ file, manager string }{
+	{"pnpm-lock.yaml", "pnpm"},
+	{"yarn.lock", "yarn"},
+	{"package-lock.json", "npm"},
+}
+
+// detectPackageManager returns the node package manager for a package:
+// "pnpm", "yarn", or "npm". The nearest lockfile decides, looking from the
+// package dir up to the workspace root, then a "packageManager" field in
+// package.json ("pnpm@9.1.0"), then a pnpm-workspace.yaml. It's npm when
+// nothing says otherwise.
+func detectPackageManager(root, dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		for _, l := range nodeLockfiles {
+			if _, err := os.Stat(filepath.Join(d, l.file)); err == nil {
+				return l.manager
+			}
+		}
+		if pkg, err := readPackageJSON(filepath.Join(d, "package.json")); err == nil && pkg.PackageManager != "" {
+			name, _, _ := strings.Cut(pkg.PackageManager, "@")
+			if name == "pnpm" || name == "yarn" || name == "npm" {
+				return name
+			}
+		}
+		if _, err := os.Stat(filepath.Join(d, "pnpm-workspace.yaml")); err == nil {
+			return "pnpm"
+		}
+		if d == root || filepath.Dir(d) == d || !strings.HasPrefix(d, root) {
+			return "npm"
+		}
+	}
+}
+
+// nodeRunCommand returns the command running a package.json script with
+// manager, succeeding without doing anything if the script isn't defined.
+// yarn has no such option, so its tasks are only defined for the scripts a
+// package has (see nodeBuiltinTasks).
+func nodeRunCommand(manager, script string) string {
+	switch manager {
+	case "pnpm":
+		// pnpm passes options after the script name on to the script
+		return "pnpm run --if-present " + script
+	case "yarn":
+		return "yarn run " + script
+	}
+	return "npm run " + script + " --if-present"
+}
+
+// nodeBuiltinTasks rewrites a node package's built-in tasks, which are
+// written for npm, to use its package manager. Tasks replaced by
+// [types.node] are left alone.
+func nodeBuiltinTasks(manager, dir string, tasks map[string]Task, sources map[string]string) {
+	if manager == "npm" {
+		return
+	}
+	var scripts map[string]string
+	if pkg, err := readPackageJSON(filepath.Join(dir, "package.json")); err == nil {
+		scripts = pkg.Scripts
+	}
+	for name, builtin := range builtinDefaults["node"] {
+		t, ok := tasks[name]
+		if !ok || !slices.Equal(t.Cmds, builtin.Cmds) {
+			continue
+		}
+		if _, ok := scripts[name]; !ok && manager == "yarn" {
+			delete(tasks, name)
+			delete(sources, name)
+			continue
+		}
+		t.Cmds = []string{nodeRunCommand(manager, name)}
+		tasks[name] = t
+	}
+}
+
+// readPnpmWorkspace returns the package patterns of a pnpm-workspace.yaml.
+// Only the packages list is read, one "- pattern" per line; negated
+// patterns are skipped.
+func readPnpmWorkspace(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	inPackages := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			inPackages = strings.TrimSpace(strings.TrimSuffix(trimmed, ":")) == "packages"
+			continue
+		}
+		item, ok := strings.CutPrefix(trimmed, "-")
+		if !inPackages || !ok {
+			continue
+		}
+		item = strings.Trim(strings.TrimSpace(item), `"'`)
+		if item != "" && !strings.HasPrefix(item, "!") {
+			patterns = append(patterns, item)
+		}
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,90 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectPackageManager(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"none", nil, "npm"},
+		{"root pnpm lockfile", map[string]string{"pnpm-lock.yaml": ""}, "pnpm"},
+		{"root yarn lockfile", map[string]string{"yarn.lock": ""}, "yarn"},
+		{"nearest lockfile wins", map[string]string{"pnpm-lock.yaml": "", "apps/web/package-lock.json": ""}, "npm"},
+		{"packageManager field", map[string]string{"package.json": `{"packageManager": "yarn@4.1.0"}`}, "yarn"},
+		{"pnpm workspace", map[string]string{"pnpm-workspace.yaml": "packages:\n  - apps/*\n"}, "pnpm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "apps", "web")
+			writeFile(t, filepath.Join(dir, "package.json"), "{}")
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(root, filepath.FromSlash(name)), content)
+			}
+			if got := detectPackageManager(root, dir); got != tt.want {
+				t.Errorf("detectPackageManager = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePackageNodeManager(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "pnpm-lock.yaml"), "")
+	dir := filepath.Join(root, "web")
+	writeFile(t, filepath.Join(dir, "package.json"), `{"scripts": {"lint": "eslint ."}}`)
+	writeFile(t, filepath.Join(dir, "ux.toml"), "[tasks]\nsize = \"{package_manager} run size\"\n")
+
+	pkg, err := resolvePackage(root, dir, nil, builtinTypes(true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"build": "pnpm run --if-present build",
+		"lint":  "pnpm run --if-present lint",
+		"test":  "pnpm run --if-present test",
+		"size":  "pnpm run size",
+	}
+	got := make(map[string]string)
+	for name, task := range pkg.Tasks {
+		got[name] = task.Cmds[0]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tasks = %v, want %v", got, want)
+	}
+
+	// yarn can't skip a missing script, so only defined ones become tasks
+	writeFile(t, filepath.Join(dir, "yarn.lock"), "")
+	pkg, err = resolvePackage(root, dir, nil, builtinTypes(true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkg.Tasks) != 2 || pkg.Tasks["lint"].Cmds[0] != "yarn run lint" {
+		t.Errorf("yarn tasks = %v", pkg.Tasks)
+	}
+}
+
+func TestReadPnpmWorkspace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pnpm-workspace.yaml")
+	writeFile(t, path, `# workspace
+packages:
+  - "apps/*"
+  - 'packages/**'  # libraries
+  - "!**/test/**"
+catalog:
+  react: ^18
+`)
+	got, err := readPnpmWorkspace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"apps/*", "packages/**"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("patterns = %v, want %v", got, want)
+	}
+}
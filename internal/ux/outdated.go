@@ -0,0 +1,130 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Outdated is a package whose outputs of a task are stale.
+type Outdated struct {
+	Task   string
+	Label  string
+	Reason string
+}
+
+// OutputTasks returns the sorted names of the tasks that declare outputs.
+func OutputTasks(tasks map[string]TaskConfig) []string {
+	var names []string
+	for name, cfg := range tasks {
+		if len(cfg.Outputs) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindOutdated checks each package defining task and reports those whose
+// outputs are missing or stale. By default an output is stale when an input
+// file was modified after it; with hash, the outputs must be exactly those
+// cached by a run with the current inputs and command, so it needs the
+// task cache; workspace is every package, for the dependencies cache keys
+// cover. It also returns how many packages were checked.
+func FindOutdated(cacheDir, task string, cfg TaskConfig, packages, workspace []Package, hash bool) (outdated []Outdated, checked int, err error) {
+	for _, pkg := range packages {
+		if _, ok := pkg.Tasks[task]; !ok {
+			continue
+		}
+		checked++
+		var reason string
+		if hash {
+			reason, err = outdatedByHash(cacheDir, task, pkg, cfg, workspace)
+		} else {
+			reason, err = outdatedByMtime(pkg, cfg)
+		}
+		if err != nil {
+			return nil, checked, fmt.Errorf("%s %s: %w", task, pkg.Label, err)
+		}
+		if reason != "" {
+			outdated = append(outdated, Outdated{Task: task, Label: pkg.Label, Reason: reason})
+		}
+	}
+	return outdated, checked, nil
+}
+
+// outdatedByMtime compares the newest input file with the oldest output.
+func outdatedByMtime(pkg Package, cfg TaskConfig) (string, error) {
+	outputs, err := globFiles(pkg.Dir, cfg.Outputs)
+	if err != nil {
+		return "", err
+	}
+	if len(outputs) == 0 {
+		return "no outputs", nil
+	}
+	oldest, oldestTime, err := extremeMtime(pkg.Dir, outputs, time.Time.Before)
+	if err != nil {
+		return "", err
+	}
+	inputs, err := cacheInputFiles(pkg.Dir, cfg)
+	if err != nil {
+		return "", err
+	}
+	if len(inputs) == 0 {
+		return "", nil
+	}
+	newest, newestTime, err := extremeMtime(pkg.Dir, inputs, time.Time.After)
+	if err != nil {
+		return "", err
+	}
+	if newestTime.After(oldestTime) {
+		return fmt.Sprintf("%s is newer than %s", newest, oldest), nil
+	}
+	return "", nil
+}
+
+// extremeMtime returns the file whose mtime wins every comparison by better.
+func extremeMtime(dir string, files []string, better func(a, b time.Time) bool) (string, time.Time, error) {
+	var best string
+	var bestTime time.Time
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if best == "" || better(info.ModTime(), bestTime) {
+			best, bestTime = rel, info.ModTime()
+		}
+	}
+	return best, bestTime, nil
+}
+
+// outdatedByHash looks up the cache entry for the package's current inputs
+// and checks that the outputs on disk match the ones it recorded.
+func outdatedByHash(cacheDir, task string, pkg Package, cfg TaskConfig, workspace []Package) (string, error) {
+	key, err := cacheKey(task, pkg, cfg, nil, dependencyPackages([]Package{pkg}, workspace))
+	if err != nil {
+		return "", err
+	}
+	meta, err := readCacheEntry(cacheEntryDir(cacheDir, task, pkg.Label, key))
+	if err != nil {
+		return "not built from the current inputs", nil
+	}
+	rels := make([]string, 0, len(meta.Outputs))
+	for rel := range meta.Outputs {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		sum, err := fileSHA256(filepath.Join(pkg.Dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Sprintf("%s is missing", rel), nil
+		}
+		if sum != meta.Outputs[rel] {
+			return fmt.Sprintf("%s differs from the build", rel), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,64 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindOutdated(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := t.TempDir()
+	pkg := Package{Label: "//web", Dir: dir, Tasks: map[string]Task{"build": {Cmds: []string{"tsc"}}}}
+	other := Package{Label: "//docs", Dir: t.TempDir(), Tasks: map[string]Task{"lint": {Cmds: []string{"vale"}}}}
+	packages := []Package{pkg, other}
+	cfg := TaskConfig{Outputs: []string{"dist/**"}}
+	check := func(hash bool) string {
+		t.Helper()
+		found, checked, err := FindOutdated(cacheDir, "build", cfg, packages, packages, hash)
+		if err != nil || checked != 1 {
+			t.Fatalf("checked %d, err = %v", checked, err)
+		}
+		if len(found) == 0 {
+			return ""
+		}
+		return found[0].Reason
+	}
+
+	writeFile(t, filepath.Join(dir, "src", "app.ts"), "let x = 1\n")
+	if got := check(false); got != "no outputs" {
+		t.Errorf("before building, reason = %q", got)
+	}
+
+	writeFile(t, filepath.Join(dir, "dist", "app.js"), "var x = 1\n")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "src", "app.ts"), old, old)
+	built := old.Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "dist", "app.js"), built, built)
+	if got := check(false); got != "" {
+		t.Errorf("after building, reason = %q", got)
+	}
+	writeFile(t, filepath.Join(dir, "src", "app.ts"), "let x = 2\n")
+	if got := check(false); got != "src/app.ts is newer than dist/app.js" {
+		t.Errorf("after editing, reason = %q", got)
+	}
+
+	if got := check(true); got != "not built from the current inputs" {
+		t.Errorf("without a cache entry, reason = %q", got)
+	}
+	key, err := cacheKey("build", pkg, cfg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storeCached(cacheDir, "build", cfg, Result{Package: pkg, Success: true}, key); err != nil {
+		t.Fatal(err)
+	}
+	if got := check(true); got != "" {
+		t.Errorf("with a cache entry, reason = %q", got)
+	}
+	writeFile(t, filepath.Join(dir, "dist", "app.js"), "tampered\n")
+	if got := check(true); got != "dist/app.js differs from the build" {
+		t.Errorf("after changing an output, reason = %q", got)
+	}
+}
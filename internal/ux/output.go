@@ -2,8 +2,10 @@ package ux
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	"golang.org/x/term"
 )
 
@@ -22,10 +25,7 @@ var (
 	styleBold     = lipgloss.NewStyle().Bold(true)
 	styleLabel    = lipgloss.NewStyle().Foreground(lipgloss.Color("86")) // Cyan-ish
 	styleWarning  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3"))  // Yellow
-
-	iconSuccess = styleSuccess.Render("✓")
-	iconFail    = styleFail.Render("✗")
-	iconRunning = styleDim.Render("●")
+	styleFlaky    = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
 
 	styleBox = lipgloss.NewStyle().
 			PaddingLeft(2).
@@ -34,6 +34,47 @@ var (
 			BorderForeground(lipgloss.Color("240"))
 )
 
+// Status icons, rendered by renderIcons.
+var iconSuccess, iconFail, iconFlaky, iconRemoved, iconAllowed, iconRunning, iconPending string
+
+func init() { renderIcons() }
+
+// renderIcons styles the status icons for the current color profile.
+func renderIcons() {
+	iconSuccess = styleSuccess.Render("✓")
+	iconFail = styleFail.Render("✗")
+	iconFlaky = styleFlaky.Render("~")
+	iconRemoved = styleDim.Render("−")
+	iconAllowed = styleFlaky.Render("✗")
+	iconRunning = styleDim.Render("●")
+	iconPending = styleDim.Render("·")
+}
+
+// colorEnabled is cleared by DisableColor.
+var colorEnabled = true
+
+// NoColorEnv names the environment variable (https://no-color.org) that,
+// when set to anything non-empty, disables color like --no-color.
+const NoColorEnv = "NO_COLOR"
+
+// DisableColor turns off colors and text styling in all output, including
+// the color codes in package output.
+func DisableColor() {
+	colorEnabled = false
+	lipgloss.SetColorProfile(termenv.Ascii)
+	renderIcons()
+}
+
+// DidYouMean formats label suggestions for a warning, with the labels
+// highlighted: "did you mean //a or //b?".
+func DidYouMean(labels []string) string {
+	styled := make([]string, len(labels))
+	for i, l := range labels {
+		styled[i] = styleLabel.Render(l)
+	}
+	return "did you mean " + strings.Join(styled, " or ") + "?"
+}
+
 // Warnf writes a formatted warning message to stderr with a colorized "warning:" prefix.
 func Warnf(format string, args ...any) {
 	prefix := styleWarning.Render("warning:")
@@ -42,7 +83,11 @@ func Warnf(format string, args ...any) {
 
 const separator = "────────────────────────────────────────────────"
 
-const clearLine = "\033[2K"
+const (
+	cursorHome = "\033[H"
+	clearToEOL = "\033[K"
+	clearBelow = "\033[J"
+)
 
 // output handles synchronized progress display during task execution.
 type output struct {
@@ -54,32 +99,89 @@ type output struct {
 	failed    int
 	running   []string
 	isTTY     bool
+	quiet     bool
 	progress  progress.Model
+
+	// Full-screen UI state: every package in order and the finished ones' results
+	ui     bool
+	header string
+	labels []string
+	done   map[string]Result
+
+	// Duration estimates from run history, refreshed every second while running
+	history *History
+	started map[string]time.Time
+	stop    chan struct{}
+	stopped bool
+
+	drawn int // lines in the live region last drawn
 }
 
-func newOutput(task string, count int, parallel bool) *output {
+func newOutput(task string, packages []Package, parallel bool, opts RunOptions) *output {
 	mode := "serial"
 	if parallel {
 		mode = "parallel"
 	}
+	count := len(packages)
 
 	header := styleHeader.Render("ux " + task)
 	info := styleDim.Render(fmt.Sprintf("(%d packages, %s)", count, mode))
-	fmt.Printf("\n%s  %s\n", header, info)
+	isTTY := term.IsTerminal(int(os.Stdout.Fd()))
+	ui := opts.UI && isTTY
+	if !ui && !opts.Quiet {
+		fmt.Printf("\n%s  %s\n", header, info)
+	}
 
 	// Create a progress bar with a nice gradient
-	pg := progress.New(
+	pgOpts := []progress.Option{
 		progress.WithDefaultGradient(),
 		progress.WithoutPercentage(),
 		progress.WithWidth(40),
-	)
+	}
+	if !colorEnabled {
+		pgOpts = append(pgOpts, progress.WithColorProfile(termenv.Ascii))
+	}
+	pg := progress.New(pgOpts...)
 
-	return &output{
+	o := &output{
 		task:     task,
 		total:    count,
 		parallel: parallel,
-		isTTY:    term.IsTerminal(int(os.Stdout.Fd())),
+		isTTY:    isTTY,
 		progress: pg,
+		quiet:    opts.Quiet,
+		ui:       ui,
+		header:   header + "  " + info,
+		done:     make(map[string]Result),
+		history:  opts.History,
+		started:  make(map[string]time.Time),
+		stop:     make(chan struct{}),
+	}
+	for _, pkg := range packages {
+		o.labels = append(o.labels, pkg.Label)
+	}
+	if isTTY && !opts.Quiet {
+		go o.tick()
+	}
+	return o
+}
+
+// tick redraws progress every second so elapsed times and estimates stay
+// current between events.
+func (o *output) tick() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+			o.mu.Lock()
+			if !o.stopped && len(o.running) > 0 {
+				o.updateProgress()
+			}
+			o.mu.Unlock()
+		}
 	}
 }
 
@@ -88,6 +190,7 @@ func (o *output) markStarted(label string) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 	o.running = append(o.running, label)
+	o.started[label] = time.Now()
 	o.updateProgress()
 }
 
@@ -96,9 +199,10 @@ func (o *output) markCompleted(r Result) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 	o.completed++
-	if !r.Success {
+	if r.Failed() {
 		o.failed++
 	}
+	o.done[r.Package.Label] = r
 	// Remove from running
 	for i, label := range o.running {
 		if label == r.Package.Label {
@@ -109,8 +213,20 @@ func (o *output) markCompleted(r Result) {
 	o.updateProgress()
 }
 
-// updateProgress writes a single-line progress indicator. Must be called with mu held.
+// markSkipped records that n packages won't run, so progress completes
+// without them.
+func (o *output) markSkipped(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.total -= n
+	o.updateProgress()
+}
+
+// updateProgress redraws the progress display. Must be called with mu held.
 func (o *output) updateProgress() {
+	if o.quiet {
+		return
+	}
 	if !o.isTTY {
 		if o.completed > 0 && o.completed == o.total {
 			passed := o.completed - o.failed
@@ -140,6 +256,50 @@ func (o *output) updateProgress() {
 		o.progress.Width = barWidth
 	}
 
+	if o.ui {
+		o.renderScreen()
+		return
+	}
+
+	o.renderRegion()
+}
+
+// renderRegion redraws the live region below the header: the progress line,
+// then one row per running package, as many as fit the terminal. Must be
+// called with mu held.
+func (o *output) renderRegion() {
+	lines := []string{o.progressStatus()}
+
+	rows := len(o.running)
+	if _, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && height > 0 && rows > height-2 {
+		rows = max(height-3, 0)
+	}
+	for _, label := range o.running[:rows] {
+		lines = append(lines, "  "+o.runningRow(label))
+	}
+	if hidden := len(o.running) - rows; hidden > 0 {
+		lines = append(lines, styleDim.Render(fmt.Sprintf("    … %d more running", hidden)))
+	}
+
+	// Return to the top of the previous region, then draw over it
+	var b strings.Builder
+	if o.drawn > 1 {
+		fmt.Fprintf(&b, "\033[%dA", o.drawn-1)
+	}
+	b.WriteString("\r" + strings.Join(lines, clearToEOL+"\n") + clearToEOL + clearBelow)
+	fmt.Print(b.String())
+	o.drawn = len(lines)
+}
+
+// runningRow renders a running package with its elapsed and expected time.
+func (o *output) runningRow(label string) string {
+	name := styleLabel.Render(fmt.Sprintf("%-40s", label))
+	elapsed := time.Since(o.started[label]).Truncate(time.Second).String()
+	return fmt.Sprintf("  %s  %s %s", iconRunning, name, styleDim.Render(elapsed+o.expected(label)))
+}
+
+// progressStatus renders the progress bar with completed, passed, and failed counts.
+func (o *output) progressStatus() string {
 	ratio := float64(o.completed) / float64(o.total)
 	bar := o.progress.ViewAs(ratio)
 
@@ -152,80 +312,253 @@ func (o *output) updateProgress() {
 	if o.failed > 0 {
 		status += " " + styleFail.Render(fmt.Sprintf("%d", o.failed))
 	}
+	if eta, ok := o.eta(); ok {
+		status += "  " + styleDim.Render(fmtEstimate(eta)+" remaining")
+	}
+	return status
+}
+
+// expected formats a running package's expected duration, if known.
+func (o *output) expected(label string) string {
+	if d, ok := o.history.ExpectedDuration(o.task, label); ok {
+		return " (" + fmtEstimate(d) + ")"
+	}
+	return ""
+}
+
+// eta estimates the time until every package has finished, from each
+// unfinished package's expected duration less the time it has been running.
+// Parallel runs end with their slowest package; serial runs add up. There is
+// no estimate unless every unfinished package has one. Must be called with mu held.
+func (o *output) eta() (time.Duration, bool) {
+	var total, longest time.Duration
+	var remaining int
+	for _, label := range o.labels {
+		if _, ok := o.done[label]; ok {
+			continue
+		}
+		d, ok := o.history.ExpectedDuration(o.task, label)
+		if !ok {
+			return 0, false
+		}
+		if start, ok := o.started[label]; ok {
+			d = max(d-time.Since(start), 0)
+		}
+		total += d
+		longest = max(longest, d)
+		remaining++
+	}
+	if remaining == 0 {
+		return 0, false
+	}
+	if o.parallel {
+		return longest, true
+	}
+	return total, true
+}
 
-	if len(o.running) > 0 {
-		status += "  " + styleDim.Render(o.running[0])
-		if len(o.running) > 1 {
-			status += styleDim.Render(fmt.Sprintf(" +%d more", len(o.running)-1))
+// renderScreen redraws the full-screen UI: the header, progress, and one row
+// per package. Rows that don't fit the terminal are summarized as a count.
+// Must be called with mu held.
+func (o *output) renderScreen() {
+	lines := []string{"", o.header, "", o.progressStatus(), ""}
+
+	rows := len(o.labels)
+	if _, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && height > 0 && rows > height-len(lines)-1 {
+		rows = max(height-len(lines)-1, 0)
+	}
+	for _, label := range o.labels[:rows] {
+		name := styleLabel.Render(fmt.Sprintf("%-40s", label))
+		if r, ok := o.done[label]; ok {
+			lines = append(lines, fmt.Sprintf("  %s  %s %s", resultIcon(r), name, styleDim.Render(fmtDuration(r.Duration))))
+		} else if slices.Contains(o.running, label) {
+			lines = append(lines, o.runningRow(label))
+		} else {
+			lines = append(lines, fmt.Sprintf("  %s  %s", iconPending, styleDim.Render(label)))
 		}
 	}
+	if hidden := len(o.labels) - rows; hidden > 0 {
+		lines = append(lines, styleDim.Render(fmt.Sprintf("  … %d more", hidden)))
+	}
 
-	fmt.Printf("\r%s%s", clearLine, status)
+	// Redraw in place from the top-left corner, then clear whatever is left below
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for _, line := range lines {
+		b.WriteString(line + clearToEOL + "\n")
+	}
+	b.WriteString(clearBelow)
+	fmt.Print(b.String())
 }
 
 // clearProgress clears the progress line before summary output.
 func (o *output) clearProgress() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.stopped {
+		o.stopped = true
+		close(o.stop)
+	}
+	if o.ui || o.quiet {
+		return
+	}
 	if o.isTTY {
-		fmt.Printf("\r%s", clearLine)
+		if o.drawn > 1 {
+			fmt.Printf("\033[%dA", o.drawn-1)
+		}
+		fmt.Printf("\r%s", clearBelow)
+		o.drawn = 0
 	}
 }
 
+// SummaryOptions controls what PrintSummary shows.
+type SummaryOptions struct {
+	// Verbose prints failure output inline.
+	Verbose bool
+	// Quiet leaves passing packages out of the table, so only failures and
+	// the final count are shown.
+	Quiet bool
+	// LogDir is the run's log directory (see LogsConfig.RunDir).
+	LogDir string
+	// Sort orders the table and failures: SortLabel (the default),
+	// SortDuration, or SortStatus.
+	Sort string
+}
+
+// Summary orders for SummaryOptions.Sort.
+const (
+	SortLabel    = "label"    // alphabetically by label
+	SortDuration = "duration" // slowest first
+	SortStatus   = "status"   // failures first, then allowed failures, removed, flaky, and passed
+)
+
+// ValidSort reports whether s is a summary order.
+func ValidSort(s string) bool {
+	return s == SortLabel || s == SortDuration || s == SortStatus
+}
+
+// summaryOrder returns results sorted for the summary, by label within
+// equal durations or statuses.
+func summaryOrder(results []Result, order string) []Result {
+	sorted := sortedResults(results)
+	switch order {
+	case SortDuration:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Duration > sorted[j].Duration })
+	case SortStatus:
+		sort.SliceStable(sorted, func(i, j int) bool { return statusRank(sorted[i]) < statusRank(sorted[j]) })
+	}
+	return sorted
+}
+
+// statusRank orders results for SortStatus.
+func statusRank(r Result) int {
+	switch {
+	case r.Removed:
+		return 2
+	case r.Allowed:
+		return 1
+	case !r.Success:
+		return 0
+	case r.Flaky:
+		return 3
+	}
+	return 4
+}
+
 // PrintSummary prints the sorted summary table, writes failure logs, and shows the final count.
-// When verbose is true, failure output is printed inline.
-func PrintSummary(task string, results []Result, verbose bool) {
-	// Sort by label for a stable, scannable summary
-	sorted := make([]Result, len(results))
-	copy(sorted, results)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].Package.Label < sorted[j].Package.Label
-	})
+func PrintSummary(task string, results []Result, opts SummaryOptions) {
+	// Sort by label for a stable, scannable summary, unless asked otherwise
+	sorted := summaryOrder(results, opts.Sort)
 
 	var passed, failed int
-	var failures []Result
+	var failures, allowed, flaky, removed []Result
 
 	for _, r := range sorted {
-		if r.Success {
+		if r.Flaky {
+			flaky = append(flaky, r)
+		}
+		switch {
+		case r.Success:
 			passed++
-		} else {
+		case r.Removed:
+			removed = append(removed, r)
+		case r.Allowed:
+			allowed = append(allowed, r)
+		default:
 			failed++
 			failures = append(failures, r)
 		}
 	}
 
-	fmt.Printf("\n  %s\n\n", styleBold.Render("Results"))
-
 	var rows []string
 	for _, r := range sorted {
-		icon := iconSuccess
-		if !r.Success {
-			icon = iconFail
+		if opts.Quiet && r.Success && !r.Flaky {
+			continue
 		}
+		icon := resultIcon(r)
 		label := styleLabel.Render(fmt.Sprintf("%-40s", r.Package.Label))
 		dur := styleDim.Render(fmtDuration(r.Duration))
+		if r.Cached {
+			dur += styleDim.Render(" (cached)")
+		}
+		if r.Removed {
+			dur += styleDim.Render(" (removed)")
+		}
+		if r.SharedWith != "" {
+			dur += styleDim.Render(" (ran in " + r.SharedWith + ")")
+		}
 		rows = append(rows, fmt.Sprintf("  %s  %s %s", icon, label, dur))
 	}
 
-	fmt.Println(styleBox.Render(strings.Join(rows, "\n")))
+	if len(rows) > 0 {
+		fmt.Printf("\n  %s\n\n", styleBold.Render("Results"))
+		fmt.Println(styleBox.Render(strings.Join(rows, "\n")))
+	}
 
-	// Write log files and show details for failures
-	if len(failures) > 0 {
+	// Write log files and show details for failures, allowed ones last
+	if len(failures)+len(allowed) > 0 {
 		fmt.Println()
-		for _, r := range failures {
-			logFile := writeFailureLog(task, r)
+		for _, r := range append(failures, allowed...) {
+			logFile := writeFailureLog(opts.LogDir, task, r)
 			failHeader := styleFail.Bold(true).Render("FAIL")
-			fmt.Printf("  %s %s\n", failHeader, r.Package.Label)
-			if r.FailedStep != "" {
-				fmt.Printf("    %s\n", styleDim.Render("→ "+r.FailedStep))
+			if r.Quarantined {
+				failHeader = styleFlaky.Bold(true).Render("FAIL (quarantined)")
+			} else if r.Allowed {
+				failHeader = styleFlaky.Bold(true).Render("FAIL (allowed)")
 			}
-			if verbose && r.Output != "" {
+			fmt.Printf("  %s %s\n", failHeader, r.Package.Label)
+			printFailedSteps(r)
+			if opts.Verbose && r.HasOutput() {
 				fmt.Println()
-				lines := strings.Split(strings.TrimRight(r.Output, "\n"), "\n")
-				for _, line := range lines {
-					fmt.Printf("    %s\n", line)
+				if err := writeOutputLines(os.Stdout, r, "    "); err != nil {
+					Warnf("cannot read the output of %s: %v", r.Package.Label, err)
 				}
 				fmt.Println()
 			}
+			if r.Host != "" {
+				fmt.Printf("    %s\n", styleDim.Render("ran on: "+r.Host))
+			}
+			if r.SharedWith != "" {
+				fmt.Printf("    %s\n", styleDim.Render("ran once in: "+r.SharedWith))
+			}
+			if len(r.Package.Owners) > 0 {
+				fmt.Printf("    %s\n", styleDim.Render("owners: "+strings.Join(r.Package.Owners, ", ")))
+			}
 			fmt.Printf("    %s\n", styleDim.Render("log: "+logFile))
+			if r.Artifacts != "" {
+				fmt.Printf("    %s\n", styleDim.Render("artifacts: "+r.Artifacts))
+			}
+		}
+	}
+
+	// Flaky packages passed on retry; name them so they don't go unnoticed
+	if len(flaky) > 0 {
+		fmt.Println()
+		for _, r := range flaky {
+			fmt.Printf("  %s %s\n", styleFlaky.Bold(true).Render("FLAKY"), r.Package.Label)
+			if r.FailedStep != "" {
+				fmt.Printf("    %s\n", styleDim.Render("→ "+r.FailedStep+" (passed on retry)"))
+			}
 		}
 	}
 
@@ -239,21 +572,104 @@ func PrintSummary(task string, results []Result, verbose bool) {
 	} else {
 		finalStatus = fmt.Sprintf("%s  %s", styleBold.Render(task+":"), styleSuccess.Render(fmt.Sprintf("%d passed", passed)))
 	}
+	if len(allowed) > 0 {
+		finalStatus += "  " + styleFlaky.Render(fmt.Sprintf("%d failed (allowed)", len(allowed)))
+	}
+	if len(flaky) > 0 {
+		finalStatus += "  " + styleFlaky.Render(fmt.Sprintf("%d flaky", len(flaky)))
+	}
+	if len(removed) > 0 {
+		finalStatus += "  " + styleDim.Render(fmt.Sprintf("%d removed", len(removed)))
+	}
 	fmt.Printf("\n  %s\n\n", finalStatus)
 }
 
-// writeFailureLog writes the full output of a failed task to /tmp/ux/<task>/<label>.log.
-func writeFailureLog(task string, r Result) string {
-	// //packages/ingest → packages-ingest
-	name := strings.TrimPrefix(r.Package.Label, "//")
-	name = strings.ReplaceAll(name, "/", "-")
+// PrintEnvironment prints the run's environment and its fingerprint below the summaries.
+func PrintEnvironment(env *Environment) {
+	fmt.Printf("  %s\n\n", styleDim.Render(fmt.Sprintf("env %s  %s", env.Fingerprint, env)))
+}
+
+// printFailedSteps shows how a failed package's steps went: each step with
+// its duration and exit code when the task has several, otherwise just the
+// failing command.
+func printFailedSteps(r Result) {
+	if len(r.Steps) > 1 {
+		for _, step := range r.Steps {
+			icon := iconSuccess
+			if !step.Success() {
+				icon = iconFail
+			}
+			fmt.Printf("    %s %s %s\n", icon, step.Cmd, styleDim.Render(describeStep(step)))
+		}
+		return
+	}
+	if r.FailedStep == "" {
+		return
+	}
+	line := "→ " + r.FailedStep
+	if len(r.Steps) == 1 {
+		line += " (" + describeStep(r.Steps[0]) + ")"
+	}
+	fmt.Printf("    %s\n", styleDim.Render(line))
+}
+
+// describeStep formats a step's duration and, if it failed, its exit code:
+// "1.2s" or "3.4s, exit 2".
+func describeStep(step StepResult) string {
+	desc := fmtDuration(step.Duration)
+	switch {
+	case step.ExitCode > 0:
+		desc += fmt.Sprintf(", exit %d", step.ExitCode)
+	case step.ExitCode < 0:
+		desc += ", killed"
+	}
+	return desc
+}
+
+// resultStatus names a finished package's outcome: passed, flaky, removed,
+// allowed (to fail), or failed.
+func resultStatus(r Result) string {
+	switch {
+	case r.Flaky:
+		return "flaky"
+	case r.Removed:
+		return "removed"
+	case r.Allowed:
+		return "allowed"
+	case !r.Success:
+		return "failed"
+	}
+	return "passed"
+}
 
-	dir := filepath.Join(os.TempDir(), "ux", task)
+// resultIcon is the status icon shown next to a finished package.
+func resultIcon(r Result) string {
+	switch {
+	case r.Flaky:
+		return iconFlaky
+	case r.Removed:
+		return iconRemoved
+	case r.Allowed:
+		return iconAllowed
+	case !r.Success:
+		return iconFail
+	}
+	return iconSuccess
+}
+
+// writeFailureLog writes the full output of a failed task to <logDir>/<task>/<label>.log,
+// replacing what was streamed there while it ran. Its header records what
+// each step ran with: the variables ux added to the environment, and the
+// step's directory, shell, start time, and exit code. The new log is renamed
+// into place, so a ux tail still reading the streamed one finishes it, and
+// it ends with the same end marker.
+func writeFailureLog(logDir, task string, r Result) string {
+	dir := taskLogDir(logDir, task)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return ""
 	}
 
-	path := filepath.Join(dir, name+".log")
+	path := packageLogPath(logDir, task, r.Package)
 
 	var content strings.Builder
 	fmt.Fprintf(&content, "ux %s %s\n", task, r.Package.Label)
@@ -262,26 +678,116 @@ func writeFailureLog(task string, r Result) string {
 		fmt.Fprintf(&content, "failed step: %s\n", r.FailedStep)
 	}
 	fmt.Fprintf(&content, "duration: %s\n", fmtDuration(r.Duration))
-	content.WriteString("\n--- output ---\n\n")
-	content.WriteString(r.Output)
+	if !r.Start.IsZero() {
+		fmt.Fprintf(&content, "started: %s\n", r.Start.Format(time.RFC3339))
+	}
+	if r.Host != "" {
+		fmt.Fprintf(&content, "host: %s\n", r.Host)
+	}
+	if env := r.Package.extraEnv(); len(env) > 0 {
+		content.WriteString("env:\n")
+		for _, kv := range env {
+			fmt.Fprintf(&content, "  %s\n", kv)
+		}
+	}
+	for _, step := range r.Steps {
+		fmt.Fprintf(&content, "step: %s (%s)\n", step.Cmd, describeStep(step))
+		fmt.Fprintf(&content, "  started: %s\n", step.Start.Format(time.RFC3339Nano))
+		if step.Dir != "" {
+			fmt.Fprintf(&content, "  cwd: %s\n", step.Dir)
+		}
+		if step.Shell != "" {
+			fmt.Fprintf(&content, "  shell: %s\n", step.Shell)
+		}
+		fmt.Fprintf(&content, "  exit code: %d\n", step.ExitCode)
+	}
+	content.WriteString(logOutputMarker)
 
-	if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+	f, err := os.CreateTemp(dir, labelFileName(r.Package.Label)+"-*.log.tmp")
+	if err != nil {
+		return ""
+	}
+	_, err = io.WriteString(f, content.String())
+	if err == nil {
+		err = r.WriteOutput(&ansiStripper{w: f})
+	}
+	if err == nil {
+		finishPackageLog(f, r)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
 		return ""
 	}
 	return path
 }
 
+// logOutputMarker separates a package log's header from the output.
+const logOutputMarker = "\n--- output ---\n\n"
+
+// createPackageLog creates a package's log file for output to be streamed
+// into while it runs, with a short header.
+func createPackageLog(logDir, task string, pkg Package) (*os.File, error) {
+	if err := os.MkdirAll(taskLogDir(logDir, task), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(packageLogPath(logDir, task, pkg))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(f, "ux %s %s\ndir: %s\n%s", task, pkg.Label, pkg.Dir, logOutputMarker)
+	return f, nil
+}
+
+// logEndMarker starts the line closing a package log once the package has
+// finished, so followers (ux tail) know to stop.
+const logEndMarker = "--- done: "
+
+// finishPackageLog appends the package's outcome to its streamed log.
+func finishPackageLog(f *os.File, r Result) {
+	fmt.Fprintf(f, "\n%s%s in %s ---\n", logEndMarker, resultStatus(r), fmtDuration(r.Duration))
+}
+
+// packageLogPath is where a package's log for a task goes.
+func packageLogPath(logDir, task string, pkg Package) string {
+	return filepath.Join(taskLogDir(logDir, task), labelFileName(pkg.Label)+".log")
+}
+
+// taskLogDir is where package logs and artifacts for a task are written:
+// under the run's log directory, or $TMPDIR/ux if none is set.
+func taskLogDir(logDir, task string) string {
+	if logDir == "" {
+		logDir = filepath.Join(os.TempDir(), "ux")
+	}
+	return filepath.Join(logDir, task)
+}
+
+// labelFileName turns a label into a flat file name: //packages/ingest → packages-ingest,
+// and the workspace root (//.) → root.
+func labelFileName(label string) string {
+	name := strings.TrimPrefix(label, "//")
+	if name == "." {
+		return "root"
+	}
+	return strings.ReplaceAll(name, "/", "-")
+}
+
 // PrintPackageList prints discovered packages (for `ux list`).
 func PrintPackageList(packages []Package) {
 	fmt.Printf("\n%s\n\n", styleHeader.Render("Workspace packages"))
 	for _, pkg := range packages {
-		typeStr := ""
-		if pkg.Type != "" {
-			typeStr = " " + styleHeader.Foreground(lipgloss.Color("36")).Render(pkg.Type)
+		fmt.Printf("  %-40s %s%s\n", pkg.Label, styleDim.Render("("+pkg.Name+")"), packageTypeTag(pkg))
+		if pkg.Description != "" {
+			fmt.Printf("    %s\n", styleDim.Render(pkg.Description))
+		}
+		if len(pkg.Owners) > 0 {
+			fmt.Printf("    %s\n", styleDim.Render("owners: "+strings.Join(pkg.Owners, ", ")))
 		}
-		label := pkg.Label
-		name := styleDim.Render("(" + pkg.Name + ")")
-		fmt.Printf("  %-40s %s%s\n", label, name, typeStr)
 
 		// Sort task names for stable output
 		var taskNames []string
@@ -291,22 +797,114 @@ func PrintPackageList(packages []Package) {
 		sort.Strings(taskNames)
 
 		for _, task := range taskNames {
-			cmds := pkg.Tasks[task]
-			source := ""
-			if s, ok := pkg.TaskSources[task]; ok && s == "default" {
-				source = styleDim.Render(" (default)")
-			}
 			taskName := styleSuccess.Render(fmt.Sprintf("%-12s", task))
-			if len(cmds) == 1 {
-				fmt.Printf("    %s %s%s\n", taskName, cmds[0], source)
-			} else {
-				fmt.Printf("    %s [%d steps]%s\n", taskName, len(cmds), source)
-			}
+			fmt.Printf("    %s %s\n", taskName, describeTask(pkg, task))
 		}
 	}
 	fmt.Println()
 }
 
+// PrintTaskList prints the packages defining a task, one line each with the
+// task's command (for `ux list --task`), under the task's [tasks] description.
+func PrintTaskList(task, description string, packages []Package) {
+	fmt.Printf("\n%s\n", styleHeader.Render(fmt.Sprintf("Packages with %s (%d)", task, len(packages))))
+	if description != "" {
+		fmt.Printf("%s\n", styleDim.Render(description))
+	}
+	fmt.Println()
+	for _, pkg := range packages {
+		typeCol := styleDim.Render(fmt.Sprintf("%-8s", pkg.Type))
+		fmt.Printf("  %-40s %s %s\n", pkg.Label, typeCol, describeTask(pkg, task))
+	}
+	fmt.Println()
+}
+
+// printAvailableTasks lists the tasks the given packages define, with
+// their descriptions, for a run of a task none of them has. A task's
+// description comes from its [tasks] entry, or else from a package that
+// describes it.
+func printAvailableTasks(w io.Writer, packages []Package, cfg map[string]TaskConfig) {
+	descriptions := make(map[string]string)
+	for _, pkg := range packages {
+		for name, t := range pkg.Tasks {
+			if descriptions[name] == "" {
+				descriptions[name] = t.Description
+			}
+		}
+	}
+	if len(descriptions) == 0 {
+		return
+	}
+	names := make([]string, 0, len(descriptions))
+	for name := range descriptions {
+		if d := cfg[name].Description; d != "" {
+			descriptions[name] = d
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "  available tasks:\n")
+	for _, name := range names {
+		if d := descriptions[name]; d != "" {
+			fmt.Fprintf(w, "    %s %s\n", styleSuccess.Render(fmt.Sprintf("%-12s", name)), styleDim.Render(d))
+		} else {
+			fmt.Fprintf(w, "    %s\n", styleSuccess.Render(name))
+		}
+	}
+}
+
+// packageTypeTag renders a package's type for listings, or "" if it has none.
+func packageTypeTag(pkg Package) string {
+	if pkg.Type == "" {
+		return ""
+	}
+	return " " + styleHeader.Foreground(lipgloss.Color("36")).Render(pkg.Type)
+}
+
+// describeTask formats a package's task for listings: its command (or step
+// count) and where it came from.
+func describeTask(pkg Package, task string) string {
+	t := pkg.Tasks[task]
+	source := ""
+	if s, ok := pkg.TaskSources[task]; ok && (s == "default" || s == "builtin" || s == rootTaskSource) {
+		source = styleDim.Render(" (" + s + ")")
+	} else if strings.HasPrefix(s, "//") {
+		source = styleDim.Render(" (from " + s + ")")
+	}
+	if t.Cwd != "" {
+		source += styleDim.Render(" in " + t.Cwd)
+	}
+	if t.Shell != "" {
+		source += styleDim.Render(" via " + t.Shell)
+	}
+	if t.Mutex != "" {
+		source += styleDim.Render(" mutex " + t.Mutex)
+	}
+	if t.RunOnce {
+		source += styleDim.Render(" run once")
+	}
+	if t.Description != "" {
+		source += styleDim.Render(" — " + t.Description)
+	}
+	if len(t.Cmds) == 1 {
+		return t.Cmds[0] + source
+	}
+	if steps := t.steps(); len(steps) == 1 {
+		return fmt.Sprintf("[%d in parallel]%s", len(t.Cmds), source)
+	} else if len(steps) < len(t.Cmds) {
+		return fmt.Sprintf("[%d steps, %d commands]%s", len(steps), len(t.Cmds), source)
+	}
+	return fmt.Sprintf("[%d steps]%s", len(t.Cmds), source)
+}
+
+// fmtEstimate formats an approximate duration coarsely: "~45s", "~3m".
+func fmtEstimate(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("~%ds", max(int(d.Round(time.Second).Seconds()), 1))
+	}
+	return fmt.Sprintf("~%dm", int(d.Round(time.Minute).Minutes()))
+}
+
 func fmtDuration(d time.Duration) string {
 	if d < time.Second {
 		return fmt.Sprintf("%dms", d.Milliseconds())
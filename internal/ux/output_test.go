@@ -0,0 +1,91 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryOrder(t *testing.T) {
+	results := []Result{
+		{Package: Package{Label: "//c"}, Success: true, Duration: 3 * time.Second},
+		{Package: Package{Label: "//a"}, Success: true, Duration: time.Second, Flaky: true},
+		{Package: Package{Label: "//d"}, Duration: 2 * time.Second},
+		{Package: Package{Label: "//b"}, Duration: 3 * time.Second, Allowed: true},
+	}
+	labels := func(rs []Result) string {
+		var l []string
+		for _, r := range rs {
+			l = append(l, r.Package.Label)
+		}
+		return strings.Join(l, " ")
+	}
+	for _, tt := range []struct {
+		order, want string
+	}{
+		{"", "//a //b //c //d"},
+		{SortLabel, "//a //b //c //d"},
+		{SortDuration, "//b //c //d //a"},
+		{SortStatus, "//d //b //a //c"},
+	} {
+		if got := labels(summaryOrder(results, tt.order)); got != tt.want {
+			t.Errorf("order %q = %s, want %s", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFailureLogEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	pkg := Package{Label: "//svc/api", Dir: dir, Env: map[string]string{"STAGE": "ci", "API_URL": "http://localhost"}, Tasks: map[string]Task{
+		"test": {Cmds: []string{"echo ok", "exit 3"}, Shell: "bash"},
+	}}
+	logDir := t.TempDir()
+	r := RunTask("test", []Package{pkg}, TaskConfig{}, RunOptions{Quiet: true})[0]
+
+	data, err := os.ReadFile(writeFailureLog(logDir, "test", r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(string(data), logOutputMarker)
+	for _, want := range []string{
+		"failed step: exit 3\n",
+		"env:\n  API_URL=http://localhost\n  STAGE=ci\n",
+		"step: echo ok (",
+		"step: exit 3 (",
+		"  cwd: " + dir + "\n  shell: bash\n  exit code: 3\n",
+		"  exit code: 0\n",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("log header missing %q:\n%s", want, header)
+		}
+	}
+}
+
+func TestWriteFailureLogKeepsEndMarker(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	pkg := Package{Label: "//svc", Dir: dir, Tasks: map[string]Task{"test": {Cmds: []string{"echo ok; exit 1"}}}}
+	logDir := t.TempDir()
+	r := RunTask("test", []Package{pkg}, TaskConfig{}, RunOptions{Quiet: true, LogDir: logDir})[0]
+
+	path := writeFailureLog(logDir, "test", r)
+	if path != packageLogPath(logDir, "test", pkg) {
+		t.Fatalf("path = %q", path)
+	}
+	// The run is active (it is this process), so only the end marker stops it
+	var out strings.Builder
+	run := fmt.Sprintf("20250101-120000-%d", os.Getpid())
+	if err := FollowLog(&PackageLog{Path: path, RunID: run}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "\n"+logEndMarker+"failed in "+fmtDuration(r.Duration)+" ---\n") {
+		t.Errorf("rewritten log doesn't end with the end marker:\n%s", out.String())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("log dir has %d entries, want only the log", len(entries))
+	}
+}
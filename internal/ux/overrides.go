@@ -0,0 +1,67 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Environment variables that override config knobs, so CI can adjust a
+// run without editing checked-in config. Each takes precedence over
+// ux.toml; a flag for the same knob takes precedence over it.
+const (
+	JobsEnv      = "UX_JOBS"      // --jobs
+	BaseRefEnv   = "UX_BASE_REF"  // the ref --affected diffs against
+	UXNoColorEnv = "UX_NO_COLOR"  // --no-color, like NO_COLOR
+	LogDirEnv    = "UX_LOG_DIR"   // [logs] dir
+	CacheDirEnv  = "UX_CACHE_DIR" // the task cache, .ux/cache by default
+)
+
+// DefaultBaseRef is the ref --affected diffs against unless UX_BASE_REF
+// names another.
+const DefaultBaseRef = "origin/main"
+
+// BaseRef returns the ref --affected diffs against.
+func BaseRef() string {
+	if ref := os.Getenv(BaseRefEnv); ref != "" {
+		return ref
+	}
+	return DefaultBaseRef
+}
+
+// EnvJobs returns the --jobs budget set by UX_JOBS, or 0 if it's unset.
+func EnvJobs() (int, error) {
+	raw := os.Getenv(JobsEnv)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s needs a positive count, got %q", JobsEnv, raw)
+	}
+	return n, nil
+}
+
+// NoColorRequested reports whether the environment turns color off, with
+// NO_COLOR or UX_NO_COLOR set to anything non-empty.
+func NoColorRequested() bool {
+	return os.Getenv(NoColorEnv) != "" || os.Getenv(UXNoColorEnv) != ""
+}
+
+// applyEnv overrides the config with the UX_ environment variables for
+// knobs it has.
+func (c *RootConfig) applyEnv() {
+	if dir := os.Getenv(LogDirEnv); dir != "" {
+		c.Logs.Dir = dir
+	}
+}
+
+// envDir resolves a directory from the environment relative to the
+// workspace root, unless it's absolute.
+func envDir(root, dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(root, dir)
+}
@@ -0,0 +1,43 @@
+package ux
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//...\"]\n\n[logs]\ndir = \"logs\"\n")
+
+	if got := BaseRef(); got != DefaultBaseRef {
+		t.Errorf("BaseRef() = %q, want %q", got, DefaultBaseRef)
+	}
+	if got := CacheDir(root); got != filepath.Join(root, StateDir, "cache") {
+		t.Errorf("CacheDir() = %q", got)
+	}
+
+	t.Setenv(BaseRefEnv, "origin/release")
+	t.Setenv(LogDirEnv, "/var/log/ux")
+	t.Setenv(CacheDirEnv, "build/cache")
+	t.Setenv(JobsEnv, "6")
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Logs.Dir != "/var/log/ux" {
+		t.Errorf("[logs] dir = %q, want UX_LOG_DIR", cfg.Logs.Dir)
+	}
+	if got := BaseRef(); got != "origin/release" {
+		t.Errorf("BaseRef() = %q, want UX_BASE_REF", got)
+	}
+	if got := CacheDir(root); got != filepath.Join(root, "build", "cache") {
+		t.Errorf("CacheDir() = %q, want it under the root", got)
+	}
+	if n, err := EnvJobs(); n != 6 || err != nil {
+		t.Errorf("EnvJobs() = %d, %v", n, err)
+	}
+	t.Setenv(JobsEnv, "lots")
+	if _, err := EnvJobs(); err == nil {
+		t.Error("EnvJobs() accepted UX_JOBS=lots")
+	}
+}
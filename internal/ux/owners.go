@@ -0,0 +1,124 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// codeownersPaths are where GitHub looks for a CODEOWNERS file, in the
+// order it looks; the first one found is used.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeownersRule is one line of a CODEOWNERS file.
+type codeownersRule struct {
+	segs    []string // pattern split on "/", relative to the workspace root
+	dirOnly bool
+	owners  []string
+}
+
+// loadCodeowners reads the workspace root's CODEOWNERS file, if it has one.
+func loadCodeowners(root string) []codeownersRule {
+	for _, name := range codeownersPaths {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		return parseCodeowners(string(data))
+	}
+	return nil
+}
+
+// parseCodeowners parses CODEOWNERS syntax: a .gitignore-style pattern
+// followed by its owners. A pattern with no owners clears ownership of the
+// paths it matches.
+func parseCodeowners(data string) []codeownersRule {
+	var rules []codeownersRule
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pattern := fields[0]
+		var r codeownersRule
+		pattern, r.dirOnly = strings.CutSuffix(pattern, "/")
+		anchored := strings.Contains(pattern, "/")
+		pattern = strings.TrimPrefix(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if !anchored {
+			pattern = "**/" + pattern
+		}
+		r.segs = strings.Split(pattern, "/")
+		r.owners = fields[1:]
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// codeowners returns the owners of the package directory rel
+// (slash-separated, relative to the workspace root): those of the last rule
+// matching it.
+func codeowners(rules []codeownersRule, rel string) []string {
+	var owners []string
+	for _, r := range rules {
+		if matchSegments(r.segs, strings.Split(rel, "/")) {
+			owners = r.owners
+		}
+	}
+	return owners
+}
+
+// withCodeowners gives packages that don't declare [package] owners the
+// owners CODEOWNERS assigns their directory. The root package's directory is
+// the whole workspace, so it gets the owners of its ux.toml instead.
+func withCodeowners(root string, packages []Package) {
+	rules := loadCodeowners(root)
+	if len(rules) == 0 {
+		return
+	}
+	for i, pkg := range packages {
+		if len(pkg.Owners) > 0 {
+			continue
+		}
+		rel := slashRel(root, pkg.Dir)
+		if rel == "" {
+			rel = "ux.toml"
+		}
+		packages[i].Owners = codeowners(rules, rel)
+	}
+}
+
+// OwnedBy reports whether pkg has an owner matching one of owners. An owner
+// matches with or without its leading "@", and a GitHub team matches by its
+// name alone: "data" matches "@acme/data".
+func OwnedBy(pkg Package, owners []string) bool {
+	for _, want := range owners {
+		want = strings.TrimPrefix(want, "@")
+		for _, owner := range pkg.Owners {
+			owner = strings.TrimPrefix(owner, "@")
+			if owner == want {
+				return true
+			}
+			if _, team, ok := strings.Cut(owner, "/"); ok && team == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterByOwner keeps the packages owned by any of owners (see OwnedBy).
+func FilterByOwner(packages []Package, owners []string) []Package {
+	var result []Package
+	for _, pkg := range packages {
+		if OwnedBy(pkg, owners) {
+			result = append(result, pkg)
+		}
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOwners(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//services/...\", \"//libs/...\"]\n\n[root-tasks]\nlint = \"true\"\n")
+	writeFile(t, filepath.Join(root, ".github", "CODEOWNERS"), `# Default owners
+*                @acme/platform
+/services/       @acme/backend   # backend services
+/services/api/   @acme/api @dana
+/services/legacy/
+docs             @acme/docs
+`)
+	writeFile(t, filepath.Join(root, "services", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "services", "billing", "go.mod"), "module billing\n")
+	writeFile(t, filepath.Join(root, "services", "legacy", "go.mod"), "module legacy\n")
+	writeFile(t, filepath.Join(root, "libs", "docs", "go.mod"), "module docs\n")
+	writeFile(t, filepath.Join(root, "libs", "data", "go.mod"), "module data\n")
+	writeFile(t, filepath.Join(root, "libs", "data", "ux.toml"), "[package]\nowners = [\"team-data\"]\n")
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string][]string)
+	for _, pkg := range packages {
+		got[pkg.Label] = pkg.Owners
+	}
+	want := map[string][]string{
+		RootLabel:            {"@acme/platform"},
+		"//libs/data":        {"team-data"}, // [package] owners wins over CODEOWNERS
+		"//libs/docs":        {"@acme/docs"},
+		"//services/api":     {"@acme/api", "@dana"},
+		"//services/billing": {"@acme/backend"},
+		"//services/legacy":  {}, // a pattern without owners clears them
+	}
+	for label, owners := range want {
+		if len(owners) == 0 && len(got[label]) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got[label], owners) {
+			t.Errorf("%s owners = %v, want %v", label, got[label], owners)
+		}
+	}
+
+	labels := func(owners ...string) []string {
+		var labels []string
+		for _, pkg := range FilterByOwner(packages, owners) {
+			labels = append(labels, pkg.Label)
+		}
+		return labels
+	}
+	for _, tc := range []struct {
+		owners []string
+		want   []string
+	}{
+		{[]string{"@acme/api"}, []string{"//services/api"}},
+		{[]string{"acme/api"}, []string{"//services/api"}},
+		{[]string{"api"}, []string{"//services/api"}},
+		{[]string{"dana"}, []string{"//services/api"}},
+		{[]string{"team-data", "backend"}, []string{"//libs/data", "//services/billing"}},
+		{[]string{"acme"}, nil},
+		{[]string{"platform"}, []string{RootLabel}},
+	} {
+		if got := labels(tc.owners...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("--owner %v selects %v, want %v", tc.owners, got, tc.want)
+		}
+	}
+}
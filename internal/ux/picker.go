@@ -0,0 +1,217 @@
+package ux
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// ErrPickCancelled is returned by PickPackages when the picker is dismissed.
+var ErrPickCancelled = errors.New("no packages picked")
+
+// pickerMaxRows caps how many packages the picker lists at once.
+const pickerMaxRows = 15
+
+// PickPackages opens a fuzzy-searchable multi-select of packages on the
+// terminal for --pick and returns the chosen ones, in their original order.
+// Enter with nothing checked picks the highlighted package.
+func PickPackages(task string, packages []Package) ([]Package, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("--pick needs an interactive terminal")
+	}
+	m := &pickerModel{task: task, packages: packages, picked: make(map[int]bool), rows: pickerMaxRows}
+	m.refilter()
+	if _, err := tea.NewProgram(m, tea.WithOutput(os.Stderr)).Run(); err != nil {
+		return nil, err
+	}
+	if !m.done {
+		return nil, ErrPickCancelled
+	}
+	var result []Package
+	for i, pkg := range packages {
+		if m.picked[i] {
+			result = append(result, pkg)
+		}
+	}
+	return result, nil
+}
+
+// pickerModel is the bubbletea model behind PickPackages.
+type pickerModel struct {
+	task     string
+	packages []Package
+	query    string
+	matches  []int // indexes into packages, best match first
+	cursor   int   // index into matches
+	offset   int   // first visible match
+	picked   map[int]bool
+	rows     int
+	done     bool
+}
+
+func (m *pickerModel) Init() tea.Cmd { return nil }
+
+func (m *pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the query, status, and help lines
+		m.rows = max(1, min(pickerMaxRows, msg.Height-4))
+		m.scroll()
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			return m, tea.Quit
+		case tea.KeyEnter:
+			if len(m.picked) == 0 && len(m.matches) > 0 {
+				m.picked[m.matches[m.cursor]] = true
+			}
+			m.done = len(m.picked) > 0
+			return m, tea.Quit
+		case tea.KeyUp, tea.KeyCtrlP:
+			m.move(-1)
+		case tea.KeyDown, tea.KeyCtrlN:
+			m.move(1)
+		case tea.KeySpace, tea.KeyTab:
+			if len(m.matches) > 0 {
+				i := m.matches[m.cursor]
+				if m.picked[i] {
+					delete(m.picked, i)
+				} else {
+					m.picked[i] = true
+				}
+				m.move(1)
+			}
+		case tea.KeyCtrlA:
+			for _, i := range m.matches {
+				m.picked[i] = true
+			}
+		case tea.KeyBackspace:
+			if m.query != "" {
+				r := []rune(m.query)
+				m.query = string(r[:len(r)-1])
+				m.refilter()
+			}
+		case tea.KeyCtrlU:
+			m.query = ""
+			m.refilter()
+		case tea.KeyRunes:
+			m.query += string(msg.Runes)
+			m.refilter()
+		}
+	}
+	return m, nil
+}
+
+func (m *pickerModel) View() string {
+	if m.done || len(m.packages) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s\n", styleHeader.Render("ux "+m.task), styleDim.Render(">"), m.query+"█")
+	end := min(m.offset+m.rows, len(m.matches))
+	for n := m.offset; n < end; n++ {
+		i := m.matches[n]
+		pointer, check := "  ", "[ ]"
+		if n == m.cursor {
+			pointer = styleLabel.Render("> ")
+		}
+		if m.picked[i] {
+			check = styleSuccess.Render("[x]")
+		}
+		line := pointer + check + " " + styleLabel.Render(m.packages[i].Label)
+		if name := m.packages[i].Name; name != "" && !strings.HasSuffix(m.packages[i].Label, "/"+name) {
+			line += " " + styleDim.Render(name)
+		}
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&b, "%s\n", styleDim.Render(fmt.Sprintf("%d/%d matching, %d picked", len(m.matches), len(m.packages), len(m.picked))))
+	b.WriteString(styleDim.Render("type to filter · space select · ctrl+a select all · enter run · esc cancel"))
+	return b.String()
+}
+
+// move moves the cursor by delta, wrapping around the matches.
+func (m *pickerModel) move(delta int) {
+	if len(m.matches) == 0 {
+		return
+	}
+	m.cursor = (m.cursor + delta + len(m.matches)) % len(m.matches)
+	m.scroll()
+}
+
+// scroll keeps the cursor within the visible rows.
+func (m *pickerModel) scroll() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.rows {
+		m.offset = m.cursor - m.rows + 1
+	}
+}
+
+// refilter recomputes the matches for the query and resets the cursor.
+func (m *pickerModel) refilter() {
+	m.matches = fuzzyFilter(m.query, m.packages)
+	m.cursor, m.offset = 0, 0
+}
+
+// fuzzyFilter returns the indexes of the packages whose label or name
+// contains the query's characters in order, best match first: the tightest
+// match, then the earliest, then by label.
+func fuzzyFilter(query string, packages []Package) []int {
+	type match struct{ index, span, start int }
+	var matches []match
+	for i, pkg := range packages {
+		span, start, ok := fuzzyMatch(query, pkg.Label)
+		if s, st, nameOK := fuzzyMatch(query, pkg.Name); nameOK && (!ok || s < span) {
+			span, start, ok = s, st+len(pkg.Label), true // prefer label matches on ties
+		}
+		if ok {
+			matches = append(matches, match{i, span, start})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].span != matches[b].span {
+			return matches[a].span < matches[b].span
+		}
+		return matches[a].start < matches[b].start
+	})
+	result := make([]int, len(matches))
+	for i, m := range matches {
+		result[i] = m.index
+	}
+	return result
+}
+
+// fuzzyMatch reports whether s contains query's characters in order,
+// ignoring case, and if so the length and start of the shortest stretch of
+// s holding them.
+func fuzzyMatch(query, s string) (span, start int, ok bool) {
+	q, t := []rune(strings.ToLower(query)), []rune(strings.ToLower(s))
+	if len(q) == 0 {
+		return 0, 0, true
+	}
+	span = -1
+	for from := range t {
+		if t[from] != q[0] {
+			continue
+		}
+		qi := 0
+		for ti := from; ti < len(t); ti++ {
+			if t[ti] == q[qi] {
+				qi++
+				if qi == len(q) {
+					if n := ti - from + 1; span < 0 || n < span {
+						span, start = n, from
+					}
+					break
+				}
+			}
+		}
+	}
+	return span, start, span >= 0
+}
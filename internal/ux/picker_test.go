@@ -0,0 +1,66 @@
+package ux
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFuzzyFilter(t *testing.T) {
+	packages := []Package{
+		{Label: "//packages/auth", Name: "auth"},
+		{Label: "//packages/ingest", Name: "ingest"},
+		{Label: "//services/api", Name: "api"},
+		{Label: "//services/ingest-worker", Name: "worker"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"//packages/auth", "//packages/ingest", "//services/api", "//services/ingest-worker"}},
+		{"ingest", []string{"//packages/ingest", "//services/ingest-worker"}},
+		{"svapi", []string{"//services/api"}},
+		{"API", []string{"//services/api"}},
+		{"wrk", []string{"//services/ingest-worker"}},
+		{"zzz", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, i := range fuzzyFilter(tt.query, packages) {
+			got = append(got, packages[i].Label)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("fuzzyFilter(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("fuzzyFilter(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPickerModel(t *testing.T) {
+	m := &pickerModel{
+		packages: []Package{{Label: "//a"}, {Label: "//b"}, {Label: "//c"}},
+		picked:   make(map[int]bool),
+		rows:     pickerMaxRows,
+	}
+	m.refilter()
+
+	keys := []tea.KeyMsg{
+		{Type: tea.KeySpace, Runes: []rune{' '}}, // check //a, move to //b
+		{Type: tea.KeyRunes, Runes: []rune("c")},
+		{Type: tea.KeySpace, Runes: []rune{' '}}, // check //c
+		{Type: tea.KeyEnter},
+	}
+	for _, k := range keys {
+		m.Update(k)
+	}
+	if !m.done || len(m.picked) != 2 || !m.picked[0] || !m.picked[2] {
+		t.Errorf("done=%v picked=%v, want //a and //c", m.done, m.picked)
+	}
+}
@@ -0,0 +1,133 @@
+package ux
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Stage is one step of a task pipeline: a task to run across a set of packages.
+// Stages run in order; packages within a stage follow the task's parallel setting.
+type Stage struct {
+	Task     string
+	Packages []Package
+}
+
+// pipelineNode is one task on one package.
+type pipelineNode struct {
+	task  string
+	label string
+}
+
+// PlanTask expands a task run into ordered stages using the root [tasks]
+// depends_on settings. A dependency "lint" runs lint on the same package
+// first; "^build" runs build on the package's deps (from [package] deps)
+// first. Build also depends on generate in packages with generated_from.
+// Dependencies a package doesn't define are skipped. all is the full
+// set of discovered packages, used to resolve deps outside the selection.
+//
+// Without any depends_on the plan is a single stage of the selected packages.
+func PlanTask(task string, selected, all []Package, cfg *RootConfig) ([]Stage, error) {
+	byLabel := make(map[string]Package, len(all))
+	for _, pkg := range all {
+		byLabel[pkg.Label] = pkg
+	}
+	for _, pkg := range selected {
+		byLabel[pkg.Label] = pkg
+	}
+
+	levels := make(map[pipelineNode]int)
+	visiting := make(map[pipelineNode]bool)
+
+	var visit func(n pipelineNode, path []string) (int, error)
+	visit = func(n pipelineNode, path []string) (int, error) {
+		if lvl, ok := levels[n]; ok {
+			return lvl, nil
+		}
+		step := n.label + ":" + n.task
+		if visiting[n] {
+			return 0, fmt.Errorf("task dependency cycle: %s", strings.Join(append(path, step), " → "))
+		}
+		visiting[n] = true
+		defer delete(visiting, n)
+
+		pkg := byLabel[n.label]
+		level := 0
+		for _, dep := range taskDeps(n.task, pkg, cfg) {
+			var children []pipelineNode
+			if depTask, ok := strings.CutPrefix(dep, "^"); ok {
+				for _, depLabel := range pkg.Deps {
+					depPkg, ok := byLabel[depLabel]
+					if !ok {
+						return 0, fmt.Errorf("%s: unknown dependency %s", n.label, depLabel)
+					}
+					if _, ok := depPkg.Tasks[depTask]; ok {
+						children = append(children, pipelineNode{depTask, depLabel})
+					}
+				}
+			} else if _, ok := pkg.Tasks[dep]; ok {
+				children = append(children, pipelineNode{dep, n.label})
+			}
+			for _, c := range children {
+				lvl, err := visit(c, append(path, step))
+				if err != nil {
+					return 0, err
+				}
+				if lvl+1 > level {
+					level = lvl + 1
+				}
+			}
+		}
+		levels[n] = level
+		return level, nil
+	}
+
+	for _, pkg := range selected {
+		if _, err := visit(pipelineNode{task, pkg.Label}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// Group nodes into stages by level, then by task name
+	type stageKey struct {
+		level int
+		task  string
+	}
+	grouped := make(map[stageKey][]Package)
+	for n, lvl := range levels {
+		k := stageKey{lvl, n.task}
+		grouped[k] = append(grouped[k], byLabel[n.label])
+	}
+	var keys []stageKey
+	for k := range grouped {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].level != keys[j].level {
+			return keys[i].level < keys[j].level
+		}
+		return keys[i].task < keys[j].task
+	})
+
+	var stages []Stage
+	for _, k := range keys {
+		pkgs := grouped[k]
+		sort.Slice(pkgs, func(i, j int) bool {
+			return pkgs[i].Label < pkgs[j].Label
+		})
+		stages = append(stages, Stage{Task: k.task, Packages: pkgs})
+	}
+	return stages, nil
+}
+
+// taskDeps returns a package's depends_on for task. A package with
+// generated_from also runs its generate task before build, so generated code
+// is never stale.
+func taskDeps(task string, pkg Package, cfg *RootConfig) []string {
+	deps := cfg.Tasks[task].DependsOn
+	if task != "build" || len(pkg.GeneratedFrom) == 0 || slices.Contains(deps, "generate") {
+		return deps
+	}
+	return append(slices.Clip(deps), "generate")
+}
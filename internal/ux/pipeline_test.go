@@ -0,0 +1,87 @@
+package ux
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanTask(t *testing.T) {
+	build := map[string]Task{"build": {Cmds: []string{"make"}}, "lint": {Cmds: []string{"lint"}}}
+	core := Package{Label: "//core", Tasks: build}
+	api := Package{Label: "//api", Deps: []string{"//core"}, Tasks: build}
+	web := Package{Label: "//web", Deps: []string{"//api", "//core"}, Tasks: build}
+	all := []Package{api, core, web}
+
+	cfg := &RootConfig{Tasks: map[string]TaskConfig{
+		"build": {DependsOn: []string{"^build", "lint"}},
+	}}
+
+	stages, err := PlanTask("build", []Package{web}, all, cfg)
+	if err != nil {
+		t.Fatalf("PlanTask: %v", err)
+	}
+
+	var got []string
+	for _, st := range stages {
+		var labels []string
+		for _, p := range st.Packages {
+			labels = append(labels, p.Label)
+		}
+		got = append(got, st.Task+" "+strings.Join(labels, ","))
+	}
+	want := []string{
+		"lint //api,//core,//web",
+		"build //core",
+		"build //api",
+		"build //web",
+	}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("stages = %q, want %q", got, want)
+	}
+}
+
+func TestPlanTaskWithoutDependencies(t *testing.T) {
+	pkgs := []Package{
+		{Label: "//a", Tasks: map[string]Task{"test": {}}},
+		{Label: "//b", Tasks: map[string]Task{"test": {}}},
+	}
+	stages, err := PlanTask("test", pkgs, pkgs, &RootConfig{})
+	if err != nil {
+		t.Fatalf("PlanTask: %v", err)
+	}
+	if len(stages) != 1 || len(stages[0].Packages) != 2 {
+		t.Errorf("stages = %+v, want a single stage with both packages", stages)
+	}
+}
+
+func TestPlanTaskCycle(t *testing.T) {
+	a := Package{Label: "//a", Deps: []string{"//b"}, Tasks: map[string]Task{"build": {}}}
+	b := Package{Label: "//b", Deps: []string{"//a"}, Tasks: map[string]Task{"build": {}}}
+	cfg := &RootConfig{Tasks: map[string]TaskConfig{"build": {DependsOn: []string{"^build"}}}}
+
+	_, err := PlanTask("build", []Package{a}, []Package{a, b}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("PlanTask error = %v, want dependency cycle", err)
+	}
+}
+
+func TestPlanTaskGeneratedFrom(t *testing.T) {
+	tasks := map[string]Task{"build": {}, "generate": {}}
+	client := Package{Label: "//client", GeneratedFrom: []string{"//proto"}, Tasks: tasks}
+	plain := Package{Label: "//plain", Tasks: tasks}
+
+	stages, err := PlanTask("build", []Package{client, plain}, []Package{client, plain}, &RootConfig{})
+	if err != nil {
+		t.Fatalf("PlanTask: %v", err)
+	}
+	var got []string
+	for _, st := range stages {
+		for _, p := range st.Packages {
+			got = append(got, st.Task+" "+p.Label)
+		}
+	}
+	want := "build //plain; generate //client; build //client"
+	if strings.Join(got, "; ") != want {
+		t.Errorf("stages = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package ux
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// groupWaitDelay is how long a cancelled command's output is still read
+// after it's killed, in case something it started holds it open.
+const groupWaitDelay = 2 * time.Second
+
+// killGroupOnCancel runs cmd in a process group of its own, so cancelling
+// its context kills everything it started rather than just the shell.
+// Where groups aren't supported, only the command itself is killed.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = groupProcAttr()
+	cmd.Cancel = func() error {
+		if err := signalGroup(cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = groupWaitDelay
+}
+
+// runInGroup runs cmd in a process group of its own (see killGroupOnCancel).
+// Out of the terminal's foreground group, it doesn't get the terminal's
+// Ctrl-C, so interrupts reach it through groupSignals instead.
+func runInGroup(cmd *exec.Cmd) error {
+	killGroupOnCancel(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr != nil {
+		defer groupSignals.track(cmd.Process.Pid)()
+	}
+	return cmd.Wait()
+}
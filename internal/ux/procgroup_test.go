@@ -0,0 +1,116 @@
+//go:build linux
+
+package ux
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKillGroupOnCancel(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", `sleep 30 & echo $! > "$0"; wait`, pidFile)
+	killGroupOnCancel(cmd)
+	start := time.Now()
+	cmd.Run()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("cancelled command took %v to return", d)
+	}
+
+	pid := readPID(t, pidFile)
+	// The background sleep was killed along with the shell (a zombie
+	// waiting to be reaped counts as gone)
+	deadline := time.Now().Add(2 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("the command's child outlived it")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSSHExecutorCancel(t *testing.T) {
+	fakeSSH(t)
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	var out bytes.Buffer
+	c := remoteCommand{Cmd: `sleep 30 & echo $! > pid; wait`, Shell: "sh"}
+	start := time.Now()
+	(sshExecutor{host: "w1", dir: dir}).run(ctx, c, &out, &out)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("cancelled run took %v to return", d)
+	}
+	pid := readPID(t, filepath.Join(dir, "pid"))
+	// Once the connection is gone, the command's children go too
+	deadline := time.Now().Add(2 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("the remote command outlived the connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readPID(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pid
+}
+
+// alive reports whether pid is running, not exited and waiting to be reaped.
+func alive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestExecuteBufferedRemovedKillsGroup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pkg")
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	pkg := Package{Label: "//pkg", Dir: dir, Tasks: map[string]Task{
+		"test": {Cmds: []string{`sleep 30 & echo $! > ` + pidFile + `; wait`}},
+	}}
+	time.AfterFunc(300*time.Millisecond, func() { os.RemoveAll(dir) })
+	start := time.Now()
+	r := executeBuffered("test", pkg, RunOptions{}, nil)
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("removed package took %v to stop", d)
+	}
+	if !r.Removed {
+		t.Errorf("result = %+v, want removed", r)
+	}
+
+	pid := readPID(t, pidFile)
+	deadline := time.Now().Add(2 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("the command's child outlived it")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,113 @@
+package ux
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ptyDrain is how long output is still read from a pty after its command
+// exits, in case something it started in the background holds it open.
+const ptyDrain = time.Second
+
+// ptyUnavailable warns, once per run, that commands run without a pty.
+var ptyUnavailable sync.Once
+
+// runInPTY runs cmd with its stdout and stderr on a new pseudo-terminal, so
+// tools that check for a terminal keep their colors and progress output,
+// and copies what it prints to cmd.Stdout. stdin stays /dev/null, so
+// nothing waits for input or switches to a watch mode. The command gets its
+// own session, so interrupts reach it through groupSignals. Where no pty can
+// be allocated, cmd runs as is.
+func runInPTY(cmd *exec.Cmd) error {
+	master, slave, err := openPTY()
+	if err != nil {
+		ptyUnavailable.Do(func() { Warnf("cannot allocate a pty, running without one: %v", err) })
+		return cmd.Run()
+	}
+	defer master.Close()
+	out := cmd.Stdout
+	cmd.Stdout, cmd.Stderr = slave, slave
+	cmd.SysProcAttr = ptyProcAttr()
+	cmd.Cancel = func() error { return signalGroup(cmd.Process.Pid, syscall.SIGKILL) }
+	err = cmd.Start()
+	slave.Close()
+	if err != nil {
+		return err
+	}
+	untrack := groupSignals.track(cmd.Process.Pid)
+	copied := make(chan struct{})
+	go func() {
+		// Reading fails (EIO) once every copy of the slave side is closed
+		io.Copy(out, master)
+		close(copied)
+	}()
+	err = cmd.Wait()
+	select {
+	case <-copied:
+	case <-time.After(ptyDrain):
+		master.Close()
+		<-copied
+	}
+	untrack()
+	return err
+}
+
+// groupSignals forwards interrupts to the commands running on a pty or in
+// a process group of their own (see runInGroup). Being out of the
+// terminal's foreground group, they don't get its Ctrl-C.
+var groupSignals = &signalForwarder{groups: make(map[int]bool)}
+
+// signalForwarder relays SIGINT and SIGTERM to the process groups it tracks
+// while there are any. Once the last of them exits after a signal, ux
+// re-raises it on itself, so an interrupted run still stops.
+type signalForwarder struct {
+	mu          sync.Mutex
+	groups      map[int]bool
+	sigs        chan os.Signal
+	interrupted os.Signal
+}
+
+// track starts forwarding signals to the process group led by pid, until
+// the returned function is called.
+func (f *signalForwarder) track(pid int) (untrack func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.groups) == 0 {
+		f.sigs = make(chan os.Signal, 1)
+		signal.Notify(f.sigs, os.Interrupt, syscall.SIGTERM)
+		go f.forward(f.sigs)
+	}
+	f.groups[pid] = true
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.groups, pid)
+		if len(f.groups) > 0 {
+			return
+		}
+		signal.Stop(f.sigs)
+		close(f.sigs)
+		if sig := f.interrupted; sig != nil {
+			f.interrupted = nil
+			if self, err := os.FindProcess(os.Getpid()); err == nil {
+				self.Signal(sig)
+			}
+		}
+	}
+}
+
+func (f *signalForwarder) forward(sigs chan os.Signal) {
+	for sig := range sigs {
+		f.mu.Lock()
+		for pid := range f.groups {
+			signalGroup(pid, sig)
+		}
+		f.interrupted = sig
+		f.mu.Unlock()
+	}
+}
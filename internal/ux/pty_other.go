@@ -0,0 +1,22 @@
+//go:build !linux
+
+package ux
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openPTY fails: ptys are only allocated on Linux.
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errors.New("ptys are only supported on Linux")
+}
+
+func ptyProcAttr() *syscall.SysProcAttr { return nil }
+
+func groupProcAttr() *syscall.SysProcAttr { return nil }
+
+func signalGroup(pid int, sig os.Signal) error {
+	return errors.New("process groups are only signaled on Linux")
+}
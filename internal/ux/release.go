@@ -0,0 +1,248 @@
+package ux
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ReleaseEnv names the variables set for the tasks `ux release` runs.
+const (
+	ReleaseVersionEnv = "UX_RELEASE_VERSION" // "1.4.0"
+	ReleaseTagEnv     = "UX_RELEASE_TAG"     // "api/v1.4.0"
+)
+
+// firstReleaseVersion is the version of a package with no release tags.
+var firstReleaseVersion = semver{0, 1, 0}
+
+// Release is a package to release: its last tag and the one it gets.
+type Release struct {
+	Package Package
+	Prev    string // last release tag, "" for a first release
+	Tag     string // "<name>/v<version>"
+	Version string
+	// Changed lists the files that changed since Prev and affect the
+	// package (empty for a first release).
+	Changed []string
+}
+
+// ReleaseTagPrefix is the part of a package's release tags before "/v": its
+// name (see checkPackageNames), or the last element of its label.
+func ReleaseTagPrefix(pkg Package) string {
+	if pkg.Name != "" {
+		return pkg.Name
+	}
+	if pkg.Label == RootLabel {
+		return "root"
+	}
+	return path.Base(strings.TrimPrefix(pkg.Label, "//"))
+}
+
+// PlanReleases finds the packages with changes since their last release
+// tag, including changes [affected] maps onto them, and the tag each gets
+// with its version bumped by bump ("major", "minor", or "patch"). Packages
+// without a tag are released at 0.1.0.
+func PlanReleases(root string, cfg AffectedConfig, packages []Package, bump string) ([]Release, error) {
+	byPrefix := make(map[string]string)
+	var releases []Release
+	for _, pkg := range packages {
+		prefix := ReleaseTagPrefix(pkg)
+		if other, ok := byPrefix[prefix]; ok {
+			return nil, fmt.Errorf("%s and %s would both be tagged %s/v*; give one a [package] name", other, pkg.Label, prefix)
+		}
+		byPrefix[prefix] = pkg.Label
+
+		prev, version, err := lastReleaseTag(root, prefix)
+		if err != nil {
+			return nil, err
+		}
+		r := Release{Package: pkg, Prev: prev}
+		if prev == "" {
+			version = firstReleaseVersion
+		} else {
+			files, err := releaseChanges(root, prev)
+			if err != nil {
+				return nil, err
+			}
+			affected, _ := ExplainAffected(root, cfg, []Package{pkg}, files)
+			if len(affected) == 0 {
+				continue
+			}
+			for _, reason := range affected[0].Reasons {
+				r.Changed = append(r.Changed, reason.File)
+			}
+			if version, err = version.bump(bump); err != nil {
+				return nil, err
+			}
+		}
+		r.Version = version.String()
+		r.Tag = prefix + "/v" + r.Version
+		releases = append(releases, r)
+	}
+	return releases, nil
+}
+
+// lastReleaseTag returns the highest "<prefix>/vX.Y.Z" tag and its version,
+// or "" if there is none.
+func lastReleaseTag(root, prefix string) (string, semver, error) {
+	out, err := gitCommand(root, "tag", "--list", prefix+"/v*").Output()
+	if err != nil {
+		return "", semver{}, fmt.Errorf("listing tags: %w", err)
+	}
+	var tag string
+	var best semver
+	for _, t := range strings.Fields(string(out)) {
+		v, ok := parseSemver(strings.TrimPrefix(t, prefix+"/v"))
+		if ok && (tag == "" || best.less(v)) {
+			tag, best = t, v
+		}
+	}
+	return tag, best, nil
+}
+
+// releaseChanges lists the workspace-relative files committed since tag.
+func releaseChanges(root, tag string) ([]string, error) {
+	out, err := gitCommand(root, "diff", "--name-only", "--relative", tag, "HEAD", "--").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %w", tag, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// ReleaseLevels groups releases so each package comes after the released
+// packages it depends on: level 0 has no such deps, and so on.
+func ReleaseLevels(releases []Release) ([][]Release, error) {
+	byLabel := make(map[string]int, len(releases))
+	for i, r := range releases {
+		byLabel[r.Package.Label] = i
+	}
+	levels := make(map[int]int)
+	visiting := make(map[int]bool)
+	var visit func(i int, path []string) (int, error)
+	visit = func(i int, path []string) (int, error) {
+		if lvl, ok := levels[i]; ok {
+			return lvl, nil
+		}
+		label := releases[i].Package.Label
+		if visiting[i] {
+			return 0, fmt.Errorf("package dependency cycle: %s", strings.Join(append(path, label), " → "))
+		}
+		visiting[i] = true
+		defer delete(visiting, i)
+		level := 0
+		for _, dep := range releases[i].Package.Deps {
+			j, ok := byLabel[dep]
+			if !ok {
+				continue // not being released
+			}
+			lvl, err := visit(j, append(path, label))
+			if err != nil {
+				return 0, err
+			}
+			level = max(level, lvl+1)
+		}
+		levels[i] = level
+		return level, nil
+	}
+
+	var grouped [][]Release
+	for i := range releases {
+		lvl, err := visit(i, nil)
+		if err != nil {
+			return nil, err
+		}
+		for len(grouped) <= lvl {
+			grouped = append(grouped, nil)
+		}
+	}
+	for i, r := range releases {
+		grouped[levels[i]] = append(grouped[levels[i]], r)
+	}
+	return grouped, nil
+}
+
+// WithReleaseEnv returns the release's package with ReleaseVersionEnv and
+// ReleaseTagEnv added to its environment.
+func (r Release) WithReleaseEnv() Package {
+	pkg := r.Package
+	env := make(map[string]string, len(pkg.Env)+2)
+	for k, v := range pkg.Env {
+		env[k] = v
+	}
+	env[ReleaseVersionEnv] = r.Version
+	env[ReleaseTagEnv] = r.Tag
+	pkg.Env = env
+	return pkg
+}
+
+// CreateReleaseTag tags HEAD with the release's tag.
+func CreateReleaseTag(root string, r Release) error {
+	if out, err := gitCommand(root, "tag", r.Tag).CombinedOutput(); err != nil {
+		return fmt.Errorf("git tag %s: %v: %s", r.Tag, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// PushReleaseTags pushes tags to origin.
+func PushReleaseTags(root string, tags []string) error {
+	args := append([]string{"push", "origin"}, tags...)
+	if out, err := gitCommand(root, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git push: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// WorkingTreeClean reports whether git sees no uncommitted changes,
+// including untracked files.
+func WorkingTreeClean(root string) (bool, error) {
+	out, err := gitCommand(root, "status", "--porcelain").Output()
+	if err != nil {
+		return false, fmt.Errorf("git status: %w", err)
+	}
+	return len(strings.TrimSpace(string(out))) == 0, nil
+}
+
+// semver is a major.minor.patch version.
+type semver [3]int
+
+func parseSemver(s string) (semver, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var v semver
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func (v semver) less(w semver) bool {
+	for i := range v {
+		if v[i] != w[i] {
+			return v[i] < w[i]
+		}
+	}
+	return false
+}
+
+func (v semver) bump(part string) (semver, error) {
+	switch part {
+	case "major":
+		return semver{v[0] + 1, 0, 0}, nil
+	case "minor":
+		return semver{v[0], v[1] + 1, 0}, nil
+	case "patch":
+		return semver{v[0], v[1], v[2] + 1}, nil
+	}
+	return v, fmt.Errorf("invalid version bump %q (use major, minor, or patch)", part)
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
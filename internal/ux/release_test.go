@@ -0,0 +1,68 @@
+package ux
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanReleases(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", root, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	api := Package{Name: "api", Label: "//services/api", Dir: filepath.Join(root, "services", "api"), Deps: []string{"//lib"}}
+	lib := Package{Label: "//lib", Dir: filepath.Join(root, "lib")}
+	writeFile(t, filepath.Join(api.Dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(lib.Dir, "lib.go"), "package lib\n")
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	releases, err := PlanReleases(root, AffectedConfig{}, []Package{api, lib}, "patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 || releases[0].Tag != "api/v0.1.0" || releases[1].Tag != "lib/v0.1.0" {
+		t.Fatalf("first releases = %+v", releases)
+	}
+	levels, err := ReleaseLevels(releases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 || levels[0][0].Package.Label != "//lib" || levels[1][0].Package.Label != "//services/api" {
+		t.Errorf("levels = %+v", levels)
+	}
+
+	git("tag", "api/v1.9.0")
+	git("tag", "api/v1.10.0")
+	git("tag", "api/vnext")
+	git("tag", "lib/v0.1.0")
+	writeFile(t, filepath.Join(api.Dir, "main.go"), "package main // changed\n")
+	git("commit", "-q", "-am", "change api")
+	releases, err = PlanReleases(root, AffectedConfig{}, []Package{api, lib}, "minor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 1 {
+		t.Fatalf("releases = %+v, want only api", releases)
+	}
+	r := releases[0]
+	if r.Prev != "api/v1.10.0" || r.Tag != "api/v1.11.0" || len(r.Changed) != 1 || r.Changed[0] != "services/api/main.go" {
+		t.Errorf("release = %+v", r)
+	}
+	if env := r.WithReleaseEnv().Env; env[ReleaseVersionEnv] != "1.11.0" || env[ReleaseTagEnv] != "api/v1.11.0" {
+		t.Errorf("env = %v", env)
+	}
+
+	if _, err := PlanReleases(root, AffectedConfig{}, []Package{api, {Name: "api", Label: "//other"}}, "patch"); err == nil {
+		t.Error("expected an error for packages sharing a tag prefix")
+	}
+}
@@ -0,0 +1,231 @@
+package ux
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AgentTokenEnv names the shared secret between `ux agent` and its clients.
+// When it's set where the agent runs, every request must carry the same
+// value, so an agent reachable from a network doesn't run anyone's commands.
+const AgentTokenEnv = "UX_AGENT_TOKEN"
+
+// exitCodeTrailer is the HTTP trailer in which an agent reports a
+// command's exit code, after its output.
+const exitCodeTrailer = "X-Ux-Exit-Code"
+
+// dirHeader names the workspace-relative package directory a session is for.
+const dirHeader = "X-Ux-Dir"
+
+// agentInfo is what GET /v1/info on an agent returns.
+type agentInfo struct {
+	// Shared is set when the agent runs commands in its own checkout of
+	// the workspace, so clients don't ship package directories.
+	Shared bool `json:"shared"`
+}
+
+// remoteCommand is a command to run on another machine, and the body of an
+// agent's POST /v1/sessions/{id}/run.
+type remoteCommand struct {
+	Cmd   string `json:"cmd"`
+	Shell string `json:"shell"`
+	// Dir is the package dir relative to the workspace root, slash-separated.
+	// An agent session already knows it.
+	Dir string   `json:"-"`
+	Cwd string   `json:"cwd,omitempty"` // slash-separated, relative to the package dir
+	Env []string `json:"env,omitempty"` // KEY=value, added to the machine's environment
+}
+
+// executor runs package commands somewhere other than this machine.
+type executor interface {
+	// run runs c, copying its output to stdout and stderr as it arrives,
+	// and returns its exit code.
+	run(ctx context.Context, c remoteCommand, stdout, stderr io.Writer) (int, error)
+}
+
+// Remote is a client of a `ux agent`, which runs packages' commands on
+// another machine and streams their output back. It is experimental.
+//
+// Each package a task runs on gets a session on the agent: either the
+// package's directory in the agent's own checkout, or a copy of the
+// package's files shipped with the request (everything except what
+// .gitignore or .uxignore exclude, and junk directories like node_modules).
+// A shipped copy is deleted when the package finishes, so outputs stay on
+// the agent.
+type Remote struct {
+	Addr   string // host:port of the agent
+	client *http.Client
+
+	once sync.Once
+	info agentInfo
+	err  error
+}
+
+// NewRemote returns a client of the agent at addr (host:port).
+func NewRemote(addr string) *Remote {
+	return &Remote{Addr: addr, client: &http.Client{}}
+}
+
+// remoteSession is a package directory on an agent.
+type remoteSession struct {
+	remote *Remote
+	id     string
+}
+
+// request sends an authenticated request to the agent.
+func (r *Remote) request(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+r.Addr+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if token := os.Getenv(AgentTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", r.Addr, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("agent %s: %s: %s", r.Addr, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// Check contacts the agent, so a run fails up front when it's unreachable
+// or rejects the token rather than once per package.
+func (r *Remote) Check() error {
+	r.once.Do(func() {
+		resp, err := r.request(context.Background(), http.MethodGet, "/v1/info", nil, nil)
+		if err != nil {
+			r.err = err
+			return
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&r.info); err != nil {
+			r.err = fmt.Errorf("agent %s: %w", r.Addr, err)
+		}
+	})
+	return r.err
+}
+
+// open starts a session for pkg, shipping its files unless the agent has a
+// shared checkout.
+func (r *Remote) open(ctx context.Context, pkg Package) (*remoteSession, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	header := http.Header{dirHeader: {strings.TrimPrefix(pkg.Label, "//")}}
+	var body io.Reader
+	if !r.info.Shared {
+		var buf bytes.Buffer
+		if err := packDir(&buf, pkg.Dir); err != nil {
+			return nil, fmt.Errorf("packing %s: %w", pkg.Label, err)
+		}
+		body = &buf
+		header.Set("Content-Type", "application/gzip")
+	}
+	resp, err := r.request(ctx, http.MethodPost, "/v1/sessions", body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("agent %s: %w", r.Addr, err)
+	}
+	return &remoteSession{remote: r, id: created.ID}, nil
+}
+
+// run runs one command in the session. The agent merges its stdout and
+// stderr, so all of its output goes to stdout.
+func (s *remoteSession) run(ctx context.Context, c remoteCommand, stdout, _ io.Writer) (int, error) {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return -1, err
+	}
+	resp, err := s.remote.request(ctx, http.MethodPost, "/v1/sessions/"+s.id+"/run", bytes.NewReader(body),
+		http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(stdout, resp.Body); err != nil {
+		return -1, fmt.Errorf("agent %s: %w", s.remote.Addr, err)
+	}
+	code, err := strconv.Atoi(resp.Trailer.Get(exitCodeTrailer))
+	if err != nil {
+		return -1, fmt.Errorf("agent %s: no exit code for %q", s.remote.Addr, c.Cmd)
+	}
+	return code, nil
+}
+
+// close ends the session, deleting a shipped copy of the package.
+func (s *remoteSession) close() {
+	resp, err := s.remote.request(context.Background(), http.MethodDelete, "/v1/sessions/"+s.id, nil, nil)
+	if err != nil {
+		logger.Debug("closing remote session", "id", s.id, "err", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// packDir writes a gzipped tar of the package files under dir to w,
+// leaving out what cache keys leave out: ignored files and junk directories.
+func packDir(w io.Writer, dir string) error {
+	files, err := walkPackageFiles(dir, ".", true, newIgnoreMatcher(dir), func(string) bool { return true })
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		if err := addToTar(tw, filepath.Join(dir, filepath.FromSlash(rel)), rel); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addToTar adds the file at path to tw as name.
+func addToTar(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
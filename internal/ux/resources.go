@@ -0,0 +1,56 @@
+package ux
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// builtinResourceClasses are the weights of the resource classes a package
+// can name with [package] resources, unless the root [resources] table
+// redefines them.
+var builtinResourceClasses = map[string]int{
+	"light":  1,
+	"medium": 2,
+	"heavy":  4,
+}
+
+// weight returns how much of the --jobs budget the package takes while it
+// runs in a parallel task.
+func (pkg Package) weight() int {
+	return max(pkg.Weight, 1)
+}
+
+// resolveWeights sets the weight of each package that names a resource
+// class, from the root [resources] table or the built-in classes.
+func resolveWeights(classes map[string]int, packages []Package) error {
+	for i, pkg := range packages {
+		if pkg.resources == "" {
+			continue
+		}
+		w, ok := classes[pkg.resources]
+		if !ok {
+			w, ok = builtinResourceClasses[pkg.resources]
+		}
+		if !ok {
+			return fmt.Errorf("%s: unknown resource class %q (known: %s)", pkg.Label, pkg.resources, resourceClassNames(classes))
+		}
+		packages[i].Weight = w
+	}
+	return nil
+}
+
+// resourceClassNames lists the known resource classes, for errors.
+func resourceClassNames(classes map[string]int) string {
+	var names []string
+	for name := range builtinResourceClasses {
+		if _, ok := classes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name := range classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
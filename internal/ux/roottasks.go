@@ -0,0 +1,54 @@
+package ux
+
+import "path/filepath"
+
+// RootLabel is the label of the package at the workspace root, where
+// [root-tasks] run.
+const RootLabel = "//."
+
+// rootTaskSource is the TaskSources value of tasks from [root-tasks].
+const rootTaskSource = "root-tasks"
+
+// withRootTasks adds the root config's [root-tasks] to the package at the
+// workspace root, creating one if the root isn't a member, so repo-level
+// commands run like any package's task.
+func withRootTasks(root string, cfg *RootConfig, packages []Package) []Package {
+	tasks := parseTasks(cfg.RootTasks)
+	for name, t := range tasks {
+		if !t.applies(root) {
+			delete(tasks, name)
+		}
+	}
+	if len(tasks) == 0 {
+		return packages
+	}
+	for i := range packages {
+		if packages[i].Label != RootLabel {
+			continue
+		}
+		// The root's ux.toml is the root config, so its tasks come from
+		// type defaults, which root tasks replace
+		pkg := &packages[i]
+		for name, t := range tasks {
+			if pkg.Tasks == nil {
+				pkg.Tasks = make(map[string]Task)
+				pkg.TaskSources = make(map[string]string)
+			}
+			pkg.Tasks[name] = t
+			pkg.TaskSources[name] = rootTaskSource
+		}
+		return packages
+	}
+
+	sources := make(map[string]string, len(tasks))
+	for name := range tasks {
+		sources[name] = rootTaskSource
+	}
+	return append(packages, Package{
+		Name:        filepath.Base(root),
+		Dir:         root,
+		Label:       RootLabel,
+		Tasks:       tasks,
+		TaskSources: sources,
+	})
+}
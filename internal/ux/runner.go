@@ -2,110 +2,710 @@ package ux
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Result captures the outcome of running a task on a single package.
 type Result struct {
+	Task       string
 	Package    Package
 	Success    bool
 	Duration   time.Duration
 	FailedStep string
 	Output     string
+	Flaky      bool   // failed once, then passed on a flake-gate retry
+	Cached     bool   // replayed from the task cache instead of running
+	Removed    bool   // the package disappeared from the workspace mid-run
+	Allowed    bool   // failed, but the task sets allow_failure or the package is quarantined
+	Artifacts  string // directory of on_failure_collect files, if any were copied
+	Host       string // the [executors] host or --remote agent it ran on; "" for here
+	Start      time.Time
+	Steps      []StepResult // the commands that ran, in order
+	// OutputFile holds the output instead of Output when there was more
+	// than RunOptions.OutputMemory of it. It's in the task's log directory,
+	// or the system temp dir for runs without one (the caller removes it).
+	OutputFile string
+	// Quarantined is set on an allowed failure of a task the package marks
+	// flaky ([package] flaky_tasks), in a --quarantine run.
+	Quarantined bool
+	// SharedWith is the label of the package whose run of a run_once task
+	// this result is, when it didn't run in this package itself.
+	SharedWith string
+	// Interrupted is set when RunOptions.Context stopped the package before
+	// it finished. It counts as a failure, but history doesn't record it.
+	Interrupted bool
+}
+
+// StepResult is the outcome of one command of a task.
+type StepResult struct {
+	Cmd      string
+	Start    time.Time
+	Duration time.Duration
+	// ExitCode is the command's exit status, or -1 if it couldn't be started
+	// or was killed by a signal.
+	ExitCode int
+	// Output is the command's merged stdout and stderr, unless the
+	// package's output spilled to its Result.OutputFile.
+	Output string
+	// Dir and Shell are where and how the command ran, for failure logs.
+	// Dir is the local path even when the command ran elsewhere.
+	Dir   string
+	Shell string
+}
+
+// Success reports whether the step's command exited zero.
+func (s StepResult) Success() bool {
+	return s.ExitCode == 0
+}
+
+// exitCode returns the exit status for a command's error (see StepResult.ExitCode).
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Failed reports whether the result counts as a failure. Packages removed
+// mid-run neither pass nor fail, and allowed failures are only reported.
+func (r Result) Failed() bool {
+	return !r.Success && !r.Removed && !r.Allowed
+}
+
+// removedPollInterval is how often a running package is checked for removal.
+const removedPollInterval = 500 * time.Millisecond
+
+// minFlakeSamples is the number of recorded runs needed before a package's
+// failure rate is trusted by the flake gate.
+const minFlakeSamples = 5
+
+// RunOptions holds optional runner behavior.
+type RunOptions struct {
+	// ExtraArgs replace {args} in the task's commands, or are appended to
+	// a task's only command when none has {args} (see Task.TakesArgs).
+	ExtraArgs []string
+	// ArgsTypes limits ExtraArgs to packages of these types (--args-for);
+	// nil means every package gets them.
+	ArgsTypes []string
+	// FlakeGate is the highest historical failure rate at which a failing
+	// package is retried once and reported as flaky. Zero disables the gate.
+	FlakeGate float64
+	// History supplies failure rates for the flake gate.
+	History *History
+	// OutputMemory is how many bytes of each package's output are kept in
+	// memory before the rest spills to a file; 0 means DefaultOutputMemory.
+	OutputMemory int
+	// Quarantine reports failures of tasks a package marks flaky
+	// ([package] flaky_tasks) without failing the run.
+	Quarantine bool
+	// StreamDir, if set, is where each running package's live output is
+	// served on a unix socket named after its label.
+	StreamDir string
+	// CacheDir is the task cache location. Empty disables caching.
+	CacheDir string
+	// Workspace is every package in the workspace. A package's cache key
+	// covers the inputs of those it depends on (Package.Deps), which are
+	// looked up here.
+	Workspace []Package
+	// MaxFailures stops a task from starting more packages once this many
+	// have failed; 0 means no limit. A parallel task starts every package it
+	// can at once, so it only matters there when Jobs or mutexes hold some back.
+	MaxFailures int
+	// Jobs is the weight budget of a parallel task: packages run at the same
+	// time only while their weights (see Package.Weight) add up to at most
+	// Jobs. A package heavier than the budget runs alone. 0 means no limit.
+	Jobs int
+	// LogDir is the run's log directory. When set, each package's output is
+	// written to <LogDir>/<task>/<label>.log as it's produced. Failure
+	// artifacts are collected there too (default $TMPDIR/ux, for library
+	// use; the CLI always passes the workspace's run directory).
+	LogDir string
+	// Quiet suppresses all progress output (--quiet, and for library use).
+	Quiet bool
+	// UI renders progress as a full-screen package list, for use between
+	// StartUI and its stop function.
+	UI bool
+	// PTY runs every command on a pseudo-terminal (--pty), as tasks with
+	// tty = true always are, so tools keep their colors and progress output.
+	PTY bool
+	// Remote, if set, runs commands on a `ux agent` instead of locally
+	// (--remote). Commands there never run on a pseudo-terminal.
+	Remote *Remote
+	// Executors, if set, runs each package of a parallel task on the host
+	// it's assigned to ([executors]). Those packages aren't cached.
+	Executors *Executors
+	// Context, if set, interrupts the run once it's done (Ctrl-C): running
+	// commands are killed and no more packages start. Those that never
+	// started are left out of the results.
+	Context context.Context
+
+	// runOnceShared maps the label of each package running a run_once task
+	// for others to those packages (see dedupeRunOnce).
+	runOnceShared map[string][]Package
 }
 
 // RunTask executes a task across all packages, respecting parallel/serial config.
-// extraArgs are appended to each command (only valid for single-command tasks).
-func RunTask(task string, packages []Package, cfg TaskConfig, extraArgs []string) []Result {
+// Results are in package order; packages skipped because of MaxFailures have none.
+// Parallel tasks honor opts.Jobs and task mutexes (see runParallel). A
+// run_once task runs once for all the packages it would run the same way,
+// and each of them gets the result (see Result.SharedWith).
+func RunTask(task string, packages []Package, cfg TaskConfig, opts RunOptions) []Result {
+	out := newOutput(task, packages, cfg.Parallel, opts)
+	all := packages
+	packages, shared := dedupeRunOnce(task, packages, opts)
+	opts.runOnceShared = shared
 	results := make([]Result, len(packages))
-	out := newOutput(task, len(packages), cfg.Parallel)
+	logger.Debug("running task", "task", task, "packages", len(packages), "parallel", cfg.Parallel,
+		"jobs", opts.Jobs, "max_failures", opts.MaxFailures, "shared", len(all)-len(packages))
 
 	if cfg.Parallel {
-		var wg sync.WaitGroup
-		for i, pkg := range packages {
-			wg.Add(1)
-			out.markStarted(pkg.Label)
-			go func(i int, pkg Package) {
-				defer wg.Done()
-				results[i] = executeBuffered(task, pkg, extraArgs)
-				out.markCompleted(results[i])
-			}(i, pkg)
-		}
-		wg.Wait()
+		results = runParallel(task, packages, cfg, opts, out)
 	} else {
+		failures := 0
 		for i, pkg := range packages {
+			if interrupted(opts) {
+				logger.Debug("interrupted", "task", task, "not_started", len(packages)-i)
+				results = results[:i]
+				out.markSkipped(len(packages) - i)
+				break
+			}
+			if opts.MaxFailures > 0 && failures >= opts.MaxFailures {
+				logger.Debug("max failures reached", "task", task, "not_started", len(packages)-i)
+				// Packages that never started are left out of the results
+				results = results[:i]
+				out.markSkipped(len(packages) - i)
+				break
+			}
 			out.markStarted(pkg.Label)
-			results[i] = executeBuffered(task, pkg, extraArgs)
+			logger.Debug("start", "task", task, "package", pkg.Label)
+			results[i] = executePackage(task, pkg, cfg, opts)
 			out.markCompleted(results[i])
+			logFinished(task, results[i])
+			if results[i].Failed() {
+				failures++
+			}
 		}
 	}
 
+	results = shareRunOnce(task, all, results, shared, cfg, opts, out)
 	out.clearProgress()
+	for i := range results {
+		results[i].Task = task
+	}
 	return results
 }
 
-// executeBuffered runs a task and captures all output into a buffer.
-func executeBuffered(task string, pkg Package, extraArgs []string) Result {
-	cmds := pkg.Tasks[task]
-	start := time.Now()
+// runParallel runs a parallel task's packages concurrently, starting each
+// as soon as it may: while the packages running take at most opts.Jobs in
+// weight (when set), no running package holds the same task mutex, and no
+// exclusive package is running. An exclusive package starts only once
+// nothing else is running.
+// Packages are tried slowest first by their recorded durations (see
+// longestFirst). Whenever a package finishes, every waiting package that can
+// now start is started, in that order, so light packages keep running while
+// a heavy one waits for room. Once opts.MaxFailures packages have failed, no more are started;
+// those are left out of the results, which are otherwise in package order.
+func runParallel(task string, packages []Package, cfg TaskConfig, opts RunOptions, out *output) []Result {
+	results := make([]Result, len(packages))
+	ran := make([]bool, len(packages))
+	waited := make([]bool, len(packages))
+	weight := func(i int) int {
+		if opts.Jobs == 0 {
+			return 0
+		}
+		return min(packages[i].weight(), opts.Jobs)
+	}
+	mutex := func(i int) string { return packages[i].Tasks[task].Mutex }
+	held := make(map[string]bool)
+	exclusive := false // an exclusive package is running
 
-	var allOutput strings.Builder
-	extra := ""
-	if len(extraArgs) > 0 {
-		extra = " " + strings.Join(extraArgs, " ")
+	type done struct {
+		i int
+		r Result
 	}
+	finished := make(chan done)
+	pending := longestFirst(task, packages, opts.History)
+	used, running, failures := 0, 0, 0
+	for len(pending) > 0 || running > 0 {
+		if interrupted(opts) && len(pending) > 0 {
+			logger.Debug("interrupted", "task", task, "not_started", len(pending))
+			out.markSkipped(len(pending))
+			pending = nil
+		}
+		if opts.MaxFailures > 0 && failures >= opts.MaxFailures && len(pending) > 0 {
+			logger.Debug("max failures reached", "task", task, "not_started", len(pending))
+			out.markSkipped(len(pending))
+			pending = nil
+		}
+		waiting := pending[:0]
+		for _, i := range pending {
+			if (opts.Jobs > 0 && used+weight(i) > opts.Jobs) || held[mutex(i)] ||
+				exclusive || (packages[i].Exclusive && running > 0) {
+				if !waited[i] {
+					waited[i] = true
+					logger.Debug("waiting", "task", task, "package", packages[i].Label,
+						"weight", weight(i), "jobs_used", used, "mutex", mutex(i), "exclusive", packages[i].Exclusive)
+				}
+				waiting = append(waiting, i)
+				continue
+			}
+			if m := mutex(i); m != "" {
+				held[m] = true
+			}
+			used += weight(i)
+			running++
+			exclusive = packages[i].Exclusive
+			ran[i] = true
+			out.markStarted(packages[i].Label)
+			logger.Debug("start", "task", task, "package", packages[i].Label, "weight", weight(i), "running", running)
+			go func(i int) {
+				finished <- done{i, executePackage(task, packages[i], cfg, opts)}
+			}(i)
+		}
+		pending = waiting
+		if running == 0 {
+			break
+		}
+
+		d := <-finished
+		results[d.i] = d.r
+		out.markCompleted(d.r)
+		logFinished(task, d.r)
+		used -= weight(d.i)
+		delete(held, mutex(d.i))
+		running--
+		if packages[d.i].Exclusive {
+			exclusive = false
+		}
+		if d.r.Failed() {
+			failures++
+		}
+	}
+
+	kept := results[:0]
+	for i, r := range results {
+		if ran[i] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// interrupted reports whether opts.Context is done.
+func interrupted(opts RunOptions) bool {
+	return opts.Context != nil && opts.Context.Err() != nil
+}
+
+// longestFirst returns the indexes of packages slowest first by their
+// expected durations from history, so that when a parallel task can't start
+// everything at once, a long package doesn't start late and hold up the end
+// of the run. Packages with no recorded duration might be the slowest, so
+// they go first. Ties keep package order.
+func longestFirst(task string, packages []Package, h *History) []int {
+	order := make([]int, len(packages))
+	expected := make([]time.Duration, len(packages))
+	for i, pkg := range packages {
+		order[i] = i
+		if d, ok := h.ExpectedDuration(task, pkg.Label); ok {
+			expected[i] = d
+		} else {
+			expected[i] = math.MaxInt64
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return expected[order[a]] > expected[order[b]] })
+	return order
+}
 
-	for _, cmdStr := range cmds {
-		var stdout, stderr bytes.Buffer
+// logFinished logs a package's outcome.
+func logFinished(task string, r Result) {
+	logger.Debug("finish", "task", task, "package", r.Package.Label, "status", resultStatus(r),
+		"duration", r.Duration, "cached", r.Cached)
+}
+
+// executePackage runs a task on one package and, if it fails, collects the
+// task's on_failure_collect artifacts.
+func executePackage(task string, pkg Package, cfg TaskConfig, opts RunOptions) Result {
+	if cfg.TTY {
+		opts.PTY = true
+	}
+	r := executeCached(task, pkg, cfg, opts)
+	r.Host = opts.Executors.hostFor(pkg.Label)
+	if opts.Remote != nil {
+		r.Host = opts.Remote.Addr
+	}
+	// Artifacts made on another machine aren't here to collect
+	if r.Failed() && len(cfg.OnFailureCollect) > 0 && r.Host == "" {
+		dir, err := collectArtifacts(opts.LogDir, task, pkg, cfg.OnFailureCollect)
+		if err != nil {
+			Warnf("cannot collect artifacts for %s: %v", pkg.Label, err)
+		}
+		r.Artifacts = dir
+	}
+	r.Quarantined = r.Failed() && opts.Quarantine && slices.Contains(pkg.FlakyTasks, task)
+	r.Allowed = r.Failed() && cfg.AllowFailure || r.Quarantined
+	return r
+}
 
-		cmd := exec.Command("sh", "-c", cmdStr+extra)
-		cmd.Dir = pkg.Dir
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+// executeCached runs a task on one package, replaying it from the cache when
+// its inputs are unchanged and storing it after a successful run.
+func executeCached(task string, pkg Package, cfg TaskConfig, opts RunOptions) Result {
+	// Outputs made on another host aren't here to store
+	if opts.CacheDir == "" || !cfg.cacheEnabled() || opts.Executors.onRemoteHost(pkg.Label) {
+		return executeLogged(task, pkg, opts)
+	}
+	shared := opts.runOnceShared[pkg.Label]
+	also := append(slices.Clone(shared), dependencyPackages(append([]Package{pkg}, shared...), opts.Workspace)...)
+	key, err := cacheKey(task, pkg, cfg, ArgsFor(pkg, opts.ExtraArgs, opts.ArgsTypes), also)
+	if err != nil {
+		Warnf("cannot cache %s: %v", pkg.Label, err)
+		return executeLogged(task, pkg, opts)
+	}
+	start := time.Now()
+	logger.Debug("cache key", "task", task, "package", pkg.Label, "key", key)
+	if log, ok := restoreCached(opts.CacheDir, task, pkg, key); ok {
+		r := Result{
+			Package:  pkg,
+			Success:  true,
+			Cached:   true,
+			Start:    start,
+			Duration: time.Since(start),
+		}
+		output := newOutputCapture(task, pkg, opts)
+		io.Copy(output, log)
+		log.Close()
+		r.Output, r.OutputFile = output.finish()
+		return r
+	}
+	r := executeLogged(task, pkg, opts)
+	if r.Success && !r.Flaky {
+		if err := storeCached(opts.CacheDir, task, cfg, r, key); err != nil {
+			Warnf("cannot cache %s: %v", pkg.Label, err)
+		}
+	}
+	return r
+}
 
-		err := cmd.Run()
+// executeLogged runs a task with its output streamed, as it's produced, to
+// the package's log and stream socket (when enabled).
+func executeLogged(task string, pkg Package, opts RunOptions) (r Result) {
+	var sinks []io.Writer
+	if opts.StreamDir != "" {
+		srv, err := newStreamServer(opts.StreamDir, pkg.Label)
+		if err != nil {
+			Warnf("cannot stream output for %s: %v", pkg.Label, err)
+		} else {
+			defer srv.Close()
+			sinks = append(sinks, srv)
+		}
+	}
+	if opts.LogDir != "" {
+		f, err := createPackageLog(opts.LogDir, task, pkg)
+		if err != nil {
+			Warnf("cannot write log for %s: %v", pkg.Label, err)
+		} else {
+			defer func() {
+				finishPackageLog(f, r)
+				f.Close()
+			}()
+			// Colors stay on the terminal; the log is for reading anywhere
+			sinks = append(sinks, &ansiStripper{w: f})
+		}
+	}
+	var live io.Writer
+	if len(sinks) > 0 {
+		live = io.MultiWriter(sinks...)
+	}
+	return executeWithGate(task, pkg, opts, live)
+}
 
-		if stdout.Len() > 0 {
-			allOutput.WriteString(stdout.String())
+// executeWithGate runs a task and, if it fails on a package whose historical
+// failure rate is within the flake gate, retries it once. A passing retry is
+// reported as flaky; a failing retry is a real failure.
+func executeWithGate(task string, pkg Package, opts RunOptions, live io.Writer) Result {
+	r := executeBuffered(task, pkg, opts, live)
+	if !r.Failed() || r.Interrupted || !withinFlakeGate(task, pkg.Label, opts) {
+		return r
+	}
+	logger.Debug("retrying within the flake gate", "task", task, "package", pkg.Label)
+	retry := executeBuffered(task, pkg, opts, live)
+	retry.Duration += r.Duration
+	retry.Start = r.Start
+	retry.Steps = append(r.Steps, retry.Steps...)
+	if retry.Success {
+		retry.Flaky = true
+		retry.FailedStep = r.FailedStep
+		if retry.OutputFile != "" {
+			os.Remove(retry.OutputFile)
 		}
-		if stderr.Len() > 0 {
-			allOutput.WriteString(stderr.String())
+		retry.Output, retry.OutputFile = r.Output, r.OutputFile
+	} else if r.OutputFile != "" {
+		os.Remove(r.OutputFile)
+	}
+	return retry
+}
+
+// withinFlakeGate reports whether a failure of task on label is consistent
+// with the package's recorded flake rate.
+func withinFlakeGate(task, label string, opts RunOptions) bool {
+	if opts.FlakeGate <= 0 || opts.History == nil {
+		return false
+	}
+	rate, samples := opts.History.FailureRate(task, label)
+	return samples >= minFlakeSamples && rate > 0 && rate <= opts.FlakeGate
+}
+
+// executeBuffered runs a task and captures all output into a buffer, one
+// cleaned-up line at a time (see lineWriter). If live is non-nil, each line is
+// also copied to it as it is produced.
+// If the package is removed while it runs, its command is cancelled and the
+// result is marked Removed rather than failed. With opts.PTY set, commands
+// run on a pseudo-terminal (see runInPTY). With opts.Remote set, they run on
+// the agent instead, in a session opened for the package, and with
+// opts.Executors, on the host the package is assigned to.
+func executeBuffered(task string, pkg Package, opts RunOptions, live io.Writer) Result {
+	t := pkg.Tasks[task]
+	start := time.Now()
+
+	proof := pkg.presenceFile()
+	if proof == "" {
+		return Result{Package: pkg, Removed: true, Start: start}
+	}
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var removed atomic.Bool
+	go func() {
+		ticker := time.NewTicker(removedPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Only the file that showed the package was there is
+				// checked, until it's gone
+				if _, err := os.Stat(proof); err == nil {
+					continue
+				}
+				if proof = pkg.presenceFile(); proof == "" {
+					removed.Store(true)
+					cancel()
+					return
+				}
+			}
 		}
+	}()
+
+	shell := t.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+	dir := t.dir(pkg.Dir)
 
+	// stdout and stderr are merged line by line, in the order they're written
+	allOutput := newOutputCapture(task, pkg, opts)
+	merger := &lineMerger{out: allOutput}
+	if live != nil {
+		merger.out = io.MultiWriter(allOutput, live)
+	}
+	done := func(r Result) Result {
+		r.Output, r.OutputFile = allOutput.finish()
+		return r
+	}
+
+	var ex executor
+	if host := opts.Executors.hostFor(pkg.Label); host != "" {
+		ex = sshExecutor{host: host, dir: opts.Executors.dir}
+	}
+	if opts.Remote != nil {
+		sess, err := opts.Remote.open(ctx, pkg)
 		if err != nil {
 			return Result{
 				Package:    pkg,
-				Success:    false,
+				Start:      start,
 				Duration:   time.Since(start),
-				FailedStep: cmdStr + extra,
-				Output:     allOutput.String(),
+				FailedStep: t.Cmds[0],
+				Output:     fmt.Sprintf("ux: %v\n", err),
 			}
 		}
+		defer sess.close()
+		ex = sess
 	}
 
-	return Result{
+	var steps []StepResult
+	for _, group := range t.steps() {
+		groupSteps := runStep(ctx, shell, dir, pkg, t.withArgs(group, ArgsFor(pkg, opts.ExtraArgs, opts.ArgsTypes)), opts.PTY, ex, merger, allOutput)
+		steps = append(steps, groupSteps...)
+
+		failed := ""
+		for _, st := range groupSteps {
+			if !st.Success() {
+				failed = st.Cmd
+				break
+			}
+		}
+		if failed != "" && (removed.Load() || !pkg.present()) {
+			return done(Result{
+				Package:  pkg,
+				Removed:  true,
+				Start:    start,
+				Duration: time.Since(start),
+				Steps:    steps,
+			})
+		}
+		if failed != "" {
+			return done(Result{
+				Package:     pkg,
+				Success:     false,
+				Duration:    time.Since(start),
+				FailedStep:  failed,
+				Start:       start,
+				Steps:       steps,
+				Interrupted: parent.Err() != nil,
+			})
+		}
+	}
+
+	return done(Result{
 		Package:  pkg,
 		Success:  true,
+		Start:    start,
+		Duration: time.Since(start),
+		Steps:    steps,
+	})
+}
+
+// runStep runs one step's commands, concurrently if there are several, and
+// returns a StepResult for each in order. Lines from all of them are merged
+// into merger as they are written; each result's Output holds only its own,
+// unless the package's output has spilled to a file.
+func runStep(ctx context.Context, shell, dir string, pkg Package, cmds []string, tty bool, ex executor, merger *lineMerger, all *outputCapture) []StepResult {
+	results := make([]StepResult, len(cmds))
+	if len(cmds) == 1 {
+		stdout, stderr := merger.newWriter(), merger.newWriter()
+		outputStart := all.Len()
+		results[0] = runCommand(ctx, shell, dir, pkg, cmds[0], tty, ex, stdout, stderr)
+		results[0].Output = all.since(outputStart)
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, cmdStr := range cmds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			own := &limitedBuffer{limit: all.limit}
+			m := &lineMerger{out: io.MultiWriter(own, mergedLines{merger})}
+			results[i] = runCommand(ctx, shell, dir, pkg, cmdStr, tty, ex, m.newWriter(), m.newWriter())
+			if !own.over {
+				results[i].Output = own.String()
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runCommand runs one command with its output going to stdout and stderr,
+// which it flushes once the command exits. With tty set, both go to a
+// pseudo-terminal whose output is written to stdout. With ex set, the
+// command runs wherever ex runs commands instead, never on a terminal.
+func runCommand(ctx context.Context, shell, dir string, pkg Package, cmdStr string, tty bool, ex executor, stdout, stderr *lineWriter) StepResult {
+	start := time.Now()
+	if ex != nil {
+		rel, _ := filepath.Rel(pkg.Dir, dir)
+		c := remoteCommand{Cmd: cmdStr, Shell: shell, Dir: strings.TrimPrefix(pkg.Label, "//"), Cwd: filepath.ToSlash(rel), Env: pkg.extraEnv()}
+		code, err := ex.run(ctx, c, stdout, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "ux: %v\n", err)
+		}
+		stdout.Flush()
+		stderr.Flush()
+		return StepResult{Cmd: cmdStr, Start: start, Duration: time.Since(start), ExitCode: code, Dir: dir, Shell: shell}
+	}
+	cmd := exec.CommandContext(ctx, shell, "-c", cmdStr)
+	cmd.Dir = dir
+	cmd.Env = pkg.environ()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var err error
+	if tty {
+		err = runInPTY(cmd)
+	} else {
+		err = runInGroup(cmd)
+	}
+	stdout.Flush()
+	stderr.Flush()
+	return StepResult{
+		Cmd:      cmdStr,
+		Start:    start,
 		Duration: time.Since(start),
-		Output:   allOutput.String(),
+		ExitCode: exitCode(err),
+		Dir:      dir,
+		Shell:    shell,
+	}
+}
+
+// mergedLines writes whole lines into a lineMerger, so the output of
+// commands running concurrently is interleaved a line at a time.
+type mergedLines struct{ m *lineMerger }
+
+func (w mergedLines) Write(line []byte) (int, error) {
+	w.m.writeLine(line)
+	return len(line), nil
+}
+
+// environ returns the environment for the package's commands: the current
+// environment plus the package's [env] table.
+func (pkg Package) environ() []string {
+	return append(os.Environ(), pkg.extraEnv()...)
+}
+
+// extraEnv returns the package's [env] table as KEY=value, sorted.
+func (pkg Package) extraEnv() []string {
+	keys := make([]string, 0, len(pkg.Env))
+	for k := range pkg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+pkg.Env[k])
 	}
+	return env
 }
 
-// gitDiffFiles returns the list of files changed vs origin/main.
-func gitDiffFiles(root string) (string, error) {
-	cmd := exec.Command("git", "diff", "--name-only", "origin/main...HEAD")
-	cmd.Dir = root
+// gitDiffFiles returns the list of files changed vs base.
+func gitDiffFiles(root, base string) (string, error) {
+	cmd := gitCommand(root, "diff", "--name-only", base+"...HEAD")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &bytes.Buffer{} // suppress stderr
 	err := cmd.Run()
 	if err != nil {
 		// Fallback: try without merge-base syntax
-		cmd2 := exec.Command("git", "diff", "--name-only", "origin/main")
-		cmd2.Dir = root
+		cmd2 := gitCommand(root, "diff", "--name-only", base)
 		out.Reset()
 		cmd2.Stdout = &out
 		err = cmd2.Run()
@@ -0,0 +1,471 @@
+package ux
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteBufferedSteps(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ux.toml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg := Package{Label: "//pkg", Dir: dir, Tasks: map[string]Task{
+		"test": {Cmds: []string{"echo one", "echo two; exit 3", "echo never"}},
+	}}
+
+	r := executeBuffered("test", pkg, RunOptions{}, nil)
+	if r.Success || r.FailedStep != "echo two; exit 3" {
+		t.Fatalf("got success=%v failed step %q", r.Success, r.FailedStep)
+	}
+	if len(r.Steps) != 2 {
+		t.Fatalf("got %d steps, want 2 (the third shouldn't run)", len(r.Steps))
+	}
+	if s := r.Steps[0]; !s.Success() || s.Output != "one\n" {
+		t.Errorf("step 1 = %+v", s)
+	}
+	if s := r.Steps[1]; s.Success() || s.ExitCode != 3 || s.Output != "two\n" {
+		t.Errorf("step 2 = %+v", s)
+	}
+	if r.Output != "one\ntwo\n" {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestExecuteBufferedArgs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	for _, tc := range []struct {
+		cmds []string
+		args []string
+		want string
+	}{
+		{[]string{"echo a"}, []string{"-x", "y"}, "a -x y\n"},
+		{[]string{"echo a {args} b"}, []string{"-x"}, "a -x b\n"},
+		{[]string{"echo a {args} b"}, nil, "a b\n"},
+		{[]string{"echo one", "echo two {args}", "echo three"}, []string{"2"}, "one\ntwo 2\nthree\n"},
+		// ${args} is the shell's, so the args are appended
+		{[]string{"args=a; echo ${args}"}, []string{"-x"}, "a -x\n"},
+		{[]string{"args=a; echo ${args} {args}", "echo b"}, []string{"-x"}, "a -x\nb\n"},
+	} {
+		pkg := Package{Label: "//pkg", Dir: dir, Tasks: map[string]Task{"test": {Cmds: tc.cmds}}}
+		if !pkg.Tasks["test"].TakesArgs() {
+			t.Errorf("%q doesn't take args", tc.cmds)
+		}
+		r := executeBuffered("test", pkg, RunOptions{ExtraArgs: tc.args}, nil)
+		if !r.Success || r.Output != tc.want {
+			t.Errorf("%q with %q: output %q, want %q", tc.cmds, tc.args, r.Output, tc.want)
+		}
+	}
+	if (Task{Cmds: []string{"echo one", "echo two"}}).TakesArgs() {
+		t.Error("a multi-step task without {args} takes args")
+	}
+	if (Task{Cmds: []string{"echo ${args}", "echo two"}}).TakesArgs() {
+		t.Error("a multi-step task with only ${args} takes args")
+	}
+}
+
+func TestRunTaskArgsFor(t *testing.T) {
+	var packages []Package
+	for _, typ := range []string{"go", "python"} {
+		dir := filepath.Join(t.TempDir(), typ)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		packages = append(packages, Package{Label: "//" + typ, Type: typ, Dir: dir, Tasks: map[string]Task{
+			"test": {Cmds: []string{"echo " + typ}},
+		}})
+	}
+
+	results := RunTask("test", packages, TaskConfig{}, RunOptions{Quiet: true, ExtraArgs: []string{"-k", "foo"}, ArgsTypes: []string{"python"}})
+	if got := results[0].Output; got != "go\n" {
+		t.Errorf("go output = %q, want no extra args", got)
+	}
+	if got := results[1].Output; got != "python -k foo\n" {
+		t.Errorf("python output = %q, want the extra args", got)
+	}
+}
+
+func TestExecuteBufferedPTY(t *testing.T) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pty: %v", err)
+	}
+	master.Close()
+	slave.Close()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	pkg := Package{Label: "//pkg", Dir: dir, Tasks: map[string]Task{
+		"test": {Cmds: []string{"test -t 1 && test -t 2 && echo tty; echo err >&2; test ! -t 0"}},
+	}}
+
+	if r := executeBuffered("test", pkg, RunOptions{PTY: true}, nil); !r.Success || r.Output != "tty\nerr\n" {
+		t.Errorf("with a pty: success=%v output %q", r.Success, r.Output)
+	}
+	if r := executeBuffered("test", pkg, RunOptions{}, nil); r.Output != "err\n" {
+		t.Errorf("without a pty: output %q", r.Output)
+	}
+}
+
+func TestExecuteBufferedParallelSteps(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ux.toml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Each command waits for the other's file, so they only finish if they run concurrently
+	pkg := Package{Label: "//pkg", Dir: dir, Tasks: map[string]Task{
+		"lint": {
+			Cmds: []string{
+				"touch a; while [ ! -e b ]; do sleep 0.01; done; echo a",
+				"touch b; while [ ! -e a ]; do sleep 0.01; done; echo b; exit 2",
+				"echo never",
+			},
+			Steps: []int{2, 1},
+		},
+	}}
+
+	r := executeBuffered("lint", pkg, RunOptions{}, nil)
+	if r.Success || !strings.HasSuffix(r.FailedStep, "exit 2") {
+		t.Fatalf("got success=%v failed step %q", r.Success, r.FailedStep)
+	}
+	if len(r.Steps) != 2 {
+		t.Fatalf("got %d steps, want 2 (the last step shouldn't run)", len(r.Steps))
+	}
+	if s := r.Steps[0]; !s.Success() || s.Output != "a\n" {
+		t.Errorf("command 1 = %+v", s)
+	}
+	if s := r.Steps[1]; s.ExitCode != 2 || s.Output != "b\n" {
+		t.Errorf("command 2 = %+v", s)
+	}
+	if r.Output != "a\nb\n" && r.Output != "b\na\n" {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestRunTaskMaxFailures(t *testing.T) {
+	var packages []Package
+	for _, name := range []string{"a", "b", "c"} {
+		dir := filepath.Join(t.TempDir(), name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "ux.toml"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{
+			"test": {Cmds: []string{"exit 1"}},
+		}})
+	}
+
+	results := RunTask("test", packages, TaskConfig{}, RunOptions{Quiet: true, MaxFailures: 2, LogDir: t.TempDir()})
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[1].Package.Label != "//b" || !results[1].Failed() {
+		t.Errorf("last result = %+v", results[1])
+	}
+}
+
+func TestRunTaskInterrupted(t *testing.T) {
+	var packages []Package
+	for _, name := range []string{"a", "b"} {
+		dir := filepath.Join(t.TempDir(), name)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{
+			"test": {Cmds: []string{"exec sleep 30"}},
+		}})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	results := RunTask("test", packages, TaskConfig{}, RunOptions{Quiet: true, Context: ctx, LogDir: t.TempDir()})
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("interrupted run took %s", elapsed)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1 (//b never started)", len(results))
+	}
+	if a := results[0]; !a.Interrupted || !a.Failed() {
+		t.Errorf("//a = %+v, want an interrupted failure", a)
+	}
+
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	h.Record("test", results)
+	if n := len(h.Tasks["test"]["//a"]); n != 0 {
+		t.Errorf("history recorded %d interrupted run(s), want none", n)
+	}
+}
+
+func TestRunTaskAllowFailure(t *testing.T) {
+	var packages []Package
+	for _, name := range []string{"a", "b"} {
+		dir := filepath.Join(t.TempDir(), name)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{
+			"typecheck": {Cmds: []string{`test "` + name + `" = a`}},
+		}})
+	}
+
+	results := RunTask("typecheck", packages, TaskConfig{AllowFailure: true}, RunOptions{Quiet: true, MaxFailures: 1, LogDir: t.TempDir()})
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2 (allowed failures don't count toward --max-failures)", len(results))
+	}
+	if !results[0].Success || results[0].Allowed {
+		t.Errorf("//a = %+v, want passed", results[0])
+	}
+	if b := results[1]; b.Success || !b.Allowed || b.Failed() {
+		t.Errorf("//b = %+v, want an allowed failure", b)
+	}
+	if s := NewRunSummary("typecheck", results); s.Failed != 0 || s.Allowed != 1 {
+		t.Errorf("summary counts failed=%d allowed=%d, want 0 and 1", s.Failed, s.Allowed)
+	}
+}
+
+func TestRunTaskRunOnce(t *testing.T) {
+	root := t.TempDir()
+	var packages []Package
+	for _, name := range []string{"a", "b", "c", "d"} {
+		dir := filepath.Join(root, name)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		lint := Task{Cmds: []string{"echo x >> runs; exit 1"}, Cwd: root, RunOnce: true}
+		if name == "c" {
+			lint.RunOnce = false // runs on its own, even if it's the same command
+		}
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{"lint": lint}})
+	}
+	packages[3].FlakyTasks = []string{"lint"}
+
+	for _, parallel := range []bool{false, true} {
+		os.Remove(filepath.Join(root, "runs"))
+		results := RunTask("lint", packages, TaskConfig{Parallel: parallel}, RunOptions{Quiet: true, Quarantine: true})
+		if len(results) != 4 {
+			t.Fatalf("got %d results, want 4", len(results))
+		}
+		data, err := os.ReadFile(filepath.Join(root, "runs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs := strings.Count(string(data), "x"); runs != 2 {
+			t.Errorf("parallel=%v: ran %d times, want 2", parallel, runs)
+		}
+		for i, want := range []string{"", "//a", "", "//a"} {
+			r := results[i]
+			if r.Package.Label != packages[i].Label || r.SharedWith != want || r.Success {
+				t.Errorf("parallel=%v: result %d = %s shared with %q, success %v; want %s shared with %q, failed",
+					parallel, i, r.Package.Label, r.SharedWith, r.Success, packages[i].Label, want)
+			}
+		}
+		if !results[3].Quarantined || results[1].Quarantined {
+			t.Errorf("parallel=%v: quarantine isn't per package", parallel)
+		}
+	}
+}
+
+func TestRunTaskRunOnceCached(t *testing.T) {
+	root := t.TempDir()
+	var packages []Package
+	for _, name := range []string{"a", "b"} {
+		dir := filepath.Join(root, name)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		writeFile(t, filepath.Join(dir, "main.py"), "print()\n")
+		lint := Task{Cmds: []string{"echo x >> runs"}, Cwd: root, RunOnce: true}
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{"lint": lint}})
+	}
+	cfg := TaskConfig{Inputs: []string{"**/*.py"}}
+	opts := RunOptions{Quiet: true, CacheDir: filepath.Join(root, ".ux", "cache")}
+	runs := func() int {
+		data, _ := os.ReadFile(filepath.Join(root, "runs"))
+		return strings.Count(string(data), "x")
+	}
+
+	RunTask("lint", packages, cfg, opts)
+	results := RunTask("lint", packages, cfg, opts)
+	if runs() != 1 || !results[0].Cached || !results[1].Cached {
+		t.Fatalf("unchanged inputs: ran %d times, want 1 and a cached replay for both", runs())
+	}
+	// Only //b changes, but //a's run covered it
+	writeFile(t, filepath.Join(root, "b", "main.py"), "print(1)\n")
+	results = RunTask("lint", packages, cfg, opts)
+	if runs() != 2 || results[1].Cached {
+		t.Errorf("after editing //b: ran %d times, want 2 and no cached replay", runs())
+	}
+}
+
+// probePackage returns a package whose test task records, in shared, which
+// probe packages were running when it finished (see probeRunning).
+func probePackage(t *testing.T, shared, name string) Package {
+	dir := filepath.Join(t.TempDir(), name)
+	writeFile(t, filepath.Join(dir, "ux.toml"), "")
+	return Package{Label: "//" + name, Dir: dir,
+		Env: map[string]string{"S": shared, "N": name, "D": "0.3"},
+		Tasks: map[string]Task{
+			"test": {Cmds: []string{`touch "$S/$N"; sleep "$D"; ls "$S" > "$S/$N.seen"; rm "$S/$N"`}},
+		}}
+}
+
+// probeRunning returns the probe packages that were running, including
+// itself, when the named one finished.
+func probeRunning(t *testing.T, shared, name string) []string {
+	data, err := os.ReadFile(filepath.Join(shared, name+".seen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slices.DeleteFunc(strings.Fields(string(data)), func(s string) bool {
+		return strings.HasSuffix(s, ".seen")
+	})
+}
+
+func TestRunTaskJobsWeights(t *testing.T) {
+	shared := t.TempDir()
+	var packages []Package
+	for _, p := range []struct {
+		name   string
+		weight int
+	}{{"heavy1", 2}, {"heavy2", 2}, {"light1", 1}, {"light2", 1}} {
+		pkg := probePackage(t, shared, p.name)
+		pkg.Weight = p.weight
+		packages = append(packages, pkg)
+	}
+
+	results := RunTask("test", packages, TaskConfig{Parallel: true}, RunOptions{Quiet: true, Jobs: 2})
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for _, name := range []string{"heavy1", "heavy2"} {
+		if got := probeRunning(t, shared, name); len(got) != 1 {
+			t.Errorf("%s ran alongside %v, want alone", name, got)
+		}
+	}
+	if len(probeRunning(t, shared, "light1")) != 2 && len(probeRunning(t, shared, "light2")) != 2 {
+		t.Errorf("light packages didn't run together")
+	}
+}
+
+func TestRunTaskLongestFirst(t *testing.T) {
+	history := &History{Tasks: map[string]map[string][]HistoryEntry{"test": {
+		"//a": {{Success: true, Duration: time.Second}},
+		"//b": {{Success: true, Duration: 5 * time.Second}},
+		"//d": {{Success: true, Duration: 3 * time.Second}, {Success: false, Duration: time.Hour}},
+	}}}
+	var packages []Package
+	for _, name := range []string{"a", "b", "c", "d"} {
+		dir := filepath.Join(t.TempDir(), name)
+		writeFile(t, filepath.Join(dir, "ux.toml"), "")
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{
+			"test": {Cmds: []string{"true"}},
+		}})
+	}
+
+	results := RunTask("test", packages, TaskConfig{Parallel: true}, RunOptions{Quiet: true, Jobs: 1, History: history})
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	slices.SortFunc(results, func(a, b Result) int { return a.Start.Compare(b.Start) })
+	var order []string
+	for _, r := range results {
+		order = append(order, r.Package.Label)
+	}
+	// //c has no history, and //d's failed run doesn't count
+	if want := []string{"//c", "//b", "//d", "//a"}; !slices.Equal(order, want) {
+		t.Errorf("started %v, want %v", order, want)
+	}
+}
+
+func TestRunTaskMutex(t *testing.T) {
+	shared := t.TempDir()
+	var packages []Package
+	for _, name := range []string{"db1", "db2", "other"} {
+		pkg := probePackage(t, shared, name)
+		if name == "other" {
+			pkg.Env["D"] = "0.45" // finishes while db2 runs, after db1
+		} else {
+			task := pkg.Tasks["test"]
+			task.Mutex = "database"
+			pkg.Tasks["test"] = task
+		}
+		packages = append(packages, pkg)
+	}
+
+	results := RunTask("test", packages, TaskConfig{Parallel: true}, RunOptions{Quiet: true})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, name := range []string{"db1", "db2"} {
+		if got := probeRunning(t, shared, name); slices.Contains(got, "db1") && slices.Contains(got, "db2") {
+			t.Errorf("db1 and db2 ran together: %v", got)
+		}
+	}
+	if got := probeRunning(t, shared, "other"); len(got) != 2 {
+		t.Errorf("other ran alongside %v, want one db package", got)
+	}
+}
+
+func TestRunTaskExclusive(t *testing.T) {
+	shared := t.TempDir()
+	var packages []Package
+	for _, name := range []string{"a", "port", "b", "c"} {
+		pkg := probePackage(t, shared, name)
+		pkg.Exclusive = name == "port"
+		if name == "a" || name == "b" {
+			pkg.Env["D"] = "0.45" // still running when c finishes
+		}
+		packages = append(packages, pkg)
+	}
+
+	results := RunTask("test", packages, TaskConfig{Parallel: true}, RunOptions{Quiet: true})
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	if got := probeRunning(t, shared, "port"); len(got) != 1 {
+		t.Errorf("port ran alongside %v, want alone", got)
+	}
+	if got := probeRunning(t, shared, "c"); len(got) != 3 {
+		t.Errorf("c ran alongside %v, want a and b", got)
+	}
+}
+
+func TestResolveWeights(t *testing.T) {
+	packages := []Package{{Label: "//a", resources: "heavy"}, {Label: "//b", resources: "gpu"}, {Label: "//c", Weight: 3}}
+	if err := resolveWeights(map[string]int{"gpu": 8}, packages); err != nil {
+		t.Fatal(err)
+	}
+	if packages[0].weight() != 4 || packages[1].weight() != 8 || packages[2].weight() != 3 {
+		t.Errorf("weights = %d, %d, %d", packages[0].weight(), packages[1].weight(), packages[2].weight())
+	}
+	err := resolveWeights(nil, []Package{{Label: "//d", resources: "huge"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown resource class") {
+		t.Errorf("err = %v, want unknown resource class", err)
+	}
+}
+
+func TestRunTaskWritesPackageLogs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ux.toml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg := Package{Label: "//svc/api", Dir: dir, Tasks: map[string]Task{
+		"test": {Cmds: []string{`printf '\033[32mhello\033[0m\n'`}},
+	}}
+	logDir := t.TempDir()
+
+	results := RunTask("test", []Package{pkg}, TaskConfig{}, RunOptions{Quiet: true, LogDir: logDir})
+	if !results[0].Success {
+		t.Fatalf("run failed: %s", results[0].Output)
+	}
+	// Colors are kept for the terminal, but not in the log
+	if results[0].Output != "\x1b[32mhello\x1b[0m\n" {
+		t.Errorf("output = %q", results[0].Output)
+	}
+	data, err := os.ReadFile(filepath.Join(logDir, "test", "svc-api.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ux test //svc/api\ndir: " + dir + "\n" + logOutputMarker + "hello\n\n" + logEndMarker + "passed in "
+	if !strings.HasPrefix(string(data), want) {
+		t.Errorf("log = %q, want it to start with %q", data, want)
+	}
+}
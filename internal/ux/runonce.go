@@ -0,0 +1,94 @@
+package ux
+
+import (
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// runOnceKey identifies what a run_once task would run on pkg: the same
+// commands, with the same extra args, in the same directory, shell,
+// environment, and host. Packages
+// with the same key share one run. It's "" unless the task is run_once.
+func runOnceKey(task string, pkg Package, opts RunOptions) string {
+	t := pkg.Tasks[task]
+	if !t.RunOnce {
+		return ""
+	}
+	steps := make([]string, len(t.Steps))
+	for i, n := range t.Steps {
+		steps[i] = strconv.Itoa(n)
+	}
+	return strings.Join([]string{
+		filepath.Clean(t.dir(pkg.Dir)), t.Shell, strings.Join(t.Cmds, "\x00"), strings.Join(steps, ","),
+		strings.Join(pkg.extraEnv(), "\x00"), opts.Executors.hostFor(pkg.Label),
+		strings.Join(ArgsFor(pkg, opts.ExtraArgs, opts.ArgsTypes), "\x00"),
+	}, "\x01")
+}
+
+// dedupeRunOnce returns the packages that actually need to run task: all
+// of them, except that of the packages sharing a run_once key (see
+// runOnceKey) only the first runs. shared maps its label to the rest.
+func dedupeRunOnce(task string, packages []Package, opts RunOptions) (run []Package, shared map[string][]Package) {
+	first := make(map[string]string) // key → label of the package that runs it
+	for _, pkg := range packages {
+		key := runOnceKey(task, pkg, opts)
+		if key == "" {
+			run = append(run, pkg)
+			continue
+		}
+		if label, ok := first[key]; ok {
+			if shared == nil {
+				shared = make(map[string][]Package)
+			}
+			shared[label] = append(shared[label], pkg)
+			continue
+		}
+		first[key] = pkg.Label
+		run = append(run, pkg)
+	}
+	return run, shared
+}
+
+// shareRunOnce attributes each result to the packages that shared its run,
+// returning results for all of packages, in order. Packages whose run never
+// started have none.
+func shareRunOnce(task string, packages []Package, results []Result, shared map[string][]Package, cfg TaskConfig, opts RunOptions, out *output) []Result {
+	if len(shared) == 0 {
+		return results
+	}
+	byLabel := make(map[string]Result, len(packages))
+	for _, r := range results {
+		byLabel[r.Package.Label] = r
+	}
+	skipped := 0
+	for label, pkgs := range shared {
+		r, ok := byLabel[label]
+		if !ok {
+			skipped += len(pkgs)
+			continue
+		}
+		for _, pkg := range pkgs {
+			s := r
+			s.Package = pkg
+			s.SharedWith = label
+			failed := !s.Success && !s.Removed
+			s.Quarantined = failed && opts.Quarantine && slices.Contains(pkg.FlakyTasks, task)
+			s.Allowed = failed && cfg.AllowFailure || s.Quarantined
+			out.markStarted(pkg.Label)
+			out.markCompleted(s)
+			byLabel[pkg.Label] = s
+		}
+	}
+	if skipped > 0 {
+		out.markSkipped(skipped)
+	}
+	all := make([]Result, 0, len(byLabel))
+	for _, pkg := range packages {
+		if r, ok := byLabel[pkg.Label]; ok {
+			all = append(all, r)
+		}
+	}
+	return all
+}
@@ -0,0 +1,187 @@
+package ux
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunState is .ux/state.json: for each task and package, the commit and
+// command of its last successful run, so --skip-unchanged can skip packages
+// that would run the same command on the same files.
+type RunState struct {
+	// Tasks maps task name → package label → its last successful run.
+	Tasks map[string]map[string]StateEntry `json:"tasks"`
+}
+
+// StateEntry records a successful run of a task on a package.
+type StateEntry struct {
+	Commit      string    `json:"commit"`      // HEAD when it ran
+	Fingerprint string    `json:"fingerprint"` // see TaskFingerprint
+	Time        time.Time `json:"time"`
+}
+
+func statePath(root string) string {
+	return filepath.Join(root, StateDir, "state.json")
+}
+
+// LoadRunState reads a workspace's run state. A missing file yields an empty state.
+func LoadRunState(root string) (*RunState, error) {
+	s := &RunState{Tasks: make(map[string]map[string]StateEntry)}
+	data, err := os.ReadFile(statePath(root))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return &RunState{Tasks: make(map[string]map[string]StateEntry)}, fmt.Errorf("parsing %s: %w", statePath(root), err)
+	}
+	if s.Tasks == nil {
+		s.Tasks = make(map[string]map[string]StateEntry)
+	}
+	return s, nil
+}
+
+// Save writes the state back to .ux/state.json.
+func (s *RunState) Save(root string) error {
+	path := statePath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// TaskFingerprint hashes a package's resolved task: its commands, how they
+// run, extra args, the package env, and the packages it depends on, so
+// adding or removing a dependency changes it. Unlike a cache key, it doesn't
+// read any files.
+func TaskFingerprint(task string, pkg Package, deps []Package, extraArgs []string) string {
+	h := sha256.New()
+	writeTaskCommand(h, task, pkg, extraArgs)
+	for _, dep := range deps {
+		fmt.Fprintf(h, "dep %s %s\n", dep.Label, dep.Dir)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Unchanged splits packages into those to run and those whose last
+// successful run of task used the same fingerprint, at a commit since which
+// git sees no change to any file in the package or the packages of
+// workspace it depends on: committed, staged, unstaged, or untracked (but
+// not ignored). Outside a git repository every package runs. extraArgs go to
+// the packages of argsTypes, or every package when it's nil (see ArgsFor).
+func (s *RunState) Unchanged(root, task string, packages, workspace []Package, extraArgs, argsTypes []string) (run, unchanged []Package) {
+	type diff struct {
+		files []string
+		err   error
+	}
+	changed := make(map[string]diff) // by commit
+	for _, pkg := range packages {
+		deps := dependencyPackages([]Package{pkg}, workspace)
+		e, ok := s.Tasks[task][pkg.Label]
+		if !ok || e.Fingerprint != TaskFingerprint(task, pkg, deps, ArgsFor(pkg, extraArgs, argsTypes)) {
+			run = append(run, pkg)
+			continue
+		}
+		d, ok := changed[e.Commit]
+		if !ok {
+			// e.g. the commit was rebased away
+			d.files, d.err = changedSinceCommit(root, e.Commit)
+			changed[e.Commit] = d
+		}
+		if d.err != nil || touchesPackages(root, append(deps, pkg), d.files) {
+			run = append(run, pkg)
+		} else {
+			unchanged = append(unchanged, pkg)
+		}
+	}
+	return run, unchanged
+}
+
+// Record notes the successful results of a run of task. A package with
+// uncommitted changes, in itself or a package of workspace it depends on,
+// isn't recorded, since its files don't match any commit, and neither is
+// anything outside a git repository.
+func (s *RunState) Record(root, task string, results []Result, workspace []Package, extraArgs, argsTypes []string) {
+	head := gitOutput(root, "rev-parse", "HEAD")
+	if head == "" {
+		return
+	}
+	dirty, err := changedSinceCommit(root, head)
+	if err != nil {
+		return
+	}
+	for _, r := range results {
+		deps := dependencyPackages([]Package{r.Package}, workspace)
+		if !r.Success || touchesPackages(root, append(deps, r.Package), dirty) {
+			continue
+		}
+		if s.Tasks[task] == nil {
+			s.Tasks[task] = make(map[string]StateEntry)
+		}
+		s.Tasks[task][r.Package.Label] = StateEntry{
+			Commit:      head,
+			Fingerprint: TaskFingerprint(task, r.Package, deps, ArgsFor(r.Package, extraArgs, argsTypes)),
+			Time:        time.Now(),
+		}
+	}
+}
+
+// changedSinceCommit lists the workspace-relative files that differ from
+// commit in the working tree, including untracked files.
+func changedSinceCommit(root, commit string) ([]string, error) {
+	var files []string
+	for _, args := range [][]string{
+		{"diff", "--name-only", "--relative", commit, "--"},
+		{"ls-files", "--others", "--exclude-standard"},
+	} {
+		cmd := gitCommand(root, args...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		for _, f := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// touchesPackages reports whether any of the workspace-relative files is in
+// one of the packages' directories.
+func touchesPackages(root string, pkgs []Package, files []string) bool {
+	for _, pkg := range pkgs {
+		dir := slashRel(root, pkg.Dir)
+		for _, f := range files {
+			if dir == "" || strings.HasPrefix(f, dir+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PrintUnchanged notes the packages --skip-unchanged left out of a task.
+func PrintUnchanged(task string, unchanged []Package) {
+	labels := make([]string, len(unchanged))
+	for i, pkg := range unchanged {
+		labels[i] = pkg.Label
+	}
+	fmt.Printf("\n  %s\n", styleDim.Render(fmt.Sprintf("%s: skipped %d unchanged package(s): %s", task, len(unchanged), labelList(labels))))
+}
@@ -0,0 +1,109 @@
+package ux
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunStateUnchanged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", root, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	var packages []Package
+	for _, name := range []string{"a", "b", "c"} {
+		dir := filepath.Join(root, name)
+		writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+		packages = append(packages, Package{Label: "//" + name, Dir: dir, Tasks: map[string]Task{
+			"test": {Cmds: []string{"go test ./..."}},
+		}})
+	}
+	// d depends on b, so it reruns when b changes
+	writeFile(t, filepath.Join(root, "d", "main.go"), "package main\n")
+	packages = append(packages, Package{Label: "//d", Dir: filepath.Join(root, "d"), Deps: []string{"//b"}, Tasks: map[string]Task{
+		"test": {Cmds: []string{"go test ./..."}},
+	}})
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	s := &RunState{Tasks: make(map[string]map[string]StateEntry)}
+	labels := func(pkgs []Package) []string {
+		var l []string
+		for _, p := range pkgs {
+			l = append(l, p.Label)
+		}
+		return l
+	}
+	if run, _ := s.Unchanged(root, "test", packages, packages, nil, nil); len(run) != 4 {
+		t.Fatalf("with no state, ran %v", labels(run))
+	}
+
+	writeFile(t, filepath.Join(root, "c", "new.go"), "package main\n") // uncommitted: not recorded
+	s.Record(root, "test", []Result{
+		{Package: packages[0], Success: true},
+		{Package: packages[1], Success: true},
+		{Package: packages[2], Success: true},
+		{Package: packages[3], Success: true},
+	}, packages, nil, nil)
+	if _, ok := s.Tasks["test"]["//c"]; ok {
+		t.Error("recorded a package with uncommitted changes")
+	}
+
+	// b changes after the run; c was never recorded
+	git("add", ".")
+	git("commit", "-q", "-m", "c")
+	writeFile(t, filepath.Join(root, "b", "main.go"), "package main // changed\n")
+	run, unchanged := s.Unchanged(root, "test", packages, packages, nil, nil)
+	if got := labels(unchanged); len(got) != 1 || got[0] != "//a" {
+		t.Errorf("unchanged = %v, want [//a]", got)
+	}
+	if got := labels(run); len(got) != 3 || got[0] != "//b" || got[1] != "//c" || got[2] != "//d" {
+		t.Errorf("run = %v, want [//b //c //d]", got)
+	}
+	if run, _ := s.Unchanged(root, "test", packages, packages, []string{"-run", "X"}, nil); len(run) != 4 {
+		t.Errorf("extra args should change the fingerprint; ran %v", labels(run))
+	}
+}
+
+func TestRunStateUnchangedGeneratedFrom(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", root, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeFile(t, filepath.Join(root, "proto", "a.proto"), "message A {}\n")
+	writeFile(t, filepath.Join(root, "api", "main.go"), "package main\n")
+	api := Package{Label: "//api", Dir: filepath.Join(root, "api"), GeneratedFrom: []string{"//proto"}, Tasks: map[string]Task{
+		"generate": {Cmds: []string{"buf generate"}},
+	}}
+	proto := Package{Label: "//proto", Dir: filepath.Join(root, "proto")}
+	workspace := []Package{api, proto}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	s := &RunState{Tasks: make(map[string]map[string]StateEntry)}
+	s.Record(root, "generate", []Result{{Package: api, Success: true}}, workspace, nil, nil)
+	if _, unchanged := s.Unchanged(root, "generate", []Package{api}, workspace, nil, nil); len(unchanged) != 1 {
+		t.Fatal("unchanged package wasn't skipped")
+	}
+	writeFile(t, filepath.Join(root, "proto", "a.proto"), "message A { string id = 1; }\n")
+	if run, _ := s.Unchanged(root, "generate", []Package{api}, workspace, nil, nil); len(run) != 1 {
+		t.Error("package wasn't rerun after its generated_from proto changed")
+	}
+}
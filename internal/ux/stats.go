@@ -0,0 +1,109 @@
+package ux
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PackageStats summarizes a package's recorded runs of one task.
+type PackageStats struct {
+	Label       string
+	Runs        int
+	Average     time.Duration
+	Last        time.Duration
+	FailureRate float64
+}
+
+// TaskNames returns the tasks with recorded runs, sorted.
+func (h *History) TaskNames() []string {
+	names := make([]string, 0, len(h.Tasks))
+	for task := range h.Tasks {
+		names = append(names, task)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats summarizes every package's recorded runs of task, slowest average
+// first. Cached replays are left out, as in ExpectedDuration, so the
+// averages reflect the time the task takes when it actually runs.
+func (h *History) Stats(task string) []PackageStats {
+	var stats []PackageStats
+	for label, entries := range h.Tasks[task] {
+		var total, last time.Duration
+		var runs int
+		for _, e := range entries {
+			if e.Cached {
+				continue
+			}
+			total += e.Duration
+			last = e.Duration
+			runs++
+		}
+		if runs == 0 {
+			continue
+		}
+		rate, _ := h.FailureRate(task, label)
+		stats = append(stats, PackageStats{
+			Label:       label,
+			Runs:        runs,
+			Average:     total / time.Duration(runs),
+			Last:        last,
+			FailureRate: rate,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Average != stats[j].Average {
+			return stats[i].Average > stats[j].Average
+		}
+		return stats[i].Label < stats[j].Label
+	})
+	return stats
+}
+
+// PrintStats prints the duration table for one task (for `ux stats`).
+func PrintStats(task string, stats []PackageStats) {
+	fmt.Printf("\n%s\n\n", styleHeader.Render("ux stats "+task))
+	fmt.Printf("  %s\n", styleDim.Render(fmt.Sprintf("%-40s %10s %10s %6s %7s", "package", "average", "last", "runs", "failed")))
+	var total time.Duration
+	for _, s := range stats {
+		failed := styleDim.Render(fmt.Sprintf("%6.0f%%", s.FailureRate*100))
+		if s.FailureRate > 0 {
+			failed = styleFail.Render(fmt.Sprintf("%6.0f%%", s.FailureRate*100))
+		}
+		fmt.Printf("  %s %10s %10s %6d %s\n",
+			styleLabel.Render(fmt.Sprintf("%-40s", s.Label)),
+			fmtDuration(s.Average), styleDim.Render(fmt.Sprintf("%10s", fmtDuration(s.Last))), s.Runs, failed)
+		total += s.Average
+	}
+	fmt.Printf("\n  %s\n\n", styleDim.Render(fmt.Sprintf("%d packages, %s total on average", len(stats), fmtDuration(total))))
+}
+
+// PrintProfile prints the n slowest packages of a run (for --profile).
+func PrintProfile(results []Result, n int) {
+	sorted := make([]Result, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Duration > sorted[j].Duration
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	multiTask := false
+	for _, r := range results {
+		if r.Task != results[0].Task {
+			multiTask = true
+		}
+	}
+
+	fmt.Printf("  %s\n\n", styleBold.Render(fmt.Sprintf("Slowest %d", len(sorted))))
+	for _, r := range sorted {
+		label := r.Package.Label
+		if multiTask {
+			label += ":" + r.Task
+		}
+		fmt.Printf("  %s %s\n", styleLabel.Render(fmt.Sprintf("%-40s", label)), styleDim.Render(fmtDuration(r.Duration)))
+	}
+	fmt.Println()
+}
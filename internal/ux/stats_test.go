@@ -0,0 +1,43 @@
+package ux
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryStats(t *testing.T) {
+	h := &History{Tasks: make(map[string]map[string][]HistoryEntry)}
+	fast := Package{Label: "//fast"}
+	slow := Package{Label: "//slow"}
+	h.Record("test", []Result{
+		{Package: fast, Success: true, Duration: 1 * time.Second},
+		{Package: slow, Success: false, Duration: 10 * time.Second},
+	})
+	h.Record("test", []Result{
+		{Package: fast, Success: true, Duration: 3 * time.Second},
+		{Package: slow, Success: true, Duration: 20 * time.Second},
+	})
+	h.Record("test", []Result{
+		{Package: fast, Success: true, Cached: true, Duration: time.Millisecond},
+		{Package: Package{Label: "//cached"}, Success: true, Cached: true},
+	})
+	h.Record("lint", []Result{{Package: fast, Success: true}})
+
+	stats := h.Stats("test")
+	want := []PackageStats{
+		{Label: "//slow", Runs: 2, Average: 15 * time.Second, Last: 20 * time.Second, FailureRate: 0.5},
+		{Label: "//fast", Runs: 2, Average: 2 * time.Second, Last: 3 * time.Second},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d stats, want %d", len(stats), len(want))
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("stats[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	if got := h.TaskNames(); len(got) != 2 || got[0] != "lint" || got[1] != "test" {
+		t.Errorf("TaskNames = %v, want [lint test]", got)
+	}
+}
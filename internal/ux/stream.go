@@ -0,0 +1,138 @@
+package ux
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// streamClientBuffer is how many writes a stream client may fall behind by
+// before it's dropped, and streamWriteTimeout how long one write to it may
+// take. Neither a slow nor a stalled client holds up the task's output.
+const (
+	streamClientBuffer = 256
+	streamWriteTimeout = 5 * time.Second
+)
+
+// streamBacklog is how much of a package's latest output a client that
+// connects late is sent first. The full output is in the package's log.
+const streamBacklog = 64 << 10
+
+// streamServer exposes one package's live output on a unix socket. Clients
+// that connect receive the last streamBacklog bytes written so far, from the
+// start of a line, then the output as it is produced, until the package
+// finishes.
+type streamServer struct {
+	mu      sync.Mutex
+	ln      net.Listener
+	path    string
+	backlog []byte // the latest output, at most 2*streamBacklog bytes
+	clients []chan []byte // each drained to its connection by a goroutine
+	closed  bool
+}
+
+// newStreamServer listens on <dir>/<label>.sock for the given package label.
+func newStreamServer(dir, label string) (*streamServer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, labelFileName(label)+".sock")
+	// A socket left behind by an interrupted run would make Listen fail
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &streamServer{ln: ln, path: path}
+	go s.acceptLoop()
+	return s, nil
+}
+
+func (s *streamServer) acceptLoop() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		ch := make(chan []byte, streamClientBuffer)
+		ch <- s.recent()
+		s.clients = append(s.clients, ch)
+		s.mu.Unlock()
+		go sendStream(conn, ch)
+	}
+}
+
+// recent returns a copy of the last streamBacklog bytes of output, starting
+// after the first newline if that cuts a line short.
+func (s *streamServer) recent() []byte {
+	b := s.backlog
+	if len(b) > streamBacklog {
+		b = b[len(b)-streamBacklog:]
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			b = b[i+1:]
+		}
+	}
+	return bytes.Clone(b)
+}
+
+// sendStream writes what arrives on ch to conn until ch is closed or a
+// write fails or times out, then closes conn.
+func sendStream(conn net.Conn, ch <-chan []byte) {
+	defer conn.Close()
+	for p := range ch {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := conn.Write(p); err != nil {
+			return
+		}
+	}
+}
+
+// Write records output and queues it for every attached client. Clients
+// too far behind are dropped; the task itself never waits or sees an error.
+func (s *streamServer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backlog = append(s.backlog, p...)
+	if len(s.backlog) > 2*streamBacklog {
+		// Trimming only once it's doubled keeps the copying amortized
+		s.backlog = append(s.backlog[:0], s.backlog[len(s.backlog)-streamBacklog:]...)
+	}
+	if len(s.clients) == 0 {
+		return len(p), nil
+	}
+	chunk := bytes.Clone(p)
+	live := s.clients[:0]
+	for _, ch := range s.clients {
+		select {
+		case ch <- chunk:
+			live = append(live, ch)
+		default:
+			close(ch)
+		}
+	}
+	s.clients = live
+	return len(p), nil
+}
+
+// Close removes the socket and disconnects every client once it has been
+// sent what's queued for it.
+func (s *streamServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.ln.Close()
+	for _, ch := range s.clients {
+		close(ch)
+	}
+	s.clients = nil
+	os.Remove(s.path)
+}
@@ -0,0 +1,116 @@
+package ux
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamServer(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := newStreamServer(dir, "//svc/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Write([]byte("before\n"))
+	conn, err := net.Dial("unix", filepath.Join(dir, "svc-api.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitForStreamClient(t, srv)
+	srv.Write([]byte("after\n"))
+	srv.Close()
+
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "before\nafter\n" {
+		t.Errorf("client got %q, want the backlog then the live output", got)
+	}
+}
+
+func TestStreamServerBacklogCapped(t *testing.T) {
+	srv, err := newStreamServer(t.TempDir(), "//svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 10*streamBacklog/len(line); i++ {
+		srv.Write([]byte(line))
+	}
+	srv.Write([]byte("last\n"))
+
+	if n := len(srv.backlog); n > 2*streamBacklog {
+		t.Errorf("backlog holds %d bytes, want at most %d", n, 2*streamBacklog)
+	}
+	got := srv.recent()
+	if len(got) > streamBacklog || !bytes.HasPrefix(got, []byte(line)) || !bytes.HasSuffix(got, []byte(line+"last\n")) {
+		t.Errorf("late joiners get %d bytes, want at most %d of whole lines ending with the latest", len(got), streamBacklog)
+	}
+}
+
+// waitForStreamClient waits until srv has accepted a client, and so queued
+// the backlog for it.
+func waitForStreamClient(t *testing.T, srv *streamServer) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.mu.Lock()
+		n := len(srv.clients)
+		srv.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the client was never accepted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamServerStalledClient(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := newStreamServer(dir, "//svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	// Connected, but never reads
+	conn, err := net.Dial("unix", filepath.Join(dir, "svc.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitForStreamClient(t, srv)
+
+	// Far more than the socket buffers and the client's queue hold
+	line := []byte(strings.Repeat("x", 1<<16) + "\n")
+	done := make(chan struct{})
+	go func() {
+		for range 4 * streamClientBuffer {
+			srv.Write(line)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a client that doesn't read blocked the output")
+	}
+	srv.mu.Lock()
+	n := len(srv.clients)
+	srv.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d clients still attached, want the stalled one dropped", n)
+	}
+	if len(srv.backlog) > 2*streamBacklog || !bytes.HasSuffix(srv.backlog, line[len(line)-streamBacklog:]) {
+		t.Errorf("backlog has %d bytes", len(srv.backlog))
+	}
+}
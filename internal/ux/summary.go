@@ -0,0 +1,158 @@
+package ux
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RunSummary is the machine-readable record of a single task run.
+type RunSummary struct {
+	Task   string    `json:"task"`
+	Time   time.Time `json:"time"`
+	Passed int       `json:"passed"`
+	Failed int       `json:"failed"`
+	// Allowed counts failures of tasks that set allow_failure.
+	Allowed  int              `json:"allowed,omitempty"`
+	Packages []PackageSummary `json:"packages"`
+	// Environment is where the run executed; it is absent when nothing ran.
+	Environment *Environment `json:"environment,omitempty"`
+}
+
+// PackageSummary is one package's outcome within a RunSummary.
+type PackageSummary struct {
+	Label      string `json:"label"`
+	Task       string `json:"task"`
+	Success    bool   `json:"success"`
+	Flaky      bool   `json:"flaky,omitempty"`
+	Cached     bool   `json:"cached,omitempty"`
+	Removed    bool   `json:"removed,omitempty"`
+	Allowed    bool   `json:"allowed,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	FailedStep string `json:"failed_step,omitempty"`
+	Artifacts  string `json:"artifacts,omitempty"`
+	Host       string `json:"host,omitempty"`
+	// Owners are who to ping when the package fails.
+	Owners []string `json:"owners,omitempty"`
+	// Quarantined marks an allowed failure of a [package] flaky_tasks task
+	// in a --quarantine run.
+	Quarantined bool `json:"quarantined,omitempty"`
+	// Steps are the commands that ran, in order; absent for cached results.
+	Steps []StepSummary `json:"steps,omitempty"`
+}
+
+// StepSummary is one command's outcome within a PackageSummary.
+type StepSummary struct {
+	Cmd        string `json:"cmd"`
+	DurationMs int64  `json:"duration_ms"`
+	ExitCode   int    `json:"exit_code"`
+}
+
+// NewRunSummary builds a summary from run results, sorted by label. Results may
+// span several tasks when the requested task has dependencies.
+func NewRunSummary(task string, results []Result) RunSummary {
+	s := RunSummary{Task: task, Time: time.Now(), Packages: []PackageSummary{}}
+	for _, r := range results {
+		if r.Success {
+			s.Passed++
+		} else if r.Failed() {
+			s.Failed++
+		} else if r.Allowed {
+			s.Allowed++
+		}
+		var steps []StepSummary
+		for _, step := range r.Steps {
+			steps = append(steps, StepSummary{
+				Cmd:        step.Cmd,
+				DurationMs: step.Duration.Milliseconds(),
+				ExitCode:   step.ExitCode,
+			})
+		}
+		s.Packages = append(s.Packages, PackageSummary{
+			Label:       r.Package.Label,
+			Task:        r.Task,
+			Success:     r.Success,
+			Flaky:       r.Flaky,
+			Cached:      r.Cached,
+			Removed:     r.Removed,
+			Allowed:     r.Allowed,
+			DurationMs:  r.Duration.Milliseconds(),
+			FailedStep:  r.FailedStep,
+			Artifacts:   r.Artifacts,
+			Host:        r.Host,
+			Owners:      r.Package.Owners,
+			Quarantined: r.Quarantined,
+			Steps:       steps,
+		})
+	}
+	sort.SliceStable(s.Packages, func(i, j int) bool {
+		return s.Packages[i].Label < s.Packages[j].Label
+	})
+	return s
+}
+
+// FailedLabels returns the labels of packages that failed in this run.
+func (s RunSummary) FailedLabels() []string {
+	var labels []string
+	for _, p := range s.Packages {
+		if !p.Success && !p.Removed {
+			labels = append(labels, p.Label)
+		}
+	}
+	return labels
+}
+
+// WriteRunSummary writes a summary as JSON to path, creating parent directories.
+func WriteRunSummary(path string, s RunSummary) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ResultFileEnv names the environment variable that, when set, is the path
+// where the final JSON summary of every run is written.
+const ResultFileEnv = "UX_RESULT_FILE"
+
+// WriteResultFile writes the run summary to $UX_RESULT_FILE, if set.
+func WriteResultFile(task string, results []Result, env *Environment) error {
+	path := os.Getenv(ResultFileEnv)
+	if path == "" {
+		return nil
+	}
+	s := NewRunSummary(task, results)
+	s.Environment = env
+	return WriteRunSummary(path, s)
+}
+
+func lastRunPath(root, task string) string {
+	return filepath.Join(root, StateDir, "last-run", task+".json")
+}
+
+// SaveLastRun persists the summary of the latest run of a task.
+func SaveLastRun(root string, s RunSummary) error {
+	return WriteRunSummary(lastRunPath(root, s.Task), s)
+}
+
+// LoadLastRun reads the summary of the latest run of a task.
+// It returns nil without error if the task has never been run.
+func LoadLastRun(root, task string) (*RunSummary, error) {
+	data, err := os.ReadFile(lastRunPath(root, task))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s RunSummary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,115 @@
+package ux
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tailPollInterval is how often a followed log is checked for new output.
+const tailPollInterval = 200 * time.Millisecond
+
+// PackageLog locates a package's log within a run.
+type PackageLog struct {
+	Path  string
+	RunID string
+}
+
+// RunActive reports whether the ux process that started a run is still
+// running, using the process ID at the end of its run ID.
+func RunActive(runID string) bool {
+	i := strings.LastIndex(runID, "-")
+	pid, err := strconv.Atoi(runID[i+1:])
+	if err != nil || pid <= 0 {
+		return false
+	}
+	return processAlive(pid)
+}
+
+// LatestRun returns the ID of the newest run with logs, or "" if there are none.
+func LatestRun(root string, cfg LogsConfig) (string, error) {
+	runs, err := cfg.listRuns(root)
+	if err != nil || len(runs) == 0 {
+		return "", err
+	}
+	return runs[len(runs)-1], nil
+}
+
+// FindPackageLog returns the newest log for a package label, searching runs
+// from newest to oldest. If task is empty, the package's most recently
+// written log in a run is chosen, whichever task it belongs to. It returns
+// nil if no run has a log for the package.
+func FindPackageLog(root string, cfg LogsConfig, label, task string) (*PackageLog, error) {
+	runs, err := cfg.listRuns(root)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if path := runPackageLog(cfg.RunDir(root, runs[i]), label, task); path != "" {
+			return &PackageLog{Path: path, RunID: runs[i]}, nil
+		}
+	}
+	return nil, nil
+}
+
+// runPackageLog returns the path of a package's log within one run's
+// directory, or "".
+func runPackageLog(runDir, label, task string) string {
+	name := labelFileName(label) + ".log"
+	if task != "" {
+		path := filepath.Join(runDir, task, name)
+		if _, err := os.Stat(path); err != nil {
+			return ""
+		}
+		return path
+	}
+	matches, _ := filepath.Glob(filepath.Join(runDir, "*", name))
+	var newest string
+	var newestTime time.Time
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.ModTime().After(newestTime) {
+			newest, newestTime = m, info.ModTime()
+		}
+	}
+	return newest
+}
+
+// FollowLog copies a package log to w and keeps copying output as it's
+// appended, until the package finishes or the run that writes it exits.
+func FollowLog(log *PackageLog, w io.Writer) error {
+	f, err := os.Open(log.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var tail []byte // the last partial line, to spot the end marker
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			tail = append(tail, buf[:n]...)
+			if bytes.Contains(tail, []byte("\n"+logEndMarker)) {
+				return nil
+			}
+			if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+				tail = tail[i:] // keep the newline so the marker still matches at line start
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if !RunActive(log.RunID) {
+			return nil
+		}
+		time.Sleep(tailPollInterval)
+	}
+}
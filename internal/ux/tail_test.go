@@ -0,0 +1,89 @@
+package ux
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPackageLog(t *testing.T) {
+	root := t.TempDir()
+	cfg := LogsConfig{Dir: "logs"}
+	write := func(run, task, body string) string {
+		path := filepath.Join(cfg.RunDir(root, run), task, "svc-api.log")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	older := write("20250101-120000-99999999", "test", "old")
+	newer := write("20250102-120000-99999999", "lint", "new")
+
+	log, err := FindPackageLog(root, cfg, "//svc/api", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log == nil || log.Path != newer || log.RunID != "20250102-120000-99999999" {
+		t.Errorf("any task: got %+v, want %s", log, newer)
+	}
+	if log, _ := FindPackageLog(root, cfg, "//svc/api", "test"); log == nil || log.Path != older {
+		t.Errorf("--task test: got %+v, want %s", log, older)
+	}
+	if log, _ := FindPackageLog(root, cfg, "//svc/web", ""); log != nil {
+		t.Errorf("unknown package: got %+v, want nil", log)
+	}
+}
+
+func TestFindPackageLogSharedDir(t *testing.T) {
+	// Two checkouts logging to the same directory only see their own runs
+	cfg := LogsConfig{Dir: t.TempDir()}
+	rootA, rootB := t.TempDir(), t.TempDir()
+	path := filepath.Join(cfg.RunDir(rootA, "20250101-120000-99999999"), "test", "svc-api.log")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if log, _ := FindPackageLog(rootA, cfg, "//svc/api", ""); log == nil || log.Path != path {
+		t.Errorf("own workspace: got %+v, want %s", log, path)
+	}
+	if log, _ := FindPackageLog(rootB, cfg, "//svc/api", ""); log != nil {
+		t.Errorf("other workspace: got %+v, want nil", log)
+	}
+	if run, _ := LatestRun(rootB, cfg); run != "" {
+		t.Errorf("other workspace's latest run = %q, want none", run)
+	}
+}
+
+func TestFollowLogStopsAtEndMarker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pkg.log")
+	body := "ux test //pkg\n" + logOutputMarker + "ok\n\n" + logEndMarker + "passed in 1.0s ---\n"
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The run is active (it is this process), so only the end marker stops it
+	var out bytes.Buffer
+	run := fmt.Sprintf("20250101-120000-%d", os.Getpid())
+	if err := FollowLog(&PackageLog{Path: path, RunID: run}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != body {
+		t.Errorf("got %q, want %q", out.String(), body)
+	}
+}
+
+func TestRunActive(t *testing.T) {
+	if !RunActive(fmt.Sprintf("20250101-120000-%d", os.Getpid())) {
+		t.Error("current process reported inactive")
+	}
+	if RunActive("20250101-120000-99999999") {
+		t.Error("nonexistent process reported active")
+	}
+}
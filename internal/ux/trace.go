@@ -0,0 +1,224 @@
+package ux
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OtelEndpointEnv names the environment variable that, when set, is the
+// OTLP/HTTP endpoint runs are exported to as spans.
+const OtelEndpointEnv = "UX_OTEL_ENDPOINT"
+
+// otlpTimeout bounds the span export at the end of a run.
+const otlpTimeout = 10 * time.Second
+
+// chromeEvent is one event in the Chrome trace event format, as read by
+// Perfetto and chrome://tracing.
+type chromeEvent struct {
+	Name string            `json:"name"`
+	Cat  string            `json:"cat,omitempty"`
+	Ph   string            `json:"ph"`
+	Ts   int64             `json:"ts"` // microseconds
+	Dur  int64             `json:"dur,omitempty"`
+	Pid  int               `json:"pid"`
+	Tid  int               `json:"tid"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes a run's timeline to path in Chrome trace format:
+// one track per package and task, with a span for the package and nested
+// spans for each of its steps.
+func WriteChromeTrace(path string, results []Result) error {
+	events := []chromeEvent{}
+	for i, r := range results {
+		tid := i + 1
+		name := r.Package.Label + " " + r.Task
+		events = append(events, chromeEvent{
+			Name: "thread_name", Ph: "M", Pid: 1, Tid: tid,
+			Args: map[string]string{"name": name},
+		})
+		events = append(events, chromeEvent{
+			Name: name,
+			Cat:  r.Task,
+			Ph:   "X",
+			Ts:   r.Start.UnixMicro(),
+			Dur:  max(r.Duration.Microseconds(), 1),
+			Pid:  1,
+			Tid:  tid,
+			Args: resultAttributes(r),
+		})
+		for _, step := range r.Steps {
+			events = append(events, chromeEvent{
+				Name: step.Cmd,
+				Cat:  "step",
+				Ph:   "X",
+				Ts:   step.Start.UnixMicro(),
+				Dur:  max(step.Duration.Microseconds(), 1),
+				Pid:  1,
+				Tid:  tid,
+				Args: map[string]string{"success": strconv.FormatBool(step.Success())},
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"traceEvents": events}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// resultAttributes describes a package's outcome for trace spans.
+func resultAttributes(r Result) map[string]string {
+	attrs := map[string]string{
+		"ux.package": r.Package.Label,
+		"ux.task":    r.Task,
+		"ux.status":  resultStatus(r),
+	}
+	if r.Cached {
+		attrs["ux.cached"] = "true"
+	}
+	if r.FailedStep != "" {
+		attrs["ux.failed_step"] = r.FailedStep
+	}
+	return attrs
+}
+
+// OTLP/JSON types, the subset needed to export spans.
+type (
+	otlpAttribute struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            struct {
+			Code int `json:"code"`
+		} `json:"status"`
+	}
+)
+
+// OTLP span kind and status codes.
+const (
+	otlpKindInternal = 1
+	otlpStatusOK     = 1
+	otlpStatusError  = 2
+)
+
+// ExportOTLP sends a run to an OTLP/HTTP collector as one trace: a root span
+// for the run, a child per package, and grandchildren for steps. endpoint is
+// the collector's base URL; /v1/traces is appended unless already present.
+func ExportOTLP(endpoint, task string, start, end time.Time, results []Result) error {
+	traceID := randomHex(16)
+	rootID := randomHex(8)
+
+	failed := false
+	for _, r := range results {
+		if r.Failed() {
+			failed = true
+		}
+	}
+	spans := []otlpSpan{newOTLPSpan(traceID, rootID, "", "ux "+task, start, end, !failed,
+		map[string]string{"ux.task": task})}
+	for _, r := range results {
+		pkgID := randomHex(8)
+		spans = append(spans, newOTLPSpan(traceID, pkgID, rootID, r.Package.Label+" "+r.Task,
+			r.Start, r.Start.Add(r.Duration), !r.Failed(), resultAttributes(r)))
+		for _, step := range r.Steps {
+			spans = append(spans, newOTLPSpan(traceID, randomHex(8), pkgID, step.Cmd,
+				step.Start, step.Start.Add(step.Duration), step.Success(), nil))
+		}
+	}
+
+	body := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]string{"service.name": "ux"}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "ux"},
+				"spans": spans,
+			}},
+		}},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	client := &http.Client{Timeout: otlpTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+func newOTLPSpan(traceID, spanID, parentID, name string, start, end time.Time, ok bool, attrs map[string]string) otlpSpan {
+	s := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		ParentSpanID:      parentID,
+		Name:              name,
+		Kind:              otlpKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        otlpAttributes(attrs),
+	}
+	s.Status.Code = otlpStatusOK
+	if !ok {
+		s.Status.Code = otlpStatusError
+	}
+	return s
+}
+
+// otlpAttributes converts string attributes, sorted by key for stable output.
+func otlpAttributes(attrs map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpAttribute, len(keys))
+	for i, k := range keys {
+		out[i].Key = k
+		out[i].Value.StringValue = attrs[k]
+	}
+	return out
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package ux
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func traceResults() []Result {
+	start := time.Unix(1700000000, 0)
+	return []Result{
+		{
+			Task: "test", Package: Package{Label: "//a"}, Success: true,
+			Start: start, Duration: 3 * time.Second,
+			Steps: []StepResult{
+				{Cmd: "go vet", Start: start, Duration: time.Second},
+				{Cmd: "go test", Start: start.Add(time.Second), Duration: 2 * time.Second},
+			},
+		},
+		{
+			Task: "test", Package: Package{Label: "//b"}, FailedStep: "npm test",
+			Start: start, Duration: time.Second,
+			Steps: []StepResult{{Cmd: "npm test", Start: start, Duration: time.Second, ExitCode: 1}},
+		},
+	}
+}
+
+func TestWriteChromeTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+	if err := WriteChromeTrace(path, traceResults()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatal(err)
+	}
+
+	// Per package: a thread name, the package span, and one span per step
+	if got := len(trace.TraceEvents); got != 7 {
+		t.Fatalf("got %d events, want 7", got)
+	}
+	pkg := trace.TraceEvents[1]
+	if pkg.Name != "//a test" || pkg.Ph != "X" || pkg.Tid != 1 || pkg.Dur != 3_000_000 {
+		t.Errorf("package span = %+v", pkg)
+	}
+	step := trace.TraceEvents[3]
+	if step.Name != "go test" || step.Ts != pkg.Ts+1_000_000 || step.Tid != 1 {
+		t.Errorf("step span = %+v", step)
+	}
+	failed := trace.TraceEvents[5]
+	if failed.Tid != 2 || failed.Args["ux.status"] != "failed" || failed.Args["ux.failed_step"] != "npm test" {
+		t.Errorf("failed span = %+v", failed)
+	}
+}
+
+func TestExportOTLP(t *testing.T) {
+	var path string
+	var body struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	defer srv.Close()
+
+	start := time.Unix(1700000000, 0)
+	if err := ExportOTLP(srv.URL, "test", start, start.Add(3*time.Second), traceResults()); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/traces" {
+		t.Errorf("posted to %q, want /v1/traces", path)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 6 {
+		t.Fatalf("got %d spans, want 6", len(spans))
+	}
+	root := spans[0]
+	if root.ParentSpanID != "" || root.Status.Code != otlpStatusError {
+		t.Errorf("root span = %+v", root)
+	}
+	for _, s := range spans[1:] {
+		if s.TraceID != root.TraceID {
+			t.Errorf("span %q has trace %s, want %s", s.Name, s.TraceID, root.TraceID)
+		}
+	}
+	if spans[1].ParentSpanID != root.SpanID || spans[2].ParentSpanID != spans[1].SpanID {
+		t.Errorf("spans aren't nested package → step")
+	}
+	if spans[4].Name != "//b test" || spans[4].Status.Code != otlpStatusError {
+		t.Errorf("failed package span = %+v", spans[4])
+	}
+}
@@ -0,0 +1,145 @@
+package ux
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// typeColors are the colors `ux list --tree` shows the built-in package
+// types in; other types get the color of packageTypeTag.
+var typeColors = map[string]string{
+	"go":        "45",
+	"python":    "220",
+	"rust":      "208",
+	"node":      "70",
+	"proto":     "141",
+	"terraform": "99",
+	"docker":    "33",
+}
+
+// treeNode is a directory in `ux list --tree`: the package there, if any,
+// and the directories below it that hold packages.
+type treeNode struct {
+	name     string // path from the parent node, e.g. "api" or "libs/go"
+	pkg      *Package
+	children map[string]*treeNode
+	count    int // packages at or below the node
+}
+
+// buildTree arranges packages by the directories in their labels.
+func buildTree(packages []Package) *treeNode {
+	root := &treeNode{children: make(map[string]*treeNode)}
+	for i := range packages {
+		pkg := &packages[i]
+		node := root
+		node.count++
+		path := strings.TrimPrefix(pkg.Label, "//")
+		if path != "." {
+			for _, part := range strings.Split(path, "/") {
+				child := node.children[part]
+				if child == nil {
+					child = &treeNode{name: part, children: make(map[string]*treeNode)}
+					node.children[part] = child
+				}
+				node = child
+				node.count++
+			}
+		}
+		node.pkg = pkg
+	}
+	return root
+}
+
+// sorted returns the node's children by name, with chains of directories
+// that hold nothing but one other directory joined into one: libs/go.
+func (n *treeNode) sorted() []*treeNode {
+	var children []*treeNode
+	for _, child := range n.children {
+		for child.pkg == nil && len(child.children) == 1 {
+			for _, only := range child.children {
+				child = &treeNode{name: child.name + "/" + only.name, pkg: only.pkg, children: only.children, count: only.count}
+			}
+		}
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	return children
+}
+
+// PrintPackageTree prints discovered packages as a tree of the directories
+// they're in, with each directory's package count and each package's type
+// and task names (for `ux list --tree`).
+func PrintPackageTree(packages []Package) {
+	writePackageTree(os.Stdout, packages)
+}
+
+func writePackageTree(w io.Writer, packages []Package) {
+	fmt.Fprintf(w, "\n%s\n\n", styleHeader.Render(fmt.Sprintf("Workspace packages (%d)", len(packages))))
+	root := buildTree(packages)
+	if root.pkg != nil {
+		fmt.Fprintf(w, "  %s\n", treePackageLine("//.", 0, *root.pkg))
+	}
+	writeTreeChildren(w, root, "  ", true)
+	fmt.Fprintln(w)
+}
+
+// writeTreeChildren writes the nodes below n, each line after indent.
+// Top-level nodes start at the margin, without branches.
+func writeTreeChildren(w io.Writer, n *treeNode, indent string, top bool) {
+	children := n.sorted()
+	width := 0
+	for _, child := range children {
+		width = max(width, len(treeName(child)))
+	}
+	for i, child := range children {
+		branch, next := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, next = "└── ", "    "
+		}
+		if top {
+			branch, next = "", ""
+		}
+		line := styleLabel.Render(treeName(child)) + styleDim.Render(fmt.Sprintf(" (%d)", child.count))
+		if child.pkg != nil {
+			line = treePackageLine(treeName(child), width, *child.pkg)
+		}
+		fmt.Fprintf(w, "%s%s%s\n", indent, styleDim.Render(branch), line)
+		writeTreeChildren(w, child, indent+styleDim.Render(next), false)
+	}
+}
+
+// treeName is how a node is shown: a directory with a trailing slash, a
+// package by its directory name.
+func treeName(n *treeNode) string {
+	if n.pkg == nil {
+		return n.name + "/"
+	}
+	return n.name
+}
+
+// treePackageLine shows a package's name, padded to width, its type, and
+// its task names, collapsed onto the one line.
+func treePackageLine(name string, width int, pkg Package) string {
+	line := fmt.Sprintf("%-*s", width, name)
+	if pkg.Type != "" {
+		color, ok := typeColors[pkg.Type]
+		if !ok {
+			color = "36"
+		}
+		line += "  " + lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(fmt.Sprintf("%-9s", pkg.Type))
+	}
+	tasks := make([]string, 0, len(pkg.Tasks))
+	for t := range pkg.Tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Strings(tasks)
+	if len(tasks) > 0 {
+		line += "  " + styleDim.Render(strings.Join(tasks, ", "))
+	}
+	return line
+}
@@ -0,0 +1,46 @@
+package ux
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePackageTree(t *testing.T) {
+	pkg := func(label, typ string, tasks ...string) Package {
+		p := Package{Label: label, Type: typ, Tasks: make(map[string]Task)}
+		for _, task := range tasks {
+			p.Tasks[task] = Task{}
+		}
+		return p
+	}
+	var out bytes.Buffer
+	writePackageTree(&out, []Package{
+		pkg("//.", "", "docs"),
+		pkg("//libs/go/util", "go", "test"),
+		pkg("//services/api", "go", "test", "build"),
+		pkg("//services/api/client", "node", "test"),
+		pkg("//services/worker", "python", "test"),
+	})
+	want := `
+Workspace packages (5)
+
+  //.  docs
+  libs/go/util  go         test
+  services/ (3)
+  ├── api     go         build, test
+  │   └── client  node       test
+  └── worker  python     test
+
+`
+	if got := stripANSI(out.String()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// stripANSI removes color codes from s.
+func stripANSI(s string) string {
+	var b strings.Builder
+	(&ansiStripper{w: &b}).Write([]byte(s))
+	return b.String()
+}
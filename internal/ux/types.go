@@ -0,0 +1,228 @@
+package ux
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// TypeConfig registers a package type beyond the built-in ones, either in
+// the root config ([types.<name>]) or from a plugin's description.
+type TypeConfig struct {
+	// Markers are files whose presence in a directory makes it a package of
+	// this type (e.g. "main.tf").
+	Markers []string `toml:"markers" json:"markers"`
+	// Tasks are the type's default tasks, in the same forms as [tasks].
+	Tasks map[string]interface{} `toml:"tasks" json:"tasks"`
+}
+
+// PluginPrefix prefixes the executable name of a type plugin: the plugin for
+// type "proto" is ux-plugin-proto.
+const PluginPrefix = "ux-plugin-"
+
+// typeMarker maps a marker file to the type it identifies.
+type typeMarker struct {
+	file     string
+	typeName string
+}
+
+// packageTypes is the set of types packages are detected as, with the tasks
+// each type provides before [defaults.<type>.tasks] apply.
+type packageTypes struct {
+	markers []typeMarker // checked in order; the first match wins
+	skip    []string     // [discovery] skip
+	tasks   map[string]map[string]Task
+	docker  DockerConfig // for docker packages' built-in tasks
+	// extends maps a type to the base type its [defaults] extends.
+	extends map[string]string
+	// followSymlinks is [discovery] follow_symlinks.
+	followSymlinks bool
+}
+
+// builtinTypes returns the built-in types, with their default tasks if
+// withTasks is set.
+func builtinTypes(withTasks bool) *packageTypes {
+	t := &packageTypes{
+		markers: append([]typeMarker(nil), markerPriority...),
+		tasks:   make(map[string]map[string]Task),
+	}
+	if withTasks {
+		for typeName, tasks := range builtinDefaults {
+			t.tasks[typeName] = tasks
+		}
+	}
+	return t
+}
+
+// loadPackageTypes combines the built-in types with those from plugins and
+// [types]. Registered types are checked before built-in ones, by name; a
+// [types] entry takes precedence over a plugin of the same name, and its
+// tasks are layered over any built-in tasks for that type.
+func loadPackageTypes(root string, cfg *RootConfig) (*packageTypes, error) {
+	builtin := cfg.Workspace.BuiltinDefaults == nil || *cfg.Workspace.BuiltinDefaults
+	types := builtinTypes(builtin)
+	types.docker = cfg.Docker
+	types.skip = cfg.Discovery.Skip
+	types.followSymlinks = cfg.Discovery.FollowSymlinks
+
+	registered := make(map[string]TypeConfig)
+	for _, name := range cfg.Workspace.Plugins {
+		tc, err := describePlugin(root, name)
+		if err != nil {
+			return nil, err
+		}
+		registered[name] = tc
+	}
+	for name, tc := range cfg.Types {
+		if plugin, ok := registered[name]; ok {
+			if len(tc.Markers) == 0 {
+				tc.Markers = plugin.Markers
+			}
+			merged := make(map[string]interface{})
+			for k, v := range plugin.Tasks {
+				merged[k] = v
+			}
+			for k, v := range tc.Tasks {
+				merged[k] = v
+			}
+			tc.Tasks = merged
+		}
+		registered[name] = tc
+	}
+
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var markers []typeMarker
+	for _, name := range names {
+		tc := registered[name]
+		for _, file := range tc.Markers {
+			markers = append(markers, typeMarker{file, name})
+		}
+		if len(tc.Tasks) == 0 {
+			continue
+		}
+		tasks := make(map[string]Task)
+		for k, v := range types.tasks[name] {
+			tasks[k] = v
+		}
+		for k, v := range parseTasks(tc.Tasks) {
+			tasks[k] = v
+		}
+		types.tasks[name] = tasks
+	}
+	// [discovery] markers come first, by file name
+	files := make([]string, 0, len(cfg.Discovery.Markers))
+	for file := range cfg.Discovery.Markers {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var discovery []typeMarker
+	for _, file := range files {
+		discovery = append(discovery, typeMarker{file, cfg.Discovery.Markers[file]})
+	}
+	types.markers = append(append(discovery, markers...), types.markers...)
+
+	known := make(map[string]bool)
+	for _, m := range types.markers {
+		known[m.typeName] = true
+	}
+	for name := range types.tasks {
+		known[name] = true
+	}
+	for name := range cfg.Defaults {
+		known[name] = true
+	}
+	types.extends = make(map[string]string)
+	for name, td := range cfg.Defaults {
+		if td.Extends == "" {
+			continue
+		}
+		if !known[td.Extends] {
+			return nil, fmt.Errorf("[defaults.%s] extends unknown type %q", name, td.Extends)
+		}
+		types.extends[name] = td.Extends
+	}
+	for name := range types.extends {
+		seen := map[string]bool{name: true}
+		for t := types.extends[name]; t != ""; t = types.extends[t] {
+			if seen[t] {
+				return nil, fmt.Errorf("[defaults.%s] extends itself through %s", name, t)
+			}
+			seen[t] = true
+		}
+	}
+	return types, nil
+}
+
+// chain returns typeName preceded by the types it extends, base first, or
+// nil for no type.
+func (t *packageTypes) chain(typeName string) []string {
+	var chain []string
+	for ; typeName != ""; typeName = t.extends[typeName] {
+		chain = append([]string{typeName}, chain...)
+	}
+	return chain
+}
+
+// describePlugin runs `ux-plugin-<name> describe` from the workspace root and
+// parses the JSON type description it prints:
+//
+//	{"markers": ["buf.yaml"], "tasks": {"lint": "buf lint"}}
+func describePlugin(root, name string) (TypeConfig, error) {
+	var tc TypeConfig
+	bin := PluginPrefix + name
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return tc, fmt.Errorf("plugin %s: %s not found on PATH", name, bin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "describe")
+	cmd.Dir = root
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return tc, fmt.Errorf("plugin %s: %w\n%s", name, err, stderr.String())
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &tc); err != nil {
+		return tc, fmt.Errorf("plugin %s: parsing description: %w", name, err)
+	}
+	return tc, nil
+}
+
+// detectType returns the type of the first marker file found in dir, or "".
+func (t *packageTypes) detectType(dir string) string {
+	for _, m := range t.markers {
+		if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+			return m.typeName
+		}
+	}
+	return ""
+}
+
+// isPackageDir reports whether dir has a ux.toml or a marker file.
+func (t *packageTypes) isPackageDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "ux.toml")); err == nil {
+		return true
+	}
+	return t.detectType(dir) != ""
+}
+
+// markerFiles returns the marker files of a type and the types it extends.
+func (t *packageTypes) markerFiles(typeName string) []string {
+	var files []string
+	for _, m := range t.markers {
+		if slices.Contains(t.chain(typeName), m.typeName) {
+			files = append(files, m.file)
+		}
+	}
+	return files
+}
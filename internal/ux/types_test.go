@@ -0,0 +1,174 @@
+package ux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPackageTypes(t *testing.T) {
+	root := t.TempDir()
+	bin := t.TempDir()
+	writeFile(t, filepath.Join(bin, PluginPrefix+"proto"), `#!/bin/sh
+echo '{"markers": ["buf.yaml"], "tasks": {"lint": "buf lint", "build": ["buf generate"]}}'
+`)
+	if err := os.Chmod(filepath.Join(bin, PluginPrefix+"proto"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//infra/...", "//schemas/...", "//tools/..."]
+plugins = ["proto"]
+
+[types.terraform]
+markers = ["main.tf"]
+
+[types.terraform.tasks]
+lint = "terraform fmt -check"
+
+[types.proto.tasks]
+lint = "buf lint --error-format=json"
+
+[types.go.tasks]
+fmt = "gofmt -l ."
+`)
+	writeFile(t, filepath.Join(root, "infra", "net", "main.tf"), "")
+	writeFile(t, filepath.Join(root, "infra", "dns", ".terraform.lock.hcl"), "")
+	// A registered marker wins over a built-in one in the same directory
+	writeFile(t, filepath.Join(root, "schemas", "api", "buf.yaml"), "")
+	writeFile(t, filepath.Join(root, "schemas", "api", "go.mod"), "module api\n")
+	writeFile(t, filepath.Join(root, "tools", "gen", "go.mod"), "module gen\n")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byLabel := make(map[string]Package)
+	for _, pkg := range packages {
+		byLabel[pkg.Label] = pkg
+	}
+
+	tests := []struct {
+		label, typeName, task, cmd string
+	}{
+		{"//infra/net", "terraform", "lint", "terraform fmt -check"},
+		// [types.terraform] tasks layer over the built-in ones
+		{"//infra/net", "terraform", "fmt", "terraform fmt -check -diff"},
+		{"//infra/dns", "terraform", "fmt", "terraform fmt -check -diff"},
+		{"//schemas/api", "proto", "lint", "buf lint --error-format=json"},
+		{"//schemas/api", "proto", "build", "buf generate"},
+		{"//tools/gen", "go", "fmt", "gofmt -l ."},
+		{"//tools/gen", "go", "test", "go test ./..."},
+	}
+	for _, tt := range tests {
+		pkg, ok := byLabel[tt.label]
+		if !ok {
+			t.Errorf("%s not discovered", tt.label)
+			continue
+		}
+		if pkg.Type != tt.typeName {
+			t.Errorf("%s type = %q, want %q", tt.label, pkg.Type, tt.typeName)
+		}
+		if got := pkg.Tasks[tt.task].Cmds; len(got) != 1 || got[0] != tt.cmd {
+			t.Errorf("%s %s = %v, want %q", tt.label, tt.task, got, tt.cmd)
+		}
+	}
+
+	if plan := byLabel["//infra/dns"].Tasks["plan"]; plan.Mutex != "terraform" || len(plan.Cmds) != 2 {
+		t.Errorf("terraform plan = %+v, want two steps with mutex terraform", plan)
+	}
+
+	if !byLabel["//infra/net"].present() {
+		t.Error("//infra/net present() = false, want true")
+	}
+}
+
+func TestLoadPackageTypesMissingPlugin(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	cfg := &RootConfig{Workspace: WorkspaceConfig{Plugins: []string{"nope"}}}
+	if _, err := loadPackageTypes(t.TempDir(), cfg); err == nil {
+		t.Error("expected an error for a missing plugin")
+	}
+}
+
+func TestDefaultsExtends(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//services/..."]
+
+[defaults.python.tasks]
+fmt = "ruff format ."
+
+[defaults.python-service]
+extends = "python"
+
+[defaults.python-service.tasks]
+test = "pytest -m 'not slow'"
+serve = "uvicorn app:main"
+
+[defaults.python-worker]
+extends = "python-service"
+
+[defaults.python-worker.tasks]
+serve = "celery worker"
+`)
+	writeFile(t, filepath.Join(root, "services", "api", "pyproject.toml"), "")
+	writeFile(t, filepath.Join(root, "services", "api", "ux.toml"), "[package]\ntype = \"python-service\"\n")
+	writeFile(t, filepath.Join(root, "services", "jobs", "pyproject.toml"), "")
+	writeFile(t, filepath.Join(root, "services", "jobs", "ux.toml"), "[package]\ntype = \"python-worker\"\n")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byLabel := make(map[string]Package)
+	for _, pkg := range packages {
+		byLabel[pkg.Label] = pkg
+	}
+
+	tests := []struct {
+		label, task, cmd, source string
+	}{
+		{"//services/api", "lint", "ruff check .", "builtin"},
+		{"//services/api", "fmt", "ruff format .", "default"},
+		{"//services/api", "test", "pytest -m 'not slow'", "default"},
+		{"//services/jobs", "lint", "ruff check .", "builtin"},
+		{"//services/jobs", "test", "pytest -m 'not slow'", "default"},
+		{"//services/jobs", "serve", "celery worker", "default"},
+	}
+	for _, tt := range tests {
+		pkg := byLabel[tt.label]
+		if got := pkg.Tasks[tt.task].Cmds; len(got) != 1 || got[0] != tt.cmd {
+			t.Errorf("%s %s = %v, want %q", tt.label, tt.task, got, tt.cmd)
+		}
+		if got := pkg.TaskSources[tt.task]; got != tt.source {
+			t.Errorf("%s %s source = %q, want %q", tt.label, tt.task, got, tt.source)
+		}
+	}
+	if got := byLabel["//services/jobs"].markers; len(got) != 1 || got[0] != "pyproject.toml" {
+		t.Errorf("python-worker markers = %v, want the base type's", got)
+	}
+}
+
+func TestDefaultsExtendsErrors(t *testing.T) {
+	for name, defaults := range map[string]map[string]TypeDefaults{
+		"unknown": {"svc": {Extends: "pyhton"}},
+		"cycle":   {"a": {Extends: "b"}, "b": {Extends: "a"}},
+	} {
+		cfg := &RootConfig{Defaults: defaults}
+		if _, err := loadPackageTypes(t.TempDir(), cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
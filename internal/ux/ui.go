@@ -0,0 +1,28 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	enterAltScreen = "\033[?1049h"
+	exitAltScreen  = "\033[?1049l"
+	hideCursor     = "\033[?25l"
+	showCursor     = "\033[?25h"
+)
+
+// StartUI switches the terminal to its alternate screen for --ui, leaving the
+// normal scrollback untouched. The returned function switches back, after
+// which the caller prints the plain summary so it lands in scrollback. An
+// interrupted run (RunOptions.Context) ends the same way. Without a terminal
+// it does nothing.
+func StartUI() (stop func()) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return func() {}
+	}
+	fmt.Print(enterAltScreen + hideCursor)
+	return func() { fmt.Print(showCursor + exitAltScreen) }
+}
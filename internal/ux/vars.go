@@ -0,0 +1,71 @@
+package ux
+
+import (
+	"strings"
+)
+
+// workspaceVarPrefix starts the placeholders that need git: resolving them
+// runs git, so it's only done when a task mentions one.
+const workspaceVarPrefix = "{git_"
+
+// expandWorkspaceVars fills in the workspace-wide placeholders in every
+// task's commands and cwd, and in package env values: {workspace_root},
+// {git_sha}, {git_short_sha}, and {git_branch}. Commands get them
+// shell-quoted where needed (see placeholderReplacers). Outside a git repository,
+// or with a detached HEAD for {git_branch}, the git ones are empty.
+func expandWorkspaceVars(root string, packages []Package) {
+	vars := []string{"{workspace_root}", root}
+	if mentionsGitVars(packages) {
+		sha := gitOutput(root, "rev-parse", "HEAD")
+		short := gitOutput(root, "rev-parse", "--short", "HEAD")
+		if sha == "" {
+			Warnf("task commands use {git_*} placeholders, but %s isn't in a git repository with commits", root)
+		}
+		vars = append(vars,
+			"{git_sha}", sha,
+			"{git_short_sha}", short,
+			"{git_branch}", gitOutput(root, "symbolic-ref", "--short", "-q", "HEAD"),
+		)
+	}
+	cmd, raw := placeholderReplacers(vars...)
+	for i := range packages {
+		for name, t := range packages[i].Tasks {
+			packages[i].Tasks[name] = t.expand(cmd, raw)
+		}
+		for k, v := range packages[i].Env {
+			packages[i].Env[k] = raw.Replace(v)
+		}
+	}
+}
+
+// mentionsGitVars reports whether any task command, cwd, or env value uses
+// a {git_*} placeholder.
+func mentionsGitVars(packages []Package) bool {
+	for _, pkg := range packages {
+		for _, t := range pkg.Tasks {
+			if strings.Contains(t.Cwd, workspaceVarPrefix) {
+				return true
+			}
+			for _, c := range t.Cmds {
+				if strings.Contains(c, workspaceVarPrefix) {
+					return true
+				}
+			}
+		}
+		for _, v := range pkg.Env {
+			if strings.Contains(v, workspaceVarPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gitOutput runs git in dir and returns its trimmed output, or "" if it fails.
+func gitOutput(dir string, args ...string) string {
+	out, err := gitCommand(dir, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,319 @@
+package ux
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// TaskLayer is one place a package's task is defined. A task's layers are
+// in resolution order, so the last one is what runs.
+type TaskLayer struct {
+	Source string // "builtin", "default", "extends", "override", or "root-tasks"
+	Where  string // e.g. "[defaults.python.tasks] in ux.toml"
+}
+
+// TaskExplanation is where a task of a package is defined.
+type TaskExplanation struct {
+	Name   string
+	Layers []TaskLayer
+}
+
+// Explanation is how `ux why` accounts for a package: which members entries
+// select it, how its type was decided, and where each task comes from.
+type Explanation struct {
+	Label        string
+	Dir          string   // workspace-relative
+	Members      []string // members entries matching the label
+	Excluded     []string // "!" members entries excluding it
+	ConfigFile   string   // workspace-relative ux.toml, if the package has one
+	ExplicitType string   // [package] type
+	DetectedType string   // from marker files
+	Marker       string   // the marker file DetectedType comes from
+	Package      *Package // nil when the label isn't a workspace package
+	Missing      string   // why it isn't, when Package is nil
+	Tasks        []TaskExplanation
+
+	// Task and TaskReasons explain why a requested task isn't defined.
+	Task        string
+	TaskReasons []string
+}
+
+// Why explains how label was resolved, and with task set, why that task
+// is or isn't defined for it.
+func Why(root string, cfg *RootConfig, packages []Package, label, task string) (*Explanation, error) {
+	label = "//" + strings.TrimSuffix(strings.TrimPrefix(label, "//"), "/")
+	if label == "//" {
+		label = RootLabel
+	}
+	rel := strings.TrimPrefix(label, "//")
+	dir := filepath.Join(root, filepath.FromSlash(rel))
+	e := &Explanation{Label: label, Dir: rel, Task: task}
+
+	types, err := loadPackageTypes(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range cfg.Workspace.Members {
+		if n, ok := strings.CutPrefix(m, "!"); ok {
+			if memberExcluded([]string{n}, label) {
+				e.Excluded = append(e.Excluded, m)
+			}
+		} else if membersCover([]string{m}, label) {
+			e.Members = append(e.Members, m)
+		}
+	}
+	var raw packageFile
+	if _, err := os.Stat(filepath.Join(dir, "ux.toml")); err == nil && label != RootLabel {
+		e.ConfigFile = path.Join(rel, "ux.toml")
+		if err := decodeConfigFile(root, filepath.Join(dir, "ux.toml"), &raw); err != nil {
+			return nil, configErrors(err, e.ConfigFile)
+		}
+		e.ExplicitType = raw.Package.Type
+	}
+	for _, m := range types.markers {
+		if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+			e.DetectedType, e.Marker = m.typeName, m.file
+			break
+		}
+	}
+
+	for i := range packages {
+		if packages[i].Label == label {
+			e.Package = &packages[i]
+		}
+	}
+	if e.Package == nil {
+		e.Missing = e.missingReason(dir, types)
+		return e, nil
+	}
+
+	layers, err := e.taskLayers(root, cfg, types, raw)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(layers))
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e.Tasks = append(e.Tasks, TaskExplanation{Name: name, Layers: layers[name]})
+	}
+	if _, ok := e.Package.Tasks[task]; task != "" && !ok {
+		e.TaskReasons = e.missingTaskReasons(cfg, types, packages)
+	}
+	return e, nil
+}
+
+// missingReason says why a label isn't a workspace package.
+func (e *Explanation) missingReason(dir string, types *packageTypes) string {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Sprintf("directory %s doesn't exist", e.Dir)
+	}
+	if e.Label == RootLabel {
+		return "the workspace root is a package only when it's a member or [root-tasks] are defined"
+	}
+	if len(e.Members) == 0 {
+		return "no members entry matches it"
+	}
+	if len(e.Excluded) > 0 {
+		return "it's excluded by " + strings.Join(e.Excluded, ", ")
+	}
+	if !types.isPackageDir(dir) {
+		var markers []string
+		for _, m := range types.markers {
+			markers = append(markers, m.file)
+		}
+		return fmt.Sprintf("it has no ux.toml or marker file (%s)", strings.Join(markers, ", "))
+	}
+	return "it has no type and defines no tasks"
+}
+
+// taskLayers finds every layer defining each of the package's tasks, in the
+// order resolvePackage applies them.
+func (e *Explanation) taskLayers(root string, cfg *RootConfig, types *packageTypes, raw packageFile) (map[string][]TaskLayer, error) {
+	layers := make(map[string][]TaskLayer)
+	add := func(tasks map[string]Task, source string, where func(task string) string) {
+		for name, t := range tasks {
+			if _, ok := e.Package.Tasks[name]; !ok || !t.applies(e.Package.Dir) {
+				continue // e.g. a node built-in for a script yarn can't run
+			}
+			layers[name] = append(layers[name], TaskLayer{source, where(name)})
+		}
+	}
+	defaults := resolveDefaults(cfg.Defaults)
+	for _, t := range types.chain(e.Package.Type) {
+		add(types.tasks[t], "builtin", func(string) string {
+			switch {
+			case cfg.typeFrom[t] != "":
+				return fmt.Sprintf("[types.%s] in %s", t, cfg.typeFrom[t])
+			case slices.Contains(cfg.Workspace.Plugins, t):
+				return "plugin " + PluginPrefix + t
+			}
+			return fmt.Sprintf("built-in %s tasks", t)
+		})
+		add(defaults[t], "default", func(task string) string {
+			file := cfg.defaultFrom[t+"."+task]
+			if file == "" {
+				file = "ux.toml"
+			}
+			return fmt.Sprintf("[defaults.%s.tasks] in %s", t, file)
+		})
+	}
+	if e.ConfigFile != "" && raw.Workspace == nil {
+		if raw.Package.Extends != "" {
+			inherited, err := resolveExtends(root, raw.Package.Extends, []string{e.Label})
+			if err != nil {
+				return nil, err
+			}
+			add(inherited.tasks, "extends", func(task string) string {
+				return fmt.Sprintf("[tasks] in %s (extends)", path.Join(strings.TrimPrefix(inherited.sources[task], "//"), "ux.toml"))
+			})
+		}
+		add(parseTasks(raw.Tasks), "override", func(string) string {
+			return "[tasks] in " + e.ConfigFile
+		})
+	}
+	if e.Label == RootLabel {
+		add(parseTasks(cfg.RootTasks), "root-tasks", func(string) string {
+			return "[root-tasks] in ux.toml"
+		})
+	}
+	return layers, nil
+}
+
+// missingTaskReasons says why the package doesn't define e.Task.
+func (e *Explanation) missingTaskReasons(cfg *RootConfig, types *packageTypes, packages []Package) []string {
+	task := e.Task
+	switch by := e.Package.skipped[task]; by {
+	case "":
+	case "[package] skip_tasks":
+		return []string{fmt.Sprintf("it's listed in [package] skip_tasks in %s", e.ConfigFile)}
+	default:
+		return []string{fmt.Sprintf("a %s marker in %s names it", SkipMarker, path.Join(e.Dir, by))}
+	}
+	var reasons []string
+	defaults := resolveDefaults(cfg.Defaults)
+	for _, t := range types.chain(e.Package.Type) {
+		for _, def := range []Task{types.tasks[t][task], defaults[t][task]} {
+			if len(def.WhenExists) > 0 && !def.applies(e.Package.Dir) {
+				reasons = append(reasons, fmt.Sprintf("the %s default applies only when %s exists", t, strings.Join(def.WhenExists, ", ")))
+			}
+		}
+	}
+	if t := e.Package.Type; t == "" {
+		reasons = append(reasons, "it has no type, so no type defaults apply")
+	} else if len(reasons) == 0 {
+		reasons = append(reasons, fmt.Sprintf("neither the built-in %s tasks nor [defaults.%s.tasks] define it", t, t))
+	}
+	if e.ConfigFile != "" {
+		reasons = append(reasons, fmt.Sprintf("[tasks] in %s doesn't define it", e.ConfigFile))
+	} else {
+		reasons = append(reasons, fmt.Sprintf("there's no %s to add it in", path.Join(e.Dir, "ux.toml")))
+	}
+
+	var typesWith []string
+	for t := range types.tasks {
+		if _, ok := types.tasks[t][task]; ok {
+			typesWith = append(typesWith, t)
+		}
+	}
+	for t := range defaults {
+		if _, ok := defaults[t][task]; ok && !slices.Contains(typesWith, t) {
+			typesWith = append(typesWith, t)
+		}
+	}
+	sort.Strings(typesWith)
+	if len(typesWith) > 0 {
+		reasons = append(reasons, fmt.Sprintf("it's a default task of these types: %s", strings.Join(typesWith, ", ")))
+	}
+	if _, ok := parseTasks(cfg.RootTasks)[task]; ok && e.Label != RootLabel {
+		reasons = append(reasons, fmt.Sprintf("it's a root task, run once from the workspace root (%s)", RootLabel))
+	}
+	if _, ok := cfg.Tasks[task]; ok {
+		reasons = append(reasons, fmt.Sprintf("[tasks] %s in ux.toml only sets how it runs; commands come from types and packages", task))
+	}
+
+	var defining []string
+	for _, pkg := range packages {
+		if _, ok := pkg.Tasks[task]; ok {
+			defining = append(defining, pkg.Label)
+		}
+	}
+	if len(defining) == 0 {
+		reasons = append(reasons, "no package in the workspace defines it")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("defined by %s", labelList(defining)))
+	}
+	return reasons
+}
+
+// PrintExplanation prints `ux why`.
+func PrintExplanation(e *Explanation) {
+	fmt.Printf("\n%s %s\n\n", styleHeader.Render("ux why"), styleBold.Render(e.Label))
+	row := func(name, value string) {
+		fmt.Printf("  %-9s %s\n", styleLabel.Render(name), value)
+	}
+	dir := e.Dir
+	if dir == "" || dir == "." {
+		dir = "(workspace root)"
+	}
+	row("dir", dir)
+	members := styleDim.Render("(none match)")
+	if len(e.Members) > 0 {
+		members = strings.Join(e.Members, ", ")
+	}
+	row("members", members)
+	if len(e.Excluded) > 0 {
+		row("excluded", styleFail.Render(strings.Join(e.Excluded, ", ")))
+	}
+	row("type", e.typeLine())
+
+	if e.Package == nil {
+		fmt.Printf("\n  %s %s\n\n", iconFail, fmt.Sprintf("not a workspace package: %s", e.Missing))
+		return
+	}
+	if e.Package.Name != filepath.Base(e.Package.Dir) {
+		row("name", e.Package.Name)
+	}
+
+	fmt.Printf("\n  %s\n", styleBold.Render("tasks"))
+	for _, t := range e.Tasks {
+		last := t.Layers[len(t.Layers)-1]
+		fmt.Printf("    %-14s %-11s %s\n", t.Name, last.Source, last.Where)
+		for i := len(t.Layers) - 2; i >= 0; i-- {
+			fmt.Printf("    %-14s %s\n", "", styleDim.Render(fmt.Sprintf("replaces %s: %s", t.Layers[i].Source, t.Layers[i].Where)))
+		}
+	}
+	if len(e.TaskReasons) > 0 {
+		fmt.Printf("\n  %s\n", styleBold.Render(fmt.Sprintf("%s isn't defined", e.Task)))
+		for _, r := range e.TaskReasons {
+			fmt.Printf("    %s %s\n", styleDim.Render("-"), r)
+		}
+	} else if e.Task != "" {
+		fmt.Printf("\n  %s %s\n", iconSuccess, fmt.Sprintf("%s is defined (see above)", e.Task))
+	}
+	fmt.Println()
+}
+
+// typeLine describes how the package's type was decided.
+func (e *Explanation) typeLine() string {
+	detected := styleDim.Render("none detected")
+	if e.DetectedType != "" {
+		detected = fmt.Sprintf("%s from %s", e.DetectedType, e.Marker)
+	}
+	switch {
+	case e.ExplicitType != "" && e.DetectedType != "" && e.DetectedType != e.ExplicitType:
+		return fmt.Sprintf("%s (set in %s; markers say %s)", e.ExplicitType, e.ConfigFile, detected)
+	case e.ExplicitType != "":
+		return fmt.Sprintf("%s (set in %s)", e.ExplicitType, e.ConfigFile)
+	case e.DetectedType != "":
+		return fmt.Sprintf("%s (detected from %s)", e.DetectedType, e.Marker)
+	}
+	return styleDim.Render("none (no [package] type and no marker file)")
+}
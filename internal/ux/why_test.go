@@ -0,0 +1,76 @@
+package ux
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWhy(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `[workspace]
+members = ["//libs/...", "!//libs/old"]
+include = ["ci.toml"]
+
+[defaults.go.tasks]
+test = "go test -race ./..."
+`)
+	writeFile(t, filepath.Join(root, "ci.toml"), "[defaults.go.tasks]\nci = \"make ci\"\n")
+	writeFile(t, filepath.Join(root, "libs", "base", "ux.toml"), "[tasks]\nrelease = \"./release.sh\"\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "go.mod"), "module a\n")
+	writeFile(t, filepath.Join(root, "libs", "a", "ux.toml"), "[package]\nextends = \"//libs/base\"\n\n[tasks]\nlint = \"golangci-lint run\"\n")
+	writeFile(t, filepath.Join(root, "libs", "old", "go.mod"), "module old\n")
+	writeFile(t, filepath.Join(root, "libs", "docs", "README.md"), "")
+
+	cfg, err := LoadRootConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := DiscoverPackages(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := Why(root, cfg, packages, "//libs/a", "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Package == nil || e.DetectedType != "go" || e.Marker != "go.mod" || !reflect.DeepEqual(e.Members, []string{"//libs/..."}) {
+		t.Fatalf("Why(//libs/a) = %+v", e)
+	}
+	sources := make(map[string][]string)
+	for _, task := range e.Tasks {
+		for _, l := range task.Layers {
+			sources[task.Name] = append(sources[task.Name], l.Source+": "+l.Where)
+		}
+	}
+	want := map[string][]string{
+		"ci":      {"default: [defaults.go.tasks] in ci.toml"},
+		"lint":    {"builtin: built-in go tasks", "override: [tasks] in libs/a/ux.toml"},
+		"release": {"extends: [tasks] in libs/base/ux.toml (extends)"},
+		"test":    {"builtin: built-in go tasks", "default: [defaults.go.tasks] in ux.toml"},
+	}
+	for name, w := range want {
+		if !reflect.DeepEqual(sources[name], w) {
+			t.Errorf("task %s layers = %v, want %v", name, sources[name], w)
+		}
+	}
+	if len(e.TaskReasons) == 0 || !strings.Contains(strings.Join(e.TaskReasons, "\n"), "no package in the workspace defines it") {
+		t.Errorf("TaskReasons = %v", e.TaskReasons)
+	}
+
+	for label, missing := range map[string]string{
+		"//libs/old":  "excluded by !//libs/old",
+		"//libs/docs": "no ux.toml or marker file",
+		"//tools/x":   "doesn't exist",
+	} {
+		e, err := Why(root, cfg, packages, label, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Package != nil || !strings.Contains(e.Missing, missing) {
+			t.Errorf("Why(%s).Missing = %q, want %q", label, e.Missing, missing)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package runner runs ux tasks across workspace packages without printing
+// progress, returning typed results instead.
+//
+// The API is experimental: Result is an alias of ux's internal result type,
+// so its fields may change between releases, as may Options.
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	ux "github.com/lairoai/ux/internal/ux"
+	"github.com/lairoai/ux/pkg/ux/workspace"
+)
+
+// Result is one package's outcome for one task.
+type Result = ux.Result
+
+// Options configure a Run.
+type Options struct {
+	// ExtraArgs go to the requested task (not its dependencies) like
+	// arguments after "--" on the command line: they replace {args} in its
+	// commands, or are appended to its only command when none has {args}.
+	// Run refuses them for a task with several commands and no {args}.
+	ExtraArgs []string
+	// NoCache runs every package even when a cached result exists.
+	NoCache bool
+	// StreamDir, if set, serves each running package's live output on a
+	// unix socket in this directory.
+	StreamDir string
+	// Jobs limits parallel tasks to packages whose weights add up to at
+	// most Jobs at a time (see workspace.Package.Weight); 0 means no limit.
+	Jobs int
+	// Yes runs tasks marked confirm = true, which Run otherwise refuses,
+	// having no one to ask (like --yes on the command line).
+	Yes bool
+}
+
+// Run runs task on the given packages of ws, or on every package that
+// defines it if packages is nil. Tasks listed in depends_on run first, in
+// stages; a failing stage stops the run. Results from every stage that ran
+// are returned, each tagged with its task. An error means the run couldn't be
+// planned, not that a package failed; check Result.Failed for that. A
+// package with more output than is kept in memory has it in a temporary
+// Result.OutputFile instead, which the caller owns: call Cleanup once done
+// with the results.
+//
+// Run doesn't record run history or the last-run summary, and doesn't run
+// [hooks]: a task that relies on before_run having set something up needs
+// the caller to do that first.
+func Run(ws *workspace.Workspace, task string, packages []workspace.Package, opts Options) ([]Result, error) {
+	if packages == nil {
+		packages = ws.Packages
+	}
+	var relevant []workspace.Package
+	for _, pkg := range packages {
+		if _, ok := pkg.Tasks[task]; ok {
+			relevant = append(relevant, pkg)
+		}
+	}
+
+	if err := ux.CheckArgs(task, relevant, opts.ExtraArgs, nil); err != nil {
+		return nil, err
+	}
+	stages, err := ux.PlanTask(task, relevant, ws.Packages, ws.Config)
+	if err != nil {
+		return nil, err
+	}
+	if prompt := ux.ConfirmPrompt(stages, ws.Config.Tasks); prompt != "" && !opts.Yes {
+		return nil, fmt.Errorf("not asked to confirm %q; set Options.Yes to run anyway", prompt)
+	}
+
+	cacheDir := ux.CacheDir(ws.Root)
+	if opts.NoCache {
+		cacheDir = ""
+	}
+
+	var all []Result
+	for _, stage := range stages {
+		var stageArgs []string
+		if stage.Task == task {
+			stageArgs = opts.ExtraArgs
+		}
+		results := ux.RunTask(stage.Task, stage.Packages, ws.Config.Tasks[stage.Task], ux.RunOptions{
+			ExtraArgs: stageArgs,
+			StreamDir: opts.StreamDir,
+			CacheDir:  cacheDir,
+			Workspace: ws.Packages,
+			Jobs:      opts.Jobs,
+			Quiet:     true,
+		})
+		all = append(all, results...)
+		for _, r := range results {
+			if r.Failed() {
+				return all, nil
+			}
+		}
+	}
+	return all, nil
+}
+
+// Failed reports whether any result failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Cleanup removes the temporary files holding the output of results
+// (Result.OutputFile), after which their output can't be read. It returns
+// the first error, having tried every file.
+func Cleanup(results []Result) error {
+	var first error
+	for _, r := range results {
+		if r.OutputFile == "" {
+			continue
+		}
+		if err := os.Remove(r.OutputFile); err != nil && !os.IsNotExist(err) && first == nil {
+			first = err
+		}
+	}
+	return first
+}
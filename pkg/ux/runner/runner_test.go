@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lairoai/ux/internal/uxtest"
+	"github.com/lairoai/ux/pkg/ux/workspace"
+)
+
+var writeFile = uxtest.WriteFile
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//libs/..."]
+builtin_defaults = false
+
+[tasks]
+build = { parallel = true }
+test = { parallel = true, depends_on = ["build"] }
+`)
+	writeFile(t, filepath.Join(root, "libs", "a", "ux.toml"), `
+[tasks]
+build = "echo building {package_name}"
+test = "echo testing"
+`)
+	writeFile(t, filepath.Join(root, "libs", "b", "ux.toml"), `
+[tasks]
+test = "exit 3"
+`)
+
+	ws, err := workspace.Load(filepath.Join(root, "libs", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.Packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(ws.Packages))
+	}
+
+	results, err := Run(ws, "test", nil, Options{NoCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Task+" "+r.Package.Label)
+	}
+	// //libs/b has nothing to build, so its test shares the first level with
+	// the build of //libs/a; its failure stops the run before //libs/a's test
+	if want := "build //libs/a,test //libs/b"; strings.Join(got, ",") != want {
+		t.Errorf("ran %v, want %s", got, want)
+	}
+	if !strings.Contains(results[0].Output, "building a") {
+		t.Errorf("build output = %q", results[0].Output)
+	}
+	if !Failed(results) || !results[1].Failed() {
+		t.Errorf("expected //libs/b to fail")
+	}
+
+	results, err = Run(ws, "test", ws.Select("//libs/a"), Options{NoCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || Failed(results) {
+		t.Errorf("selected run: got %d results, failed=%v", len(results), Failed(results))
+	}
+}
+
+func TestRunConfirm(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), `
+[workspace]
+members = ["//svc"]
+builtin_defaults = false
+
+[tasks]
+deploy = { confirm = true }
+`)
+	writeFile(t, filepath.Join(root, "svc", "ux.toml"), "[tasks]\ndeploy = \"touch deployed\"\n")
+	ws, err := workspace.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Run(ws, "deploy", nil, Options{}); err == nil || !strings.Contains(err.Error(), "Options.Yes") {
+		t.Errorf("without Yes: err = %v, want a refusal", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "svc", "deployed")); err == nil {
+		t.Fatal("deploy ran without Yes")
+	}
+	results, err := Run(ws, "deploy", nil, Options{Yes: true})
+	if err != nil || Failed(results) {
+		t.Fatalf("with Yes: %v, %v", results, err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//big\"]\nbuiltin_defaults = false\n")
+	writeFile(t, filepath.Join(root, "big", "ux.toml"), "[tasks]\nbuild = \"yes | head -c 2000000\"\n")
+	ws, err := workspace.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Run(ws, "build", nil, Options{NoCache: true})
+	if err != nil || len(results) != 1 || Failed(results) {
+		t.Fatalf("results = %v, err = %v", results, err)
+	}
+	spilled := results[0].OutputFile
+	if spilled == "" {
+		t.Fatal("2 MB of output wasn't spilled to a file")
+	}
+	if _, err := os.Stat(spilled); err != nil {
+		t.Fatal(err)
+	}
+	if err := Cleanup(results); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spilled); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after Cleanup (%v)", spilled, err)
+	}
+}
+
+func TestRunExtraArgs(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "ux.toml"), "[workspace]\nmembers = [\"//svc\"]\nbuiltin_defaults = false\n")
+	writeFile(t, filepath.Join(root, "svc", "ux.toml"), `
+[tasks]
+check = ["echo lint", "echo test"]
+test = ["echo unit", "echo e2e {args}"]
+`)
+	ws, err := workspace.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Run(ws, "check", nil, Options{ExtraArgs: []string{"-v"}}); err == nil || !strings.Contains(err.Error(), "{args}") {
+		t.Errorf("multi-step task without {args}: err = %v, want a refusal", err)
+	}
+	results, err := Run(ws, "test", nil, Options{ExtraArgs: []string{"-v"}, NoCache: true})
+	if err != nil || len(results) != 1 || !strings.Contains(results[0].Output, "e2e -v") {
+		t.Errorf("with {args}: results = %v, err = %v", results, err)
+	}
+}
@@ -0,0 +1,62 @@
+// Package workspace loads a ux workspace: its root config and the packages
+// it contains, with their tasks resolved exactly as the ux CLI sees them.
+//
+// The API is experimental: Config, Package, and the other types are aliases
+// of ux's internal types, so their fields may change between releases.
+package workspace
+
+import (
+	ux "github.com/lairoai/ux/internal/ux"
+)
+
+type (
+	// Config is the parsed root ux.toml, with includes merged.
+	Config = ux.RootConfig
+	// TaskConfig is a task's settings from the root [tasks] table.
+	TaskConfig = ux.TaskConfig
+	// Package is a discovered package with its resolved tasks and env.
+	Package = ux.Package
+	// Task is one task's commands and how they run.
+	Task = ux.Task
+)
+
+// Workspace is a loaded workspace.
+type Workspace struct {
+	// Root is the absolute path of the directory holding the root ux.toml.
+	Root     string
+	Config   *Config
+	Packages []Package
+}
+
+// Load finds the workspace containing dir, searching upward, and loads its
+// config and packages. Unlike the CLI, it doesn't run package hooks.
+func Load(dir string) (*Workspace, error) {
+	root, err := ux.FindWorkspaceRootFrom(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ux.LoadRootConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	packages, err := Discover(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Workspace{Root: root, Config: cfg, Packages: packages}, nil
+}
+
+// Discover returns the packages of the workspace at root, sorted by label.
+func Discover(root string, cfg *Config) ([]Package, error) {
+	return ux.DiscoverPackages(root, cfg)
+}
+
+// Select returns the packages matching any of the given //label filters
+// ("//services/api", "//packages/..."). With no filters it returns every
+// package.
+func (w *Workspace) Select(filters ...string) []Package {
+	if len(filters) == 0 {
+		return w.Packages
+	}
+	return ux.FilterByLabels(w.Packages, filters)
+}